@@ -0,0 +1,19 @@
+package config
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoCompanyNumber is returned when a request has not been through the CompanyMiddleware
+var ErrNoCompanyNumber = errors.New("company number is not in request context")
+
+// GetCompanyNumber returns the canonical company number that the CompanyMiddleware put in the request context
+func GetCompanyNumber(ctx context.Context) (string, error) {
+	companyNumber, ok := ctx.Value(CompanyNumber).(string)
+	if !ok || companyNumber == "" {
+		return "", ErrNoCompanyNumber
+	}
+
+	return companyNumber, nil
+}
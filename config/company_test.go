@@ -0,0 +1,29 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitGetCompanyNumber(t *testing.T) {
+	Convey("error when there is no company number in the context", t, func() {
+		companyNumber, err := GetCompanyNumber(context.Background())
+		So(companyNumber, ShouldBeEmpty)
+		So(err, ShouldEqual, ErrNoCompanyNumber)
+	})
+
+	Convey("error when the company number in the context is empty", t, func() {
+		ctx := context.WithValue(context.Background(), CompanyNumber, "")
+		_, err := GetCompanyNumber(ctx)
+		So(err, ShouldEqual, ErrNoCompanyNumber)
+	})
+
+	Convey("company number is read from the context", t, func() {
+		ctx := context.WithValue(context.Background(), CompanyNumber, "SC123456")
+		companyNumber, err := GetCompanyNumber(ctx)
+		So(err, ShouldBeNil)
+		So(companyNumber, ShouldEqual, "SC123456")
+	})
+}
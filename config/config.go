@@ -2,6 +2,7 @@
 package config
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -11,6 +12,16 @@ import (
 var cfg *Config
 var mtx sync.Mutex
 
+// The ways that request and response bodies can be checked against the API spec. They are not checked by default.
+const (
+	SchemaValidationLog    = "log"
+	SchemaValidationReject = "reject"
+)
+
+// LiveEnvironment is the environment that serves the public, in which bodies that do not match the API spec are only
+// ever logged
+const LiveEnvironment = "live"
+
 // Config defines the configuration options for this service.
 type Config struct {
 	BindAddr                   string       `env:"BIND_ADDR"                      flag:"bind-addr"                       flagDesc:"Bind address"`
@@ -27,6 +38,18 @@ type Config struct {
 	WeeklyMaintenanceDay       time.Weekday `env:"WEEKLY_MAINTENANCE_DAY"         flag:"weekly-maintenance-day"          flagDesc:"The day on which Weekly E5 maintenance takes place"`
 	PlannedMaintenanceStart    string       `env:"PLANNED_MAINTENANCE_START_TIME" flag:"planned-maintenance-start-time"  flagDesc:"The time of the day at which Planned E5 maintenance starts"`
 	PlannedMaintenanceEnd      string       `env:"PLANNED_MAINTENANCE_END_TIME"   flag:"planned-maintenance-end-time"    flagDesc:"The time of the day at which Planned E5 maintenance ends"`
+	BulkLookupMaxCompanies     int          `env:"BULK_LOOKUP_MAX_COMPANIES"      flag:"bulk-lookup-max-companies"       flagDesc:"The most companies that can be looked up in a single bulk penalty summary request"`
+	BulkLookupConcurrency      int          `env:"BULK_LOOKUP_CONCURRENCY"        flag:"bulk-lookup-concurrency"         flagDesc:"The most E5 requests made at once by a bulk penalty summary request"`
+	CHSAPIKey                  string       `env:"CHS_API_KEY"                    flag:"chs-api-key"                     flagDesc:"API key used to call the payments API"`
+	Mode                       string       `env:"MODE"                           flag:"mode"                            flagDesc:"Run as the API (api, the default) or as the payment-processed consumer (consumer)"`
+	ConsumerGroupName          string       `env:"CONSUMER_GROUP_NAME"            flag:"consumer-group-name"             flagDesc:"Kafka consumer group used to consume payment-processed messages"`
+	PaymentProcessedTopic      string       `env:"PAYMENT_PROCESSED_TOPIC"        flag:"payment-processed-topic"         flagDesc:"Kafka topic that payment-processed messages are consumed from"`
+	PaymentProcessedDLQTopic   string       `env:"PAYMENT_PROCESSED_DLQ_TOPIC"    flag:"payment-processed-dlq-topic"     flagDesc:"Kafka topic that payment-processed messages which cannot be processed are sent to"`
+	ConsumerMaxAttempts        int          `env:"CONSUMER_MAX_ATTEMPTS"          flag:"consumer-max-attempts"           flagDesc:"The most times a payment-processed message is processed before it is dead-lettered"`
+	PaymentJobWorkers          int          `env:"PAYMENT_JOB_WORKERS"            flag:"payment-job-workers"             flagDesc:"The most payments completed at once for asynchronous payment requests"`
+	SchemaValidation           string       `env:"SCHEMA_VALIDATION"              flag:"schema-validation"               flagDesc:"Check request and response bodies against the API spec and log (log) or reject (reject) those that do not match"`
+	PaymentJobCallbackHosts    []string     `env:"PAYMENT_JOB_CALLBACK_HOSTS"     flag:"payment-job-callback-hosts"      flagDesc:"Hosts that the results of asynchronous payment requests can be posted back to"`
+	Environment                string       `env:"ENVIRONMENT"                    flag:"environment"                     flagDesc:"The environment the service is deployed to, e.g. live"`
 }
 
 // Get returns a pointer to a Config instance
@@ -42,9 +65,22 @@ func Get() (*Config, error) {
 	cfg = &Config{}
 
 	err := gofigure.Gofigure(cfg)
+	if err == nil {
+		err = cfg.validate()
+	}
 	if err != nil {
+		cfg = nil
 		return nil, err
 	}
 
 	return cfg, nil
 }
+
+// validate checks that the options configured can be used together in the environment the service is deployed to
+func (c *Config) validate() error {
+	// a mismatch between the spec and the handlers must not turn into failed requests for the public
+	if c.Environment == LiveEnvironment && c.SchemaValidation == SchemaValidationReject {
+		return fmt.Errorf("schema validation cannot be set to [%s] in the [%s] environment", SchemaValidationReject, LiveEnvironment)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitValidate(t *testing.T) {
+	Convey("Schema validation can log mismatches in live", t, func() {
+		c := &Config{Environment: LiveEnvironment, SchemaValidation: SchemaValidationLog}
+		So(c.validate(), ShouldBeNil)
+	})
+
+	Convey("Schema validation cannot reject mismatches in live", t, func() {
+		c := &Config{Environment: LiveEnvironment, SchemaValidation: SchemaValidationReject}
+		So(c.validate(), ShouldNotBeNil)
+	})
+
+	Convey("Schema validation can reject mismatches outside of live", t, func() {
+		c := &Config{Environment: "staging", SchemaValidation: SchemaValidationReject}
+		So(c.validate(), ShouldBeNil)
+	})
+}
@@ -10,4 +10,6 @@ const (
 	CompanyNumber = ContextKey("CompanyNumber")
 	// PayableResource is the key that stores the payable resource
 	PayableResource = ContextKey("PayableResource")
+	// Scheme is the key that stores the penalty scheme the request was routed through
+	Scheme = ContextKey("Scheme")
 )
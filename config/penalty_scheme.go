@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/companieshouse/lfp-pay-api/i18n"
+	"gopkg.in/go-playground/validator.v9"
+	"gopkg.in/yaml.v2"
+)
+
+// PenaltySchemesFile is the yaml file describing the penalty schemes hosted by this service
+const PenaltySchemesFile = "assets/penalty_schemes.yml"
+
+// ErrNoPenaltyScheme is returned when a request has not been routed through a penalty scheme
+var ErrNoPenaltyScheme = errors.New("penalty scheme is not in request context")
+
+// PenaltyScheme describes a penalty regime, e.g. late filing penalties, that is held in E5 under its own company code
+// and served under its own routes. ResumeJourneyMultipleLink is used in place of ResumeJourneyLink when paying for more
+// than one penalty at once. WelshDescription is used in place of Description in Welsh emails, where the scheme has one.
+type PenaltyScheme struct {
+	Name                      string `yaml:"name"                         validate:"required"`
+	CompanyCode               string `yaml:"company_code"                 validate:"required"`
+	AllowedTypesFile          string `yaml:"allowed_types_file"           validate:"required"`
+	PayabilityRulesFile       string `yaml:"payability_rules_file"        validate:"required"`
+	RoutePrefix               string `yaml:"route_prefix"                 validate:"required"`
+	Description               string `yaml:"description"                  validate:"required"`
+	WelshDescription          string `yaml:"welsh_description"`
+	DescriptionIdentifier     string `yaml:"description_identifier"       validate:"required"`
+	ProductType               string `yaml:"product_type"                 validate:"required"`
+	ResourceKind              string `yaml:"resource_kind"                validate:"required"`
+	ResumeJourneyLink         string `yaml:"resume_journey_link"          validate:"required"`
+	ResumeJourneyMultipleLink string `yaml:"resume_journey_multiple_link" validate:"required"`
+	EmailAppID                string `yaml:"email_app_id"                 validate:"required"`
+	EmailMessageType          string `yaml:"email_message_type"           validate:"required"`
+	RefundEmailAppID          string `yaml:"refund_email_app_id"          validate:"required"`
+	RefundEmailMessageType    string `yaml:"refund_email_message_type"    validate:"required"`
+}
+
+// penaltySchemes is the structure of the penalty schemes yaml file
+type penaltySchemes struct {
+	Schemes []*PenaltyScheme `yaml:"penalty_schemes"`
+}
+
+// RouteTemplate is the mux path template that the scheme's routes are registered under
+func (s *PenaltyScheme) RouteTemplate() string {
+	return "/company/{company_number}/penalties/" + s.RoutePrefix
+}
+
+// BulkRouteTemplate is the mux path template that the scheme's routes covering more than one company are registered
+// under
+func (s *PenaltyScheme) BulkRouteTemplate() string {
+	return "/penalties/" + s.RoutePrefix
+}
+
+// CompanyPath is the path to the scheme's resources for a specific company
+func (s *PenaltyScheme) CompanyPath(companyNumber string) string {
+	return fmt.Sprintf("/company/%s/penalties/%s", companyNumber, s.RoutePrefix)
+}
+
+// LocalisedDescription is the description of the scheme in the language, or in English if the scheme has no
+// description in the language
+func (s *PenaltyScheme) LocalisedDescription(lang string) string {
+	if lang == i18n.Welsh && s.WelshDescription != "" {
+		return s.WelshDescription
+	}
+	return s.Description
+}
+
+// LoadPenaltySchemes reads and validates the penalty schemes in the yaml file at the given path
+func LoadPenaltySchemes(path string) ([]*PenaltyScheme, error) {
+	yamlFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading penalty schemes yaml file: [%v]", err)
+	}
+
+	schemes := penaltySchemes{}
+	err = yaml.Unmarshal(yamlFile, &schemes)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling penalty schemes yaml file: [%v]", err)
+	}
+
+	if len(schemes.Schemes) == 0 {
+		return nil, fmt.Errorf("no penalty schemes defined in [%s]", path)
+	}
+
+	v := validator.New()
+	names := map[string]bool{}
+	routePrefixes := map[string]bool{}
+	for _, scheme := range schemes.Schemes {
+		err = v.Struct(scheme)
+		if err != nil {
+			return nil, fmt.Errorf("invalid penalty scheme [%s]: [%v]", scheme.Name, err)
+		}
+
+		// each scheme must be uniquely identifiable and must not clash with another scheme's routes
+		if names[scheme.Name] || routePrefixes[scheme.RoutePrefix] {
+			return nil, fmt.Errorf("penalty scheme [%s] is defined more than once", scheme.Name)
+		}
+		names[scheme.Name] = true
+		routePrefixes[scheme.RoutePrefix] = true
+	}
+
+	return schemes.Schemes, nil
+}
+
+// GetPenaltyScheme returns the penalty scheme that the request was routed through
+func GetPenaltyScheme(ctx context.Context) (*PenaltyScheme, error) {
+	scheme, ok := ctx.Value(Scheme).(*PenaltyScheme)
+	if !ok || scheme == nil {
+		return nil, ErrNoPenaltyScheme
+	}
+
+	return scheme, nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/i18n"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testScheme = `
+  - name: late-filing
+    company_code: LP
+    allowed_types_file: assets/penalty_types.yml
+    payability_rules_file: assets/payability_rules.yml
+    route_prefix: late-filing
+    description: Late Filing Penalty
+    description_identifier: late-filing-penalty
+    product_type: late-filing-penalty
+    resource_kind: late-filing-penalty#late-filing-penalty
+    resume_journey_link: /late-filing-penalty/company/%s/penalty/%s/view-penalties
+    resume_journey_multiple_link: /late-filing-penalty/company/%s/view-penalties
+    email_app_id: lfp-pay-api.late_filing_penalty_received_email
+    email_message_type: late_filing_penalty_received_email
+    refund_email_app_id: lfp-pay-api.late_filing_penalty_refunded_email
+    refund_email_message_type: late_filing_penalty_refunded_email
+`
+
+func writeSchemesFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "penalty-schemes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "penalty_schemes.yml")
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUnitLoadPenaltySchemes(t *testing.T) {
+	Convey("the schemes shipped with the service are valid", t, func() {
+		schemes, err := LoadPenaltySchemes("../" + PenaltySchemesFile)
+		So(err, ShouldBeNil)
+		So(schemes, ShouldHaveLength, 1)
+		So(schemes[0].CompanyCode, ShouldEqual, "LP")
+		So(schemes[0].RouteTemplate(), ShouldEqual, "/company/{company_number}/penalties/late-filing")
+		So(schemes[0].BulkRouteTemplate(), ShouldEqual, "/penalties/late-filing")
+		So(schemes[0].CompanyPath("10000024"), ShouldEqual, "/company/10000024/penalties/late-filing")
+	})
+
+	Convey("error when the file does not exist", t, func() {
+		schemes, err := LoadPenaltySchemes("does_not_exist.yml")
+		So(schemes, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("error when no schemes are defined", t, func() {
+		path := writeSchemesFile(t, "penalty_schemes: []\n")
+		defer os.RemoveAll(filepath.Dir(path))
+
+		schemes, err := LoadPenaltySchemes(path)
+		So(schemes, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("error when a scheme is missing a field", t, func() {
+		path := writeSchemesFile(t, "penalty_schemes:\n  - name: late-filing\n    company_code: LP\n")
+		defer os.RemoveAll(filepath.Dir(path))
+
+		schemes, err := LoadPenaltySchemes(path)
+		So(schemes, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("error when a scheme is defined twice", t, func() {
+		path := writeSchemesFile(t, "penalty_schemes:"+testScheme+testScheme)
+		defer os.RemoveAll(filepath.Dir(path))
+
+		schemes, err := LoadPenaltySchemes(path)
+		So(schemes, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestUnitGetPenaltyScheme(t *testing.T) {
+	Convey("error when there is no scheme in the context", t, func() {
+		scheme, err := GetPenaltyScheme(context.Background())
+		So(scheme, ShouldBeNil)
+		So(err, ShouldEqual, ErrNoPenaltyScheme)
+	})
+
+	Convey("scheme is read from the context", t, func() {
+		ctx := context.WithValue(context.Background(), Scheme, &PenaltyScheme{Name: "late-filing"})
+		scheme, err := GetPenaltyScheme(ctx)
+		So(err, ShouldBeNil)
+		So(scheme.Name, ShouldEqual, "late-filing")
+	})
+}
+
+func TestUnitLocalisedDescription(t *testing.T) {
+	Convey("Welsh description is used for Welsh", t, func() {
+		scheme := &PenaltyScheme{Description: "Late Filing Penalty", WelshDescription: "Cosb am Ffeilio'n Hwyr"}
+		So(scheme.LocalisedDescription(i18n.Welsh), ShouldEqual, "Cosb am Ffeilio'n Hwyr")
+		So(scheme.LocalisedDescription(i18n.English), ShouldEqual, "Late Filing Penalty")
+	})
+
+	Convey("English description is used when there is no Welsh description", t, func() {
+		scheme := &PenaltyScheme{Description: "Late Filing Penalty"}
+		So(scheme.LocalisedDescription(i18n.Welsh), ShouldEqual, "Late Filing Penalty")
+	})
+}
@@ -0,0 +1,186 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/companieshouse/chs.go/avro"
+	"github.com/companieshouse/chs.go/avro/schema"
+	"github.com/companieshouse/chs.go/kafka/producer"
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/service"
+)
+
+// Mode is the value of the MODE config that runs the service as the payment-processed consumer rather than the API
+const Mode = "consumer"
+
+// PaymentProcessedSchemaName is the schema used to read payment-processed messages
+const PaymentProcessedSchemaName = "payment-processed"
+
+const (
+	defaultConsumerGroupName        = "lfp-pay-api-payment-processed"
+	defaultPaymentProcessedTopic    = "payment-processed"
+	defaultPaymentProcessedDLQTopic = "payment-processed-lfp-pay-api-error"
+	defaultConsumerMaxAttempts      = 3
+)
+
+// retryDelay is how long to wait before processing a message again, multiplied by the number of attempts so far
+var retryDelay = 5 * time.Second
+
+// processor processes the value of a message
+type processor interface {
+	Process(ctx context.Context, value []byte) error
+}
+
+// messageSender sends messages to a kafka topic
+type messageSender interface {
+	Send(message *producer.Message) (int32, int64, error)
+}
+
+// paymentProcessedHandler consumes payment-processed messages from the partitions claimed by the consumer group.
+// The offset of a message is only marked once it has been processed or sent to the dead-letter topic, so a message
+// being processed when the consumer stops is consumed again.
+type paymentProcessedHandler struct {
+	processor   processor
+	deadLetter  messageSender
+	dlqTopic    string
+	maxAttempts int
+}
+
+// Setup is run when the consumer joins the group
+func (h *paymentProcessedHandler) Setup(session sarama.ConsumerGroupSession) error {
+	log.Info("payment-processed consumer joined group", log.Data{"member_id": session.MemberID(), "claims": session.Claims()})
+	return nil
+}
+
+// Cleanup is run when the consumer leaves the group
+func (h *paymentProcessedHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	log.Info("payment-processed consumer left group", log.Data{"member_id": session.MemberID()})
+	return nil
+}
+
+// ConsumeClaim processes the messages in a claimed partition in order
+func (h *paymentProcessedHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		err := h.handle(session.Context(), message)
+		if err != nil {
+			return err
+		}
+		session.MarkMessage(message, "")
+	}
+	return nil
+}
+
+// handle processes a message, trying again if the error is retryable, and sends it to the dead-letter topic if it
+// cannot be processed. An error is only returned if the message could not be sent to the dead-letter topic or the
+// consumer is stopping, in which case its offset must not be marked.
+func (h *paymentProcessedHandler) handle(ctx context.Context, message *sarama.ConsumerMessage) error {
+	logData := log.Data{"topic": message.Topic, "partition": message.Partition, "offset": message.Offset}
+
+	for attempt := 1; ; attempt++ {
+		err := h.processor.Process(ctx, message.Value)
+		if err == nil {
+			return nil
+		}
+
+		logData["attempt"] = attempt
+		log.Error(fmt.Errorf("error processing payment-processed message: [%v]", err), logData)
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) || attempt >= h.maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay * time.Duration(attempt)):
+		}
+	}
+
+	_, _, err := h.deadLetter.Send(&producer.Message{Topic: h.dlqTopic, Value: message.Value})
+	if err != nil {
+		return fmt.Errorf("error sending message to dead-letter topic [%s]: [%v]", h.dlqTopic, err)
+	}
+
+	log.Info("payment-processed message sent to dead-letter topic", logData)
+
+	return nil
+}
+
+// Run consumes payment-processed messages until the context is cancelled, completing the penalty payments in them
+func Run(ctx context.Context, cfg *config.Config, svc dao.Service, schemes []*config.PenaltyScheme) error {
+	paymentProcessedSchema, err := schema.Get(cfg.SchemaRegistryURL, PaymentProcessedSchemaName)
+	if err != nil {
+		return fmt.Errorf("error getting schema from schema registry: [%v]", err)
+	}
+
+	deadLetterProducer, err := producer.New(&producer.Config{Acks: &producer.WaitForAll, BrokerAddrs: cfg.BrokerAddr})
+	if err != nil {
+		return fmt.Errorf("error creating kafka producer: [%v]", err)
+	}
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V2_0_0_0
+	saramaConfig.Consumer.Return.Errors = true
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	groupName := valueOrDefault(cfg.ConsumerGroupName, defaultConsumerGroupName)
+	group, err := sarama.NewConsumerGroup(cfg.BrokerAddr, groupName, saramaConfig)
+	if err != nil {
+		return fmt.Errorf("error creating kafka consumer group: [%v]", err)
+	}
+	defer group.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			log.Error(fmt.Errorf("payment-processed consumer error: [%v]", err))
+		}
+	}()
+
+	maxAttempts := cfg.ConsumerMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultConsumerMaxAttempts
+	}
+
+	handler := &paymentProcessedHandler{
+		processor: &PaymentProcessedProcessor{
+			Service:  &service.PayableResourceService{Config: cfg, DAO: svc},
+			E5Client: e5.NewClient(cfg.E5Username, cfg.E5APIURL),
+			Schemes:  schemes,
+			Schema:   &avro.Schema{Definition: paymentProcessedSchema},
+			Config:   cfg,
+		},
+		deadLetter:  deadLetterProducer,
+		dlqTopic:    valueOrDefault(cfg.PaymentProcessedDLQTopic, defaultPaymentProcessedDLQTopic),
+		maxAttempts: maxAttempts,
+	}
+	topics := []string{valueOrDefault(cfg.PaymentProcessedTopic, defaultPaymentProcessedTopic)}
+
+	log.Info("starting payment-processed consumer", log.Data{"group": groupName, "topics": topics, "dead_letter_topic": handler.dlqTopic})
+
+	// Consume returns whenever the group rebalances, so it is called again until the consumer is stopped
+	for {
+		err = group.Consume(ctx, topics, handler)
+		if err != nil {
+			return fmt.Errorf("error consuming payment-processed messages: [%v]", err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// valueOrDefault returns the configured value, or the default if it has not been configured
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
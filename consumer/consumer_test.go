@@ -0,0 +1,120 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/companieshouse/chs.go/kafka/producer"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// processorFunc allows a function to be used as a processor
+type processorFunc func(ctx context.Context, value []byte) error
+
+func (f processorFunc) Process(ctx context.Context, value []byte) error {
+	return f(ctx, value)
+}
+
+// recordingSender records the messages sent to it
+type recordingSender struct {
+	sent []*producer.Message
+	err  error
+}
+
+func (s *recordingSender) Send(message *producer.Message) (int32, int64, error) {
+	if s.err != nil {
+		return 0, 0, s.err
+	}
+	s.sent = append(s.sent, message)
+	return 0, 0, nil
+}
+
+func TestUnitPaymentProcessedHandler(t *testing.T) {
+	retryDelay = time.Millisecond
+	message := &sarama.ConsumerMessage{Topic: "payment-processed", Value: []byte("message")}
+
+	newHandler := func(process processorFunc, sender *recordingSender) *paymentProcessedHandler {
+		return &paymentProcessedHandler{processor: process, deadLetter: sender, dlqTopic: "dlq", maxAttempts: 3}
+	}
+
+	Convey("message processed first time", t, func() {
+		sender := &recordingSender{}
+		attempts := 0
+		handler := newHandler(func(context.Context, []byte) error {
+			attempts++
+			return nil
+		}, sender)
+
+		So(handler.handle(context.Background(), message), ShouldBeNil)
+		So(attempts, ShouldEqual, 1)
+		So(sender.sent, ShouldBeEmpty)
+	})
+
+	Convey("retryable error is retried until the message is processed", t, func() {
+		sender := &recordingSender{}
+		attempts := 0
+		handler := newHandler(func(context.Context, []byte) error {
+			attempts++
+			if attempts < 3 {
+				return &retryableError{errors.New("any error")}
+			}
+			return nil
+		}, sender)
+
+		So(handler.handle(context.Background(), message), ShouldBeNil)
+		So(attempts, ShouldEqual, 3)
+		So(sender.sent, ShouldBeEmpty)
+	})
+
+	Convey("message is dead-lettered once it has been tried the most times", t, func() {
+		sender := &recordingSender{}
+		attempts := 0
+		handler := newHandler(func(context.Context, []byte) error {
+			attempts++
+			return &retryableError{errors.New("any error")}
+		}, sender)
+
+		So(handler.handle(context.Background(), message), ShouldBeNil)
+		So(attempts, ShouldEqual, 3)
+		So(sender.sent, ShouldHaveLength, 1)
+		So(sender.sent[0].Topic, ShouldEqual, "dlq")
+		So(string(sender.sent[0].Value), ShouldEqual, "message")
+	})
+
+	Convey("error that is not retryable is dead-lettered straight away", t, func() {
+		sender := &recordingSender{}
+		attempts := 0
+		handler := newHandler(func(context.Context, []byte) error {
+			attempts++
+			return errors.New("any error")
+		}, sender)
+
+		So(handler.handle(context.Background(), message), ShouldBeNil)
+		So(attempts, ShouldEqual, 1)
+		So(sender.sent, ShouldHaveLength, 1)
+	})
+
+	Convey("error when the message cannot be dead-lettered", t, func() {
+		sender := &recordingSender{err: errors.New("kafka error")}
+		handler := newHandler(func(context.Context, []byte) error {
+			return errors.New("any error")
+		}, sender)
+
+		So(handler.handle(context.Background(), message), ShouldNotBeNil)
+	})
+
+	Convey("retries stop when the consumer is stopping", t, func() {
+		sender := &recordingSender{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		handler := newHandler(func(context.Context, []byte) error {
+			return &retryableError{errors.New("any error")}
+		}, sender)
+
+		So(handler.handle(ctx, message), ShouldEqual, context.Canceled)
+		So(sender.sent, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,2 @@
+// Package consumer contains the kafka consumer which completes penalty payments from payment-processed messages.
+package consumer
@@ -0,0 +1,177 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/companieshouse/chs.go/avro"
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/service"
+)
+
+// the calls to other services are variables so that they can be mocked in unit tests
+var (
+	getPaymentResourceLink = service.GetPaymentResourceLink
+	getPaymentInformation  = service.GetPaymentInformation
+	completePayment        = (*service.PayableResourceService).CompletePayment
+	finishPayment          = (*service.PayableResourceService).FinishPayment
+)
+
+// resourceLinkPattern matches the resource link of a penalty payment, which is the payment details of the payable
+// resource, capturing the company number, route prefix of the penalty scheme and the payable resource reference
+var resourceLinkPattern = regexp.MustCompile(`^/company/([^/]+)/penalties/([^/]+)/payable/([^/]+)/payment$`)
+
+// PaymentProcessed is the payment-processed message sent by the payment platform once a payment has been taken
+type PaymentProcessed struct {
+	PaymentResourceID string `avro:"payment_resource_id"`
+}
+
+// retryableError is an error processing a message that may succeed if the message is processed again, e.g. because
+// another service could not be reached
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// PaymentProcessedProcessor completes penalty payments from payment-processed messages in the same way that
+// PayResourceHandler does when the payment platform calls back to the API
+type PaymentProcessedProcessor struct {
+	Service  *service.PayableResourceService
+	E5Client *e5.Client
+	Schemes  []*config.PenaltyScheme
+	Schema   *avro.Schema
+	Config   *config.Config
+}
+
+// Process completes the payment in a payment-processed message. Messages for payments that are not penalty payments,
+// or whose payable resource has already been paid and completed, are ignored. An error is returned if the payment cannot be completed,
+// which is a retryableError if processing the message again may succeed.
+func (p *PaymentProcessedProcessor) Process(ctx context.Context, value []byte) error {
+	var message PaymentProcessed
+	err := p.Schema.Unmarshal(value, &message)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling payment-processed message: [%v]", err)
+	}
+	if message.PaymentResourceID == "" {
+		return errors.New("payment-processed message has no payment resource id")
+	}
+
+	logData := log.Data{"payment_id": message.PaymentResourceID}
+
+	req, err := p.newRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	link, err := getPaymentResourceLink(message.PaymentResourceID, req)
+	if err != nil {
+		return &retryableError{fmt.Errorf("error getting payment resource link: [%v]", err)}
+	}
+
+	// the topic carries every payment taken by the payment platform, most of which are not for penalties
+	companyNumber, reference, scheme := p.resolveResourceLink(link)
+	if scheme == nil {
+		log.Trace("payment is not for a payable resource", log.Data{"payment_id": message.PaymentResourceID, "resource": link})
+		return nil
+	}
+	logData["lfp_reference"] = reference
+	logData["company_number"] = companyNumber
+
+	req = req.WithContext(context.WithValue(req.Context(), config.Scheme, scheme))
+
+	resource, responseType, err := p.Service.GetPayableResource(req, companyNumber, reference)
+	if err != nil {
+		return &retryableError{err}
+	}
+	if responseType == service.NotFound {
+		return fmt.Errorf("payable resource [%s] for company [%s] not found", reference, companyNumber)
+	}
+
+	// the payment has usually been completed by the payment platform calling back to the API, in which case only the
+	// steps that failed then are left to finish
+	if resource.Payment.Status == constants.Paid.String() {
+		err = finishPayment(p.Service, p.E5Client, *resource, scheme, req)
+		if err == service.ErrAlreadyPaid {
+			log.Info("payable resource has already been paid", logData)
+			return nil
+		}
+		if err != nil {
+			return &retryableError{err}
+		}
+		log.Info("payment finished from payment-processed message", logData)
+		return nil
+	}
+	// a refunded resource was paid before it was refunded, so the payment has nothing left to do
+	if resource.Payment.Status == service.PaymentStatusRefunded {
+		log.Info("payable resource has been paid and refunded", logData)
+		return nil
+	}
+
+	payment, err := getPaymentInformation(message.PaymentResourceID, req)
+	if err != nil {
+		return &retryableError{fmt.Errorf("error getting payment information: [%v]", err)}
+	}
+
+	err = validators.New().ValidateForPayment(*resource, *payment)
+	if err != nil {
+		return fmt.Errorf("error validating payment: [%v]", err)
+	}
+
+	// the steps after the resource is marked as paid are stored with it until they succeed, so every error is worth
+	// retrying
+	err = completePayment(p.Service, p.E5Client, *resource, *payment, scheme, req)
+	switch err {
+	case nil:
+	case service.ErrAlreadyPaid:
+		log.Info("payable resource has already been paid", logData)
+		return nil
+	case service.ErrPaidAfterCancellation:
+		// the payment has been recorded for finance to refund, so there is nothing to retry
+		log.Info("payment taken for cancelled payable resource recorded to be refunded", logData)
+		return nil
+	default:
+		return &retryableError{fmt.Errorf("error completing payment: [%v]", err)}
+	}
+
+	log.Info("payment completed from payment-processed message", logData)
+
+	return nil
+}
+
+// resolveResourceLink finds the payable resource and penalty scheme from the resource link of a payment. The scheme is
+// nil if the link is not to a payable resource.
+func (p *PaymentProcessedProcessor) resolveResourceLink(link string) (string, string, *config.PenaltyScheme) {
+	matches := resourceLinkPattern.FindStringSubmatch(link)
+	if matches == nil {
+		return "", "", nil
+	}
+
+	for _, scheme := range p.Schemes {
+		if scheme.RoutePrefix == matches[2] {
+			return matches[1], matches[3], scheme
+		}
+	}
+
+	return "", "", nil
+}
+
+// newRequest creates the request used to call other services and to send the confirmation email. go-sdk-manager
+// takes its credentials from the request, so it carries the API key of the service.
+func (p *PaymentProcessedProcessor) newRequest(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(p.Config.CHSAPIKey, "")
+	return req, nil
+}
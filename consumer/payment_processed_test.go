@@ -0,0 +1,255 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/companieshouse/chs.go/avro"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testPaymentProcessedSchema = `{
+  "type": "record",
+  "name": "payment_processed",
+  "namespace": "payments",
+  "fields": [
+    {"name": "payment_resource_id", "type": "string"}
+  ]
+}`
+
+var testScheme = &config.PenaltyScheme{Name: "late-filing", CompanyCode: "LP", RoutePrefix: "late-filing"}
+
+const testResourceLink = "/company/10000024/penalties/late-filing/payable/123/payment"
+
+func newTestProcessor(mockService *mocks.MockService) *PaymentProcessedProcessor {
+	return &PaymentProcessedProcessor{
+		Service:  &service.PayableResourceService{DAO: mockService},
+		E5Client: e5.NewClient("SYSTEM", "https://e5"),
+		Schemes:  []*config.PenaltyScheme{testScheme},
+		Schema:   &avro.Schema{Definition: testPaymentProcessedSchema},
+		Config:   &config.Config{},
+	}
+}
+
+func paymentProcessedMessage(t *testing.T, processor *PaymentProcessedProcessor, paymentID string) []byte {
+	value, err := processor.Schema.Marshal(PaymentProcessed{PaymentResourceID: paymentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// stubServices replaces the calls to other services for the duration of a test. Completing the payment returns
+// completeErr, and finishing the payment of a resource that has already been paid returns finishErr.
+func stubServices(link string, linkErr error, payment *validators.PaymentInformation, completeErr, finishErr error) func() {
+	getPaymentResourceLink = func(string, *http.Request) (string, error) { return link, linkErr }
+	getPaymentInformation = func(string, *http.Request) (*validators.PaymentInformation, error) { return payment, nil }
+	completePayment = func(*service.PayableResourceService, *e5.Client, models.PayableResource, validators.PaymentInformation, *config.PenaltyScheme, *http.Request) error {
+		return completeErr
+	}
+	finishPayment = func(*service.PayableResourceService, *e5.Client, models.PayableResource, *config.PenaltyScheme, *http.Request) error {
+		return finishErr
+	}
+
+	return func() {
+		getPaymentResourceLink = service.GetPaymentResourceLink
+		getPaymentInformation = service.GetPaymentInformation
+		completePayment = (*service.PayableResourceService).CompletePayment
+		finishPayment = (*service.PayableResourceService).FinishPayment
+	}
+}
+
+func isRetryable(err error) bool {
+	var retryable *retryableError
+	return errors.As(err, &retryable)
+}
+
+func TestUnitPaymentProcessedProcessor(t *testing.T) {
+	paidPayment := &validators.PaymentInformation{PaymentID: "P123", Status: "paid", Amount: "0", Reference: "late_filing_penalty_123"}
+
+	pending := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber: "10000024",
+			Reference:     "123",
+			Data:          models.PayableResourceDataDao{Payment: models.PaymentDao{Status: "pending"}},
+		}
+	}
+
+	Convey("message that cannot be read is not retried", t, func() {
+		processor := newTestProcessor(nil)
+		err := processor.Process(context.Background(), []byte("not a message"))
+		So(err, ShouldNotBeNil)
+		So(isRetryable(err), ShouldBeFalse)
+	})
+
+	Convey("payments that are not for a payable resource are ignored", t, func() {
+		defer stubServices("/transactions/123/payment", nil, paidPayment, nil, nil)()
+		processor := newTestProcessor(nil)
+
+		So(processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123")), ShouldBeNil)
+	})
+
+	Convey("payments for a penalty scheme that is not hosted are ignored", t, func() {
+		defer stubServices("/company/10000024/penalties/other/payable/123/payment", nil, paidPayment, nil, nil)()
+		processor := newTestProcessor(nil)
+
+		So(processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123")), ShouldBeNil)
+	})
+
+	Convey("error getting the payment from the payment platform is retried", t, func() {
+		defer stubServices("", errors.New("any error"), paidPayment, nil, nil)()
+		processor := newTestProcessor(nil)
+
+		err := processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123"))
+		So(isRetryable(err), ShouldBeTrue)
+	})
+
+	Convey("payable resource that does not exist is not retried", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, nil, nil)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(nil, nil)
+		processor := newTestProcessor(mockService)
+
+		err := processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123"))
+		So(err, ShouldNotBeNil)
+		So(isRetryable(err), ShouldBeFalse)
+	})
+
+	Convey("payable resource that has already been paid and completed is ignored", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, nil, service.ErrAlreadyPaid)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		paid := pending()
+		paid.Data.Payment.Status = "paid"
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(paid, nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		So(processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123")), ShouldBeNil)
+	})
+
+	Convey("payable resource that has already been paid has its payment finished", t, func() {
+		finished := false
+		defer stubServices(testResourceLink, nil, paidPayment, nil, nil)()
+		finishPayment = func(*service.PayableResourceService, *e5.Client, models.PayableResource, *config.PenaltyScheme, *http.Request) error {
+			finished = true
+			return nil
+		}
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		paid := pending()
+		paid.Data.Payment.Status = "paid"
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(paid, nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		So(processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123")), ShouldBeNil)
+		So(finished, ShouldBeTrue)
+	})
+
+	Convey("error finishing the payment of a payable resource that has already been paid is retried", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, nil, service.ErrPaymentIncomplete)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		paid := pending()
+		paid.Data.Payment.Status = "paid"
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(paid, nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		err := processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123"))
+		So(isRetryable(err), ShouldBeTrue)
+	})
+
+	Convey("payment for a cancelled payable resource is recorded to be refunded and not retried", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, service.ErrPaidAfterCancellation, nil)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		cancelled := pending()
+		cancelled.Data.Payment.Status = service.PaymentStatusCancelled
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(cancelled, nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		So(processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123")), ShouldBeNil)
+	})
+
+	Convey("payment that fails validation is not retried", t, func() {
+		defer stubServices(testResourceLink, nil, &validators.PaymentInformation{Status: "failed"}, nil, nil)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(pending(), nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		err := processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123"))
+		So(err, ShouldNotBeNil)
+		So(isRetryable(err), ShouldBeFalse)
+	})
+
+	Convey("error marking the payable resource as paid is retried", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, errors.New("any error"), nil)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(pending(), nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		err := processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123"))
+		So(isRetryable(err), ShouldBeTrue)
+	})
+
+	Convey("error updating E5 after the resource is paid is retried", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, service.ErrPaymentIncomplete, nil)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(pending(), nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		err := processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123"))
+		So(isRetryable(err), ShouldBeTrue)
+	})
+
+	Convey("payment completed another way in the meantime is ignored", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, service.ErrAlreadyPaid, nil)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(pending(), nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		So(processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123")), ShouldBeNil)
+	})
+
+	Convey("payment is completed", t, func() {
+		defer stubServices(testResourceLink, nil, paidPayment, nil, nil)()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "123").Return(pending(), nil)
+		mockService.EXPECT().IsInScheme("10000024", "123", gomock.Any()).Return(true, nil)
+		processor := newTestProcessor(mockService)
+
+		So(processor.Process(context.Background(), paymentProcessedMessage(t, processor, "P123")), ShouldBeNil)
+	})
+}
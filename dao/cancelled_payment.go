@@ -0,0 +1,118 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CancelledPaymentDao is a payment that the payment platform took for a payable resource after it had been cancelled,
+// which is kept so that it can be refunded. It is stored alongside the payable resource rather than in the resource
+// returned to clients, so the etag is not changed when it is saved.
+type CancelledPaymentDao struct {
+	PaymentID  string    `bson:"payment_id"`
+	Reference  string    `bson:"reference"`
+	Amount     string    `bson:"amount"`
+	PaidAt     time.Time `bson:"paid_at"`
+	RecordedAt time.Time `bson:"recorded_at"`
+}
+
+// CancelledPaymentItemDao is a payment taken for a cancelled payable resource, along with the resource it is for
+type CancelledPaymentItemDao struct {
+	CompanyNumber    string              `bson:"company_number"`
+	Reference        string              `bson:"reference"`
+	CancelledPayment CancelledPaymentDao `bson:"cancelled_payment"`
+	Refund           *RefundDao          `bson:"refund,omitempty"`
+}
+
+// RecordCancelledPayment stores a payment taken for a cancelled payable resource. A payment is often completed more
+// than once, e.g. by both the API callback and the payment-processed message, so the payment that was recorded first is
+// kept.
+func (m *MongoService) RecordCancelledPayment(companyNumber, reference string, payment *CancelledPaymentDao) error {
+	filter := bson.M{
+		"reference":         reference,
+		"company_number":    companyNumber,
+		"cancelled_payment": bson.M{"$exists": false},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"cancelled_payment", payment},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	return nil
+}
+
+// GetCancelledPayment gets the payment taken for the cancelled payable resource, or nil if there is none
+func (m *MongoService) GetCancelledPayment(companyNumber, reference string) (*CancelledPaymentDao, error) {
+	var resource struct {
+		CancelledPayment *CancelledPaymentDao `bson:"cancelled_payment"`
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.FindOne().SetProjection(bson.M{"cancelled_payment": 1})
+	dbResource := collection.FindOne(context.Background(), bson.M{"reference": reference, "company_number": companyNumber}, findOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payable resource found", log.Data{"company_number": companyNumber, "reference": reference})
+			return nil, nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	return resource.CancelledPayment, nil
+}
+
+// ListUnrefundedCancelledPayments gets the payments taken for the penalty scheme's cancelled payable resources that
+// the payment platform has not yet accepted a refund for, oldest first
+func (m *MongoService) ListUnrefundedCancelledPayments(scheme *config.PenaltyScheme) ([]CancelledPaymentItemDao, error) {
+	filter := bson.M{
+		"$or":               schemeDocumentsFilter(scheme, "/company/[^/]+"),
+		"cancelled_payment": bson.M{"$exists": true},
+		"refund.status":     bson.M{"$ne": refundStatusSubmitted},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.Find().
+		SetProjection(bson.M{"company_number": 1, "reference": 1, "cancelled_payment": 1, "refund": 1}).
+		SetSort(bson.M{"cancelled_payment.recorded_at": 1})
+
+	cursor, err := collection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		log.Error(err, log.Data{"scheme": scheme.Name})
+		return nil, err
+	}
+
+	payments := []CancelledPaymentItemDao{}
+	err = cursor.All(context.Background(), &payments)
+	if err != nil {
+		log.Error(err, log.Data{"scheme": scheme.Name})
+		return nil, err
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/companieshouse/chs.go/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveE5PaymentID stores the id of the payment created in E5 for the payable resource, so that the payment can be
+// timed out in E5 if the resource is cancelled after E5 has been left locked. The etag is not changed as the id is not
+// part of the resource returned to clients.
+func (m *MongoService) SaveE5PaymentID(companyNumber, reference, paymentID string) error {
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"e5_payment_id", paymentID},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	return nil
+}
+
+// GetE5PaymentID gets the id of the payment created in E5 for the payable resource, or an empty string if no payment
+// has been created in E5
+func (m *MongoService) GetE5PaymentID(companyNumber, reference string) (string, error) {
+	var resource struct {
+		E5PaymentID string `bson:"e5_payment_id"`
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.FindOne().SetProjection(bson.M{"e5_payment_id": 1})
+	dbResource := collection.FindOne(context.Background(), bson.M{"reference": reference, "company_number": companyNumber}, findOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payable resource found", log.Data{"company_number": companyNumber, "reference": reference})
+			return "", nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return "", err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return "", err
+	}
+
+	return resource.E5PaymentID, nil
+}
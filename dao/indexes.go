@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// createdByIDField is the id of the user that created a payable resource
+const createdByIDField = "data.created_by.id"
+
+// indexTimeout is how long creating the indexes is allowed to take at startup
+const indexTimeout = 30 * time.Second
+
+// payableResourceIndexes are the indexes needed by queries that are not on the company number and reference. Listing
+// a user's payable resources is by the user and sorted by when they were created. Payment jobs are only on the
+// resources paid for asynchronously, so their index is sparse.
+var payableResourceIndexes = []mongo.IndexModel{
+	{
+		Keys:    bson.D{{createdByIDField, 1}, {"data.created_at", -1}},
+		Options: options.Index().SetName("created_by_id_created_at"),
+	},
+	{
+		Keys:    bson.D{{paymentJobStatusField, 1}},
+		Options: options.Index().SetName("payment_job_status").SetSparse(true),
+	},
+}
+
+// ensureIndexes creates any of the indexes that do not already exist. Creating an index that already exists has no
+// effect. A failure is logged rather than stopping the service as the queries still work, only more slowly.
+func (m *MongoService) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+
+	collection := m.db.Collection(m.CollectionName)
+	names, err := collection.Indexes().CreateMany(ctx, payableResourceIndexes)
+	if err != nil {
+		log.Error(err, log.Data{"collection": m.CollectionName})
+		return
+	}
+
+	log.Info("mongodb indexes are in place", log.Data{"collection": m.CollectionName, "indexes": names})
+}
@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/companieshouse/chs.go/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveLanguage stores the language that the user that created the payable resource would like to be written to in,
+// so that the emails about it are sent in that language. The etag is not changed as the language is not part of the
+// resource returned to clients.
+func (m *MongoService) SaveLanguage(companyNumber, reference, lang string) error {
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"language", lang},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	return nil
+}
+
+// GetLanguage gets the language that the user that created the payable resource would like to be written to in, or an
+// empty string if it is not known
+func (m *MongoService) GetLanguage(companyNumber, reference string) (string, error) {
+	var resource struct {
+		Language string `bson:"language"`
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.FindOne().SetProjection(bson.M{"language": 1})
+	dbResource := collection.FindOne(context.Background(), bson.M{"reference": reference, "company_number": companyNumber}, findOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payable resource found", log.Data{"company_number": companyNumber, "reference": reference})
+			return "", nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return "", err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return "", err
+	}
+
+	return resource.Language, nil
+}
@@ -3,12 +3,15 @@ package dao
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -17,6 +20,9 @@ import (
 
 var client *mongo.Client
 
+// ErrEtagMismatch is returned when a payable resource has been modified since it was read, so the update was not applied
+var ErrEtagMismatch = errors.New("the payable resource has been modified since it was read")
+
 func getMongoClient(mongoDBURL string) *mongo.Client {
 	if client != nil {
 		return client
@@ -63,15 +69,11 @@ type MongoService struct {
 	CollectionName string
 }
 
-// SaveE5Error will update the resource by flagging an error in e5 for a particular action
+// SaveE5Error will update the resource by flagging an error in e5 for a particular action. The error is kept for
+// internal use and is not part of the resource returned to clients, so the etag is not changed and a client's
+// conditional update is not failed by it.
 func (m *MongoService) SaveE5Error(companyNumber, reference string, action e5.Action) error {
-	dao, err := m.GetPayableResource(companyNumber, reference)
-	if err != nil {
-		log.Error(err, log.Data{"company_number": companyNumber, "lfp_reference": reference})
-		return err
-	}
-
-	filter := bson.M{"_id": dao.ID}
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
 	update := bson.D{
 		{
 			"$set", bson.D{
@@ -82,24 +84,29 @@ func (m *MongoService) SaveE5Error(companyNumber, reference string, action e5.Ac
 
 	collection := m.db.Collection(m.CollectionName)
 
-	log.Debug("updating e5 command error in mongo document", log.Data{"_id": dao.ID})
-
-	_, err = collection.UpdateOne(context.Background(), filter, update)
+	result, err := collection.UpdateOne(context.Background(), filter, update)
 	if err != nil {
-		log.Error(err, log.Data{"_id": dao.ID, "company_number": dao.CompanyNumber, "reference": dao.Reference})
+		log.Error(err, log.Data{"company_number": companyNumber, "lfp_reference": reference})
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		err = fmt.Errorf("payable resource [%s] not found for company [%s]", reference, companyNumber)
+		log.Error(err)
 		return err
 	}
 
 	return nil
 }
 
-// CreatePayableResource will store the payable request into the database
-func (m *MongoService) CreatePayableResource(dao *models.PayableResourceDao) error {
+// CreatePayableResource will store the payable request into the database, along with the name of the penalty scheme
+// it was created under
+func (m *MongoService) CreatePayableResource(dao *models.PayableResourceDao, scheme string) error {
 
 	dao.ID = primitive.NewObjectID()
 
 	collection := m.db.Collection(m.CollectionName)
-	_, err := collection.InsertOne(context.Background(), dao)
+	_, err := collection.InsertOne(context.Background(), payableResourceDocument{PayableResourceDao: *dao, Scheme: scheme})
 	if err != nil {
 		log.Error(err)
 		return err
@@ -135,36 +142,143 @@ func (m *MongoService) GetPayableResource(companyNumber, reference string) (*mod
 	return &resource, nil
 }
 
-// UpdatePaymentDetails will save the document back to Mongo
-func (m *MongoService) UpdatePaymentDetails(dao *models.PayableResourceDao) error {
-	filter := bson.M{"_id": dao.ID}
-
+// ClearE5Error removes the failed E5 command from the resource once the payment has been completed in E5 or timed out,
+// so that the company's account is no longer reported as locked by it. The etag is not changed, as with SaveE5Error.
+func (m *MongoService) ClearE5Error(companyNumber, reference string) error {
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
 	update := bson.D{
 		{
-			"$set", bson.D{
-				{"data.payment.status", dao.Data.Payment.Status},
-				{"data.payment.reference", dao.Data.Payment.Reference},
-				{"data.payment.paid_at", dao.Data.Payment.PaidAt},
-				{"data.payment.amount", dao.Data.Payment.Amount},
+			"$unset", bson.D{
+				{"e5_command_error", ""},
 			},
 		},
 	}
 
 	collection := m.db.Collection(m.CollectionName)
 
-	log.Debug("updating payment details in mongo document", log.Data{"_id": dao.ID})
-
 	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "lfp_reference": reference})
+		return err
+	}
+
+	return nil
+}
+
+// UpdatePaymentDetails will save the document back to Mongo. The update is only applied if the document has not been
+// modified since it was read, i.e. the stored etag still matches the etag on the supplied dao, otherwise
+// ErrEtagMismatch is returned.
+func (m *MongoService) UpdatePaymentDetails(dao *models.PayableResourceDao) error {
+	return m.updatePaymentDetails(dao)
+}
+
+// updatePaymentDetails updates the payment details of the resource along with any other fields given, provided it
+// has not been modified since it was read
+func (m *MongoService) updatePaymentDetails(dao *models.PayableResourceDao, fields ...bson.E) error {
+	// the etag is regenerated from the changed document so that it always reflects the stored data
+	etag, err := utils.GeneratePayableResourceEtag(dao)
 	if err != nil {
 		log.Error(err, log.Data{"_id": dao.ID, "company_number": dao.CompanyNumber, "reference": dao.Reference})
 		return err
 	}
 
+	set := bson.D{
+		{"data.payment.status", dao.Data.Payment.Status},
+		{"data.payment.reference", dao.Data.Payment.Reference},
+		{"data.payment.paid_at", dao.Data.Payment.PaidAt},
+		{"data.payment.amount", dao.Data.Payment.Amount},
+		{"data.etag", etag},
+	}
+	update := bson.D{{"$set", append(set, fields...)}}
+
+	log.Debug("updating payment details in mongo document", log.Data{"_id": dao.ID})
+
+	err = m.updateIfUnmodified(dao, dao.Data.Etag, update)
+	if err != nil {
+		return err
+	}
+
+	dao.Data.Etag = etag
+
 	log.Debug("updated payment details in mongo document", log.Data{"_id": dao.ID})
 
 	return nil
 }
 
+// updateIfUnmodified applies the update to the resource only if its stored etag still matches the supplied etag, so
+// that concurrent writers cannot overwrite each other's changes
+func (m *MongoService) updateIfUnmodified(dao *models.PayableResourceDao, etag string, update bson.D) error {
+	filter := bson.M{"_id": dao.ID, "data.etag": etag}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"_id": dao.ID, "company_number": dao.CompanyNumber, "reference": dao.Reference})
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		log.Info("payable resource has been modified since it was read", log.Data{
+			"_id":            dao.ID,
+			"company_number": dao.CompanyNumber,
+			"reference":      dao.Reference,
+			"etag":           etag,
+		})
+		return ErrEtagMismatch
+	}
+
+	return nil
+}
+
+// CountE5CommandErrors counts the payable resources for a company in the penalty scheme that failed on one of the given
+// E5 commands, and have not since been completed in E5 or timed out
+func (m *MongoService) CountE5CommandErrors(companyNumber string, scheme *config.PenaltyScheme, actions []e5.Action) (int64, error) {
+	commandErrors := make([]string, 0, len(actions))
+	for _, action := range actions {
+		commandErrors = append(commandErrors, string(action))
+	}
+
+	filter := schemeFilter(companyNumber, scheme)
+	filter["e5_command_error"] = bson.M{"$in": commandErrors}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	count, err := collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber})
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetPayableResourcesForTransaction gets every payable resource for the company in the penalty scheme that includes the
+// given transaction, newest first
+func (m *MongoService) GetPayableResourcesForTransaction(companyNumber string, scheme *config.PenaltyScheme, transactionID string) ([]models.PayableResourceDao, error) {
+	// transactions are stored as a map keyed on the transaction id
+	filter := schemeFilter(companyNumber, scheme)
+	filter["data.transactions."+transactionID] = bson.M{"$exists": true}
+	findOptions := options.Find().SetSort(bson.M{"data.created_at": -1})
+
+	collection := m.db.Collection(m.CollectionName)
+
+	cursor, err := collection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "transaction_id": transactionID})
+		return nil, err
+	}
+
+	var resources []models.PayableResourceDao
+	err = cursor.All(context.Background(), &resources)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "transaction_id": transactionID})
+		return nil, err
+	}
+
+	return resources, nil
+}
+
 // Shutdown is a hook that can be used to clean up db resources
 func (m *MongoService) Shutdown() {
 	if client != nil {
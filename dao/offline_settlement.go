@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AuditActionSettledOffline is the audit action recorded when a payable resource is marked as paid outside of the
+// service
+const AuditActionSettledOffline = "settled-offline"
+
+// AuditEntryDao records a change made to a payable resource by a member of staff rather than by the payment journey
+type AuditEntryDao struct {
+	Action            string    `bson:"action"`
+	UserID            string    `bson:"user_id"`
+	Email             string    `bson:"email"`
+	Reason            string    `bson:"reason"`
+	ExternalReference string    `bson:"external_reference,omitempty"`
+	CreatedAt         time.Time `bson:"created_at"`
+}
+
+// SettleOffline saves the payment details of a payable resource that has been paid outside of the service and adds
+// the audit entry in the same update. As with UpdatePaymentDetails the update is only applied if the document has not
+// been modified since it was read, otherwise ErrEtagMismatch is returned.
+func (m *MongoService) SettleOffline(dao *models.PayableResourceDao, entry *AuditEntryDao) error {
+	etag, err := utils.GeneratePayableResourceEtag(dao)
+	if err != nil {
+		return err
+	}
+
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"data.payment.status", dao.Data.Payment.Status},
+				{"data.payment.reference", dao.Data.Payment.Reference},
+				{"data.payment.paid_at", dao.Data.Payment.PaidAt},
+				{"data.payment.amount", dao.Data.Payment.Amount},
+				{"data.etag", etag},
+			},
+		},
+		{
+			"$push", bson.D{
+				{"audit", entry},
+			},
+		},
+	}
+
+	err = m.updateIfUnmodified(dao, dao.Data.Etag, update)
+	if err != nil {
+		return err
+	}
+
+	dao.Data.Etag = etag
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PayableResourceListFilter restricts and pages the payable resources listed for a company. Empty fields are not
+// filtered on.
+type PayableResourceListFilter struct {
+	// Status is the payment status of the resource, e.g. pending or paid
+	Status string
+	// CreatedFrom includes resources created at or after this time
+	CreatedFrom *time.Time
+	// CreatedBefore includes resources created before this time
+	CreatedBefore *time.Time
+	// Skip is the number of matching resources to skip
+	Skip int64
+	// Limit is the most resources to return
+	Limit int64
+}
+
+// ListPayableResources gets the company's payable resources that match the filter, newest first, along with the total
+// number of matching resources regardless of the skip and limit
+func (m *MongoService) ListPayableResources(companyNumber string, filter PayableResourceListFilter) ([]models.PayableResourceDao, int64, error) {
+	return m.listPayableResources(bson.M{"company_number": companyNumber}, filter, log.Data{"company_number": companyNumber})
+}
+
+// ListPayableResourcesCreatedBy gets the payable resources created by the user, for any company, that match the
+// filter, newest first, along with the total number of matching resources regardless of the skip and limit
+func (m *MongoService) ListPayableResourcesCreatedBy(userID string, filter PayableResourceListFilter) ([]models.PayableResourceDao, int64, error) {
+	return m.listPayableResources(bson.M{createdByIDField: userID}, filter, log.Data{"user_id": userID})
+}
+
+// listPayableResources applies the filter to the query and gets the requested page of resources
+func (m *MongoService) listPayableResources(query bson.M, filter PayableResourceListFilter, logData log.Data) ([]models.PayableResourceDao, int64, error) {
+	if filter.Status != "" {
+		query["data.payment.status"] = filter.Status
+	}
+
+	createdAt := bson.M{}
+	if filter.CreatedFrom != nil {
+		createdAt["$gte"] = *filter.CreatedFrom
+	}
+	if filter.CreatedBefore != nil {
+		createdAt["$lt"] = *filter.CreatedBefore
+	}
+	if len(createdAt) > 0 {
+		query["data.created_at"] = createdAt
+	}
+
+	logData["status"] = filter.Status
+
+	collection := m.db.Collection(m.CollectionName)
+
+	total, err := collection.CountDocuments(context.Background(), query)
+	if err != nil {
+		log.Error(err, logData)
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().SetSort(bson.M{"data.created_at": -1}).SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		findOptions.SetLimit(filter.Limit)
+	}
+
+	cursor, err := collection.Find(context.Background(), query, findOptions)
+	if err != nil {
+		log.Error(err, logData)
+		return nil, 0, err
+	}
+
+	resources := []models.PayableResourceDao{}
+	err = cursor.All(context.Background(), &resources)
+	if err != nil {
+		log.Error(err, logData)
+		return nil, 0, err
+	}
+
+	return resources, total, nil
+}
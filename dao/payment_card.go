@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/companieshouse/chs.go/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PaymentCardDao is how a payable resource was paid for on the payment platform. It is stored alongside the payment
+// details in the payable resource so that receipts can be reissued without going back to the payment platform.
+type PaymentCardDao struct {
+	PaymentID string `bson:"payment_id"`
+	CardType  string `bson:"card_type"`
+}
+
+// SavePaymentCard stores how the payable resource was paid for
+func (m *MongoService) SavePaymentCard(companyNumber, reference string, card *PaymentCardDao) error {
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"data.payment.payment_id", card.PaymentID},
+				{"data.payment.card_type", card.CardType},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	return nil
+}
+
+// GetPaymentCard gets how the payable resource was paid for, or nil if it was paid for before this was stored
+func (m *MongoService) GetPaymentCard(companyNumber, reference string) (*PaymentCardDao, error) {
+	var resource struct {
+		Data struct {
+			Payment PaymentCardDao `bson:"payment"`
+		} `bson:"data"`
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.FindOne().SetProjection(bson.M{"data.payment.payment_id": 1, "data.payment.card_type": 1})
+	dbResource := collection.FindOne(context.Background(), bson.M{"reference": reference, "company_number": companyNumber}, findOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payable resource found", log.Data{"company_number": companyNumber, "reference": reference})
+			return nil, nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	if resource.Data.Payment.PaymentID == "" {
+		return nil, nil
+	}
+
+	return &resource.Data.Payment, nil
+}
@@ -0,0 +1,105 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PaymentCompletionDao is what is still to be done to complete a payment once the payable resource has been marked as
+// paid. It is saved in the same update that marks the resource as paid, so that a paid resource always records whether
+// E5 and the user have been told about the payment, and is stored alongside the resource rather than in the resource
+// returned to clients, so the etag is not changed when the steps are finished.
+type PaymentCompletionDao struct {
+	Payment      validators.PaymentInformation `bson:"payment"`
+	E5Pending    bool                          `bson:"e5_pending"`
+	E5Action     e5.Action                     `bson:"e5_action,omitempty"`
+	EmailPending bool                          `bson:"email_pending"`
+	ClaimedAt    time.Time                     `bson:"claimed_at"`
+}
+
+// MarkAsPaid will update the resource with its payment details and store the steps still to be taken to complete the
+// payment, provided it has not been modified since it was read
+func (m *MongoService) MarkAsPaid(dao *models.PayableResourceDao, completion *PaymentCompletionDao) error {
+	return m.updatePaymentDetails(dao, bson.E{Key: "payment_completion", Value: completion})
+}
+
+// ClaimPaymentCompletion marks the steps still to be taken to complete the payable resource's payment as being
+// finished, so that no other process finishes them at the same time, and returns them. They can be claimed when they
+// were last claimed before staleBefore, which is the case once a process has released them. nil is returned if every
+// step has been finished or they cannot be claimed.
+func (m *MongoService) ClaimPaymentCompletion(companyNumber, reference string, staleBefore time.Time) (*PaymentCompletionDao, error) {
+	var resource struct {
+		PaymentCompletion *PaymentCompletionDao `bson:"payment_completion"`
+	}
+
+	filter := bson.M{
+		"reference":      reference,
+		"company_number": companyNumber,
+		"$or": bson.A{
+			bson.M{"payment_completion.e5_pending": true},
+			bson.M{"payment_completion.email_pending": true},
+		},
+		"payment_completion.claimed_at": bson.M{"$lt": staleBefore},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"payment_completion.claimed_at", time.Now()},
+			},
+		},
+	}
+	updateOptions := options.FindOneAndUpdate().
+		SetProjection(bson.M{"payment_completion": 1}).
+		SetReturnDocument(options.After)
+
+	collection := m.db.Collection(m.CollectionName)
+	dbResource := collection.FindOneAndUpdate(context.Background(), filter, update, updateOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payment completion to claim", log.Data{"company_number": companyNumber, "reference": reference})
+			return nil, nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	return resource.PaymentCompletion, nil
+}
+
+// UpdatePaymentCompletion stores the steps still to be taken to complete the payable resource's payment
+func (m *MongoService) UpdatePaymentCompletion(companyNumber, reference string, completion *PaymentCompletionDao) error {
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"payment_completion", completion},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPaymentJobExists is returned when creating a payment job for a payable resource that already has one that has
+// not failed
+var ErrPaymentJobExists = errors.New("the payable resource already has a payment job")
+
+const (
+	// paymentJobStatusPending is the status of a payment job that is waiting for a worker
+	paymentJobStatusPending = "pending"
+	// paymentJobStatusProcessing is the status of a payment job that a worker has claimed
+	paymentJobStatusProcessing = "processing"
+	// paymentJobStatusFailed is the status of a payment job that could not be completed, which can be created again
+	paymentJobStatusFailed = "failed"
+)
+
+// paymentJobStatusField is the status of the payable resource's payment job
+const paymentJobStatusField = "payment_job.status"
+
+// PaymentJobDao is a payment that has been validated and is waiting to be completed by a worker. It is stored
+// alongside the payable resource rather than in the resource returned to clients, so the etag is not changed when it
+// is saved.
+type PaymentJobDao struct {
+	Status      string                        `bson:"status"`
+	Payment     validators.PaymentInformation `bson:"payment"`
+	Scheme      string                        `bson:"scheme"`
+	CallbackURL string                        `bson:"callback_url,omitempty"`
+	Error       string                        `bson:"error,omitempty"`
+	Attempts    int                           `bson:"attempts"`
+	Recorded    bool                          `bson:"recorded"`
+	CreatedAt   time.Time                     `bson:"created_at"`
+	UpdatedAt   time.Time                     `bson:"updated_at"`
+}
+
+// PaymentJobKey identifies the payable resource that a payment job belongs to
+type PaymentJobKey struct {
+	CompanyNumber string `bson:"company_number"`
+	Reference     string `bson:"reference"`
+}
+
+// CreatePaymentJob stores a new payment job for the payable resource. Only one payment job can be in progress at once,
+// so ErrPaymentJobExists is returned unless the resource has no payment job or its last one failed.
+func (m *MongoService) CreatePaymentJob(companyNumber, reference string, job *PaymentJobDao) error {
+	filter := bson.M{
+		"reference":      reference,
+		"company_number": companyNumber,
+		"$or": bson.A{
+			bson.M{"payment_job": bson.M{"$exists": false}},
+			bson.M{paymentJobStatusField: paymentJobStatusFailed},
+		},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"payment_job", job},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrPaymentJobExists
+	}
+
+	return nil
+}
+
+// ClaimPaymentJob marks the payable resource's payment job as being processed, so that no other worker processes it
+// at the same time, and returns it. A job can be claimed when it is pending, or when it was claimed before staleBefore
+// by a worker that has since stopped. nil is returned if there is no job that can be claimed.
+func (m *MongoService) ClaimPaymentJob(companyNumber, reference string, staleBefore time.Time) (*PaymentJobDao, error) {
+	var resource struct {
+		PaymentJob *PaymentJobDao `bson:"payment_job"`
+	}
+
+	filter := bson.M{
+		"reference":      reference,
+		"company_number": companyNumber,
+		"$or":            claimablePaymentJobs(staleBefore),
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{paymentJobStatusField, paymentJobStatusProcessing},
+				{"payment_job.updated_at", time.Now()},
+			},
+		},
+	}
+	updateOptions := options.FindOneAndUpdate().
+		SetProjection(bson.M{"payment_job": 1}).
+		SetReturnDocument(options.After)
+
+	collection := m.db.Collection(m.CollectionName)
+	dbResource := collection.FindOneAndUpdate(context.Background(), filter, update, updateOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payment job to claim", log.Data{"company_number": companyNumber, "reference": reference})
+			return nil, nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	return resource.PaymentJob, nil
+}
+
+// UpdatePaymentJob stores the latest state of the payable resource's payment job
+func (m *MongoService) UpdatePaymentJob(companyNumber, reference string, job *PaymentJobDao) error {
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"payment_job", job},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	return nil
+}
+
+// GetPaymentJob gets the payment job of the payable resource, or nil if it has none
+func (m *MongoService) GetPaymentJob(companyNumber, reference string) (*PaymentJobDao, error) {
+	var resource struct {
+		PaymentJob *PaymentJobDao `bson:"payment_job"`
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.FindOne().SetProjection(bson.M{"payment_job": 1})
+	dbResource := collection.FindOne(context.Background(), bson.M{"reference": reference, "company_number": companyNumber}, findOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payable resource found", log.Data{"company_number": companyNumber, "reference": reference})
+			return nil, nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	return resource.PaymentJob, nil
+}
+
+// ListClaimablePaymentJobs finds the payable resources whose payment job can be claimed, i.e. it is pending or it was
+// claimed before staleBefore by a worker that has since stopped
+func (m *MongoService) ListClaimablePaymentJobs(staleBefore time.Time) ([]PaymentJobKey, error) {
+	filter := bson.M{"$or": claimablePaymentJobs(staleBefore)}
+	findOptions := options.Find().SetProjection(bson.M{"company_number": 1, "reference": 1})
+
+	collection := m.db.Collection(m.CollectionName)
+
+	cursor, err := collection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	var keys []PaymentJobKey
+	err = cursor.All(context.Background(), &keys)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// claimablePaymentJobs matches the payment jobs that are pending, or that were claimed before staleBefore
+func claimablePaymentJobs(staleBefore time.Time) bson.A {
+	return bson.A{
+		bson.M{paymentJobStatusField: paymentJobStatusPending},
+		bson.M{
+			paymentJobStatusField:    paymentJobStatusProcessing,
+			"payment_job.updated_at": bson.M{"$lt": staleBefore},
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrRefundExists is returned when requesting a refund for a payable resource that already has one that has not
+// failed, including one whose outcome is not yet known
+var ErrRefundExists = errors.New("the payable resource already has a refund")
+
+const (
+	// refundStatusFailed is the status of a refund that the payment platform did not accept, which can be requested
+	// again
+	refundStatusFailed = "failed"
+	// refundStatusSubmitted is the status of a refund that the payment platform has accepted
+	refundStatusSubmitted = "submitted"
+	// e5ReversalPending is the E5 reversal status of a refund whose payment finance still have to reverse in E5
+	e5ReversalPending = "pending"
+)
+
+// RefundDao is a refund of the payment for a payable resource. It is stored alongside the payable resource rather than
+// in the resource returned to clients, so the etag is not changed when it is saved.
+type RefundDao struct {
+	Status            string     `bson:"status"`
+	Amount            string     `bson:"amount"`
+	Reason            string     `bson:"reason"`
+	PaymentID         string     `bson:"payment_id"`
+	RefundID          string     `bson:"refund_id,omitempty"`
+	FailureReason     string     `bson:"failure_reason,omitempty"`
+	E5ReversalStatus  string     `bson:"e5_reversal_status,omitempty"`
+	E5ReversedByID    string     `bson:"e5_reversed_by_id,omitempty"`
+	E5ReversedByEmail string     `bson:"e5_reversed_by_email,omitempty"`
+	E5ReversedAt      *time.Time `bson:"e5_reversed_at,omitempty"`
+	RequestedByID     string     `bson:"requested_by_id"`
+	RequestedByEmail  string     `bson:"requested_by_email"`
+	RequestedAt       time.Time  `bson:"requested_at"`
+	UpdatedAt         time.Time  `bson:"updated_at"`
+}
+
+// PendingE5ReversalDao is a refund whose payment is waiting to be reversed in E5, along with the payable resource it is
+// for
+type PendingE5ReversalDao struct {
+	CompanyNumber string    `bson:"company_number"`
+	Reference     string    `bson:"reference"`
+	Refund        RefundDao `bson:"refund"`
+}
+
+// CreateRefund stores a new refund for the payable resource. Only one refund can be in progress at once, so
+// ErrRefundExists is returned unless the resource has no refund or its last refund failed.
+func (m *MongoService) CreateRefund(companyNumber, reference string, refund *RefundDao) error {
+	filter := bson.M{
+		"reference":      reference,
+		"company_number": companyNumber,
+		"$or": bson.A{
+			bson.M{"refund": bson.M{"$exists": false}},
+			bson.M{"refund.status": refundStatusFailed},
+		},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"refund", refund},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrRefundExists
+	}
+
+	return nil
+}
+
+// UpdateRefund stores the latest state of the payable resource's refund
+func (m *MongoService) UpdateRefund(companyNumber, reference string, refund *RefundDao) error {
+	filter := bson.M{"reference": reference, "company_number": companyNumber}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"refund", refund},
+			},
+		},
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+
+	_, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return err
+	}
+
+	return nil
+}
+
+// GetRefund gets the refund of the payable resource, or nil if it has not been refunded
+func (m *MongoService) GetRefund(companyNumber, reference string) (*RefundDao, error) {
+	var resource struct {
+		Refund *RefundDao `bson:"refund"`
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.FindOne().SetProjection(bson.M{"refund": 1})
+	dbResource := collection.FindOne(context.Background(), bson.M{"reference": reference, "company_number": companyNumber}, findOptions)
+
+	err := dbResource.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Debug("no payable resource found", log.Data{"company_number": companyNumber, "reference": reference})
+			return nil, nil
+		}
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	err = dbResource.Decode(&resource)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference})
+		return nil, err
+	}
+
+	return resource.Refund, nil
+}
+
+// ListPendingE5Reversals gets the refunds of the penalty scheme's payable resources that the payment platform has
+// accepted but whose payments have not yet been reversed in E5, oldest first
+func (m *MongoService) ListPendingE5Reversals(scheme *config.PenaltyScheme) ([]PendingE5ReversalDao, error) {
+	filter := bson.M{
+		"$or":                       schemeDocumentsFilter(scheme, "/company/[^/]+"),
+		"refund.status":             refundStatusSubmitted,
+		"refund.e5_reversal_status": e5ReversalPending,
+	}
+
+	collection := m.db.Collection(m.CollectionName)
+	findOptions := options.Find().
+		SetProjection(bson.M{"company_number": 1, "reference": 1, "refund": 1}).
+		SetSort(bson.M{"refund.updated_at": 1})
+
+	cursor, err := collection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		log.Error(err, log.Data{"scheme": scheme.Name})
+		return nil, err
+	}
+
+	reversals := []PendingE5ReversalDao{}
+	err = cursor.All(context.Background(), &reversals)
+	if err != nil {
+		log.Error(err, log.Data{"scheme": scheme.Name})
+		return nil, err
+	}
+
+	return reversals, nil
+}
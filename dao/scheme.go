@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// payableResourceDocument is a payable resource as it is stored, along with the name of the penalty scheme it was
+// created under
+type payableResourceDocument struct {
+	models.PayableResourceDao `bson:",inline"`
+	Scheme                    string `bson:"scheme"`
+}
+
+// schemeFilter matches the company's payable resources that belong to the penalty scheme
+func schemeFilter(companyNumber string, scheme *config.PenaltyScheme) bson.M {
+	return bson.M{
+		"company_number": companyNumber,
+		"$or":            schemeDocumentsFilter(scheme, regexp.QuoteMeta("/company/"+companyNumber)),
+	}
+}
+
+// schemeDocumentsFilter gives the alternatives that match payable resources belonging to the penalty scheme. Resources
+// created before the scheme was stored are matched on their self link, which has always been under the scheme's
+// routes, below the company path matched by the companyPattern regular expression.
+func schemeDocumentsFilter(scheme *config.PenaltyScheme, companyPattern string) bson.A {
+	return bson.A{
+		bson.M{"scheme": scheme.Name},
+		bson.M{
+			"scheme":          bson.M{"$exists": false},
+			"data.links.self": bson.M{"$regex": "^" + companyPattern + regexp.QuoteMeta("/penalties/"+scheme.RoutePrefix+"/")},
+		},
+	}
+}
+
+// IsInScheme checks whether the payable resource belongs to the penalty scheme, so that a resource cannot be reached
+// through the routes of another scheme
+func (m *MongoService) IsInScheme(companyNumber, reference string, scheme *config.PenaltyScheme) (bool, error) {
+	filter := schemeFilter(companyNumber, scheme)
+	filter["reference"] = reference
+
+	collection := m.db.Collection(m.CollectionName)
+
+	count, err := collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": companyNumber, "reference": reference, "scheme": scheme.Name})
+		return false, err
+	}
+
+	return count > 0, nil
+}
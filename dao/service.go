@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"time"
+
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/e5"
@@ -8,14 +10,82 @@ import (
 
 // Service interface declares how to interact with the persistence layer regardless of underlying technology
 type Service interface {
-	// CreatePayableResource will persist a newly created resource
-	CreatePayableResource(dao *models.PayableResourceDao) error
+	// CreatePayableResource will persist a newly created resource, along with the penalty scheme it was created under
+	CreatePayableResource(dao *models.PayableResourceDao, scheme string) error
 	// GetPayableResource will find a single payable resource with the given companyNumber and reference
 	GetPayableResource(companyNumber, reference string) (*models.PayableResourceDao, error)
-	// UpdatePaymentDetails will update the resource with changed values
+	// IsInScheme checks whether the payable resource belongs to the penalty scheme
+	IsInScheme(companyNumber, reference string, scheme *config.PenaltyScheme) (bool, error)
+	// UpdatePaymentDetails will update the resource with changed values, provided it has not been modified since it
+	// was read
 	UpdatePaymentDetails(dao *models.PayableResourceDao) error
+	// MarkAsPaid will update the resource with its payment details and store the steps still to be taken to complete
+	// the payment, provided it has not been modified since it was read
+	MarkAsPaid(dao *models.PayableResourceDao, completion *PaymentCompletionDao) error
+	// ClaimPaymentCompletion marks the steps still to be taken to complete the resource's payment as being finished
+	// and returns them, or nil if there are none or they were claimed since staleBefore
+	ClaimPaymentCompletion(companyNumber, reference string, staleBefore time.Time) (*PaymentCompletionDao, error)
+	// UpdatePaymentCompletion stores the steps still to be taken to complete the resource's payment
+	UpdatePaymentCompletion(companyNumber, reference string, completion *PaymentCompletionDao) error
+	// SettleOffline will update the resource as paid outside of the service and record who did it and why, provided
+	// it has not been modified since it was read
+	SettleOffline(dao *models.PayableResourceDao, entry *AuditEntryDao) error
 	// SaveE5Error stored which command to E5 failed e.g. create, authorise or confirm
 	SaveE5Error(companyNumber, reference string, action e5.Action) error
+	// ClearE5Error removes the failed E5 command from the resource once the E5 payment is no longer in progress
+	ClearE5Error(companyNumber, reference string) error
+	// SaveE5PaymentID stores the id of the payment created in E5 for the resource
+	SaveE5PaymentID(companyNumber, reference, paymentID string) error
+	// GetE5PaymentID gets the id of the payment created in E5 for the resource, or an empty string if there is none
+	GetE5PaymentID(companyNumber, reference string) (string, error)
+	// SaveLanguage stores the language that the user that created the resource would like to be written to in
+	SaveLanguage(companyNumber, reference, lang string) error
+	// GetLanguage gets the language that the user that created the resource would like to be written to in, or an
+	// empty string if it is not known
+	GetLanguage(companyNumber, reference string) (string, error)
+	// CountE5CommandErrors counts the payable resources for a company in the penalty scheme that failed on one of the
+	// given E5 commands and have not since been completed in E5 or timed out
+	CountE5CommandErrors(companyNumber string, scheme *config.PenaltyScheme, actions []e5.Action) (int64, error)
+	// GetPayableResourcesForTransaction will find every payable resource for the company in the penalty scheme that
+	// includes the given transaction, newest first
+	GetPayableResourcesForTransaction(companyNumber string, scheme *config.PenaltyScheme, transactionID string) ([]models.PayableResourceDao, error)
+	// ListPayableResources will find the company's payable resources that match the filter, newest first, along with
+	// the total number that match
+	ListPayableResources(companyNumber string, filter PayableResourceListFilter) ([]models.PayableResourceDao, int64, error)
+	// ListPayableResourcesCreatedBy will find the payable resources created by the user that match the filter, newest
+	// first, along with the total number that match
+	ListPayableResourcesCreatedBy(userID string, filter PayableResourceListFilter) ([]models.PayableResourceDao, int64, error)
+	// SavePaymentCard stores how the payable resource was paid for
+	SavePaymentCard(companyNumber, reference string, card *PaymentCardDao) error
+	// GetPaymentCard gets how the payable resource was paid for, or nil if it is not known
+	GetPaymentCard(companyNumber, reference string) (*PaymentCardDao, error)
+	// CreateRefund stores a new refund for the resource, unless it already has one that has not failed
+	CreateRefund(companyNumber, reference string, refund *RefundDao) error
+	// UpdateRefund stores the latest state of the resource's refund
+	UpdateRefund(companyNumber, reference string, refund *RefundDao) error
+	// GetRefund gets the refund of the resource, or nil if it has not been refunded
+	GetRefund(companyNumber, reference string) (*RefundDao, error)
+	// RecordCancelledPayment stores a payment taken for the cancelled resource, unless one has already been recorded
+	RecordCancelledPayment(companyNumber, reference string, payment *CancelledPaymentDao) error
+	// GetCancelledPayment gets the payment taken for the cancelled resource, or nil if there is none
+	GetCancelledPayment(companyNumber, reference string) (*CancelledPaymentDao, error)
+	// ListUnrefundedCancelledPayments gets the payments taken for the scheme's cancelled resources that have not been
+	// refunded, oldest first
+	ListUnrefundedCancelledPayments(scheme *config.PenaltyScheme) ([]CancelledPaymentItemDao, error)
+	// ListPendingE5Reversals gets the refunds of the scheme's resources whose payments are waiting to be reversed in
+	// E5, oldest first
+	ListPendingE5Reversals(scheme *config.PenaltyScheme) ([]PendingE5ReversalDao, error)
+	// CreatePaymentJob stores a new payment job for the resource, unless it already has one that has not failed
+	CreatePaymentJob(companyNumber, reference string, job *PaymentJobDao) error
+	// ClaimPaymentJob marks the resource's payment job as being processed and returns it, or nil if it cannot be
+	// claimed because it is not pending and was claimed since staleBefore
+	ClaimPaymentJob(companyNumber, reference string, staleBefore time.Time) (*PaymentJobDao, error)
+	// UpdatePaymentJob stores the latest state of the resource's payment job
+	UpdatePaymentJob(companyNumber, reference string, job *PaymentJobDao) error
+	// GetPaymentJob gets the payment job of the resource, or nil if it has none
+	GetPaymentJob(companyNumber, reference string) (*PaymentJobDao, error)
+	// ListClaimablePaymentJobs finds the resources whose payment job is pending or was claimed before staleBefore
+	ListClaimablePaymentJobs(staleBefore time.Time) ([]PaymentJobKey, error)
 	// Shutdown can be called to clean up any open resources that the service may be holding on to.
 	Shutdown()
 }
@@ -24,8 +94,10 @@ type Service interface {
 // database driver will be hidden from outside of this package
 func NewDAOService(cfg *config.Config) Service {
 	database := getMongoDatabase(cfg.MongoDBURL, cfg.Database)
-	return &MongoService{
+	service := &MongoService{
 		db:             database,
 		CollectionName: cfg.MongoCollection,
 	}
+	service.ensureIndexes()
+	return service
 }
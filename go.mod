@@ -3,6 +3,7 @@ module github.com/companieshouse/lfp-pay-api
 go 1.19
 
 require (
+	github.com/Shopify/sarama v1.23.1
 	github.com/companieshouse/api-sdk-go v0.1.55
 	github.com/companieshouse/chs.go v1.2.8
 	github.com/companieshouse/filing-notification-sender v2.0.0-rc3+incompatible
@@ -21,7 +22,6 @@ require (
 
 require (
 	github.com/DataDog/zstd v1.3.6-0.20190409195224-796139022798 // indirect
-	github.com/Shopify/sarama v1.23.1 // indirect
 	github.com/companieshouse/envconf v0.1.4 // indirect
 	github.com/companieshouse/private-api-sdk-go v0.1.13 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/companieshouse/lfp-pay-api/validators"
+)
+
+// BulkPenaltySummaryRequest is the list of companies to summarise in a single request
+type BulkPenaltySummaryRequest struct {
+	CompanyNumbers []string `json:"company_numbers"`
+}
+
+// BulkPenaltySummaryResponse holds a result for each company in the request, in the same order
+type BulkPenaltySummaryResponse struct {
+	Kind  string                             `json:"kind"`
+	Items []service.BulkPenaltySummaryResult `json:"items"`
+}
+
+// BulkPenaltySummaryHandler summarises the penalty accounts of several companies at once for internal batch jobs.
+// The request is only rejected when it is malformed; companies that cannot be summarised are reported in their own
+// result.
+func BulkPenaltySummaryHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start POST bulk penalty summary request")
+
+		var request BulkPenaltySummaryRequest
+		err := json.NewDecoder(req.Body).Decode(&request)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("invalid request"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, "failed to read request body")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		maxCompanies := svc.BulkLookupMaxCompanies()
+		if len(request.CompanyNumbers) == 0 || len(request.CompanyNumbers) > maxCompanies {
+			log.InfoR(req, "invalid number of companies in bulk request", log.Data{"count": len(request.CompanyNumbers)})
+			m := utils.NewErrorResponsef(utils.ErrorCodeInvalidRequestBody, "between 1 and %d company numbers must be supplied", maxCompanies)
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		response := BulkPenaltySummaryResponse{
+			Kind:  scheme.ProductType + "#summaries",
+			Items: svc.GetPenaltySummaries(request.CompanyNumbers, scheme, validators.TransactionIsPayable),
+		}
+
+		utils.WriteJSON(w, req, response)
+
+		log.InfoR(req, "Successful POST request for bulk penalty summary", log.Data{"count": len(request.CompanyNumbers)})
+	})
+}
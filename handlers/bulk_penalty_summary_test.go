@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveBulkPenaltySummaryHandler(body string, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/penalties/late-filing/summaries", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), config.Scheme, testPenaltyScheme))
+	res := httptest.NewRecorder()
+
+	BulkPenaltySummaryHandler(svc).ServeHTTP(res, req)
+
+	return res
+}
+
+func TestUnitBulkPenaltySummaryHandler(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+
+	Convey("request body cannot be read", t, func() {
+		res := serveBulkPenaltySummaryHandler("{", &service.PayableResourceService{Config: cfg})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("no company numbers supplied", t, func() {
+		res := serveBulkPenaltySummaryHandler(`{"company_numbers": []}`, &service.PayableResourceService{Config: cfg})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("too many company numbers supplied", t, func() {
+		svc := &service.PayableResourceService{Config: &config.Config{BulkLookupMaxCompanies: 2}}
+		res := serveBulkPenaltySummaryHandler(`{"company_numbers": ["10000024", "10000025", "10000026"]}`, svc)
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("penalty scheme is not in the request context", t, func() {
+		req := httptest.NewRequest(http.MethodPost, "/penalties/late-filing/summaries", strings.NewReader(`{"company_numbers": ["10000024"]}`))
+		res := httptest.NewRecorder()
+		BulkPenaltySummaryHandler(&service.PayableResourceService{Config: cfg}).ServeHTTP(res, req)
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("each company has its own result", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01", httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+		svc := &service.PayableResourceService{Config: cfg}
+
+		res := serveBulkPenaltySummaryHandler(`{"company_numbers": ["10000024", "ABC12345"]}`, svc)
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+
+		var response BulkPenaltySummaryResponse
+		err := json.NewDecoder(res.Body).Decode(&response)
+		So(err, ShouldBeNil)
+		So(response.Kind, ShouldEqual, "late-filing-penalty#summaries")
+		So(response.Items, ShouldResemble, []service.BulkPenaltySummaryResult{
+			{CompanyNumber: "10000024", Error: "there was a problem summarising the penalties"},
+			{CompanyNumber: "ABC12345", Error: "invalid company number"},
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// CancelPayableResourceHandler abandons a pending payable resource so that it can no longer be paid, releasing the
+// company's account in E5 if an earlier payment attempt left it locked
+func CancelPayableResourceHandler(svc *service.PayableResourceService, e5Client *e5.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// get payable resource from context, put there by PayableResourceAuthenticationInterceptor
+		resource, ok := req.Context().Value(config.PayableResource).(*models.PayableResource)
+		if !ok {
+			log.ErrorR(req, fmt.Errorf("invalid PayableResource in request context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "the payable resource is not present in the request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		logData := log.Data{"lfp_reference": resource.Reference, "company_number": resource.CompanyNumber}
+
+		// the client can make the cancellation conditional on the version of the resource it holds
+		if utils.IsPreconditionFailed(req, resource.Etag) {
+			log.InfoR(req, "payable resource etag does not match If-Match header", logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceModified, "the payable resource has been modified")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusPreconditionFailed)
+			return
+		}
+
+		err = svc.CancelPayableResource(resource, e5Client, scheme)
+		switch err {
+		case nil:
+		case service.ErrAlreadyPaid, service.ErrAlreadyCancelled, service.ErrAlreadyRefunded, service.ErrPaymentInProgress:
+			log.InfoR(req, "payable resource cannot be cancelled: "+err.Error(), logData)
+			m := newErrorResponse(err, errorCodeResourceConflict, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusConflict)
+			return
+		case dao.ErrEtagMismatch:
+			log.InfoR(req, "payable resource modified whilst being cancelled", logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceModified, "the payable resource has been modified")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusPreconditionFailed)
+			return
+		default:
+			log.ErrorR(req, err, logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem cancelling the payable resource")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.InfoR(req, "Successful DELETE request for payable resource", logData)
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveCancelPayableResourceHandler(resource *models.PayableResource, ifMatch string, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/company/10000024/penalties/late-filing/payable/LP123456", nil)
+	ctx := context.WithValue(req.Context(), config.Scheme, testPenaltyScheme)
+	if resource != nil {
+		ctx = context.WithValue(ctx, config.PayableResource, resource)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	res := httptest.NewRecorder()
+
+	CancelPayableResourceHandler(svc, e5.NewClient("SYSTEM", "e5api")).ServeHTTP(res, req.WithContext(ctx))
+
+	return res
+}
+
+func TestUnitCancelPayableResourceHandler(t *testing.T) {
+	resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456", Etag: "etag"}
+
+	pending := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber: "10000024",
+			Reference:     "LP123456",
+			Data:          models.PayableResourceDataDao{Payment: models.PaymentDao{Status: "pending"}},
+		}
+	}
+
+	Convey("payable resource must be in context", t, func() {
+		res := serveCancelPayableResourceHandler(nil, "", &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("If-Match header must match the payable resource etag", t, func() {
+		res := serveCancelPayableResourceHandler(resource, `"oldetag"`, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusPreconditionFailed)
+	})
+
+	Convey("paid payable resource cannot be cancelled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		paid := pending()
+		paid.Data.Payment.Status = "paid"
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil)
+
+		res := serveCancelPayableResourceHandler(resource, "", &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusConflict)
+	})
+
+	Convey("payable resource modified whilst being cancelled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil)
+		mockService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+		mockService.EXPECT().UpdatePaymentDetails(gomock.Any()).Return(dao.ErrEtagMismatch)
+
+		res := serveCancelPayableResourceHandler(resource, "", &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusPreconditionFailed)
+	})
+
+	Convey("pending payable resource is cancelled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil)
+		mockService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+		mockService.EXPECT().UpdatePaymentDetails(gomock.Any()).Return(nil)
+
+		res := serveCancelPayableResourceHandler(resource, `"etag"`, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusNoContent)
+	})
+}
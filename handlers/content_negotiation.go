@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The content types that resources can be rendered as
+const (
+	contentTypeJSON = "application/json"
+	contentTypeHTML = "text/html"
+	contentTypeCSV  = "text/csv"
+	contentTypePDF  = "application/pdf"
+)
+
+// negotiateFormat returns the format requested by the client out of the formats supported, which are mapped to their
+// content types. The format query parameter takes precedence over the Accept header so that a rendering can be
+// downloaded from a plain link. The default format is returned when the client accepts anything, and an empty string
+// when it only asks for formats that are not supported.
+func negotiateFormat(req *http.Request, contentTypes map[string]string, defaultFormat string) string {
+	if format := req.URL.Query().Get("format"); format != "" {
+		if _, ok := contentTypes[format]; ok {
+			return format
+		}
+		return ""
+	}
+
+	accept := req.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return defaultFormat
+	}
+
+	// the first supported media type listed wins. quality values are not taken into account.
+	for _, mediaType := range strings.Split(accept, ",") {
+		mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0])
+		if mediaType == "*/*" {
+			return defaultFormat
+		}
+		for format, contentType := range contentTypes {
+			if mediaType == contentType {
+				return format
+			}
+		}
+	}
+
+	return ""
+}
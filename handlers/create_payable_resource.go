@@ -4,29 +4,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/companieshouse/chs.go/authentication"
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 	"github.com/companieshouse/lfp-pay-api/transformers"
 	"github.com/companieshouse/lfp-pay-api/utils"
 	"github.com/companieshouse/lfp-pay-api/validators"
-	"gopkg.in/go-playground/validator.v9"
 )
 
-// CreatePayableResourceHandler takes a http requests and creates a new payable resource
+// CreatePayableResourceHandler takes a http requests and creates a new payable resource. A dry run makes the same
+// checks and responds with the resource that would have been created, without creating it.
 func CreatePayableResourceHandler(svc dao.Service) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dryRun, err := isDryRun(r)
+		if err != nil {
+			log.ErrorR(r, fmt.Errorf("invalid query parameters: %v", err))
+			writeInvalidDryRun(w, r)
+			return
+		}
+
 		var request models.PayableRequest
-		err := json.NewDecoder(r.Body).Decode(&request)
+		err = json.NewDecoder(r.Body).Decode(&request)
 
 		// request body failed to get decoded
 		if err != nil {
 			log.ErrorR(r, fmt.Errorf("invalid request"))
-			m := models.NewMessageResponse("failed to read request body")
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, "failed to read request body")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
@@ -34,27 +41,40 @@ func CreatePayableResourceHandler(svc dao.Service) http.Handler {
 		userDetails := r.Context().Value(authentication.ContextKeyUserDetails)
 		if userDetails == nil {
 			log.ErrorR(r, fmt.Errorf("user details not in context"))
-			m := models.NewMessageResponse("user details not in request context")
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "user details not in request context")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
 			return
 		}
 
-		companyNumber := r.Context().Value(config.CompanyNumber)
-		if companyNumber == nil {
-			log.ErrorR(r, fmt.Errorf("company not in context"))
-			m := models.NewMessageResponse("company number not in request context")
+		companyNumber, err := config.GetCompanyNumber(r.Context())
+		if err != nil {
+			log.ErrorR(r, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "company number is not in request context")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
 			return
 		}
 
-		request.CompanyNumber = strings.ToUpper(companyNumber.(string))
+		request.CompanyNumber = companyNumber
 		request.CreatedBy = userDetails.(authentication.AuthUserDetails)
 
+		scheme, err := config.GetPenaltyScheme(r.Context())
+		if err != nil {
+			log.ErrorR(r, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
+			return
+		}
+
+		if dryRun {
+			dryRunCreatePayableResource(w, r, &request, scheme)
+			return
+		}
+
 		// validate that the transactions being requested do exist in E5
-		validTransactions, err := validators.TransactionsArePayable(request.CompanyNumber, request.Transactions)
+		validTransactions, err := validators.TransactionsArePayable(request.CompanyNumber, request.Transactions, scheme)
 		if err != nil {
 			log.ErrorR(r, fmt.Errorf("invalid request - failed matching against e5"))
-			m := models.NewMessageResponse("the transactions you want to pay for do not exist or are not payable at this time")
+			m := newErrorResponse(err, errorCodeNotPayable, "the transactions you want to pay for do not exist or are not payable at this time")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
@@ -62,26 +82,64 @@ func CreatePayableResourceHandler(svc dao.Service) http.Handler {
 		// validTransactions contains extra values that have been added from E5 validation so override request body transactions with validated transactions
 		request.Transactions = validTransactions
 
-		v := validator.New()
+		v := newValidator()
 		err = v.Struct(request)
 
 		if err != nil {
 			log.ErrorR(r, fmt.Errorf("invalid request - failed validation"))
-			m := models.NewMessageResponse("invalid request body")
+			m := newErrorResponse(err, utils.ErrorCodeInvalidRequestBody, "invalid request body")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
 
-		model := transformers.PayableResourceRequestToDB(&request)
+		model := transformers.PayableResourceRequestToDB(&request, scheme)
 
-		err = svc.CreatePayableResource(model)
+		err = svc.CreatePayableResource(model, scheme.Name)
 		if err != nil {
 			log.ErrorR(r, fmt.Errorf("failed to create payable request in database"))
-			m := models.NewMessageResponse("there was a problem handling your request")
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
 			return
 		}
 
+		// the emails about the resource are written in the language of the request that created it, which is only
+		// logged if it cannot be stored as the emails are then written in English
+		err = svc.SaveLanguage(model.CompanyNumber, model.Reference, i18n.FromRequest(r))
+		if err != nil {
+			log.ErrorR(r, fmt.Errorf("failed to store language of payable resource: [%v]", err))
+		}
+
 		utils.WriteJSONWithStatus(w, r, transformers.PayableResourceDaoToCreatedResponse(model), http.StatusCreated)
 	})
 }
+
+// dryRunCreatePayableResource makes every check made when creating a payable resource and responds with the resource
+// that would have been created, or with every check that failed. Nothing is stored, so the resource's reference is
+// not reserved.
+func dryRunCreatePayableResource(w http.ResponseWriter, r *http.Request, request *models.PayableRequest, scheme *config.PenaltyScheme) {
+	validTransactions, failures, err := validators.CheckTransactionsArePayable(request.CompanyNumber, request.Transactions, scheme)
+	if err != nil {
+		log.ErrorR(r, fmt.Errorf("failed checking transactions against e5: [%v]", err))
+		m := newErrorResponse(err, utils.ErrorCodeInternalError, "there was a problem checking the transactions")
+		utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
+		return
+	}
+
+	// the request body is validated with the transactions from E5, which are only complete when they all passed
+	if len(failures) == 0 {
+		request.Transactions = validTransactions
+		err = newValidator().Struct(request)
+		if err != nil {
+			failures = requestBodyFailures(err, i18n.FromRequest(r))
+		}
+	}
+
+	if len(failures) > 0 {
+		log.InfoR(r, "dry run of creating payable resource failed", log.Data{"company_number": request.CompanyNumber, "failures": len(failures)})
+		writeDryRun(w, r, nil, failures)
+		return
+	}
+
+	model := transformers.PayableResourceRequestToDB(request, scheme)
+	writeDryRun(w, r, transformers.PayableResourceDBToRequest(model), nil)
+}
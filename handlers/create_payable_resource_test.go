@@ -14,7 +14,9 @@ import (
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/utils"
 	"github.com/golang/mock/gomock"
 	"github.com/jarcoal/httpmock"
 	. "github.com/smartystreets/goconvey/convey"
@@ -31,8 +33,10 @@ func serveCreatePayableResourceHandler(body []byte, service dao.Service) *httpte
 	return res
 }
 
+var testPenaltyScheme = mocks.LateFilingScheme()
+
 func testContext() context.Context {
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), config.Scheme, testPenaltyScheme)
 	ctx = context.WithValue(ctx, authentication.ContextKeyUserDetails, authentication.AuthUserDetails{})
 	ctx = context.WithValue(ctx, config.CompanyNumber, "10000024")
 	return ctx
@@ -129,6 +133,10 @@ func TestUnitCreatePayableResourceHandler(t *testing.T) {
 		res := serveCreatePayableResourceHandler(body, mocks.NewMockService(mockCtrl))
 
 		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var response utils.ErrorResponse
+		So(json.NewDecoder(res.Body).Decode(&response), ShouldBeNil)
+		So(response.ErrorCode, ShouldEqual, utils.ErrorCodeInvalidRequestBody)
+		So(response.Field, ShouldEqual, "transactions")
 	})
 
 	Convey("Only allowed 1 transaction in a resource", t, func() {
@@ -152,6 +160,31 @@ func TestUnitCreatePayableResourceHandler(t *testing.T) {
 		res := serveCreatePayableResourceHandler(body, mockService)
 
 		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var response utils.ErrorResponse
+		So(json.NewDecoder(res.Body).Decode(&response), ShouldBeNil)
+		So(response.ErrorCode, ShouldEqual, "MULTIPLE_PENALTIES")
+		So(response.TransactionID, ShouldBeEmpty)
+	})
+
+	Convey("the transaction that cannot be paid for is returned with the error code", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		body, _ := json.Marshal(&models.PayableRequest{
+			Transactions: []models.TransactionItem{{TransactionID: "123", Amount: 150}},
+		})
+
+		res := serveCreatePayableResourceHandler(body, mocks.NewMockService(mockCtrl))
+
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var response utils.ErrorResponse
+		So(json.NewDecoder(res.Body).Decode(&response), ShouldBeNil)
+		So(response.ErrorCode, ShouldEqual, "TRANSACTION_NOT_FOUND")
+		So(response.TransactionID, ShouldEqual, "123")
 	})
 
 	Convey("internal server error when failing to create payable resource", t, func() {
@@ -164,7 +197,7 @@ func TestUnitCreatePayableResourceHandler(t *testing.T) {
 		mockService := mocks.NewMockService(mockCtrl)
 
 		// expect the CreatePayableResource to be called once and return an error
-		mockService.EXPECT().CreatePayableResource(gomock.Any()).Return(errors.New("any error"))
+		mockService.EXPECT().CreatePayableResource(gomock.Any(), "late-filing").Return(errors.New("any error"))
 
 		body, _ := json.Marshal(&models.PayableRequest{
 			CompanyNumber: "10000024",
@@ -189,7 +222,8 @@ func TestUnitCreatePayableResourceHandler(t *testing.T) {
 		mockService := mocks.NewMockService(mockCtrl)
 
 		// expect the CreatePayableResource to be called once and return without error
-		mockService.EXPECT().CreatePayableResource(gomock.Any()).Return(nil)
+		mockService.EXPECT().CreatePayableResource(gomock.Any(), "late-filing").Return(nil)
+		mockService.EXPECT().SaveLanguage("10000024", gomock.Any(), i18n.English).Return(nil)
 
 		body, _ := json.Marshal(&models.PayableRequest{
 			CompanyNumber: "10000024",
@@ -204,4 +238,168 @@ func TestUnitCreatePayableResourceHandler(t *testing.T) {
 		So(res.Code, ShouldEqual, http.StatusCreated)
 		So(res.Header().Get("Content-Type"), ShouldEqual, "application/json")
 	})
+
+	Convey("the language of the request is stored, and the resource is still created if it cannot be", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().CreatePayableResource(gomock.Any(), "late-filing").Return(nil)
+		mockService.EXPECT().SaveLanguage("10000024", gomock.Any(), i18n.Welsh).Return(errors.New("any error"))
+
+		body, _ := json.Marshal(&models.PayableRequest{
+			CompanyNumber: "10000024",
+			CreatedBy:     authentication.AuthUserDetails{},
+			Transactions: []models.TransactionItem{
+				{TransactionID: "00378420", Amount: 150, MadeUpDate: "2017-02-28", Type: "penalty"},
+			},
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/company/1000024/penalties/late-filing/payable", bytes.NewReader(body))
+		req.Header.Set("Accept-Language", "cy-GB, en;q=0.8")
+		res := httptest.NewRecorder()
+		CreatePayableResourceHandler(mockService).ServeHTTP(res, req.WithContext(testContext()))
+
+		So(res.Code, ShouldEqual, http.StatusCreated)
+	})
+
+	Convey("error messages are in the language of the request", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		req := httptest.NewRequest(http.MethodPost, "/company/1000024/penalties/late-filing/payable", bytes.NewReader([]byte("{")))
+		req.Header.Set("Accept-Language", "cy")
+		res := httptest.NewRecorder()
+		CreatePayableResourceHandler(mocks.NewMockService(mockCtrl)).ServeHTTP(res, req.WithContext(testContext()))
+
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		So(res.Header().Get("Content-Language"), ShouldEqual, i18n.Welsh)
+
+		var response utils.ErrorResponse
+		So(json.Unmarshal(res.Body.Bytes(), &response), ShouldBeNil)
+		So(response.ErrorCode, ShouldEqual, utils.ErrorCodeInvalidRequestBody)
+		So(response.Message, ShouldEqual, "methwyd â darllen corff y cais")
+	})
+}
+
+func TestUnitCreatePayableResourceHandlerDryRun(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+
+	url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+
+	serveDryRun := func(query string, body []byte, service dao.Service) *httptest.ResponseRecorder {
+		path := "/company/1000024/penalties/late-filing/payable?" + query
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		res := httptest.NewRecorder()
+		CreatePayableResourceHandler(service).ServeHTTP(res, req.WithContext(testContext()))
+		return res
+	}
+
+	Convey("dry_run must be a boolean", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		res := serveDryRun("dry_run=maybe", []byte("{}"), mocks.NewMockService(mockCtrl))
+
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var response utils.ErrorResponse
+		So(json.Unmarshal(res.Body.Bytes(), &response), ShouldBeNil)
+		So(response.ErrorCode, ShouldEqual, utils.ErrorCodeInvalidRequest)
+		So(response.Message, ShouldEqual, "dry_run must be true or false")
+	})
+
+	Convey("dry_run error is translated", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		req := httptest.NewRequest(http.MethodPost, "/company/1000024/penalties/late-filing/payable?dry_run=maybe", bytes.NewReader([]byte("{}")))
+		req.Header.Set("Accept-Language", "cy")
+		res := httptest.NewRecorder()
+		CreatePayableResourceHandler(mocks.NewMockService(mockCtrl)).ServeHTTP(res, req.WithContext(testContext()))
+
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var response utils.ErrorResponse
+		So(json.Unmarshal(res.Body.Bytes(), &response), ShouldBeNil)
+		So(response.Message, ShouldEqual, "rhaid i dry_run fod yn true neu false")
+	})
+
+	Convey("every failing check is returned and nothing is created", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5ResponseMultipleTx))
+
+		body, _ := json.Marshal(&models.PayableRequest{
+			Transactions: []models.TransactionItem{
+				{TransactionID: "00378420", Amount: 150},
+				{TransactionID: "123", Amount: 150},
+			},
+		})
+
+		res := serveDryRun("dry_run=true", body, mocks.NewMockService(mockCtrl))
+
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var response DryRunResponse
+		So(json.NewDecoder(res.Body).Decode(&response), ShouldBeNil)
+		So(response.Valid, ShouldBeFalse)
+		So(response.Failures, ShouldHaveLength, 2)
+		So(response.Failures[0].Check, ShouldEqual, "single-penalty")
+		So(response.Failures[1].Check, ShouldEqual, "transaction-exists")
+		So(response.Failures[1].TransactionID, ShouldEqual, "123")
+	})
+
+	Convey("a request without transactions fails the request body check", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		body, _ := json.Marshal(&models.PayableRequest{})
+		res := serveDryRun("dry_run=true", body, mocks.NewMockService(mockCtrl))
+
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var response DryRunResponse
+		So(json.NewDecoder(res.Body).Decode(&response), ShouldBeNil)
+		So(response.Failures, ShouldHaveLength, 1)
+		So(response.Failures[0].Check, ShouldEqual, "request-body")
+		So(response.Failures[0].Message, ShouldEqual, "transactions failed the required validation")
+	})
+
+	Convey("the would-be resource is returned and nothing is created", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		body, _ := json.Marshal(&models.PayableRequest{
+			Transactions: []models.TransactionItem{
+				{TransactionID: "00378420", Amount: 150},
+			},
+		})
+
+		res := serveDryRun("dry_run=true", body, mocks.NewMockService(mockCtrl))
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		var response struct {
+			Valid    bool                    `json:"valid"`
+			Resource *models.PayableResource `json:"resource"`
+		}
+		So(json.NewDecoder(res.Body).Decode(&response), ShouldBeNil)
+		So(response.Valid, ShouldBeTrue)
+		So(response.Resource.CompanyNumber, ShouldEqual, "10000024")
+		So(response.Resource.Transactions, ShouldHaveLength, 1)
+		So(response.Resource.Transactions[0].Type, ShouldEqual, "penalty")
+		So(response.Resource.Payment.Status, ShouldEqual, "pending")
+	})
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/i18n"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/companieshouse/lfp-pay-api/validators"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// dryRunParam is the query parameter that asks for a request to be validated without being acted on
+const dryRunParam = "dry_run"
+
+// invalidDryRunFormat is the message for a dry_run query parameter that is not a boolean, formatted with the parameter
+const invalidDryRunFormat = "%s must be true or false"
+
+// The checks made by a dry run that are not made on the transactions, as reported in a validators.CheckFailure
+const (
+	checkRequestBody    = "request-body"
+	checkNotCancelled   = "not-cancelled"
+	checkNotPaid        = "not-paid"
+	checkPaymentExists  = "payment-exists"
+	checkPaymentIsValid = "payment-valid"
+)
+
+// DryRunResponse is the outcome of validating a request without acting on it. A valid request has the resource that
+// it would have created or updated, and an invalid request has every check that it failed.
+type DryRunResponse struct {
+	Valid    bool                      `json:"valid"`
+	Resource interface{}               `json:"resource,omitempty"`
+	Failures []validators.CheckFailure `json:"failures,omitempty"`
+}
+
+// Localise translates the message of each failed check into the language
+func (d *DryRunResponse) Localise(lang string) {
+	for i := range d.Failures {
+		d.Failures[i].Message = i18n.Translate(lang, d.Failures[i].Message)
+	}
+}
+
+// isDryRun returns true if the request asks to be validated without being acted on. An error is returned if the
+// dry_run query parameter is not a boolean.
+func isDryRun(req *http.Request) (bool, error) {
+	value := req.URL.Query().Get(dryRunParam)
+	if value == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf(invalidDryRunFormat, dryRunParam)
+	}
+	return dryRun, nil
+}
+
+// writeInvalidDryRun responds with a 400 for a dry_run query parameter that is not a boolean, in the language of the
+// request
+func writeInvalidDryRun(w http.ResponseWriter, req *http.Request) {
+	m := utils.NewErrorResponsef(utils.ErrorCodeInvalidRequest, invalidDryRunFormat, dryRunParam)
+	utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+}
+
+// writeDryRun responds with the resource the request would have created or updated, or with a 400 and the checks it
+// failed
+func writeDryRun(w http.ResponseWriter, req *http.Request, resource interface{}, failures []validators.CheckFailure) {
+	if len(failures) > 0 {
+		utils.WriteJSONWithStatus(w, req, &DryRunResponse{Failures: failures}, http.StatusBadRequest)
+		return
+	}
+	utils.WriteJSON(w, req, &DryRunResponse{Valid: true, Resource: resource})
+}
+
+// requestBodyFailures reports each field of a request body that failed validation, described in the language
+func requestBodyFailures(err error, lang string) []validators.CheckFailure {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return []validators.CheckFailure{{Check: checkRequestBody, ErrorCode: utils.ErrorCodeInvalidRequestBody, Message: err.Error()}}
+	}
+
+	failures := make([]validators.CheckFailure, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		failures = append(failures, validators.CheckFailure{
+			Check:     checkRequestBody,
+			ErrorCode: utils.ErrorCodeInvalidRequestBody,
+			Message:   i18n.Sprintf(lang, "%s failed the %s validation", fieldPath(fieldErr), fieldErr.Tag()),
+		})
+	}
+	return failures
+}
+
+// resourceStateFailures reports a payable resource that cannot be paid for because it has been cancelled or paid, even
+// if the payment has since been refunded
+func resourceStateFailures(resource *models.PayableResource) []validators.CheckFailure {
+	switch resource.Payment.Status {
+	case constants.Paid.String(), service.PaymentStatusRefunded:
+		return []validators.CheckFailure{{Check: checkNotPaid, ErrorCode: errorCodeResourcePaid, Message: "the payable resource has already been paid"}}
+	case service.PaymentStatusCancelled:
+		return []validators.CheckFailure{{Check: checkNotCancelled, ErrorCode: errorCodeResourceCancelled, Message: "the payable resource has been cancelled"}}
+	}
+	return nil
+}
+
+// paymentNotFoundFailure reports a payment that could not be found on the payment platform
+func paymentNotFoundFailure() validators.CheckFailure {
+	return validators.CheckFailure{Check: checkPaymentExists, ErrorCode: errorCodePaymentNotFound, Message: "the payment does not exist"}
+}
+
+// invalidPaymentFailure reports a payment that cannot be used to pay for the payable resource, e.g. because it is for
+// a different amount
+func invalidPaymentFailure(err error) validators.CheckFailure {
+	return validators.CheckFailure{Check: checkPaymentIsValid, ErrorCode: errorCodePaymentInvalid, Message: err.Error()}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/companieshouse/lfp-pay-api/validators"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// The error codes of the errors that are specific to payable resources, payments and refunds
+const (
+	errorCodeResourcePaid         = "RESOURCE_PAID"
+	errorCodeResourceCancelled    = "RESOURCE_CANCELLED"
+	errorCodeResourceRefunded     = "RESOURCE_REFUNDED"
+	errorCodeResourceNotPaid      = "RESOURCE_NOT_PAID"
+	errorCodeResourceConflict     = "RESOURCE_CONFLICT"
+	errorCodePenaltyNotFound      = "PENALTY_NOT_FOUND"
+	errorCodeNotPayable           = "TRANSACTIONS_NOT_PAYABLE"
+	errorCodePaymentInProgress    = "PAYMENT_IN_PROGRESS"
+	errorCodePaymentNotPaid       = "PAYMENT_NOT_PAID"
+	errorCodePaymentNotFound      = "PAYMENT_NOT_FOUND"
+	errorCodePaymentInvalid       = "PAYMENT_INVALID"
+	errorCodePaymentJobInProgress = "PAYMENT_JOB_IN_PROGRESS"
+	errorCodePaymentJobNotFound   = "PAYMENT_JOB_NOT_FOUND"
+	errorCodeNotRefundable        = "NOT_REFUNDABLE"
+	errorCodeRefundRejected       = "REFUND_REJECTED"
+	errorCodeRefundNotFound       = "REFUND_NOT_FOUND"
+	errorCodeRefundUnknown        = "REFUND_UNKNOWN"
+	errorCodeE5ReversalNotPending = "E5_REVERSAL_NOT_PENDING"
+	errorCodeAccountNotFound      = "FINANCE_ACCOUNT_NOT_FOUND"
+)
+
+// errorCodes are the error codes of the errors from the services, DAO and E5 that a client can act on. The error codes
+// of the payability checks are reported by validators.NewCheckFailure.
+var errorCodes = map[error]string{
+	service.ErrAlreadyPaid:           errorCodeResourcePaid,
+	service.ErrAlreadyCancelled:      errorCodeResourceCancelled,
+	service.ErrPaidAfterCancellation: errorCodeResourceCancelled,
+	service.ErrAlreadyRefunded:       errorCodeResourceRefunded,
+	service.ErrNotPaid:               errorCodeResourceNotPaid,
+	service.ErrLFPNotFound:           utils.ErrorCodeResourceNotFound,
+	service.ErrPaymentInProgress:     errorCodePaymentInProgress,
+	service.ErrPaymentNotFulfilled:   errorCodePaymentNotPaid,
+	service.ErrPayment:               errorCodePaymentInvalid,
+	service.ErrPaymentJobInProgress:  errorCodePaymentJobInProgress,
+	service.ErrPaymentJobNotFound:    errorCodePaymentJobNotFound,
+	service.ErrNotRefundable:         errorCodeNotRefundable,
+	service.ErrRefundRejected:        errorCodeRefundRejected,
+	service.ErrRefundNotFound:        errorCodeRefundNotFound,
+	service.ErrRefundUnknown:         errorCodeRefundUnknown,
+	service.ErrE5ReversalNotPending:  errorCodeE5ReversalNotPending,
+	dao.ErrEtagMismatch:              utils.ErrorCodeResourceModified,
+	dao.ErrRefundExists:              errorCodeResourceRefunded,
+	dao.ErrPaymentJobExists:          errorCodePaymentJobInProgress,
+	e5.ErrE5NotFound:                 errorCodeAccountNotFound,
+	e5.ErrE5BadRequest:               utils.ErrorCodeFinanceSystemError,
+	e5.ErrE5InternalServer:           utils.ErrorCodeFinanceSystemError,
+	e5.ErrUnexpectedServerError:      utils.ErrorCodeFinanceSystemError,
+	e5.ErrFailedToReadBody:           utils.ErrorCodeFinanceDataInvalid,
+}
+
+// newErrorResponse describes the error in an error response with the supplied message. The error code is the one for
+// the error, or the supplied code if it has none, e.g. because it is unexpected. A failed payability check has the
+// check's own message and the transaction that failed, and a request body that failed validation has the field that
+// failed.
+func newErrorResponse(err error, code, message string) *utils.ErrorResponse {
+	m := utils.NewErrorResponse(code, message)
+	if err == nil {
+		return m
+	}
+
+	for e, c := range errorCodes {
+		if errors.Is(err, e) {
+			m.ErrorCode = c
+			return m
+		}
+	}
+
+	if failure := validators.NewCheckFailure(err); failure.ErrorCode != "" {
+		m.ErrorCode = failure.ErrorCode
+		m.Message = failure.Message
+		m.TransactionID = failure.TransactionID
+		return m
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) && len(fieldErrs) > 0 {
+		m.ErrorCode = utils.ErrorCodeInvalidRequestBody
+		m.Field = fieldPath(fieldErrs[0])
+	}
+
+	return m
+}
+
+// newValidator returns a validator that names the fields that fail validation as they are named in the request body
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// fieldPath is the path of the field that failed validation within the request body, e.g. transactions[0].amount
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
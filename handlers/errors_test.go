@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitNewErrorResponse(t *testing.T) {
+	Convey("the error code is mapped from service, DAO and E5 errors", t, func() {
+		So(newErrorResponse(service.ErrAlreadyPaid, utils.ErrorCodeInternalError, "paid").ErrorCode, ShouldEqual, errorCodeResourcePaid)
+		So(newErrorResponse(dao.ErrEtagMismatch, utils.ErrorCodeInternalError, "modified").ErrorCode, ShouldEqual, utils.ErrorCodeResourceModified)
+		So(newErrorResponse(e5.ErrE5InternalServer, utils.ErrorCodeInternalError, "e5").ErrorCode, ShouldEqual, utils.ErrorCodeFinanceSystemError)
+	})
+
+	Convey("wrapped errors are mapped", t, func() {
+		err := fmt.Errorf("getting transactions: %w", e5.ErrE5NotFound)
+
+		So(newErrorResponse(err, utils.ErrorCodeInternalError, "e5").ErrorCode, ShouldEqual, errorCodeAccountNotFound)
+	})
+
+	Convey("the supplied error code and message are used for an unexpected error", t, func() {
+		m := newErrorResponse(errors.New("boom"), utils.ErrorCodeInternalError, "there was a problem")
+
+		So(m, ShouldResemble, &utils.ErrorResponse{ErrorCode: utils.ErrorCodeInternalError, Message: "there was a problem"})
+	})
+
+	Convey("a request body that fails validation names the field that failed", t, func() {
+		request := struct {
+			Reference string `json:"reference" validate:"required"`
+		}{}
+		err := newValidator().Struct(request)
+
+		m := newErrorResponse(err, utils.ErrorCodeInvalidRequestBody, "invalid request body")
+
+		So(m.ErrorCode, ShouldEqual, utils.ErrorCodeInvalidRequestBody)
+		So(m.Field, ShouldEqual, "reference")
+	})
+}
@@ -17,7 +17,7 @@ func HandleHealthCheckFinanceSystem(w http.ResponseWriter, r *http.Request) {
 	cfg, err := config.Get()
 	if err != nil {
 		log.ErrorR(r, fmt.Errorf("error returning config: [%v]", err))
-		m := models.NewMessageResponse("failed to get maintenance times from config")
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "failed to get maintenance times from config")
 		utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/authentication"
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// OfflineSettlementRequest is the body of a request to mark a payable resource as paid outside of the service
+type OfflineSettlementRequest struct {
+	Reason            string `json:"reason" validate:"required"`
+	ExternalReference string `json:"external_reference" validate:"required"`
+}
+
+// SettleOfflineHandler marks a payable resource as paid when finance staff have taken the payment outside of the
+// service, e.g. by cheque or bank transfer. The payment is already in E5 so no payment is sent to it, but a lock left on
+// the account by an earlier attempt to pay online is released.
+func SettleOfflineHandler(svc *service.PayableResourceService, e5Client *e5.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start POST offline payment request")
+
+		vars := mux.Vars(req)
+		companyNumber, err := config.GetCompanyNumber(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "company number is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		userDetails, ok := req.Context().Value(authentication.ContextKeyUserDetails).(authentication.AuthUserDetails)
+		if !ok || userDetails.ID == "" {
+			log.ErrorR(req, fmt.Errorf("user details not in context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "user details not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		var request OfflineSettlementRequest
+		err = json.NewDecoder(req.Body).Decode(&request)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("invalid request"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, "failed to read request body")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		err = newValidator().Struct(request)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("invalid request - failed validation: %v", err))
+			m := newErrorResponse(err, utils.ErrorCodeInvalidRequestBody, "invalid request body")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		resource, responseType, err := svc.GetPayableResource(req, companyNumber, vars["payable_id"])
+		switch {
+		case err != nil:
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		case responseType == service.NotFound:
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceNotFound, "payable resource not found")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		}
+
+		logData := log.Data{
+			"lfp_reference":      resource.Reference,
+			"company_number":     resource.CompanyNumber,
+			"external_reference": request.ExternalReference,
+		}
+
+		// the settlement can be made conditional on the version of the resource the member of staff looked at
+		if utils.IsPreconditionFailed(req, resource.Etag) {
+			log.InfoR(req, "payable resource etag does not match If-Match header", logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceModified, "the payable resource has been modified")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusPreconditionFailed)
+			return
+		}
+
+		err = svc.SettleOffline(resource, service.OfflineSettlement{
+			Reason:            request.Reason,
+			ExternalReference: request.ExternalReference,
+			UserID:            userDetails.ID,
+			Email:             userDetails.Email,
+		}, e5Client, scheme)
+		switch err {
+		case nil:
+		case service.ErrInvalidExternalReference:
+			log.InfoR(req, "payable resource cannot be settled offline: "+err.Error(), logData)
+			m := newErrorResponse(err, utils.ErrorCodeInvalidRequestBody, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		case service.ErrAlreadyPaid, service.ErrAlreadyCancelled, service.ErrAlreadyRefunded, service.ErrPaymentInProgress:
+			log.InfoR(req, "payable resource cannot be settled offline: "+err.Error(), logData)
+			m := newErrorResponse(err, errorCodeResourceConflict, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusConflict)
+			return
+		case dao.ErrEtagMismatch:
+			log.InfoR(req, "payable resource modified whilst being settled offline", logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceModified, "the payable resource has been modified")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusPreconditionFailed)
+			return
+		default:
+			log.ErrorR(req, err, logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem settling the payable resource")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.InfoR(req, "Successful POST request for offline payment", logData)
+	})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/companieshouse/chs.go/authentication"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveSettleOfflineHandler(body string, ifMatch string, userDetails interface{}, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/company/10000024/penalties/late-filing/payable/LP123456/offline-payment", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"payable_id": "LP123456"})
+	ctx := context.WithValue(req.Context(), config.Scheme, testPenaltyScheme)
+	ctx = context.WithValue(ctx, config.CompanyNumber, "10000024")
+	if userDetails != nil {
+		ctx = context.WithValue(ctx, authentication.ContextKeyUserDetails, userDetails)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	res := httptest.NewRecorder()
+
+	SettleOfflineHandler(svc, e5.NewClient("SYSTEM", "e5api")).ServeHTTP(res, req.WithContext(ctx))
+
+	return res
+}
+
+func TestUnitSettleOfflineHandler(t *testing.T) {
+	user := authentication.AuthUserDetails{ID: "abc", Email: "finance@example.com"}
+	body := `{"reason":"paid by cheque","external_reference":"LP0001234"}`
+
+	pending := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber: "10000024",
+			Reference:     "LP123456",
+			Data: models.PayableResourceDataDao{
+				Etag:    "etag",
+				Payment: models.PaymentDao{Status: "pending"},
+			},
+		}
+	}
+
+	Convey("no user details in context", t, func() {
+		res := serveSettleOfflineHandler(body, "", nil, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("request body cannot be read", t, func() {
+		res := serveSettleOfflineHandler("{", "", user, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("reason is required", t, func() {
+		res := serveSettleOfflineHandler(`{"external_reference":"LP0001234"}`, "", user, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("external reference is required", t, func() {
+		res := serveSettleOfflineHandler(`{"reason":"paid by cheque"}`, "", user, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("error getting the payable resource", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(nil, errors.New("any error"))
+
+		res := serveSettleOfflineHandler(body, "", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("payable resource not found", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(nil, nil)
+
+		res := serveSettleOfflineHandler(body, "", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("If-Match header must match the payable resource etag", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil)
+		mockService.EXPECT().IsInScheme("10000024", "LP123456", testPenaltyScheme).Return(true, nil)
+
+		res := serveSettleOfflineHandler(body, `"oldetag"`, user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusPreconditionFailed)
+	})
+
+	Convey("paid payable resource cannot be settled offline", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		paid := pending()
+		paid.Data.Payment.Status = "paid"
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil).Times(2)
+		mockService.EXPECT().IsInScheme("10000024", "LP123456", testPenaltyScheme).Return(true, nil)
+
+		res := serveSettleOfflineHandler(body, "", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusConflict)
+	})
+
+	Convey("external reference must be an e5 payment id for the penalty scheme", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil)
+		mockService.EXPECT().IsInScheme("10000024", "LP123456", testPenaltyScheme).Return(true, nil)
+
+		res := serveSettleOfflineHandler(`{"reason":"paid by cheque","external_reference":"CHQ-0001"}`, "", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		So(res.Body.String(), ShouldContainSubstring, service.ErrInvalidExternalReference.Error())
+	})
+
+	Convey("payable resource with a payment in progress in e5 cannot be settled offline", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil).Times(2)
+		mockService.EXPECT().IsInScheme("10000024", "LP123456", testPenaltyScheme).Return(true, nil)
+		mockService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("X123", nil)
+
+		res := serveSettleOfflineHandler(body, "", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusConflict)
+	})
+
+	Convey("payable resource modified whilst being settled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil).Times(2)
+		mockService.EXPECT().IsInScheme("10000024", "LP123456", testPenaltyScheme).Return(true, nil)
+		mockService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+		mockService.EXPECT().SettleOffline(gomock.Any(), gomock.Any()).Return(dao.ErrEtagMismatch)
+
+		res := serveSettleOfflineHandler(body, "", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusPreconditionFailed)
+	})
+
+	Convey("error saving the settlement", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil).Times(2)
+		mockService.EXPECT().IsInScheme("10000024", "LP123456", testPenaltyScheme).Return(true, nil)
+		mockService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+		mockService.EXPECT().SettleOffline(gomock.Any(), gomock.Any()).Return(errors.New("any error"))
+
+		res := serveSettleOfflineHandler(body, "", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("payable resource settled offline", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil).Times(2)
+		mockService.EXPECT().IsInScheme("10000024", "LP123456", testPenaltyScheme).Return(true, nil)
+		mockService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+		mockService.EXPECT().SettleOffline(gomock.Any(), gomock.Any()).Return(nil)
+
+		res := serveSettleOfflineHandler(body, `"etag"`, user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusNoContent)
+	})
+}
@@ -4,60 +4,97 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"sync"
 
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api-core/validators"
 	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 	"github.com/companieshouse/lfp-pay-api/service"
 	"github.com/companieshouse/lfp-pay-api/utils"
-	"gopkg.in/go-playground/validator.v9"
 )
 
-// handleEmailKafkaMessage allows us to mock the call to sendEmailKafkaMessage for unit tests
-var handleEmailKafkaMessage = service.SendEmailKafkaMessage
+// completePayment allows us to mock completing the payment for unit tests
+var completePayment = (*service.PayableResourceService).CompletePayment
 
-var wg sync.WaitGroup
+// payResourceRequest is the body of a request to pay for a payable resource. The callback URL is only used when the
+// payment is completed in the background.
+type payResourceRequest struct {
+	models.PatchResourceRequest
+	CallbackURL string `json:"callback_url" validate:"omitempty,url"`
+}
 
 // PayResourceHandler will update the resource to mark it as paid and also tell the finance system that the
-// transaction(s) associated with it are paid.
-func PayResourceHandler(svc *service.PayableResourceService, e5Client *e5.Client) http.Handler {
+// transaction(s) associated with it are paid. A client that prefers an asynchronous response is instead answered
+// once the payment has been validated, and the payment is completed by the job pool. A dry run only validates the
+// payment and responds with the resource as it would be once paid.
+func PayResourceHandler(svc *service.PayableResourceService, e5Client *e5.Client, jobs *service.PaymentJobPool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dryRun, err := isDryRun(r)
+		if err != nil {
+			log.ErrorR(r, fmt.Errorf("invalid query parameters: %v", err))
+			writeInvalidDryRun(w, r)
+			return
+		}
+
 		// 1. get the payable resource our of the context. authorisation is already handled in the interceptor
 		i := r.Context().Value(config.PayableResource)
 		if i == nil {
 			err := fmt.Errorf("no payable resource in context. check PayableAuthenticationInterceptor is installed")
 			log.ErrorR(r, err)
-			m := models.NewMessageResponse("no payable request present in request context")
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "no payable request present in request context")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
 
 		resource := i.(*models.PayableResource)
 
+		scheme, err := config.GetPenaltyScheme(r.Context())
+		if err != nil {
+			log.ErrorR(r, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
+			return
+		}
+
 		log.Info("processing LFP payment", log.Data{
 			"lfp_reference":  resource.Reference,
 			"company_number": resource.CompanyNumber,
 		})
 
+		// the client can make the update conditional on the version of the resource it holds
+		if utils.IsPreconditionFailed(r, resource.Etag) {
+			log.InfoR(r, "payable resource etag does not match If-Match header", log.Data{
+				"lfp_reference": resource.Reference,
+				"etag":          resource.Etag,
+			})
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceModified, "the payable resource has been modified")
+			utils.WriteJSONWithStatus(w, r, m, http.StatusPreconditionFailed)
+			return
+		}
+
+		if dryRun {
+			dryRunPayResource(w, r, resource)
+			return
+		}
+
 		// 2. validate the request and check the reference number against the payment api to validate that is has
 		// actually been paid
-		var request models.PatchResourceRequest
-		err := json.NewDecoder(r.Body).Decode(&request)
+		var request payResourceRequest
+		err = json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
 			log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference})
-			m := models.NewMessageResponse("there was a problem reading the request body")
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, "there was a problem reading the request body")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
-		v := validator.New()
+		v := newValidator()
 		err = v.Struct(request)
 
 		if err != nil {
 			log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference, "payment_id": request.Reference})
-			m := models.NewMessageResponse("the request contained insufficient data and/or failed validation")
+			m := newErrorResponse(err, utils.ErrorCodeInvalidRequestBody, "the request contained insufficient data and/or failed validation")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
@@ -65,73 +102,121 @@ func PayResourceHandler(svc *service.PayableResourceService, e5Client *e5.Client
 		payment, err := service.GetPaymentInformation(request.Reference, r)
 		if err != nil {
 			log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference, "payment_id": request.Reference})
-			m := models.NewMessageResponse("the payable resource does not exist")
+			m := utils.NewErrorResponse(errorCodePaymentNotFound, "the payable resource does not exist")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
 
 		err = validators.New().ValidateForPayment(*resource, *payment)
 		if err != nil {
-			m := models.NewMessageResponse("there was a problem validating this payment")
+			m := utils.NewErrorResponse(errorCodePaymentInvalid, "there was a problem validating this payment")
 			utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 			return
 		}
 
-		wg.Add(3)
-
-		go sendConfirmationEmail(resource, payment, r, w)
-		go updateDatabase(resource, payment, svc, r, w)
-		go updateE5(e5Client, resource, payment, svc, r, w)
+		if jobs != nil && utils.PrefersAsync(r) {
+			submitPaymentJob(w, r, jobs, resource, payment, scheme, request.CallbackURL)
+			return
+		}
 
-		wg.Wait()
+		// the resource is marked as paid before E5 is updated so that a payment completed more than once, e.g. by both this
+		// callback and the payment-processed message, is only posted to E5 once. a step that fails is finished when the
+		// payment is completed again.
+		err = completePayment(svc, e5Client, *resource, *payment, scheme, r)
+		if err == service.ErrPaidAfterCancellation {
+			// the card has been charged for a resource that was cancelled, so the payment has been recorded to be refunded
+			m := newErrorResponse(err, errorCodeResourceCancelled, "the payable resource has been cancelled and the payment will be refunded")
+			utils.WriteJSONWithStatus(w, r, m, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.ErrorR(r, err, log.Data{
+				"lfp_reference":  resource.Reference,
+				"company_number": resource.CompanyNumber,
+				"payment_id":     payment.Reference,
+			})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNoContent) // This will not be set if status has already been set
 	})
 }
-func sendConfirmationEmail(resource *models.PayableResource, payment *validators.PaymentInformation, r *http.Request, w http.ResponseWriter) {
-	// Send confirmation email
-	defer wg.Done()
-	err := handleEmailKafkaMessage(*resource, r)
+
+// dryRunPayResource makes every check made when paying for a payable resource and responds with the resource as it
+// would be once paid, or with every check that failed. Nothing is stored, sent to E5 or emailed.
+func dryRunPayResource(w http.ResponseWriter, r *http.Request, resource *models.PayableResource) {
+	failures := resourceStateFailures(resource)
+
+	var request payResourceRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
-		log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference, "payment_id": payment.Reference})
-		w.WriteHeader(http.StatusInternalServerError)
+		log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference})
+		m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, "there was a problem reading the request body")
+		utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
 		return
 	}
 
-	log.Info("confirmation email sent to customer", log.Data{
-		"lfp_reference":  resource.Reference,
-		"company_number": resource.CompanyNumber,
-		"email_address":  resource.CreatedBy.Email,
-	})
-}
+	err = newValidator().Struct(request)
+	if err != nil {
+		writeDryRun(w, r, nil, append(failures, requestBodyFailures(err, i18n.FromRequest(r))...))
+		return
+	}
 
-func updateDatabase(resource *models.PayableResource, payment *validators.PaymentInformation, svc *service.PayableResourceService, r *http.Request, w http.ResponseWriter) {
-	// Update the payable resource in the db
-	defer wg.Done()
-	err := svc.UpdateAsPaid(*resource, *payment)
+	payment, err := service.GetPaymentInformation(request.Reference, r)
 	if err != nil {
-		log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference, "payment_id": payment.Reference})
-		w.WriteHeader(http.StatusInternalServerError)
+		log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference, "payment_id": request.Reference})
+		writeDryRun(w, r, nil, append(failures, paymentNotFoundFailure()))
 		return
 	}
 
-	log.Info("payment resource is now marked as paid in db", log.Data{
-		"lfp_reference":  resource.Reference,
-		"company_number": resource.CompanyNumber,
-	})
+	err = validators.New().ValidateForPayment(*resource, *payment)
+	if err != nil {
+		failures = append(failures, invalidPaymentFailure(err))
+	}
+
+	if len(failures) > 0 {
+		log.InfoR(r, "dry run of paying for payable resource failed", log.Data{"lfp_reference": resource.Reference, "failures": len(failures)})
+		writeDryRun(w, r, nil, failures)
+		return
+	}
+
+	// the payment details are those that would be stored when the resource is marked as paid
+	paid := *resource
+	paid.Payment.Reference = payment.Reference
+	paid.Payment.Status = payment.Status
+	paid.Payment.PaidAt = &payment.CompletedAt
+	paid.Payment.Amount = payment.Amount
+
+	writeDryRun(w, r, &paid, nil)
 }
 
-func updateE5(e5Client *e5.Client, resource *models.PayableResource, payment *validators.PaymentInformation, svc *service.PayableResourceService, r *http.Request, w http.ResponseWriter) {
-	// Mark the resource as paid in e5
-	defer wg.Done()
-	err := service.MarkTransactionsAsPaid(svc, e5Client, *resource, *payment)
-	if err != nil {
-		log.ErrorR(r, err, log.Data{
-			"lfp_reference":  resource.Reference,
-			"company_number": resource.CompanyNumber,
-		})
-		w.WriteHeader(http.StatusInternalServerError)
+// submitPaymentJob stores a job to complete the validated payment in the background and responds with where its
+// progress can be followed
+func submitPaymentJob(w http.ResponseWriter, r *http.Request, jobs *service.PaymentJobPool, resource *models.PayableResource, payment *validators.PaymentInformation, scheme *config.PenaltyScheme, callbackURL string) {
+	job, err := jobs.Submit(resource, payment, scheme, callbackURL)
+	switch err {
+	case nil:
+	case service.ErrPaymentJobInProgress:
+		m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+		utils.WriteJSONWithStatus(w, r, m, http.StatusConflict)
+		return
+	case service.ErrCallbackNotAllowed:
+		log.InfoR(r, "payment job callback URL is not allowed", log.Data{"lfp_reference": resource.Reference, "callback_url": callbackURL})
+		m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, err.Error())
+		utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
+		return
+	default:
+		log.ErrorR(r, err, log.Data{"lfp_reference": resource.Reference, "payment_id": payment.Reference})
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem submitting the payment")
+		utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
 		return
 	}
+
+	log.InfoR(r, "payment job submitted", log.Data{"lfp_reference": resource.Reference, "payment_id": payment.Reference})
+
+	w.Header().Set("Location", job.Links.Self)
+	w.Header().Set("Preference-Applied", utils.PreferRespondAsync)
+	utils.WriteJSONWithStatus(w, r, job, http.StatusAccepted)
 }
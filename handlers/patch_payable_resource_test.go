@@ -13,8 +13,8 @@ import (
 	"github.com/companieshouse/api-sdk-go/companieshouseapi"
 	"github.com/companieshouse/go-session-handler/httpsession"
 	"github.com/companieshouse/go-session-handler/session"
-	"github.com/companieshouse/lfp-pay-api-core/constants"
 	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
 	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/dao"
 	"github.com/companieshouse/lfp-pay-api/e5"
@@ -65,8 +65,9 @@ func dispatchPayResourceHandler(
 	}
 
 	ctx = context.WithValue(ctx, httpsession.ContextKeySession, &session.Session{})
+	ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
 
-	h := PayResourceHandler(svc, e5.NewClient("foo", "e5api"))
+	h := PayResourceHandler(svc, e5.NewClient("foo", "e5api"), nil)
 	req := httptest.NewRequest(http.MethodPost, "/", body).WithContext(ctx)
 	res := httptest.NewRecorder()
 
@@ -85,14 +86,16 @@ func dispatchPayResourceHandler(
 	return res, nil
 }
 
-// Mock function for erroring when preparing and sending kafka message
-func mockSendEmailKafkaMessageError(payableResource models.PayableResource, req *http.Request) error {
-	return errors.New("error")
-}
-
-// Mock function for successful preparing and sending of kafka message
-func mockSendEmailKafkaMessage(payableResource models.PayableResource, req *http.Request) error {
-	return nil
+// stubCompletePayment replaces completing the payment with one that records the payment and returns err, for the
+// duration of a test
+func stubCompletePayment(err error, completed **validators.PaymentInformation) func() {
+	completePayment = func(_ *service.PayableResourceService, _ *e5.Client, _ models.PayableResource, payment validators.PaymentInformation, _ *config.PenaltyScheme, _ *http.Request) error {
+		*completed = &payment
+		return err
+	}
+	return func() {
+		completePayment = (*service.PayableResourceService).CompletePayment
+	}
 }
 
 func TestUnitPayResourceHandler(t *testing.T) {
@@ -107,6 +110,21 @@ func TestUnitPayResourceHandler(t *testing.T) {
 			So(body.Message, ShouldEqual, "no payable request present in request context")
 		})
 
+		Convey("If-Match header must match the payable resource etag for a dry run", func() {
+			ctx := context.WithValue(context.Background(), config.PayableResource, &models.PayableResource{Etag: "etag"})
+			ctx = context.WithValue(ctx, httpsession.ContextKeySession, &session.Session{})
+			ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
+
+			b, _ := json.Marshal(&models.PatchResourceRequest{Reference: "123"})
+			req := httptest.NewRequest(http.MethodPatch, "/?dry_run=true", bytes.NewReader(b)).WithContext(ctx)
+			req.Header.Set("If-Match", `"oldetag"`)
+			res := httptest.NewRecorder()
+
+			PayResourceHandler(&service.PayableResourceService{}, e5.NewClient("foo", "e5api"), nil).ServeHTTP(res, req)
+
+			So(res.Code, ShouldEqual, http.StatusPreconditionFailed)
+		})
+
 		Convey("reference is required in request body", func() {
 			ctx := context.WithValue(context.Background(), config.PayableResource, &models.PayableResource{})
 			res, body := dispatchPayResourceHandler(ctx, t, &models.PatchResourceRequest{}, nil)
@@ -163,196 +181,316 @@ func TestUnitPayResourceHandler(t *testing.T) {
 			So(body.Message, ShouldEqual, "there was a problem validating this payment")
 		})
 
-		Convey("problem with sending confirmation email", func() {
-			mockCtrl := gomock.NewController(t)
-			defer mockCtrl.Finish()
-
-			// stub the response from the payments api
-			p := &companieshouseapi.PaymentResource{Status: "paid", Amount: "0", Reference: "late_filing_penalty_123"}
+		// stubs the response from the payments api for a payment that has been taken
+		stubPaidPayment := func() {
+			p := &companieshouseapi.PaymentResource{
+				Status:    "paid",
+				Amount:    "150",
+				Reference: "late_filing_penalty_123",
+				CreatedBy: companieshouseapi.CreatedBy{
+					Email: "test@example.com",
+				},
+			}
 			responder, _ := httpmock.NewJsonResponder(http.StatusOK, p)
 			httpmock.RegisterResponder(
 				http.MethodGet,
 				companieshouseapi.PaymentsBasePath+"/payments/123",
 				responder,
 			)
-
 			httpmock.RegisterResponder(
 				http.MethodGet,
 				companieshouseapi.PaymentsBasePath+"/private/payments/123/payment-details",
 				httpmock.NewStringResponder(http.StatusOK, "{}"),
 			)
+		}
 
-			// stub the mongo lookup
-			dataModel := &models.PayableResourceDao{}
-			mockService := mocks.NewMockService(mockCtrl)
-			mockService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(dataModel, nil)
-			mockService.EXPECT().UpdatePaymentDetails(dataModel).Times(1)
-			mockService.EXPECT().SaveE5Error("", "123", e5.CreateAction).Return(errors.New(""))
-
-			// the payable resource in the request context
-			model := &models.PayableResource{Reference: "123"}
-			ctx := context.WithValue(context.Background(), config.PayableResource, model)
+		// the payable resource in the request context
+		newContext := func() context.Context {
+			model := &models.PayableResource{
+				Reference:     "123",
+				CompanyNumber: "10000024",
+				Transactions: []models.TransactionItem{
+					{TransactionID: "123", Amount: 150},
+				},
+			}
+			return context.WithValue(context.Background(), config.PayableResource, model)
+		}
 
-			// stub kafka message
-			handleEmailKafkaMessage = mockSendEmailKafkaMessageError
+		Convey("problem completing the payment", func() {
+			stubPaidPayment()
+			var completed *validators.PaymentInformation
+			defer stubCompletePayment(service.ErrPaymentIncomplete, &completed)()
 
 			reqBody := &models.PatchResourceRequest{Reference: "123"}
-			res, body := dispatchPayResourceHandler(ctx, t, reqBody, mockService)
+			res, body := dispatchPayResourceHandler(newContext(), t, reqBody, nil)
 
-			So(dataModel.IsPaid(), ShouldBeTrue)
+			So(completed, ShouldNotBeNil)
 			So(res.Code, ShouldEqual, http.StatusInternalServerError)
 			So(body, ShouldBeNil)
 		})
 
 		Convey("LFP has already been paid", func() {
-			mockCtrl := gomock.NewController(t)
-			defer mockCtrl.Finish()
+			stubPaidPayment()
+			var completed *validators.PaymentInformation
+			defer stubCompletePayment(service.ErrAlreadyPaid, &completed)()
 
-			// stub the response from the payments api
-			p := &companieshouseapi.PaymentResource{Status: "paid", Amount: "0", Reference: "late_filing_penalty_123"}
-			responder, _ := httpmock.NewJsonResponder(http.StatusOK, p)
-			httpmock.RegisterResponder(
-				http.MethodGet,
-				companieshouseapi.PaymentsBasePath+"/payments/123",
-				responder,
-			)
+			reqBody := &models.PatchResourceRequest{Reference: "123"}
+			res, body := dispatchPayResourceHandler(newContext(), t, reqBody, nil)
 
-			httpmock.RegisterResponder(
-				http.MethodGet,
-				companieshouseapi.PaymentsBasePath+"/private/payments/123/payment-details",
-				httpmock.NewStringResponder(http.StatusOK, "{}"),
-			)
+			So(res.Code, ShouldEqual, http.StatusInternalServerError)
+			So(body, ShouldBeNil)
+		})
 
-			// stub the mongo lookup
-			dataModel := &models.PayableResourceDao{
-				Data: models.PayableResourceDataDao{
-					Payment: models.PaymentDao{
-						Status: constants.Paid.String(),
-					},
-				},
-			}
+		Convey("payment taken for a cancelled resource is recorded to be refunded", func() {
+			stubPaidPayment()
+			var completed *validators.PaymentInformation
+			defer stubCompletePayment(service.ErrPaidAfterCancellation, &completed)()
 
-			mockService := mocks.NewMockService(mockCtrl)
-			mockService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(dataModel, nil)
-			mockService.EXPECT().SaveE5Error("", "123", e5.CreateAction).Return(errors.New(""))
+			reqBody := &models.PatchResourceRequest{Reference: "123"}
+			res, body := dispatchPayResourceHandler(newContext(), t, reqBody, nil)
 
-			// the payable resource in the request context
-			model := &models.PayableResource{Reference: "123"}
-			ctx := context.WithValue(context.Background(), config.PayableResource, model)
+			So(completed, ShouldNotBeNil)
+			So(res.Code, ShouldEqual, http.StatusConflict)
+			So(body.Message, ShouldEqual, "the payable resource has been cancelled and the payment will be refunded")
+		})
+
+		Convey("If-Match header must match the payable resource etag before the payment is recorded", func() {
+			stubPaidPayment()
+			var completed *validators.PaymentInformation
+			defer stubCompletePayment(nil, &completed)()
 
-			// stub kafka message
-			handleEmailKafkaMessage = mockSendEmailKafkaMessage
+			ctx := context.WithValue(newContext(), httpsession.ContextKeySession, &session.Session{})
+			ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
+			b, _ := json.Marshal(&models.PatchResourceRequest{Reference: "123"})
+			req := httptest.NewRequest(http.MethodPatch, "/", bytes.NewReader(b)).WithContext(ctx)
+			req.Header.Set("If-Match", `"oldetag"`)
+			res := httptest.NewRecorder()
+
+			PayResourceHandler(&service.PayableResourceService{}, e5.NewClient("foo", "e5api"), nil).ServeHTTP(res, req)
+
+			So(res.Code, ShouldEqual, http.StatusPreconditionFailed)
+			So(completed, ShouldBeNil)
+		})
+
+		Convey("success when payment is valid", func() {
+			stubPaidPayment()
+			var completed *validators.PaymentInformation
+			defer stubCompletePayment(nil, &completed)()
 
 			reqBody := &models.PatchResourceRequest{Reference: "123"}
-			res, body := dispatchPayResourceHandler(ctx, t, reqBody, mockService)
+			res, body := dispatchPayResourceHandler(newContext(), t, reqBody, nil)
 
-			So(dataModel.IsPaid(), ShouldBeTrue)
-			So(res.Code, ShouldEqual, http.StatusInternalServerError)
+			So(res.Code, ShouldEqual, http.StatusNoContent)
 			So(body, ShouldBeNil)
+			So(completed.Reference, ShouldEqual, "late_filing_penalty_123")
+			So(completed.Amount, ShouldEqual, "150")
 		})
+	})
+}
 
-		Convey("problem with sending request to E5", func() {
+func TestUnitPayResourceHandlerAsync(t *testing.T) {
+	Convey("PayResourceHandler submits a payment job when an asynchronous response is preferred", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		// stub the response from the payments api
+		p := &companieshouseapi.PaymentResource{
+			Status:    "paid",
+			Amount:    "150",
+			Reference: "late_filing_penalty_123",
+			CreatedBy: companieshouseapi.CreatedBy{
+				Email: "test@example.com",
+			},
+		}
+		responder, _ := httpmock.NewJsonResponder(http.StatusOK, p)
+		httpmock.RegisterResponder(http.MethodGet, companieshouseapi.PaymentsBasePath+"/payments/123", responder)
+		httpmock.RegisterResponder(
+			http.MethodGet,
+			companieshouseapi.PaymentsBasePath+"/private/payments/123/payment-details",
+			httpmock.NewStringResponder(http.StatusOK, "{}"),
+		)
+
+		// the payable resource in the request context
+		model := &models.PayableResource{
+			Reference:     "123",
+			CompanyNumber: "10000024",
+			Etag:          "etag",
+			Links:         models.PayableResourceLinks{Self: "/company/10000024/penalties/late-filing/payable/123"},
+			Transactions: []models.TransactionItem{
+				{TransactionID: "123", Amount: 150},
+			},
+		}
+		ctx := context.WithValue(context.Background(), config.PayableResource, model)
+		ctx = context.WithValue(ctx, httpsession.ContextKeySession, &session.Session{})
+		ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
+
+		dispatch := func(mockService dao.Service, body string) *httptest.ResponseRecorder {
+			svc := &service.PayableResourceService{DAO: mockService, Config: &config.Config{PaymentJobCallbackHosts: []string{"example.com"}}}
+			jobs := service.NewPaymentJobPool(svc, e5.NewClient("foo", "e5api"), []*config.PenaltyScheme{testPenaltyScheme})
+			req := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(body)).WithContext(ctx)
+			req.Header.Set("Prefer", "respond-async")
+			res := httptest.NewRecorder()
+			PayResourceHandler(svc, e5.NewClient("foo", "e5api"), jobs).ServeHTTP(res, req)
+			return res
+		}
+
+		Convey("the job is stored and its location returned", func() {
 			mockCtrl := gomock.NewController(t)
 			defer mockCtrl.Finish()
+			mockService := mocks.NewMockService(mockCtrl)
+			mockService.EXPECT().CreatePaymentJob("10000024", "123", gomock.Any()).DoAndReturn(
+				func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+					So(job.Status, ShouldEqual, service.PaymentJobStatusPending)
+					So(job.Scheme, ShouldEqual, testPenaltyScheme.Name)
+					So(job.CallbackURL, ShouldEqual, "https://example.com/callback")
+					So(job.Payment.Reference, ShouldEqual, "late_filing_penalty_123")
+					return nil
+				})
+
+			res := dispatch(mockService, `{"reference":"123","callback_url":"https://example.com/callback"}`)
+
+			So(res.Code, ShouldEqual, http.StatusAccepted)
+			So(res.Header().Get("Location"), ShouldEqual, "/company/10000024/penalties/late-filing/payable/123/payment/job")
+			So(res.Header().Get("Preference-Applied"), ShouldEqual, "respond-async")
+
+			var job service.PaymentJob
+			So(json.NewDecoder(res.Body).Decode(&job), ShouldBeNil)
+			So(job.Status, ShouldEqual, service.PaymentJobStatusPending)
+			So(job.Links.Resource, ShouldEqual, "/company/10000024/penalties/late-filing/payable/123")
+		})
 
-			// stub the response from the payments api
-			p := &companieshouseapi.PaymentResource{Status: "paid", Amount: "0", Reference: "late_filing_penalty_123"}
-			responder, _ := httpmock.NewJsonResponder(http.StatusOK, p)
-			httpmock.RegisterResponder(
-				http.MethodGet,
-				companieshouseapi.PaymentsBasePath+"/payments/123",
-				responder,
-			)
+		Convey("the callback URL must be a URL", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
 
-			httpmock.RegisterResponder(
-				http.MethodGet,
-				companieshouseapi.PaymentsBasePath+"/private/payments/123/payment-details",
-				httpmock.NewStringResponder(http.StatusOK, "{}"),
-			)
+			res := dispatch(mocks.NewMockService(mockCtrl), `{"reference":"123","callback_url":"not a url"}`)
 
-			// stub the response from the e5 api
-			e5Responder := httpmock.NewStringResponder(http.StatusBadRequest, e5ValidationError)
-			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment", e5Responder)
+			So(res.Code, ShouldEqual, http.StatusBadRequest)
+		})
 
-			// stub the mongo lookup
-			dataModel := &models.PayableResourceDao{}
-			mockService := mocks.NewMockService(mockCtrl)
-			mockService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(dataModel, nil)
-			mockService.EXPECT().UpdatePaymentDetails(dataModel).Times(1)
-			mockService.EXPECT().SaveE5Error("", "123", e5.CreateAction).Return(errors.New(""))
+		Convey("the callback URL must be on an allowed host", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
 
-			// the payable resource in the request context
-			model := &models.PayableResource{Reference: "123"}
-			ctx := context.WithValue(context.Background(), config.PayableResource, model)
+			res := dispatch(mocks.NewMockService(mockCtrl), `{"reference":"123","callback_url":"https://localhost:8080/internal"}`)
+
+			So(res.Code, ShouldEqual, http.StatusBadRequest)
+			So(res.Body.String(), ShouldContainSubstring, "INVALID_REQUEST_BODY")
+		})
 
-			// stub kafka message
-			handleEmailKafkaMessage = mockSendEmailKafkaMessage
+		Convey("a payment that is already in progress is a conflict", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockService := mocks.NewMockService(mockCtrl)
+			mockService.EXPECT().CreatePaymentJob("10000024", "123", gomock.Any()).Return(dao.ErrPaymentJobExists)
 
-			reqBody := &models.PatchResourceRequest{Reference: "123"}
-			res, body := dispatchPayResourceHandler(ctx, t, reqBody, mockService)
+			res := dispatch(mockService, `{"reference":"123"}`)
 
-			So(dataModel.IsPaid(), ShouldBeTrue)
-			So(res.Code, ShouldEqual, http.StatusInternalServerError)
-			So(body, ShouldBeNil)
+			So(res.Code, ShouldEqual, http.StatusConflict)
 		})
 
-		Convey("success when payment is valid", func() {
+		Convey("error storing the job", func() {
 			mockCtrl := gomock.NewController(t)
 			defer mockCtrl.Finish()
+			mockService := mocks.NewMockService(mockCtrl)
+			mockService.EXPECT().CreatePaymentJob("10000024", "123", gomock.Any()).Return(errors.New("any error"))
 
-			// stub the response from the payments api
-			p := &companieshouseapi.PaymentResource{
-				Status:    "paid",
-				Amount:    "150",
-				Reference: "late_filing_penalty_123",
-				CreatedBy: companieshouseapi.CreatedBy{
-					Email: "test@example.com",
-				},
-			}
+			res := dispatch(mockService, `{"reference":"123"}`)
 
-			responder, _ := httpmock.NewJsonResponder(http.StatusOK, p)
-			httpmock.RegisterResponder(
-				http.MethodGet,
-				companieshouseapi.PaymentsBasePath+"/payments/123",
-				responder,
-			)
+			So(res.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
 
+func TestUnitPayResourceHandlerDryRun(t *testing.T) {
+	Convey("PayResourceHandler only validates the payment in a dry run", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		stubPayment := func(status, amount string) {
+			responder, _ := httpmock.NewJsonResponder(http.StatusOK, &companieshouseapi.PaymentResource{
+				Status:    status,
+				Amount:    amount,
+				Reference: "late_filing_penalty_123",
+			})
+			httpmock.RegisterResponder(http.MethodGet, companieshouseapi.PaymentsBasePath+"/payments/123", responder)
 			httpmock.RegisterResponder(
 				http.MethodGet,
 				companieshouseapi.PaymentsBasePath+"/private/payments/123/payment-details",
 				httpmock.NewStringResponder(http.StatusOK, "{}"),
 			)
+		}
 
-			// stub the response from the e5 api
-			e5Responder := httpmock.NewBytesResponder(http.StatusOK, nil)
-			httpmock.RegisterResponder(http.MethodPost, "e5api/arTransactions/payment", e5Responder)
-			httpmock.RegisterResponder(http.MethodPost, "e5api/arTransactions/payment/authorise", e5Responder)
-			httpmock.RegisterResponder(http.MethodPost, "e5api/arTransactions/payment/confirm", e5Responder)
-
-			// stub the mongo lookup
-			dataModel := &models.PayableResourceDao{}
-			mockService := mocks.NewMockService(mockCtrl)
-			mockService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(dataModel, nil)
-			mockService.EXPECT().UpdatePaymentDetails(dataModel).Times(1)
-
-			// the payable resource in the request context
-			model := &models.PayableResource{
+		newResource := func() *models.PayableResource {
+			return &models.PayableResource{
 				Reference:     "123",
 				CompanyNumber: "10000024",
+				Payment:       models.Payment{Status: "pending", Amount: "150"},
 				Transactions: []models.TransactionItem{
 					{TransactionID: "123", Amount: 150},
 				},
 			}
-			ctx := context.WithValue(context.Background(), config.PayableResource, model)
+		}
 
-			// stub kafka message
-			handleEmailKafkaMessage = mockSendEmailKafkaMessage
+		// the service has no DAO, so the test fails if anything is stored
+		dispatch := func(resource *models.PayableResource, body string) (*httptest.ResponseRecorder, *DryRunResponse) {
+			ctx := context.WithValue(context.Background(), config.PayableResource, resource)
+			ctx = context.WithValue(ctx, httpsession.ContextKeySession, &session.Session{})
+			ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
+			req := httptest.NewRequest(http.MethodPatch, "/?dry_run=true", bytes.NewBufferString(body)).WithContext(ctx)
+			res := httptest.NewRecorder()
+			PayResourceHandler(&service.PayableResourceService{}, e5.NewClient("foo", "e5api"), nil).ServeHTTP(res, req)
+
+			var response DryRunResponse
+			_ = json.Unmarshal(res.Body.Bytes(), &response)
+			return res, &response
+		}
 
-			reqBody := &models.PatchResourceRequest{Reference: "123"}
-			res, body := dispatchPayResourceHandler(ctx, t, reqBody, mockService)
+		Convey("a valid payment returns the resource as it would be once paid", func() {
+			stubPayment("paid", "150")
 
-			So(res.Code, ShouldEqual, http.StatusNoContent)
-			So(body, ShouldBeNil)
+			res, response := dispatch(newResource(), `{"reference":"123"}`)
+
+			So(res.Code, ShouldEqual, http.StatusOK)
+			So(response.Valid, ShouldBeTrue)
+			So(res.Body.String(), ShouldContainSubstring, `"status":"paid"`)
+			So(res.Body.String(), ShouldContainSubstring, `"reference":"late_filing_penalty_123"`)
+		})
+
+		Convey("every failing check is returned", func() {
+			stubPayment("paid", "100")
+			resource := newResource()
+			resource.Payment.Status = service.PaymentStatusCancelled
+
+			res, response := dispatch(resource, `{"reference":"123"}`)
+
+			So(res.Code, ShouldEqual, http.StatusBadRequest)
+			So(response.Valid, ShouldBeFalse)
+			So(response.Failures, ShouldHaveLength, 2)
+			So(response.Failures[0].Check, ShouldEqual, "not-cancelled")
+			So(response.Failures[1].Check, ShouldEqual, "payment-valid")
+		})
+
+		Convey("a payment that cannot be found fails", func() {
+			httpmock.RegisterResponder(
+				http.MethodGet,
+				companieshouseapi.PaymentsBasePath+"/payments/123",
+				httpmock.NewStringResponder(http.StatusNotFound, ""),
+			)
+
+			res, response := dispatch(newResource(), `{"reference":"123"}`)
+
+			So(res.Code, ShouldEqual, http.StatusBadRequest)
+			So(response.Failures, ShouldHaveLength, 1)
+			So(response.Failures[0].Check, ShouldEqual, "payment-exists")
+		})
+
+		Convey("a request body without a reference fails", func() {
+			res, response := dispatch(newResource(), `{}`)
+
+			So(res.Code, ShouldEqual, http.StatusBadRequest)
+			So(response.Failures, ShouldHaveLength, 1)
+			So(response.Failures[0].Check, ShouldEqual, "request-body")
 		})
 	})
 }
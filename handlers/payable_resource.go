@@ -5,8 +5,10 @@ import (
 	"net/http"
 
 	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/constants"
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
 	"github.com/companieshouse/lfp-pay-api/utils"
 )
 
@@ -18,10 +20,59 @@ func HandleGetPayableResource(w http.ResponseWriter, req *http.Request) {
 
 	if !ok {
 		log.ErrorR(req, fmt.Errorf("invalid PayableResource in request context"))
-		m := models.NewMessageResponse("the payable resource is not present in the request context")
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "the payable resource is not present in the request context")
 		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
 		return
 	}
 
-	utils.WriteJSON(w, req, payableResource)
+	response := service.PayableResourceResponse{PayableResource: *payableResource}
+
+	// once paid, let the user know what is left to pay in case they have only paid off part of the penalties
+	if payableResource.Payment.Status == constants.Paid.String() {
+		remainingBalance, err := getRemainingBalance(req, payableResource)
+		if err != nil {
+			log.ErrorR(req, err, log.Data{"lfp_reference": payableResource.Reference})
+		} else {
+			response.RemainingBalance = &remainingBalance
+		}
+	}
+
+	etag, err := payableResourceResponseEtag(response)
+	if err != nil {
+		log.ErrorR(req, err, log.Data{"lfp_reference": payableResource.Reference})
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	utils.SetEtagHeader(w, etag)
+	if utils.IsNotModified(req, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	utils.WriteJSON(w, req, response)
+}
+
+// payableResourceResponseEtag is the etag of the resource, unless the response includes the remaining balance, which
+// can change without the resource changing and so is part of the etag of the response
+func payableResourceResponseEtag(response service.PayableResourceResponse) (string, error) {
+	if response.RemainingBalance == nil {
+		return response.Etag, nil
+	}
+
+	return utils.GenerateEtag(struct {
+		Etag             string  `json:"etag"`
+		RemainingBalance float64 `json:"remaining_balance"`
+	}{response.Etag, *response.RemainingBalance})
+}
+
+// getRemainingBalance looks up the balance left in E5 on the penalties paid for by the resource
+func getRemainingBalance(req *http.Request, payableResource *models.PayableResource) (float64, error) {
+	scheme, err := config.GetPenaltyScheme(req.Context())
+	if err != nil {
+		return 0, err
+	}
+
+	return service.GetRemainingBalance(*payableResource, scheme)
 }
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// ListPayableResourcesHandler lists every payable resource, i.e. every payment attempt, for the supplied company
+// number so that support staff can see what happened to a company's payments
+func ListPayableResourcesHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start GET payable resources request")
+
+		companyNumber, err := utils.GetCompanyNumberFromVars(mux.Vars(req))
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidCompanyNumber, "company number is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		companyNumber, err = utils.NormaliseCompanyNumber(companyNumber)
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidCompanyNumber, "invalid company number")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		listOptions, err := parsePayableResourceListOptions(req)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("invalid query parameters: %v", err))
+			m := utils.NewErrorResponsef(utils.ErrorCodeInvalidRequest, "invalid query parameters: %v", err)
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		response, err := svc.ListPayableResources(companyNumber, *listOptions, req.URL.Path, req.URL.Query())
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error listing payable resources: %v", err))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, req, response)
+
+		log.InfoR(req, "Successful GET request for payable resources", log.Data{"company_number": companyNumber, "total_results": response.TotalResults})
+	})
+}
+
+// parsePayableResourceListOptions reads the filtering and pagination query parameters for listing payable resources
+func parsePayableResourceListOptions(req *http.Request) (*service.PayableResourceListOptions, error) {
+	query := req.URL.Query()
+	options := &service.PayableResourceListOptions{
+		Page:         1,
+		ItemsPerPage: service.DefaultItemsPerPage,
+	}
+
+	if status := query.Get("status"); status != "" {
+		if !isPayableResourceStatus(status) {
+			return nil, fmt.Errorf("status must be one of %s", strings.Join(service.PayableResourceStatuses, ", "))
+		}
+		options.Status = status
+	}
+
+	var err error
+	options.From, err = parseDateParameter(query.Get("from"), "from")
+	if err != nil {
+		return nil, err
+	}
+	options.To, err = parseDateParameter(query.Get("to"), "to")
+	if err != nil {
+		return nil, err
+	}
+
+	if page := query.Get("page"); page != "" {
+		pageNumber, err := strconv.Atoi(page)
+		if err != nil || pageNumber < 1 {
+			return nil, fmt.Errorf("page must be a positive number")
+		}
+		options.Page = pageNumber
+	}
+
+	if itemsPerPage := query.Get("items_per_page"); itemsPerPage != "" {
+		pageSize, err := strconv.Atoi(itemsPerPage)
+		if err != nil || pageSize < 1 || pageSize > service.MaxItemsPerPage {
+			return nil, fmt.Errorf("items_per_page must be a number between 1 and %d", service.MaxItemsPerPage)
+		}
+		options.ItemsPerPage = pageSize
+	}
+
+	return options, nil
+}
+
+func isPayableResourceStatus(status string) bool {
+	for _, s := range service.PayableResourceStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveListPayableResourcesHandler(companyNumber, query string, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/company/"+companyNumber+"/penalties/late-filing/payable"+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"company_number": companyNumber})
+	res := httptest.NewRecorder()
+
+	ListPayableResourcesHandler(svc).ServeHTTP(res, req)
+
+	return res
+}
+
+func TestUnitListPayableResourcesHandler(t *testing.T) {
+	Convey("invalid company number", t, func() {
+		res := serveListPayableResourcesHandler("ABC12345", "", &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("invalid query parameters", t, func() {
+		res := serveListPayableResourcesHandler("10000024", "?status=unknown", &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("error listing payable resources", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListPayableResources("10000024", gomock.Any()).Return(nil, int64(0), errors.New("any error"))
+
+		res := serveListPayableResourcesHandler("10000024", "", &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("payable resources are listed", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListPayableResources("00006400", gomock.Any()).Return([]models.PayableResourceDao{
+			{CompanyNumber: "00006400", Reference: "LP123456", Data: models.PayableResourceDataDao{Payment: models.PaymentDao{Status: "pending"}}},
+		}, int64(1), nil)
+
+		res := serveListPayableResourcesHandler("6400", "?status=pending", &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+
+		var response service.PayableResourceListResponse
+		err := json.NewDecoder(res.Body).Decode(&response)
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 1)
+		So(response.Items[0].Reference, ShouldEqual, "LP123456")
+		So(response.Items[0].Payment.Status, ShouldEqual, "pending")
+	})
+}
+
+func TestUnitParsePayableResourceListOptions(t *testing.T) {
+	Convey("Defaults when no query parameters are supplied", t, func() {
+		req := httptest.NewRequest(http.MethodGet, "/company/10000024/penalties/late-filing/payable", nil)
+		options, err := parsePayableResourceListOptions(req)
+		So(err, ShouldBeNil)
+		So(options.Status, ShouldBeEmpty)
+		So(options.From, ShouldBeNil)
+		So(options.To, ShouldBeNil)
+		So(options.Page, ShouldEqual, 1)
+		So(options.ItemsPerPage, ShouldEqual, service.DefaultItemsPerPage)
+	})
+
+	Convey("All query parameters are read", t, func() {
+		req := httptest.NewRequest(http.MethodGet, "/company/10000024/penalties/late-filing/payable?status=paid&from=2019-01-01&to=2019-12-31&page=3&items_per_page=20", nil)
+		options, err := parsePayableResourceListOptions(req)
+		So(err, ShouldBeNil)
+		So(options.Status, ShouldEqual, "paid")
+		So(options.From.Format("2006-01-02"), ShouldEqual, "2019-01-01")
+		So(options.To.Format("2006-01-02"), ShouldEqual, "2019-12-31")
+		So(options.Page, ShouldEqual, 3)
+		So(options.ItemsPerPage, ShouldEqual, 20)
+	})
+
+	Convey("Invalid query parameters are rejected", t, func() {
+		for _, query := range []string{"status=failed", "from=01-01-2019", "to=today", "page=0", "items_per_page=101"} {
+			req := httptest.NewRequest(http.MethodGet, "/company/10000024/penalties/late-filing/payable?"+query, nil)
+			options, err := parsePayableResourceListOptions(req)
+			So(options, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/jarcoal/httpmock"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -72,6 +74,96 @@ func TestUnitHandleGetPayableResource(t *testing.T) {
 		So(resultPayable.Transactions[0].Amount, ShouldEqual, payable.Transactions[0].Amount)
 		So(resultPayable.Transactions[0].Type, ShouldEqual, payable.Transactions[0].Type)
 		So(resultPayable.Transactions[0].TransactionID, ShouldEqual, payable.Transactions[0].TransactionID)
+		So(w.Header().Get("ETag"), ShouldEqual, `"qwertyetag1234"`)
 
 	})
+	Convey("PayableResource not modified", t, func() {
+		payable := models.PayableResource{
+			CompanyNumber: "12345678",
+			Reference:     "abcdef",
+			Etag:          "qwertyetag1234",
+		}
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("If-None-Match", `"qwertyetag1234"`)
+		ctx := context.WithValue(req.Context(), config.PayableResource, &payable)
+		w := httptest.NewRecorder()
+
+		HandleGetPayableResource(w, req.WithContext(ctx))
+
+		So(w.Code, ShouldEqual, 304)
+		So(w.Header().Get("ETag"), ShouldEqual, `"qwertyetag1234"`)
+		So(w.Body.Len(), ShouldEqual, 0)
+	})
+
+	Convey("Paid PayableResource includes the remaining balance", t, func() {
+		cfg, _ := config.Get()
+		cfg.E5APIURL = "https://e5"
+		cfg.E5Username = "SYSTEM"
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		payable := models.PayableResource{
+			CompanyNumber: "10000024",
+			Reference:     "abcdef",
+			Transactions:  []models.TransactionItem{{TransactionID: "00378420", Amount: 50, Type: "penalty"}},
+			Payment:       models.Payment{Amount: "50", Status: "paid"},
+		}
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		ctx := context.WithValue(req.Context(), config.PayableResource, &payable)
+		ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
+		w := httptest.NewRecorder()
+
+		HandleGetPayableResource(w, req.WithContext(ctx))
+
+		So(w.Code, ShouldEqual, 200)
+
+		result := service.PayableResourceResponse{}
+		json.NewDecoder(w.Body).Decode(&result)
+		So(result.Reference, ShouldEqual, "abcdef")
+		So(*result.RemainingBalance, ShouldEqual, 150)
+	})
+
+	Convey("The etag of a paid PayableResource includes the remaining balance", t, func() {
+		cfg, _ := config.Get()
+		cfg.E5APIURL = "https://e5"
+		cfg.E5Username = "SYSTEM"
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		payable := models.PayableResource{
+			CompanyNumber: "10000024",
+			Reference:     "ghijkl",
+			Etag:          "qwertyetag1234",
+			Transactions:  []models.TransactionItem{{TransactionID: "00378420", Amount: 50, Type: "penalty"}},
+			Payment:       models.Payment{Amount: "50", Status: "paid"},
+		}
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		ctx := context.WithValue(req.Context(), config.PayableResource, &payable)
+		ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
+		w := httptest.NewRecorder()
+
+		HandleGetPayableResource(w, req.WithContext(ctx))
+
+		So(w.Code, ShouldEqual, 200)
+		etag := w.Header().Get("ETag")
+		So(etag, ShouldNotEqual, `"qwertyetag1234"`)
+
+		req = httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+
+		HandleGetPayableResource(w, req.WithContext(ctx))
+
+		So(w.Code, ShouldEqual, 304)
+		So(w.Header().Get("ETag"), ShouldEqual, etag)
+	})
 }
@@ -19,24 +19,32 @@ func HandleGetPaymentDetails(w http.ResponseWriter, req *http.Request) {
 
 	if !ok {
 		log.ErrorR(req, fmt.Errorf("invalid PayableResource in request context"))
-		m := models.NewMessageResponse("the payable resource is not present in the request context")
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "the payable resource is not present in the request context")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	scheme, err := config.GetPenaltyScheme(req.Context())
+	if err != nil {
+		log.ErrorR(req, err)
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
 		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
 		return
 	}
 
 	// Get the payment details from the payable resource
-	paymentDetails, responseType, err := paymentDetailsService.GetPaymentDetailsFromPayableResource(req, payableResource)
+	paymentDetails, responseType, err := paymentDetailsService.GetPaymentDetailsFromPayableResource(req, payableResource, scheme)
 	logData := log.Data{"company_number": payableResource.CompanyNumber, "reference": payableResource.Reference}
 	if err != nil {
 		switch responseType {
 		case service.InvalidData:
 			log.DebugR(req, fmt.Sprintf("invalid data getting payment details from payable resource so returning not found [%s]", err.Error()), logData)
-			m := models.NewMessageResponse("payable resource does not exist or has insufficient data")
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceNotFound, "payable resource does not exist or has insufficient data")
 			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
 			return
 		default:
 			log.ErrorR(req, fmt.Errorf("error when getting payment details from PayableResource: [%v]", err), logData)
-			m := models.NewMessageResponse("payable resource does not exist or has insufficient data")
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "payable resource does not exist or has insufficient data")
 			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
 			return
 		}
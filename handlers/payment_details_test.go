@@ -20,6 +20,7 @@ func serveGetPaymentDetailsHandler(payableResource *models.PayableResource) *htt
 
 	if payableResource != nil {
 		ctx := context.WithValue(req.Context(), config.PayableResource, payableResource)
+		ctx = context.WithValue(ctx, config.Scheme, testPenaltyScheme)
 		req = req.WithContext(ctx)
 	}
 
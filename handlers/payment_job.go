@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// GetPaymentJobHandler gets the payment job of a payable resource so that a payment submitted asynchronously can be
+// followed until it has been completed
+func GetPaymentJobHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// get payable resource from context, put there by PayableResourceAuthenticationInterceptor
+		resource, ok := req.Context().Value(config.PayableResource).(*models.PayableResource)
+		if !ok {
+			log.ErrorR(req, fmt.Errorf("invalid PayableResource in request context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "the payable resource is not present in the request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		job, err := svc.GetPaymentJob(resource)
+		switch err {
+		case nil:
+		case service.ErrPaymentJobNotFound:
+			m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		default:
+			log.ErrorR(req, err, log.Data{"lfp_reference": resource.Reference})
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem getting the payment job")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, req, job)
+	})
+}
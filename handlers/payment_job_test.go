@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitGetPaymentJobHandler(t *testing.T) {
+	Convey("GetPaymentJobHandler", t, func() {
+		resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+		ctx := context.WithValue(context.Background(), config.PayableResource, resource)
+
+		dispatch := func(svc *service.PayableResourceService, ctx context.Context) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			res := httptest.NewRecorder()
+			GetPaymentJobHandler(svc).ServeHTTP(res, req)
+			return res
+		}
+
+		Convey("payable resource must be in context", func() {
+			res := dispatch(&service.PayableResourceService{}, context.Background())
+			So(res.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("a resource that was not paid for asynchronously has no payment job", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockService := mocks.NewMockService(mockCtrl)
+			mockService.EXPECT().GetPaymentJob("10000024", "LP123456").Return(nil, nil)
+
+			res := dispatch(&service.PayableResourceService{DAO: mockService}, ctx)
+			So(res.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("error getting the payment job", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockService := mocks.NewMockService(mockCtrl)
+			mockService.EXPECT().GetPaymentJob("10000024", "LP123456").Return(nil, errors.New("any error"))
+
+			res := dispatch(&service.PayableResourceService{DAO: mockService}, ctx)
+			So(res.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("the payment job is returned", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockService := mocks.NewMockService(mockCtrl)
+			mockService.EXPECT().GetPaymentJob("10000024", "LP123456").Return(&dao.PaymentJobDao{Status: service.PaymentJobStatusSucceeded}, nil)
+
+			res := dispatch(&service.PayableResourceService{DAO: mockService}, ctx)
+			So(res.Code, ShouldEqual, http.StatusOK)
+			So(res.Body.String(), ShouldContainSubstring, `"status":"succeeded"`)
+		})
+	})
+}
@@ -4,53 +4,81 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/companieshouse/chs.go/log"
-	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/service"
 	"github.com/companieshouse/lfp-pay-api/utils"
-	"github.com/gorilla/mux"
 )
 
 // HandleGetPenalties retrieves the penalty details for the supplied company number from e5
 func HandleGetPenalties(w http.ResponseWriter, req *http.Request) {
 	log.InfoR(req, "start GET penalties request from e5")
 
-	// Check for a company number in request
-	vars := mux.Vars(req)
-	companyNumber, err := utils.GetCompanyNumberFromVars(vars)
+	companyNumber, err := config.GetCompanyNumber(req.Context())
 	if err != nil {
 		log.ErrorR(req, err)
-		m := models.NewMessageResponse("company number is not in request context")
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "company number is not in request context")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	listOptions, err := parsePenaltyListOptions(req)
+	if err != nil {
+		log.ErrorR(req, fmt.Errorf("invalid query parameters: %v", err))
+		m := utils.NewErrorResponsef(utils.ErrorCodeInvalidRequest, "invalid query parameters: %v", err)
 		utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
 		return
 	}
 
-	companyNumber = strings.ToUpper(companyNumber)
+	scheme, err := config.GetPenaltyScheme(req.Context())
+	if err != nil {
+		log.ErrorR(req, err)
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
 
 	// Call service layer to handle request to E5
-	transactionListResponse, responseType, err := service.GetPenalties(companyNumber)
+	transactionListResponse, responseType, err := service.GetPenalties(companyNumber, scheme)
+	if err == nil && transactionListResponse == nil {
+		err = fmt.Errorf("no transactions returned with response type [%s]", responseType.String())
+	}
 	if err != nil {
 		log.ErrorR(req, fmt.Errorf("error calling e5 to get transactions: %v", err))
-		switch responseType {
-		case service.InvalidData:
-			m := models.NewMessageResponse("failed to read finance transactions")
+		if responseType == service.InvalidData {
+			m := utils.NewErrorResponse(utils.ErrorCodeFinanceDataInvalid, "failed to read finance transactions")
 			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
 			return
-		case service.Error:
-		default:
-			m := models.NewMessageResponse("there was a problem communicating with the finance backend")
-			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
-			return
 		}
+		m := newErrorResponse(err, utils.ErrorCodeFinanceSystemError, "there was a problem communicating with the finance backend")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	penaltyListResponse, err := service.FilterPenalties(transactionListResponse, *listOptions, req.URL.Path, req.URL.Query())
+	if err != nil {
+		log.ErrorR(req, fmt.Errorf("error filtering transactions: %v", err))
+		m := utils.NewErrorResponse(utils.ErrorCodeFinanceDataInvalid, "failed to read finance transactions")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	// the etag is derived from the transactions so the client can skip the body if it already holds this list
+	utils.SetEtagHeader(w, penaltyListResponse.Etag)
+	if utils.IsNotModified(req, penaltyListResponse.Etag) {
+		w.WriteHeader(http.StatusNotModified)
+		log.InfoR(req, "penalties not modified since last request", log.Data{"company_number": companyNumber})
+		return
 	}
 
 	// response body contains fully decorated REST model
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	err = json.NewEncoder(w).Encode(transactionListResponse)
+	err = json.NewEncoder(w).Encode(penaltyListResponse)
 	if err != nil {
 		log.ErrorR(req, fmt.Errorf("error writing response: %v", err))
 		return
@@ -58,3 +86,86 @@ func HandleGetPenalties(w http.ResponseWriter, req *http.Request) {
 
 	log.InfoR(req, "Successfully GET penalties from e5", log.Data{"company_number": companyNumber})
 }
+
+// parsePenaltyListOptions reads the filtering, sorting and pagination query parameters from the request
+func parsePenaltyListOptions(req *http.Request) (*service.PenaltyListOptions, error) {
+	query := req.URL.Query()
+	options := &service.PenaltyListOptions{
+		Page: 1,
+	}
+
+	if transactionType := query.Get("type"); transactionType != "" {
+		if transactionType != service.Penalty.String() && transactionType != service.Other.String() {
+			return nil, fmt.Errorf("type must be %s or %s", service.Penalty, service.Other)
+		}
+		options.Type = transactionType
+	}
+
+	if paid := query.Get("paid"); paid != "" {
+		isPaid, err := strconv.ParseBool(paid)
+		if err != nil {
+			return nil, fmt.Errorf("paid must be true or false")
+		}
+		options.Paid = &isPaid
+	}
+
+	if overdue := query.Get("overdue"); overdue != "" {
+		isOverdue, err := strconv.ParseBool(overdue)
+		if err != nil {
+			return nil, fmt.Errorf("overdue must be true or false")
+		}
+		options.Overdue = &isOverdue
+	}
+
+	var err error
+	options.From, err = parseDateParameter(query.Get("from"), "from")
+	if err != nil {
+		return nil, err
+	}
+	options.To, err = parseDateParameter(query.Get("to"), "to")
+	if err != nil {
+		return nil, err
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if sort != service.SortByDueDate && sort != service.SortByDueDateDescending {
+			return nil, fmt.Errorf("sort must be %s or %s", service.SortByDueDate, service.SortByDueDateDescending)
+		}
+		options.Sort = sort
+	}
+
+	if page := query.Get("page"); page != "" {
+		pageNumber, err := strconv.Atoi(page)
+		if err != nil || pageNumber < 1 {
+			return nil, fmt.Errorf("page must be a positive number")
+		}
+		options.Page = pageNumber
+		// a page was asked for, so the list is paged even if the page size was not given
+		options.ItemsPerPage = service.DefaultItemsPerPage
+	}
+
+	if itemsPerPage := query.Get("items_per_page"); itemsPerPage != "" {
+		pageSize, err := strconv.Atoi(itemsPerPage)
+		if err != nil || pageSize < 1 || pageSize > service.MaxItemsPerPage {
+			return nil, fmt.Errorf("items_per_page must be a number between 1 and %d", service.MaxItemsPerPage)
+		}
+		options.ItemsPerPage = pageSize
+	}
+
+	return options, nil
+}
+
+// parseDateParameter parses an optional date query parameter, returning nil if it was not supplied
+func parseDateParameter(value, name string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	// the dates are compared against transaction dates, which are in UK time
+	date, err := service.ParseE5Date(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date in the format YYYY-MM-DD", name)
+	}
+
+	return &date, nil
+}
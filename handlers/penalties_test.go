@@ -1,26 +1,96 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/jarcoal/httpmock"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestUnitHandleGetPenalties(t *testing.T) {
-	Convey("Request Body Empty", t, func() {
-		req, _ := http.NewRequest("GET", "/company/NI038379/penalties/late-filing", nil)
+	Convey("Company number not in context", t, func() {
+		req := httptest.NewRequest("GET", "/company/10000024/penalties/late-filing", nil)
 		w := httptest.NewRecorder()
 		HandleGetPenalties(w, req)
-		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Code, ShouldEqual, http.StatusInternalServerError)
 	})
 
-	Convey("Request Body Invalid", t, func() {
-		req := httptest.NewRequest("GET", "/test", nil)
+	Convey("Invalid query parameters", t, func() {
+		req := httptest.NewRequest("GET", "/company/10000024/penalties/late-filing?items_per_page=1000", nil).WithContext(testContext())
 		w := httptest.NewRecorder()
 		HandleGetPenalties(w, req)
 		So(w.Code, ShouldEqual, http.StatusBadRequest)
 	})
 
+	Convey("Error getting transactions from E5", t, func() {
+		cfg, _ := config.Get()
+		cfg.E5APIURL = "https://e5"
+		cfg.E5Username = "SYSTEM"
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(500, `{"httpStatusCode": 500, "status": "INTERNAL_SERVER_ERROR"}`))
+
+		req := httptest.NewRequest("GET", "/company/10000024/penalties/late-filing", nil).WithContext(testContext())
+		w := httptest.NewRecorder()
+		HandleGetPenalties(w, req)
+
+		So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		var response utils.ErrorResponse
+		So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+		So(response.ErrorCode, ShouldEqual, utils.ErrorCodeFinanceSystemError)
+	})
+}
+
+func TestUnitParsePenaltyListOptions(t *testing.T) {
+	Convey("Defaults when no query parameters are supplied", t, func() {
+		req := httptest.NewRequest("GET", "/company/10000024/penalties/late-filing", nil)
+		options, err := parsePenaltyListOptions(req)
+		So(err, ShouldBeNil)
+		So(options.Page, ShouldEqual, 1)
+		So(options.ItemsPerPage, ShouldEqual, 0)
+		So(options.Type, ShouldBeEmpty)
+		So(options.Paid, ShouldBeNil)
+		So(options.Overdue, ShouldBeNil)
+		So(options.From, ShouldBeNil)
+		So(options.To, ShouldBeNil)
+	})
+
+	Convey("All query parameters are read", t, func() {
+		req := httptest.NewRequest("GET", "/company/10000024/penalties/late-filing?type=penalty&paid=false&overdue=true&from=2017-01-01&to=2018-01-01&sort=due_date&page=2&items_per_page=10", nil)
+		options, err := parsePenaltyListOptions(req)
+		So(err, ShouldBeNil)
+		So(options.Type, ShouldEqual, "penalty")
+		So(*options.Paid, ShouldBeFalse)
+		So(*options.Overdue, ShouldBeTrue)
+		So(options.From.Format("2006-01-02"), ShouldEqual, "2017-01-01")
+		So(options.To.Format("2006-01-02"), ShouldEqual, "2018-01-01")
+		So(options.Sort, ShouldEqual, service.SortByDueDate)
+		So(options.Page, ShouldEqual, 2)
+		So(options.ItemsPerPage, ShouldEqual, 10)
+	})
+
+	Convey("A page without a page size uses the default page size", t, func() {
+		req := httptest.NewRequest("GET", "/company/10000024/penalties/late-filing?page=2", nil)
+		options, err := parsePenaltyListOptions(req)
+		So(err, ShouldBeNil)
+		So(options.Page, ShouldEqual, 2)
+		So(options.ItemsPerPage, ShouldEqual, service.DefaultItemsPerPage)
+	})
+
+	Convey("Invalid query parameters are rejected", t, func() {
+		for _, query := range []string{"type=fee", "paid=maybe", "overdue=soon", "from=01-01-2017", "to=tomorrow", "sort=amount", "page=0", "items_per_page=abc"} {
+			req := httptest.NewRequest("GET", "/company/10000024/penalties/late-filing?"+query, nil)
+			options, err := parsePenaltyListOptions(req)
+			So(options, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+		}
+	})
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/companieshouse/lfp-pay-api/validators"
+	"github.com/gorilla/mux"
+)
+
+// GetPenaltyHandler retrieves a single penalty for the supplied company number from e5, along with whether it can be
+// paid online and links to any payable resources that include it
+func GetPenaltyHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start GET penalty request from e5")
+
+		companyNumber, err := config.GetCompanyNumber(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "company number is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+		penaltyReference := mux.Vars(req)["penalty_reference"]
+		logData := log.Data{"company_number": companyNumber, "penalty_reference": penaltyReference}
+
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		transactionListResponse, responseType, err := service.GetPenalties(companyNumber, scheme)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error calling e5 to get transactions: %v", err), logData)
+			switch responseType {
+			case service.InvalidData:
+				m := utils.NewErrorResponse(utils.ErrorCodeFinanceDataInvalid, "failed to read finance transactions")
+				utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			default:
+				m := newErrorResponse(err, utils.ErrorCodeFinanceSystemError, "there was a problem communicating with the finance backend")
+				utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		transaction := service.FindTransaction(transactionListResponse, penaltyReference)
+		if transaction == nil {
+			log.InfoR(req, "penalty not found", logData)
+			m := utils.NewErrorResponse(errorCodePenaltyNotFound, "penalty not found")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		}
+
+		payableResourceLinks, err := svc.GetPayableResourceLinks(companyNumber, scheme, penaltyReference)
+		if err != nil {
+			log.ErrorR(req, err, logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		penalty := service.PenaltyResponse{
+			TransactionListItem: *transaction,
+			DueStatus:           service.GetCurrentDueStatus(*transaction),
+			IsPayable:           true,
+			Links: service.PenaltyLinks{
+				Self:             req.URL.Path,
+				PayableResources: payableResourceLinks,
+			},
+		}
+
+		err = validators.TransactionIsPayable(companyNumber, *transaction, transactionListResponse.Items, scheme)
+		if err != nil {
+			penalty.IsPayable = false
+			penalty.NotPayableReason = err.Error()
+		}
+
+		utils.WriteJSON(w, req, penalty)
+
+		log.InfoR(req, "Successfully GET penalty from e5", logData)
+	})
+}
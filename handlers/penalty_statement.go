@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// The formats a statement can be downloaded in
+const (
+	statementFormatCSV = "csv"
+	statementFormatPDF = "pdf"
+)
+
+var statementContentTypes = map[string]string{
+	statementFormatCSV: contentTypeCSV,
+	statementFormatPDF: contentTypePDF,
+}
+
+// getCompanyName allows us to mock the call to the company profile api for unit tests
+var getCompanyName = service.GetCompanyName
+
+// HandleGetPenaltyStatement renders every transaction the company has in e5 as a downloadable CSV or PDF statement.
+// The format is chosen with the format query parameter, or otherwise from the Accept header.
+func HandleGetPenaltyStatement(w http.ResponseWriter, req *http.Request) {
+	log.InfoR(req, "start GET penalty statement request from e5")
+
+	companyNumber, err := config.GetCompanyNumber(req.Context())
+	if err != nil {
+		log.ErrorR(req, err)
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "company number is not in request context")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+	logData := log.Data{"company_number": companyNumber}
+
+	// there is no default format as a statement is always a download
+	format := negotiateFormat(req, statementContentTypes, "")
+	if format == "" {
+		log.InfoR(req, "no acceptable statement format requested", logData)
+		m := utils.NewErrorResponsef(utils.ErrorCodeNotAcceptable, "statements can only be downloaded as %s or %s", contentTypeCSV, contentTypePDF)
+		utils.WriteJSONWithStatus(w, req, m, http.StatusNotAcceptable)
+		return
+	}
+	logData["format"] = format
+
+	scheme, err := config.GetPenaltyScheme(req.Context())
+	if err != nil {
+		log.ErrorR(req, err)
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	transactionListResponse, responseType, err := service.GetPenalties(companyNumber, scheme)
+	if err != nil {
+		log.ErrorR(req, fmt.Errorf("error calling e5 to get transactions: %v", err), logData)
+		switch responseType {
+		case service.InvalidData:
+			m := utils.NewErrorResponse(utils.ErrorCodeFinanceDataInvalid, "failed to read finance transactions")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+		default:
+			m := newErrorResponse(err, utils.ErrorCodeFinanceSystemError, "there was a problem communicating with the finance backend")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	companyName, err := getCompanyName(companyNumber, req)
+	if err != nil {
+		log.ErrorR(req, fmt.Errorf("error getting company name: %v", err), logData)
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem getting the company name")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	statement := service.NewStatement(companyNumber, companyName, transactionListResponse, scheme, time.Now())
+
+	// render the whole statement before writing anything so that a failure can still be reported as an error
+	var body bytes.Buffer
+	if format == statementFormatCSV {
+		err = statement.WriteCSV(&body)
+	} else {
+		err = statement.WritePDF(&body)
+	}
+	if err != nil {
+		log.ErrorR(req, fmt.Errorf("error rendering statement: %v", err), logData)
+		m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+		utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-statement-%s.%s", scheme.ProductType, companyNumber, format)
+	w.Header().Set("Content-Type", statementContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+
+	_, err = body.WriteTo(w)
+	if err != nil {
+		log.ErrorR(req, fmt.Errorf("error writing response: %v", err), logData)
+		return
+	}
+
+	log.InfoR(req, "Successfully GET penalty statement from e5", logData)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveGetPenaltyStatement(query, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/company/10000024/penalties/late-filing/statement"+query, nil)
+	req.Header.Set("Accept", accept)
+	req = req.WithContext(testContext())
+	res := httptest.NewRecorder()
+
+	HandleGetPenaltyStatement(res, req)
+
+	return res
+}
+
+func TestUnitHandleGetPenaltyStatement(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+
+	url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+
+	getCompanyName = func(companyNumber string, req *http.Request) (string, error) {
+		return "TEST LTD", nil
+	}
+	defer func() { getCompanyName = service.GetCompanyName }()
+
+	Convey("a statement can be downloaded as CSV", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		res := serveGetPenaltyStatement("", "text/html, text/csv;q=0.9")
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Header().Get("Content-Type"), ShouldEqual, "text/csv")
+		So(res.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="late-filing-penalty-statement-10000024.csv"`)
+		So(res.Body.String(), ShouldContainSubstring, "Company name,TEST LTD\n")
+		So(res.Body.String(), ShouldContainSubstring, "00378420,penalty,2017-11-28,2017-12-12,150.00,150.00,Overdue\n")
+	})
+
+	Convey("a statement can be downloaded as PDF", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		res := serveGetPenaltyStatement("?format=pdf", "")
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+		So(res.Body.String(), ShouldStartWith, "%PDF-1.4")
+		So(res.Body.String(), ShouldContainSubstring, "(TEST LTD) Tj")
+	})
+
+	Convey("error when no acceptable format is requested", t, func() {
+		So(serveGetPenaltyStatement("", "application/json").Code, ShouldEqual, http.StatusNotAcceptable)
+		So(serveGetPenaltyStatement("?format=xlsx", "text/csv").Code, ShouldEqual, http.StatusNotAcceptable)
+	})
+
+	Convey("error getting the company name", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+		getCompanyName = func(companyNumber string, req *http.Request) (string, error) {
+			return "", errors.New("company profile api error")
+		}
+
+		res := serveGetPenaltyStatement("?format=csv", "")
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/companieshouse/lfp-pay-api/validators"
+)
+
+// PenaltySummaryHandler summarises the penalty account for the supplied company number
+func PenaltySummaryHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start GET penalty summary request")
+
+		companyNumber, err := config.GetCompanyNumber(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "company number is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		summary, responseType, err := svc.GetPenaltySummary(companyNumber, scheme, validators.TransactionIsPayable)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error getting penalty summary: %v", err))
+			switch responseType {
+			case service.InvalidData:
+				m := utils.NewErrorResponse(utils.ErrorCodeFinanceDataInvalid, "failed to read finance transactions")
+				utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			default:
+				m := newErrorResponse(err, utils.ErrorCodeInternalError, "there was a problem summarising the penalties")
+				utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		utils.WriteJSON(w, req, summary)
+
+		log.InfoR(req, "Successful GET request for penalty summary", log.Data{"company_number": companyNumber})
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// partPaymentRules allow penalties to be paid off in instalments, including those already part paid
+const partPaymentRules = `
+rules:
+  - id: single-penalty
+    enabled: true
+    error_code: MULTIPLE_PENALTIES
+    message: the company has more than one outstanding penalty
+  - id: not-part-paid
+    enabled: false
+    error_code: PART_PAID
+    message: the transaction is already part paid
+  - id: not-paid
+    enabled: true
+    error_code: ALREADY_PAID
+    message: this transaction is already paid
+  - id: is-penalty
+    enabled: true
+    error_code: NOT_A_PENALTY
+    message: you cannot pay for this type of transaction
+  - id: full-amount
+    enabled: true
+    error_code: AMOUNT_MISMATCH
+    message: the amount must be the outstanding amount of the transaction, or less when part payments are allowed
+    parameters:
+      allow_part_payments: true
+  - id: part-payment-limits
+    enabled: true
+    error_code: PART_PAYMENT_LIMITS
+    message: the amount is outside the limits for part paying the transaction
+    parameters:
+      minimum_amount: 25
+  - id: not-dca
+    enabled: true
+    error_code: WITH_DCA
+    message: the transaction is with a debt collecting agency
+`
+
+// moduleRoot is where the scheme's asset paths are resolved from, found before any test changes the working directory
+var moduleRoot, _ = filepath.Abs("..")
+
+func servePenaltySummaryHandler(svc *service.PayableResourceService, scheme *config.PenaltyScheme) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/company/10000024/penalties/late-filing/summary", nil)
+	req = req.WithContext(context.WithValue(testContext(), config.Scheme, scheme))
+	res := httptest.NewRecorder()
+
+	PenaltySummaryHandler(svc).ServeHTTP(res, req)
+
+	return res
+}
+
+func TestUnitPenaltySummaryHandler(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+
+	url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+	partPaid := strings.Replace(e5Response, `"outstandingAmount": 150`, `"outstandingAmount": 50`, 1)
+
+	dir, err := ioutil.TempDir("", "payability-rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rulesFile := filepath.Join(dir, "payability_rules.yml")
+	if err = ioutil.WriteFile(rulesFile, []byte(partPaymentRules), 0600); err != nil {
+		t.Fatal(err)
+	}
+	shippedScheme := *testPenaltyScheme
+	shippedScheme.AllowedTypesFile = filepath.Join(moduleRoot, testPenaltyScheme.AllowedTypesFile)
+	shippedScheme.PayabilityRulesFile = filepath.Join(moduleRoot, testPenaltyScheme.PayabilityRulesFile)
+	partPaymentScheme := shippedScheme
+	partPaymentScheme.PayabilityRulesFile = rulesFile
+
+	Convey("a part paid penalty is not payable under the shipped rules", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder(http.MethodGet, url, httpmock.NewStringResponder(http.StatusOK, partPaid))
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().CountE5CommandErrors("10000024", &shippedScheme, gomock.Any()).Return(int64(0), nil)
+
+		res := servePenaltySummaryHandler(&service.PayableResourceService{DAO: mockService, Config: cfg}, &shippedScheme)
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		var summary service.PenaltySummary
+		So(json.Unmarshal(res.Body.Bytes(), &summary), ShouldBeNil)
+		So(summary.TotalOutstanding, ShouldEqual, 50)
+		So(summary.PayableOutstanding, ShouldEqual, 0)
+	})
+
+	Convey("a part paid penalty is payable when the scheme allows part payments", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder(http.MethodGet, url, httpmock.NewStringResponder(http.StatusOK, partPaid))
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().CountE5CommandErrors("10000024", &partPaymentScheme, gomock.Any()).Return(int64(0), nil)
+
+		res := servePenaltySummaryHandler(&service.PayableResourceService{DAO: mockService, Config: cfg}, &partPaymentScheme)
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		var summary service.PenaltySummary
+		So(json.Unmarshal(res.Body.Bytes(), &summary), ShouldBeNil)
+		So(summary.TotalOutstanding, ShouldEqual, 50)
+		So(summary.PayableOutstanding, ShouldEqual, 50)
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveGetPenaltyHandler(penaltyReference string, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+	path := "/company/10000024/penalties/late-filing/" + penaltyReference
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req = req.WithContext(testContext())
+	req = mux.SetURLVars(req, map[string]string{"penalty_reference": penaltyReference})
+	res := httptest.NewRecorder()
+
+	GetPenaltyHandler(svc).ServeHTTP(res, req)
+
+	return res
+}
+
+func TestUnitGetPenaltyHandler(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+
+	url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+
+	Convey("unknown penalty reference", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+		svc := &service.PayableResourceService{DAO: mocks.NewMockService(mockCtrl), Config: cfg}
+
+		res := serveGetPenaltyHandler("00000001", svc)
+
+		So(res.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("error getting payable resources", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResourcesForTransaction("10000024", testPenaltyScheme, "00378420").Return(nil, errors.New("any error"))
+		svc := &service.PayableResourceService{DAO: mockService, Config: cfg}
+
+		res := serveGetPenaltyHandler("00378420", svc)
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("penalty is returned with its payable resources", t, func() {
+		httpmock.Activate()
+		mockCtrl := gomock.NewController(t)
+		defer httpmock.DeactivateAndReset()
+		defer mockCtrl.Finish()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResourcesForTransaction("10000024", testPenaltyScheme, "00378420").Return([]models.PayableResourceDao{
+			{Data: models.PayableResourceDataDao{Links: models.PayableResourceLinksDao{Self: "/company/10000024/penalties/late-filing/payable/123"}}},
+		}, nil)
+		svc := &service.PayableResourceService{DAO: mockService, Config: cfg}
+
+		res := serveGetPenaltyHandler("00378420", svc)
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+
+		var penalty service.PenaltyResponse
+		err := json.NewDecoder(res.Body).Decode(&penalty)
+		So(err, ShouldBeNil)
+		So(penalty.ID, ShouldEqual, "00378420")
+		So(penalty.Type, ShouldEqual, "penalty")
+		So(penalty.IsPayable, ShouldBeTrue)
+		So(penalty.Links.Self, ShouldEqual, "/company/10000024/penalties/late-filing/00378420")
+		So(penalty.Links.PayableResources, ShouldResemble, []string{"/company/10000024/penalties/late-filing/payable/123"})
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// The formats a receipt can be rendered as
+const (
+	receiptFormatJSON = "json"
+	receiptFormatHTML = "html"
+	receiptFormatPDF  = "pdf"
+)
+
+var receiptContentTypes = map[string]string{
+	receiptFormatJSON: contentTypeJSON,
+	receiptFormatHTML: contentTypeHTML,
+	receiptFormatPDF:  contentTypePDF,
+}
+
+// GetReceiptHandler returns the receipt for a paid payable resource as JSON, or rendered as HTML or PDF. The format is
+// chosen with the format query parameter, or otherwise from the Accept header.
+func GetReceiptHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// get payable resource from context, put there by PayableResourceAuthenticationInterceptor
+		payableResource, ok := req.Context().Value(config.PayableResource).(*models.PayableResource)
+		if !ok {
+			log.ErrorR(req, fmt.Errorf("invalid PayableResource in request context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "the payable resource is not present in the request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+		logData := log.Data{"company_number": payableResource.CompanyNumber, "lfp_reference": payableResource.Reference}
+
+		format := negotiateFormat(req, receiptContentTypes, receiptFormatJSON)
+		if format == "" {
+			log.InfoR(req, "no acceptable receipt format requested", logData)
+			m := utils.NewErrorResponsef(utils.ErrorCodeNotAcceptable, "receipts can only be returned as %s, %s or %s", contentTypeJSON, contentTypeHTML, contentTypePDF)
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotAcceptable)
+			return
+		}
+		logData["format"] = format
+
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		if payableResource.Payment.Status != constants.Paid.String() {
+			log.InfoR(req, "receipt requested for a payable resource that has not been paid", logData)
+			m := utils.NewErrorResponse(errorCodeResourceNotPaid, service.ErrNotPaid.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		}
+
+		companyName, err := getCompanyName(payableResource.CompanyNumber, req)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error getting company name: %v", err), logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem getting the company name")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		receipt, err := svc.GetReceipt(payableResource, companyName, scheme)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error getting receipt: %v", err), logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		if format == receiptFormatJSON {
+			utils.WriteJSON(w, req, receipt)
+			log.InfoR(req, "Successful GET request for receipt", logData)
+			return
+		}
+
+		// render the whole receipt before writing anything so that a failure can still be reported as an error
+		var body bytes.Buffer
+		if format == receiptFormatHTML {
+			err = receipt.WriteHTML(&body)
+		} else {
+			err = receipt.WritePDF(&body)
+		}
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error rendering receipt: %v", err), logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", receiptContentTypes[format])
+		if format == receiptFormatPDF {
+			filename := fmt.Sprintf("%s-receipt-%s.pdf", scheme.ProductType, payableResource.Reference)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		}
+		w.WriteHeader(http.StatusOK)
+
+		_, err = body.WriteTo(w)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error writing response: %v", err), logData)
+			return
+		}
+
+		log.InfoR(req, "Successful GET request for receipt", logData)
+	})
+}
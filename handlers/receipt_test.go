@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveGetReceipt(svc *service.PayableResourceService, resource *models.PayableResource, query, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/company/10000024/penalties/late-filing/payable/LP123456/receipt"+query, nil)
+	req.Header.Set("Accept", accept)
+	ctx := context.WithValue(req.Context(), config.Scheme, testPenaltyScheme)
+	ctx = context.WithValue(ctx, config.PayableResource, resource)
+	res := httptest.NewRecorder()
+
+	GetReceiptHandler(svc).ServeHTTP(res, req.WithContext(ctx))
+
+	return res
+}
+
+func TestUnitGetReceiptHandler(t *testing.T) {
+	paidAt := time.Date(2019, 7, 1, 14, 30, 0, 0, time.UTC)
+	paid := &models.PayableResource{
+		CompanyNumber: "10000024",
+		Reference:     "LP123456",
+		Transactions:  []models.TransactionItem{{TransactionID: "00378420", MadeUpDate: "2019-03-31", Amount: 150}},
+		Payment:       models.Payment{Amount: "150", Reference: "payment-ref", Status: "paid", PaidAt: &paidAt},
+	}
+
+	getCompanyName = func(companyNumber string, req *http.Request) (string, error) {
+		return "TEST LTD", nil
+	}
+	defer func() { getCompanyName = service.GetCompanyName }()
+
+	Convey("no payable resource in context", t, func() {
+		req := httptest.NewRequest(http.MethodGet, "/receipt", nil)
+		res := httptest.NewRecorder()
+		GetReceiptHandler(&service.PayableResourceService{}).ServeHTTP(res, req)
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("receipt for an unpaid resource does not exist", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		svc := &service.PayableResourceService{DAO: mocks.NewMockService(mockCtrl)}
+
+		pending := *paid
+		pending.Payment = models.Payment{Amount: "150", Status: "pending"}
+
+		res := serveGetReceipt(svc, &pending, "", "")
+		So(res.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("receipt is returned as JSON by default", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(&dao.PaymentCardDao{CardType: "Visa"}, nil)
+		svc := &service.PayableResourceService{DAO: mockService}
+
+		res := serveGetReceipt(svc, paid, "", "*/*")
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Header().Get("Content-Type"), ShouldEqual, "application/json")
+
+		var receipt service.Receipt
+		So(json.NewDecoder(res.Body).Decode(&receipt), ShouldBeNil)
+		So(receipt.CompanyName, ShouldEqual, "TEST LTD")
+		So(receipt.CardType, ShouldEqual, "Visa")
+		So(receipt.TotalAmount, ShouldEqual, 150)
+	})
+
+	Convey("receipt is rendered as HTML", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPaymentCard(gomock.Any(), gomock.Any()).Return(nil, nil)
+		svc := &service.PayableResourceService{DAO: mockService}
+
+		res := serveGetReceipt(svc, paid, "", "text/html,application/xhtml+xml")
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Header().Get("Content-Type"), ShouldEqual, "text/html")
+		So(res.Body.String(), ShouldContainSubstring, "<h1>Late Filing Penalty payment receipt</h1>")
+	})
+
+	Convey("receipt is rendered as PDF", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPaymentCard(gomock.Any(), gomock.Any()).Return(nil, nil)
+		svc := &service.PayableResourceService{DAO: mockService}
+
+		res := serveGetReceipt(svc, paid, "?format=pdf", "")
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+		So(res.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="late-filing-penalty-receipt-LP123456.pdf"`)
+		So(res.Body.String(), ShouldStartWith, "%PDF-1.4")
+	})
+
+	Convey("error when no acceptable format is requested", t, func() {
+		res := serveGetReceipt(&service.PayableResourceService{}, paid, "", "text/csv")
+		So(res.Code, ShouldEqual, http.StatusNotAcceptable)
+	})
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/authentication"
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// handleRefundEmailKafkaMessage allows us to mock the call to SendRefundEmailKafkaMessage for unit tests
+var handleRefundEmailKafkaMessage = service.SendRefundEmailKafkaMessage
+
+// RefundRequest is the body of a request to refund the payment for a payable resource
+type RefundRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// RefundPayableResourceHandler refunds the payment for a paid payable resource, e.g. when the penalty has been
+// cancelled on appeal, and tells the user that paid that they are being refunded
+func RefundPayableResourceHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start POST refund request")
+
+		vars := mux.Vars(req)
+		companyNumber, err := utils.GetCompanyNumberFromVars(vars)
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidCompanyNumber, "company number is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		companyNumber, err = utils.NormaliseCompanyNumber(companyNumber)
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidCompanyNumber, "invalid company number")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		userDetails, ok := req.Context().Value(authentication.ContextKeyUserDetails).(authentication.AuthUserDetails)
+		if !ok || userDetails.ID == "" {
+			log.ErrorR(req, fmt.Errorf("user details not in context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "user details not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		var request RefundRequest
+		err = json.NewDecoder(req.Body).Decode(&request)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("invalid request"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, "failed to read request body")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		err = newValidator().Struct(request)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("invalid request - failed validation: %v", err))
+			m := newErrorResponse(err, utils.ErrorCodeInvalidRequestBody, "invalid request body")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		resource, responseType, err := svc.GetPayableResource(req, companyNumber, vars["payable_id"])
+		switch {
+		case err != nil:
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		case responseType == service.NotFound:
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceNotFound, "payable resource not found")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		}
+
+		logData := log.Data{"lfp_reference": resource.Reference, "company_number": resource.CompanyNumber}
+
+		refund, err := svc.RefundPayableResource(resource, service.RefundRequest{
+			Reason: request.Reason,
+			UserID: userDetails.ID,
+			Email:  userDetails.Email,
+		})
+		switch err {
+		case nil:
+		case service.ErrNotPaid, service.ErrNotRefundable, service.ErrAlreadyRefunded:
+			log.InfoR(req, "payable resource cannot be refunded: "+err.Error(), logData)
+			m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusConflict)
+			return
+		case service.ErrRefundRejected:
+			log.InfoR(req, "payment platform did not accept the refund", logData)
+			m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadGateway)
+			return
+		case service.ErrRefundUnknown:
+			// the refund may have been made, so it is reconciled rather than requested again
+			log.InfoR(req, "outcome of the refund is not known", logData)
+			m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusGatewayTimeout)
+			return
+		default:
+			log.ErrorR(req, err, logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem refunding the payable resource")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		// the refund has been made so a failure to send the email is only logged
+		err = handleRefundEmailKafkaMessage(*resource, refund, svc.GetLanguage(*resource), req)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error sending refund email: [%v]", err), logData)
+		}
+
+		utils.WriteJSONWithStatus(w, req, refund, http.StatusCreated)
+
+		log.InfoR(req, "Successful POST request for refund", logData)
+	})
+}
+
+// GetRefundHandler gets the refund of a payable resource so that its progress can be followed
+func GetRefundHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// get payable resource from context, put there by PayableResourceAuthenticationInterceptor
+		resource, ok := req.Context().Value(config.PayableResource).(*models.PayableResource)
+		if !ok {
+			log.ErrorR(req, fmt.Errorf("invalid PayableResource in request context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "the payable resource is not present in the request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		refund, err := svc.GetRefund(resource)
+		switch err {
+		case nil:
+		case service.ErrRefundNotFound:
+			m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		default:
+			log.ErrorR(req, err, log.Data{"lfp_reference": resource.Reference})
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem getting the refund")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, req, refund)
+	})
+}
+
+// RecordE5ReversalHandler records that finance have reversed the payment of a refunded payable resource in E5
+func RecordE5ReversalHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start POST e5 reversal request")
+
+		vars := mux.Vars(req)
+		companyNumber, err := utils.GetCompanyNumberFromVars(vars)
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidCompanyNumber, "company number is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		companyNumber, err = utils.NormaliseCompanyNumber(companyNumber)
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInvalidCompanyNumber, "invalid company number")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		userDetails, ok := req.Context().Value(authentication.ContextKeyUserDetails).(authentication.AuthUserDetails)
+		if !ok || userDetails.ID == "" {
+			log.ErrorR(req, fmt.Errorf("user details not in context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "user details not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		resource, responseType, err := svc.GetPayableResource(req, companyNumber, vars["payable_id"])
+		switch {
+		case err != nil:
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		case responseType == service.NotFound:
+			m := utils.NewErrorResponse(utils.ErrorCodeResourceNotFound, "payable resource not found")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		}
+
+		logData := log.Data{"lfp_reference": resource.Reference, "company_number": resource.CompanyNumber}
+
+		refund, err := svc.RecordE5Reversal(resource, userDetails.ID, userDetails.Email)
+		switch err {
+		case nil:
+		case service.ErrRefundNotFound:
+			m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusNotFound)
+			return
+		case service.ErrE5ReversalNotPending:
+			log.InfoR(req, "e5 reversal cannot be recorded: "+err.Error(), logData)
+			m := newErrorResponse(err, utils.ErrorCodeInternalError, err.Error())
+			utils.WriteJSONWithStatus(w, req, m, http.StatusConflict)
+			return
+		default:
+			log.ErrorR(req, err, logData)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem recording the E5 reversal")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, req, refund)
+
+		log.InfoR(req, "Successful POST request for e5 reversal", logData)
+	})
+}
+
+// ListPendingE5ReversalsHandler lists the refunds of the penalty scheme whose payments finance still have to reverse in
+// E5
+func ListPendingE5ReversalsHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		list, err := svc.ListPendingE5Reversals(scheme)
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem listing the E5 reversals")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, req, list)
+	})
+}
+
+// ListCancelledPaymentsHandler lists the payments taken for the penalty scheme's cancelled payable resources that
+// finance still have to refund
+func ListCancelledPaymentsHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		scheme, err := config.GetPenaltyScheme(req.Context())
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "penalty scheme is not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		list, err := svc.ListUnrefundedCancelledPayments(scheme)
+		if err != nil {
+			log.ErrorR(req, err)
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem listing the cancelled payments")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, req, list)
+	})
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/companieshouse/chs.go/authentication"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/i18n"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveRefundPayableResourceHandler(body string, userDetails interface{}, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/company/10000024/penalties/late-filing/payable/LP123456/refund", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"company_number": "10000024", "payable_id": "LP123456"})
+	if userDetails != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authentication.ContextKeyUserDetails, userDetails))
+	}
+	res := httptest.NewRecorder()
+
+	RefundPayableResourceHandler(svc).ServeHTTP(res, req)
+
+	return res
+}
+
+func TestUnitRefundPayableResourceHandler(t *testing.T) {
+	user := authentication.AuthUserDetails{ID: "abc", Email: "finance@example.com"}
+	body := `{"reason":"cancelled on appeal"}`
+	refundURL := "https://api-payments.companieshouse.gov.uk/private/payments/P123/refunds"
+
+	paid := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber: "10000024",
+			Reference:     "LP123456",
+			Data:          models.PayableResourceDataDao{Payment: models.PaymentDao{Status: "paid", Amount: "150"}},
+		}
+	}
+
+	Convey("no user details in context", t, func() {
+		res := serveRefundPayableResourceHandler(body, nil, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("reason is required", t, func() {
+		res := serveRefundPayableResourceHandler(`{}`, user, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("payable resource not found", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(nil, nil)
+
+		res := serveRefundPayableResourceHandler(body, user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("payable resource that has already been refunded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid(), nil).Times(2)
+		mockService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(&dao.PaymentCardDao{PaymentID: "P123"}, nil)
+		mockService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(dao.ErrRefundExists)
+		mockService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: service.RefundStatusSubmitted}, nil)
+
+		res := serveRefundPayableResourceHandler(body, user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusConflict)
+	})
+
+	Convey("error saving the refund", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid(), nil).Times(2)
+		mockService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(&dao.PaymentCardDao{PaymentID: "P123"}, nil)
+		mockService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(errors.New("any error"))
+
+		res := serveRefundPayableResourceHandler(body, user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("outcome of the refund is not known", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder(http.MethodPost, refundURL, httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid(), nil).Times(2)
+		mockService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(&dao.PaymentCardDao{PaymentID: "P123"}, nil)
+		mockService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+		mockService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+
+		res := serveRefundPayableResourceHandler(body, user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusGatewayTimeout)
+		So(res.Body.String(), ShouldContainSubstring, "REFUND_UNKNOWN")
+	})
+
+	Convey("payable resource refunded and the user emailed", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		responder, _ := httpmock.NewJsonResponder(http.StatusCreated, service.PaymentRefund{RefundID: "R123"})
+		httpmock.RegisterResponder(http.MethodPost, refundURL, responder)
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid(), nil).Times(2)
+		mockService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(&dao.PaymentCardDao{PaymentID: "P123"}, nil)
+		mockService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+		mockService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+		mockService.EXPECT().UpdatePaymentDetails(gomock.Any()).Return(nil)
+		mockService.EXPECT().GetLanguage("10000024", "LP123456").Return(i18n.Welsh, nil)
+
+		emailed := false
+		handleRefundEmailKafkaMessage = func(_ models.PayableResource, _ *service.Refund, lang string, _ *http.Request) error {
+			emailed = true
+			So(lang, ShouldEqual, i18n.Welsh)
+			return errors.New("email is only logged")
+		}
+		defer func() { handleRefundEmailKafkaMessage = service.SendRefundEmailKafkaMessage }()
+
+		res := serveRefundPayableResourceHandler(body, user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusCreated)
+		So(emailed, ShouldBeTrue)
+
+		var refund service.Refund
+		So(json.Unmarshal(res.Body.Bytes(), &refund), ShouldBeNil)
+		So(refund.Status, ShouldEqual, service.RefundStatusSubmitted)
+		So(refund.RefundID, ShouldEqual, "R123")
+	})
+}
+
+func TestUnitGetRefundHandler(t *testing.T) {
+	serve := func(resource *models.PayableResource, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/company/10000024/penalties/late-filing/payable/LP123456/refund", nil)
+		if resource != nil {
+			req = req.WithContext(context.WithValue(req.Context(), config.PayableResource, resource))
+		}
+		res := httptest.NewRecorder()
+
+		GetRefundHandler(svc).ServeHTTP(res, req)
+
+		return res
+	}
+	resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+
+	Convey("payable resource must be in context", t, func() {
+		res := serve(nil, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("payable resource has not been refunded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetRefund("10000024", "LP123456").Return(nil, nil)
+
+		res := serve(resource, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("refund is returned", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: service.RefundStatusSubmitted}, nil)
+
+		res := serve(resource, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldContainSubstring, `"status":"submitted"`)
+	})
+}
+
+func TestUnitRecordE5ReversalHandler(t *testing.T) {
+	user := authentication.AuthUserDetails{ID: "abc", Email: "finance@example.com"}
+
+	serve := func(userDetails interface{}, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/company/10000024/penalties/late-filing/payable/LP123456/refund/e5-reversal", nil)
+		req = mux.SetURLVars(req, map[string]string{"company_number": "10000024", "payable_id": "LP123456"})
+		if userDetails != nil {
+			req = req.WithContext(context.WithValue(req.Context(), authentication.ContextKeyUserDetails, userDetails))
+		}
+		res := httptest.NewRecorder()
+
+		RecordE5ReversalHandler(svc).ServeHTTP(res, req)
+
+		return res
+	}
+	paid := &models.PayableResourceDao{CompanyNumber: "10000024", Reference: "LP123456"}
+
+	Convey("no user details in context", t, func() {
+		res := serve(nil, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("payable resource has not been refunded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil)
+		mockService.EXPECT().GetRefund("10000024", "LP123456").Return(nil, nil)
+
+		res := serve(user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("e5 reversal has already been recorded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil)
+		mockService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: service.RefundStatusSubmitted, E5ReversalStatus: service.E5ReversalCompleted}, nil)
+
+		res := serve(user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusConflict)
+		So(res.Body.String(), ShouldContainSubstring, "E5_REVERSAL_NOT_PENDING")
+	})
+
+	Convey("e5 reversal recorded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil)
+		mockService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: service.RefundStatusSubmitted, E5ReversalStatus: service.E5ReversalPending}, nil)
+		mockService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+
+		res := serve(user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+
+		var refund service.Refund
+		So(json.Unmarshal(res.Body.Bytes(), &refund), ShouldBeNil)
+		So(refund.E5ReversalStatus, ShouldEqual, service.E5ReversalCompleted)
+		So(refund.E5ReversedBy, ShouldEqual, "finance@example.com")
+	})
+}
+
+func TestUnitListPendingE5ReversalsHandler(t *testing.T) {
+	serve := func(svc *service.PayableResourceService) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/penalties/late-filing/e5-reversals", nil)
+		req = req.WithContext(context.WithValue(req.Context(), config.Scheme, testPenaltyScheme))
+		res := httptest.NewRecorder()
+
+		ListPendingE5ReversalsHandler(svc).ServeHTTP(res, req)
+
+		return res
+	}
+
+	Convey("error listing the e5 reversals", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListPendingE5Reversals(testPenaltyScheme).Return(nil, errors.New("any error"))
+
+		res := serve(&service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("pending e5 reversals are listed", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListPendingE5Reversals(testPenaltyScheme).Return([]dao.PendingE5ReversalDao{{
+			CompanyNumber: "10000024",
+			Reference:     "LP123456",
+			Refund:        dao.RefundDao{Status: service.RefundStatusSubmitted, RefundID: "R123", E5ReversalStatus: service.E5ReversalPending},
+		}}, nil)
+
+		res := serve(&service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+
+		var list service.PendingE5ReversalList
+		So(json.Unmarshal(res.Body.Bytes(), &list), ShouldBeNil)
+		So(list.Items, ShouldHaveLength, 1)
+		So(list.Items[0].Reference, ShouldEqual, "LP123456")
+		So(list.Items[0].Refund.RefundID, ShouldEqual, "R123")
+	})
+}
+
+func TestUnitListCancelledPaymentsHandler(t *testing.T) {
+	serve := func(svc *service.PayableResourceService) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/penalties/late-filing/cancelled-payments", nil)
+		req = req.WithContext(context.WithValue(req.Context(), config.Scheme, testPenaltyScheme))
+		res := httptest.NewRecorder()
+
+		ListCancelledPaymentsHandler(svc).ServeHTTP(res, req)
+
+		return res
+	}
+
+	Convey("error listing the cancelled payments", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListUnrefundedCancelledPayments(testPenaltyScheme).Return(nil, errors.New("any error"))
+
+		res := serve(&service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("cancelled payments are listed", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListUnrefundedCancelledPayments(testPenaltyScheme).Return([]dao.CancelledPaymentItemDao{{
+			CompanyNumber:    "10000024",
+			Reference:        "LP123456",
+			CancelledPayment: dao.CancelledPaymentDao{PaymentID: "P123", Amount: "150.50"},
+		}}, nil)
+
+		res := serve(&service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+
+		var list service.CancelledPaymentList
+		So(json.Unmarshal(res.Body.Bytes(), &list), ShouldBeNil)
+		So(list.Items, ShouldHaveLength, 1)
+		So(list.Items[0].Reference, ShouldEqual, "LP123456")
+		So(list.Items[0].PaymentID, ShouldEqual, "P123")
+	})
+}
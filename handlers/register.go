@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/companieshouse/chs.go/authentication"
@@ -11,14 +12,20 @@ import (
 	"github.com/companieshouse/lfp-pay-api/interceptors"
 	"github.com/companieshouse/lfp-pay-api/middleware"
 	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/spec"
 	"github.com/gorilla/mux"
 )
 
+// userPayablesPath is where the signed in user's payable resources are listed
+const userPayablesPath = "/user/penalties/payable"
+
 var payableResourceService *service.PayableResourceService
 var paymentDetailsService *service.PaymentDetailsService
+var paymentJobPool *service.PaymentJobPool
 
-// Register defines the route mappings for the main router and it's subrouters
-func Register(mainRouter *mux.Router, cfg *config.Config, svc dao.Service) {
+// Register defines the route mappings for the main router and it's subrouters. The penalty routes are registered once
+// for every penalty scheme, and the API spec is served alongside them.
+func Register(mainRouter *mux.Router, cfg *config.Config, svc dao.Service, schemes []*config.PenaltyScheme, apiSpec *spec.Spec) {
 
 	payableResourceService = &service.PayableResourceService{
 		Config: cfg,
@@ -33,49 +40,141 @@ func Register(mainRouter *mux.Router, cfg *config.Config, svc dao.Service) {
 		Service: *payableResourceService,
 	}
 
-	// only oauth2 users can create payable resources
+	// only oauth2 users can create payable resources or list their own
 	oauth2OnlyInterceptor := &authentication.OAuth2OnlyAuthenticationInterceptor{
 		StrictPaths: map[string][]string{
-			"/company/{company_number}/penalties/late-filing/payable": []string{http.MethodPost},
+			userPayablesPath: {http.MethodGet},
 		},
 	}
+	for _, scheme := range schemes {
+		oauth2OnlyInterceptor.StrictPaths[scheme.RouteTemplate()+"/payable"] = []string{http.MethodPost}
+	}
 
 	e5Client := e5.NewClient(cfg.E5Username, cfg.E5APIURL)
 
+	// payments submitted asynchronously are completed in the background once the pool is started
+	paymentJobPool = service.NewPaymentJobPool(payableResourceService, e5Client, schemes)
+
 	userAuthInterceptor := &authentication.UserAuthenticationInterceptor{
 		AllowAPIKeyUser:                true,
 		RequireElevatedAPIKeyPrivilege: true,
 	}
 
+	// bodies are only checked against the spec when configured to, which is log only in live. the check wraps each
+	// handler so that it runs after the authentication interceptors, and unauthenticated requests are refused before
+	// anything about the spec is given away.
+	checkSchema := mux.MiddlewareFunc(func(h http.Handler) http.Handler { return h })
+	switch cfg.SchemaValidation {
+	case config.SchemaValidationLog, config.SchemaValidationReject:
+		checkSchema = middleware.SchemaValidationMiddleware(apiSpec, cfg.SchemaValidation == config.SchemaValidationReject)
+	}
+
 	mainRouter.HandleFunc("/healthcheck", healthCheck).Methods(http.MethodGet).Name("healthcheck")
 	mainRouter.HandleFunc("/healthcheck/finance-system", HandleHealthCheckFinanceSystem).Methods(http.MethodGet).Name("healthcheck-finance-system")
+	mainRouter.Handle(specPath, GetSpecHandler(apiSpec)).Methods(http.MethodGet).Name("get-spec")
 
-	appRouter := mainRouter.PathPrefix("/company/{company_number}/penalties/late-filing").Subrouter()
-	appRouter.HandleFunc("", HandleGetPenalties).Methods(http.MethodGet).Name("get-penalties")
-	appRouter.Handle("/payable", CreatePayableResourceHandler(svc)).Methods(http.MethodPost).Name("create-payable")
-	appRouter.Use(
+	// a user's payable resources are listed for every penalty scheme at once
+	userRouter := mainRouter.PathPrefix(userPayablesPath).Subrouter()
+	userRouter.Handle("", checkSchema(ListUserPayableResourcesHandler(payableResourceService))).Methods(http.MethodGet).Name("get-user-payables")
+	userRouter.Use(
 		oauth2OnlyInterceptor.OAuth2OnlyAuthenticationIntercept,
 		userAuthInterceptor.UserAuthenticationIntercept,
-		middleware.CompanyMiddleware,
 	)
 
-	// sub router for handling interactions with existing payable resources to apply relevant
-	// PayableAuthenticationInterceptor
-	existingPayableRouter := appRouter.PathPrefix("/payable/{payable_id}").Subrouter()
-	existingPayableRouter.HandleFunc("", HandleGetPayableResource).Name("get-payable").Methods(http.MethodGet)
-	existingPayableRouter.HandleFunc("/payment", HandleGetPaymentDetails).Methods(http.MethodGet).Name("get-payment-details")
-	existingPayableRouter.Use(payableAuthInterceptor.PayableAuthenticationIntercept)
-
-	// separate router for the patch request so that we can apply the interceptor to it without interfering with
-	// other routes
-	payResourceRouter := appRouter.PathPrefix("/payable/{payable_id}/payment").Methods(http.MethodPatch).Subrouter()
-	payResourceRouter.Use(payableAuthInterceptor.PayableAuthenticationIntercept, authentication.ElevatedPrivilegesInterceptor)
-	payResourceRouter.Handle("", PayResourceHandler(payableResourceService, e5Client)).Name("mark-as-paid")
+	// each penalty scheme is served under its own route prefix, with the scheme in the context of every request
+	for _, scheme := range schemes {
+		appRouter := mainRouter.PathPrefix(scheme.RouteTemplate()).Subrouter()
+		appRouter.Handle("", checkSchema(http.HandlerFunc(HandleGetPenalties))).Methods(http.MethodGet).Name(routeName(scheme, "get-penalties"))
+		appRouter.Handle("/summary", checkSchema(PenaltySummaryHandler(payableResourceService))).Methods(http.MethodGet).Name(routeName(scheme, "get-penalty-summary"))
+		appRouter.Handle("/statement", checkSchema(http.HandlerFunc(HandleGetPenaltyStatement))).Methods(http.MethodGet).Name(routeName(scheme, "get-penalty-statement"))
+		appRouter.Handle("/{penalty_reference:[0-9A-Z]+}", checkSchema(GetPenaltyHandler(payableResourceService))).Methods(http.MethodGet).Name(routeName(scheme, "get-penalty"))
+		appRouter.Handle("/payable", checkSchema(CreatePayableResourceHandler(svc))).Methods(http.MethodPost).Name(routeName(scheme, "create-payable"))
+		appRouter.Use(
+			middleware.PenaltySchemeMiddleware(scheme),
+			oauth2OnlyInterceptor.OAuth2OnlyAuthenticationIntercept,
+			userAuthInterceptor.UserAuthenticationIntercept,
+			middleware.CompanyMiddleware,
+		)
+
+		// sub router for handling interactions with existing payable resources to apply relevant
+		// PayableAuthenticationInterceptor
+		existingPayableRouter := appRouter.PathPrefix("/payable/{payable_id}").Subrouter()
+		existingPayableRouter.Handle("", checkSchema(http.HandlerFunc(HandleGetPayableResource))).Name(routeName(scheme, "get-payable")).Methods(http.MethodGet)
+		existingPayableRouter.Handle("/payment", checkSchema(http.HandlerFunc(HandleGetPaymentDetails))).Methods(http.MethodGet).Name(routeName(scheme, "get-payment-details"))
+		existingPayableRouter.Handle("", checkSchema(CancelPayableResourceHandler(payableResourceService, e5Client))).Methods(http.MethodDelete).Name(routeName(scheme, "cancel-payable"))
+		existingPayableRouter.Handle("/receipt", checkSchema(GetReceiptHandler(payableResourceService))).Methods(http.MethodGet).Name(routeName(scheme, "get-receipt"))
+		existingPayableRouter.Handle("/refund", checkSchema(GetRefundHandler(payableResourceService))).Methods(http.MethodGet).Name(routeName(scheme, "get-refund"))
+		existingPayableRouter.Handle("/payment/job", checkSchema(GetPaymentJobHandler(payableResourceService))).Methods(http.MethodGet).Name(routeName(scheme, "get-payment-job"))
+		existingPayableRouter.Use(payableAuthInterceptor.PayableAuthenticationIntercept)
+
+		// separate router for the patch request so that we can apply the interceptor to it without interfering with
+		// other routes
+		payResourceRouter := appRouter.PathPrefix("/payable/{payable_id}/payment").Methods(http.MethodPatch).Subrouter()
+		payResourceRouter.Use(payableAuthInterceptor.PayableAuthenticationIntercept, authentication.ElevatedPrivilegesInterceptor)
+		payResourceRouter.Handle("", checkSchema(PayResourceHandler(payableResourceService, e5Client, paymentJobPool))).Name(routeName(scheme, "mark-as-paid"))
+
+		// separate router for listing every payable resource for a company, which only support staff and internal
+		// services can do
+		listPayableRouter := appRouter.PathPrefix("/payable").Methods(http.MethodGet).Subrouter()
+		listPayableRouter.Use(interceptors.AdminPenaltyLookupIntercept)
+		listPayableRouter.Handle("", checkSchema(ListPayableResourcesHandler(payableResourceService))).Name(routeName(scheme, "list-payables"))
+
+		// separate router for finance staff to record that a payable resource has been paid outside of the service
+		settlePayableRouter := appRouter.PathPrefix("/payable/{payable_id}/offline-payment").Methods(http.MethodPost).Subrouter()
+		settlePayableRouter.Use(interceptors.AdminPenaltySettleIntercept)
+		settlePayableRouter.Handle("", checkSchema(SettleOfflineHandler(payableResourceService, e5Client))).Name(routeName(scheme, "settle-offline"))
+
+		// separate router for finance staff to refund the payment for a payable resource
+		refundPayableRouter := appRouter.PathPrefix("/payable/{payable_id}/refund").Methods(http.MethodPost).Subrouter()
+		refundPayableRouter.Use(interceptors.AdminPenaltyRefundIntercept)
+		refundPayableRouter.Handle("", checkSchema(RefundPayableResourceHandler(payableResourceService))).Name(routeName(scheme, "refund-payable"))
+		refundPayableRouter.Handle("/e5-reversal", checkSchema(RecordE5ReversalHandler(payableResourceService))).Name(routeName(scheme, "record-e5-reversal"))
+
+		// routes that are not for a single company are only available to internal services using elevated api keys
+		bulkRouter := mainRouter.PathPrefix(scheme.BulkRouteTemplate()).Subrouter()
+		bulkRouter.Handle("/summaries", checkSchema(BulkPenaltySummaryHandler(payableResourceService))).Methods(http.MethodPost).Name(routeName(scheme, "bulk-penalty-summary"))
+		bulkRouter.Use(
+			middleware.PenaltySchemeMiddleware(scheme),
+			authentication.ElevatedPrivilegesInterceptor,
+		)
+
+		// separate routers for finance staff to find the refunds whose payments they still have to reverse in E5, and the
+		// payments taken for cancelled resources that they still have to refund
+		e5ReversalRouter := mainRouter.PathPrefix(scheme.BulkRouteTemplate() + "/e5-reversals").Methods(http.MethodGet).Subrouter()
+		e5ReversalRouter.Handle("", checkSchema(ListPendingE5ReversalsHandler(payableResourceService))).Name(routeName(scheme, "list-e5-reversals"))
+		e5ReversalRouter.Use(
+			middleware.PenaltySchemeMiddleware(scheme),
+			interceptors.AdminPenaltyLookupIntercept,
+		)
+		cancelledPaymentRouter := mainRouter.PathPrefix(scheme.BulkRouteTemplate() + "/cancelled-payments").Methods(http.MethodGet).Subrouter()
+		cancelledPaymentRouter.Handle("", checkSchema(ListCancelledPaymentsHandler(payableResourceService))).Name(routeName(scheme, "list-cancelled-payments"))
+		cancelledPaymentRouter.Use(
+			middleware.PenaltySchemeMiddleware(scheme),
+			interceptors.AdminPenaltyLookupIntercept,
+		)
+	}
 
 	// Set middleware across all routers and sub routers
 	mainRouter.Use(log.Handler)
 }
 
+// StartPaymentJobPool starts completing the payments submitted asynchronously to the registered routes, until the
+// context is cancelled. Jobs that are interrupted are picked up again by the next instance to sweep for them.
+func StartPaymentJobPool(ctx context.Context) {
+	paymentJobPool.Start(ctx)
+}
+
+// WaitPaymentJobPool waits for the payment job pool to stop once the context it was started with is cancelled
+func WaitPaymentJobPool() {
+	paymentJobPool.Wait()
+}
+
+// routeName qualifies the name of a route with the penalty scheme it is registered under, e.g.
+// late-filing-get-penalties
+func routeName(scheme *config.PenaltyScheme, name string) string {
+	return scheme.Name + "-" + name
+}
+
 func healthCheck(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -3,31 +3,139 @@ package handlers
 import (
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/spec"
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+// schemaFile is the API spec, which is found before any test changes the working directory
+var schemaFile, _ = filepath.Abs("../spec/schema.json")
+
 func TestUnitRegisterRoutes(t *testing.T) {
 	Convey("Register routes", t, func() {
 		router := mux.NewRouter()
 		mockCtrl := gomock.NewController(t)
 		defer mockCtrl.Finish()
 		mockService := mocks.NewMockService(mockCtrl)
-		Register(router, &config.Config{}, mockService)
+		Register(router, &config.Config{}, mockService, []*config.PenaltyScheme{{Name: "late-filing", RoutePrefix: "late-filing"}}, &spec.Spec{})
 
 		So(router.GetRoute("healthcheck"), ShouldNotBeNil)
 		So(router.GetRoute("healthcheck-finance-system"), ShouldNotBeNil)
-		So(router.GetRoute("get-penalties"), ShouldNotBeNil)
-		So(router.GetRoute("create-payable"), ShouldNotBeNil)
-		So(router.GetRoute("get-payable"), ShouldNotBeNil)
-		So(router.GetRoute("get-payment-details"), ShouldNotBeNil)
-		So(router.GetRoute("mark-as-paid"), ShouldNotBeNil)
+		So(router.GetRoute("get-spec"), ShouldNotBeNil)
+		So(router.GetRoute("get-user-payables"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-penalties"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-penalty-summary"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-penalty-statement"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-penalty"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-create-payable"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-payable"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-cancel-payable"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-payment-details"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-receipt"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-mark-as-paid"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-list-payables"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-settle-offline"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-refund-payable"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-refund"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-get-payment-job"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-bulk-penalty-summary"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-record-e5-reversal"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-list-e5-reversals"), ShouldNotBeNil)
+		So(router.GetRoute("late-filing-list-cancelled-payments"), ShouldNotBeNil)
+	})
+}
+
+func TestUnitRegisteredRoutesAreInSpec(t *testing.T) {
+	Convey("Every registered route is described in the API spec", t, func() {
+		apiSpec, err := spec.Load(schemaFile)
+		So(err, ShouldBeNil)
+
+		router := mux.NewRouter()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		Register(router, &config.Config{}, mockService, []*config.PenaltyScheme{{Name: "late-filing", RoutePrefix: "late-filing"}}, apiSpec)
+
+		variable := regexp.MustCompile(`{[^}]+}`)
+		err = router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			if route.GetHandler() == nil {
+				return nil
+			}
+			template, err := route.GetPathTemplate()
+			So(err, ShouldBeNil)
+			path := variable.ReplaceAllString(template, "LP123456")
+
+			// the methods of a route that is the only one in its subrouter are set on the subrouter
+			methods, err := route.GetMethods()
+			for i := len(ancestors) - 1; err != nil && i >= 0; i-- {
+				methods, err = ancestors[i].GetMethods()
+			}
+			So(err, ShouldBeNil)
+
+			for _, method := range methods {
+				So(apiSpec.FindOperation(method, path), ShouldNotBeNil)
+			}
+			return nil
+		})
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestUnitRegisterSchemaValidation(t *testing.T) {
+	Convey("Bodies are only checked against the spec once the request has been authenticated", t, func() {
+		apiSpec, err := spec.Load(schemaFile)
+		So(err, ShouldBeNil)
+
+		router := mux.NewRouter()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		cfg := &config.Config{SchemaValidation: config.SchemaValidationReject}
+		Register(router, cfg, mockService, []*config.PenaltyScheme{{Name: "late-filing", RoutePrefix: "late-filing"}}, apiSpec)
+
+		req := httptest.NewRequest(http.MethodPost, "/company/10000024/penalties/late-filing/payable/LP123456/refund", strings.NewReader(`{"reason":1}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+}
+
+func TestUnitRegisterPayableRoutes(t *testing.T) {
+	Convey("Payable resource requests are routed by method and path", t, func() {
+		router := mux.NewRouter()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		Register(router, &config.Config{}, mockService, []*config.PenaltyScheme{{Name: "late-filing", RoutePrefix: "late-filing"}}, &spec.Spec{})
+
+		routes := map[string]string{
+			http.MethodGet + " /company/10000024/penalties/late-filing/payable":                           "late-filing-list-payables",
+			http.MethodPost + " /company/10000024/penalties/late-filing/payable":                          "late-filing-create-payable",
+			http.MethodGet + " /company/10000024/penalties/late-filing/payable/LP123456":                  "late-filing-get-payable",
+			http.MethodDelete + " /company/10000024/penalties/late-filing/payable/LP123456":               "late-filing-cancel-payable",
+			http.MethodPost + " /company/10000024/penalties/late-filing/payable/LP123456/offline-payment": "late-filing-settle-offline",
+			http.MethodPost + " /company/10000024/penalties/late-filing/payable/LP123456/refund":          "late-filing-refund-payable",
+			http.MethodGet + " /company/10000024/penalties/late-filing/payable/LP123456/refund":           "late-filing-get-refund",
+			http.MethodGet + " /company/10000024/penalties/late-filing/payable/LP123456/payment":          "late-filing-get-payment-details",
+			http.MethodPatch + " /company/10000024/penalties/late-filing/payable/LP123456/payment":        "late-filing-mark-as-paid",
+			http.MethodGet + " /company/10000024/penalties/late-filing/payable/LP123456/payment/job":      "late-filing-get-payment-job",
+		}
+		for request, name := range routes {
+			parts := strings.SplitN(request, " ", 2)
+			var match mux.RouteMatch
+			So(router.Match(httptest.NewRequest(parts[0], parts[1], nil), &match), ShouldBeTrue)
+			So(match.Route.GetName(), ShouldEqual, name)
+		}
 	})
 }
 
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/spec"
+)
+
+// specPath is where the API spec is served
+const specPath = "/penalties/spec"
+
+// GetSpecHandler serves the swagger spec describing the API
+func GetSpecHandler(apiSpec *spec.Spec) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write(apiSpec.Raw())
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error writing api spec: [%v]", err))
+		}
+	})
+}
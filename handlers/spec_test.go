@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/spec"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitGetSpecHandler(t *testing.T) {
+	Convey("the api spec is served as it was read", t, func() {
+		raw := `{"swagger": "2.0", "paths": {}}`
+		apiSpec, err := spec.New([]byte(raw))
+		So(err, ShouldBeNil)
+
+		req := httptest.NewRequest(http.MethodGet, specPath, nil)
+		res := httptest.NewRecorder()
+		GetSpecHandler(apiSpec).ServeHTTP(res, req)
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(res.Body.String(), ShouldEqual, raw)
+	})
+}
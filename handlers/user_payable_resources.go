@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/companieshouse/chs.go/authentication"
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// ListUserPayableResourcesHandler lists the payable resources created by the signed in user, for any company, so
+// that they can see their penalty payments and resume any that they have not finished
+func ListUserPayableResourcesHandler(svc *service.PayableResourceService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log.InfoR(req, "start GET user payable resources request")
+
+		userDetails, ok := req.Context().Value(authentication.ContextKeyUserDetails).(authentication.AuthUserDetails)
+		if !ok || userDetails.ID == "" {
+			log.ErrorR(req, fmt.Errorf("user details not in context"))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "user details not in request context")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		listOptions, err := parsePayableResourceListOptions(req)
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("invalid query parameters: %v", err))
+			m := utils.NewErrorResponsef(utils.ErrorCodeInvalidRequest, "invalid query parameters: %v", err)
+			utils.WriteJSONWithStatus(w, req, m, http.StatusBadRequest)
+			return
+		}
+
+		response, err := svc.ListUserPayableResources(userDetails.ID, *listOptions, req.URL.Path, req.URL.Query())
+		if err != nil {
+			log.ErrorR(req, fmt.Errorf("error listing user's payable resources: %v", err))
+			m := utils.NewErrorResponse(utils.ErrorCodeInternalError, "there was a problem handling your request")
+			utils.WriteJSONWithStatus(w, req, m, http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, req, response)
+
+		log.InfoR(req, "Successful GET request for user payable resources", log.Data{"user_id": userDetails.ID, "total_results": response.TotalResults})
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/chs.go/authentication"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/companieshouse/lfp-pay-api/service"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveListUserPayableResourcesHandler(query string, userDetails interface{}, svc *service.PayableResourceService) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/user/penalties/payable"+query, nil)
+	if userDetails != nil {
+		req = req.WithContext(context.WithValue(req.Context(), authentication.ContextKeyUserDetails, userDetails))
+	}
+	res := httptest.NewRecorder()
+
+	ListUserPayableResourcesHandler(svc).ServeHTTP(res, req)
+
+	return res
+}
+
+func TestUnitListUserPayableResourcesHandler(t *testing.T) {
+	user := authentication.AuthUserDetails{ID: "abc", Email: "test@example.com"}
+
+	Convey("no user details in context", t, func() {
+		res := serveListUserPayableResourcesHandler("", nil, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("invalid query parameters", t, func() {
+		res := serveListUserPayableResourcesHandler("?page=0", user, &service.PayableResourceService{})
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("error listing the user's payable resources", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListPayableResourcesCreatedBy("abc", gomock.Any()).Return(nil, int64(0), errors.New("any error"))
+
+		res := serveListUserPayableResourcesHandler("", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("the user's payable resources are listed", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockService := mocks.NewMockService(mockCtrl)
+		mockService.EXPECT().ListPayableResourcesCreatedBy("abc", gomock.Any()).Return([]models.PayableResourceDao{
+			{CompanyNumber: "10000024", Reference: "LP123456", Data: models.PayableResourceDataDao{Payment: models.PaymentDao{Status: "paid"}}},
+		}, int64(1), nil)
+
+		res := serveListUserPayableResourcesHandler("", user, &service.PayableResourceService{DAO: mockService})
+
+		So(res.Code, ShouldEqual, http.StatusOK)
+
+		var response service.PayableResourceListResponse
+		err := json.NewDecoder(res.Body).Decode(&response)
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 1)
+		So(response.Items[0].Reference, ShouldEqual, "LP123456")
+	})
+}
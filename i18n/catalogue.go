@@ -0,0 +1,92 @@
+package i18n
+
+// welsh is the Welsh translation of each message, keyed by the English message. Messages with arguments are keyed by
+// their format, with the verbs in the same order in the translation.
+var welsh = map[string]string{
+	// request context and request body
+	"company number is not in request context":                         "nid yw rhif y cwmni yng nghyd-destun y cais",
+	"company number not in request context":                            "nid yw rhif y cwmni yng nghyd-destun y cais",
+	"user details not in request context":                              "nid yw manylion y defnyddiwr yng nghyd-destun y cais",
+	"penalty scheme is not in request context":                         "nid yw'r cynllun cosbau yng nghyd-destun y cais",
+	"no payable request present in request context":                    "nid oes cais taladwy yng nghyd-destun y cais",
+	"the payable resource is not present in the request context":       "nid yw'r adnodd taladwy yng nghyd-destun y cais",
+	"failed to read request body":                                      "methwyd â darllen corff y cais",
+	"there was a problem reading the request body":                     "roedd problem wrth ddarllen corff y cais",
+	"invalid request body":                                             "corff cais annilys",
+	"invalid company number":                                           "rhif cwmni annilys",
+	"the request contained insufficient data and/or failed validation": "nid oedd digon o ddata yn y cais a/neu methodd y dilysu",
+	"the request body does not match the api spec: %s":                 "nid yw corff y cais yn cyfateb i fanyleb yr API: %s",
+	"the response body does not match the api spec: %s":                "nid yw corff yr ymateb yn cyfateb i fanyleb yr API: %s",
+	"%s failed the %s validation":                                      "methodd %s y dilysiad %s",
+	"%s must be true or false":                                         "rhaid i %s fod yn true neu false",
+	"invalid query parameters: %v":                                     "paramedrau ymholiad annilys: %v",
+	"between 1 and %d company numbers must be supplied":                "rhaid rhoi rhwng 1 a %d o rifau cwmni",
+	"receipts can only be returned as %s, %s or %s":                    "dim ond fel %s, %s neu %s y gellir dychwelyd derbynebau",
+	"statements can only be downloaded as %s or %s":                    "dim ond fel %s neu %s y gellir lawrlwytho datganiadau",
+	"failed to get maintenance times from config":                      "methwyd â chael yr amseroedd cynnal a chadw o'r ffurfweddiad",
+	"there was a problem handling your request":                        "roedd problem wrth ymdrin â'ch cais",
+	"there was a problem communicating with the finance backend":       "roedd problem wrth gyfathrebu â'r system gyllid",
+	"failed to read finance transactions":                              "methwyd â darllen y trafodion cyllid",
+	"there was a problem getting the company name":                     "roedd problem wrth gael enw'r cwmni",
+	"there was a problem summarising the penalties":                    "roedd problem wrth grynhoi'r cosbau",
+	"penalty not found":                                                "ni chafwyd hyd i'r gosb",
+
+	// payable resources
+	"payable resource not found":                                                        "ni chafwyd hyd i'r adnodd taladwy",
+	"payable resource does not exist or has insufficient data":                          "nid yw'r adnodd taladwy yn bodoli neu nid oes ganddo ddigon o ddata",
+	"the payable resource does not exist":                                               "nid yw'r adnodd taladwy yn bodoli",
+	"the payable resource has been modified":                                            "mae'r adnodd taladwy wedi'i newid",
+	"the payable resource has been modified since it was read":                          "mae'r adnodd taladwy wedi'i newid ers iddo gael ei ddarllen",
+	"the payable resource has been cancelled and the payment will be refunded":          "mae'r adnodd taladwy wedi'i ganslo a bydd y taliad yn cael ei ad-dalu",
+	"the payable resource has been cancelled":                                           "mae'r adnodd taladwy wedi'i ganslo",
+	"the payable resource has already been cancelled":                                   "mae'r adnodd taladwy eisoes wedi'i ganslo",
+	"the payable resource has already been paid":                                        "mae'r adnodd taladwy eisoes wedi'i dalu",
+	"the payable resource has not been paid":                                            "nid yw'r adnodd taladwy wedi'i dalu",
+	"the payable resource is being paid for":                                            "mae'r adnodd taladwy yn cael ei dalu",
+	"the transactions you want to pay for do not exist or are not payable at this time": "nid yw'r trafodion rydych am dalu amdanynt yn bodoli neu ni ellir talu amdanynt ar hyn o bryd",
+	"there was a problem checking the transactions":                                     "roedd problem wrth wirio'r trafodion",
+	"there was a problem cancelling the payable resource":                               "roedd problem wrth ganslo'r adnodd taladwy",
+	"there was a problem settling the payable resource":                                 "roedd problem wrth setlo'r adnodd taladwy",
+	"the LFP does not exist":                                                            "nid yw'r gosb ffeilio hwyr yn bodoli",
+	"the LFP has already been paid":                                                     "mae'r gosb ffeilio hwyr eisoes wedi'i thalu",
+
+	// payments
+	"the resource you are trying to pay for has not been paid":                                   "nid yw'r adnodd rydych yn ceisio talu amdano wedi'i dalu",
+	"the payment does not exist":                                                                 "nid yw'r taliad yn bodoli",
+	"there was a problem validating the payment":                                                 "roedd problem wrth ddilysu'r taliad",
+	"there was a problem validating this payment":                                                "roedd problem wrth ddilysu'r taliad hwn",
+	"there was a problem submitting the payment":                                                 "roedd problem wrth gyflwyno'r taliad",
+	"the payable resource already has a payment in progress":                                     "mae taliad eisoes ar y gweill ar gyfer yr adnodd taladwy",
+	"the external reference is not a payment ID for the penalty scheme":                          "nid yw'r cyfeirnod allanol yn ID taliad ar gyfer y cynllun cosbau",
+	"the payable resource already has a payment job":                                             "mae tasg dalu eisoes gan yr adnodd taladwy",
+	"the payable resource has no payment job":                                                    "nid oes tasg dalu gan yr adnodd taladwy",
+	"there was a problem getting the payment job":                                                "roedd problem wrth gael y dasg dalu",
+	"the callback URL is not allowed":                                                            "nid yw'r URL adalw wedi'i ganiatáu",
+	"the payable resource has already been refunded":                                             "mae'r adnodd taladwy eisoes wedi'i ad-dalu",
+	"the payable resource already has a refund":                                                  "mae ad-daliad eisoes gan yr adnodd taladwy",
+	"the payable resource has not been refunded":                                                 "nid yw'r adnodd taladwy wedi'i ad-dalu",
+	"the payable resource has no payment on the payment platform to refund":                      "nid oes gan yr adnodd taladwy daliad ar y platfform talu i'w ad-dalu",
+	"the payment platform did not accept the refund":                                             "ni dderbyniodd y platfform talu yr ad-daliad",
+	"there was a problem refunding the payable resource":                                         "roedd problem wrth ad-dalu'r adnodd taladwy",
+	"there was a problem getting the refund":                                                     "roedd problem wrth gael yr ad-daliad",
+	"the outcome of the refund is not known and it will be reconciled with the payment platform": "nid yw canlyniad yr ad-daliad yn hysbys a chaiff ei gysoni â'r platfform talu",
+	"the refund has no E5 reversal waiting to be made":                                           "nid oes gan yr ad-daliad wrthdroad E5 yn aros i'w wneud",
+	"there was a problem recording the E5 reversal":                                              "roedd problem wrth gofnodi'r gwrthdroad E5",
+	"there was a problem listing the cancelled payments":                                         "roedd problem wrth restru'r taliadau a ganslwyd",
+	"there was a problem listing the E5 reversals":                                               "roedd problem wrth restru'r gwrthdroadau E5",
+
+	// transactions that cannot be paid for
+	"invalid transaction":                              "trafodyn annilys",
+	"you cannot pay for this type of transaction":      "ni allwch dalu am y math hwn o drafodyn",
+	"the transaction is with a debt collecting agency": "mae'r trafodyn gydag asiantaeth casglu dyledion",
+	"this transaction is already paid":                 "mae'r trafodyn hwn eisoes wedi'i dalu",
+	"the transaction is already part paid":             "mae rhan o'r trafodyn eisoes wedi'i thalu",
+	"the amount must be the outstanding amount of the transaction, or less when part payments are allowed": "rhaid i'r swm fod yn swm sy'n weddill ar y trafodyn, neu'n llai pan ganiateir rhan-daliadau",
+	"the company has more than one outstanding penalty":                                                    "mae gan y cwmni fwy nag un gosb sy'n ddyledus",
+	"the same transaction cannot be paid for more than once":                                               "ni ellir talu am yr un trafodyn fwy nag unwaith",
+	"the amount is outside the limits for part paying the transaction":                                     "mae'r swm y tu allan i'r terfynau ar gyfer talu rhan o'r trafodyn",
+
+	// emails
+	"Confirmation of your Companies House penalty payment":   "Cadarnhad o'ch taliad cosb i Dŷ'r Cwmnïau",
+	"Your Companies House penalty payment is being refunded": "Mae eich taliad cosb i Dŷ'r Cwmnïau yn cael ei ad-dalu",
+}
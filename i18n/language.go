@@ -0,0 +1,128 @@
+// Package i18n translates the messages of the API and the emails it sends into the languages that Companies House
+// services are provided in.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The languages that messages are provided in, as the primary language subtags used in the Accept-Language header
+const (
+	English = "en"
+	Welsh   = "cy"
+)
+
+// contextKey is used within the context api to store values
+type contextKey string
+
+// storedLanguageKey is the key that stores the lookup of the language stored for the resource a request is for
+const storedLanguageKey = contextKey("StoredLanguage")
+
+// welshMonths are the names of the months in Welsh, as used in dates
+var welshMonths = [...]string{
+	"Ionawr", "Chwefror", "Mawrth", "Ebrill", "Mai", "Mehefin",
+	"Gorffennaf", "Awst", "Medi", "Hydref", "Tachwedd", "Rhagfyr",
+}
+
+// IsSupported returns true if messages are provided in the language
+func IsSupported(lang string) bool {
+	return lang == English || lang == Welsh
+}
+
+// FromRequest returns the language that the client of the request would most like messages in. A client that accepts
+// none of the supported languages is written to in the language stored for the resource the request is for, or in
+// English if there is none.
+func FromRequest(req *http.Request) string {
+	if lang, ok := acceptedLanguage(req.Header.Get("Accept-Language")); ok {
+		return lang
+	}
+	if lookup, ok := req.Context().Value(storedLanguageKey).(func() string); ok {
+		if lang := lookup(); IsSupported(lang) {
+			return lang
+		}
+	}
+	return English
+}
+
+// WithStoredLanguage returns a copy of the context in which the language stored for the resource the request is for
+// is looked up with the function. It is only looked up once, and only when the client accepts none of the supported
+// languages.
+func WithStoredLanguage(ctx context.Context, lookup func() string) context.Context {
+	var once sync.Once
+	var lang string
+	return context.WithValue(ctx, storedLanguageKey, func() string {
+		once.Do(func() {
+			lang = lookup()
+		})
+		return lang
+	})
+}
+
+// FromAcceptLanguage returns the supported language with the highest quality in the Accept-Language header, or
+// English if there is none. Of languages with the same quality the first listed is chosen, and regional variants such
+// as cy-GB are treated as the language itself.
+func FromAcceptLanguage(header string) string {
+	lang, _ := acceptedLanguage(header)
+	return lang
+}
+
+// acceptedLanguage returns the supported language with the highest quality in the Accept-Language header, and false
+// with English if there is none
+func acceptedLanguage(header string) (string, bool) {
+	chosen := English
+	chosenQuality := 0.0
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		lang := strings.ToLower(strings.TrimSpace(strings.SplitN(parts[0], "-", 2)[0]))
+		if !IsSupported(lang) {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err == nil {
+					quality = q
+				}
+			}
+		}
+
+		if quality > chosenQuality {
+			chosen = lang
+			chosenQuality = quality
+		}
+	}
+	return chosen, chosenQuality > 0
+}
+
+// Translate returns the message in the language, or the message as it is if there is no translation for it
+func Translate(lang, message string) string {
+	if lang == Welsh {
+		if translated, ok := welsh[message]; ok {
+			return translated
+		}
+	}
+	return message
+}
+
+// Sprintf translates the format into the language before formatting the arguments with it. The arguments are not
+// translated.
+func Sprintf(lang, format string, args ...interface{}) string {
+	return fmt.Sprintf(Translate(lang, format), args...)
+}
+
+// FormatDate formats the date as it is written in the language, e.g. 28 February 2017 or 28 Chwefror 2017
+func FormatDate(lang string, date time.Time) string {
+	if lang == Welsh {
+		return fmt.Sprintf("%d %s %d", date.Day(), welshMonths[date.Month()-1], date.Year())
+	}
+	return date.Format("2 January 2006")
+}
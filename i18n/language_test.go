@@ -0,0 +1,105 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitFromAcceptLanguage(t *testing.T) {
+	Convey("English when no language is accepted", t, func() {
+		So(FromAcceptLanguage(""), ShouldEqual, English)
+	})
+
+	Convey("English when no supported language is accepted", t, func() {
+		So(FromAcceptLanguage("fr-FR, de;q=0.9"), ShouldEqual, English)
+	})
+
+	Convey("regional variants are treated as the language", t, func() {
+		So(FromAcceptLanguage("cy-GB"), ShouldEqual, Welsh)
+		So(FromAcceptLanguage("CY"), ShouldEqual, Welsh)
+	})
+
+	Convey("language with the highest quality is chosen", t, func() {
+		So(FromAcceptLanguage("en;q=0.5, cy;q=0.9"), ShouldEqual, Welsh)
+		So(FromAcceptLanguage("cy;q=0.5, en-GB"), ShouldEqual, English)
+	})
+
+	Convey("first language is chosen when the qualities are the same", t, func() {
+		So(FromAcceptLanguage("cy, en"), ShouldEqual, Welsh)
+		So(FromAcceptLanguage("en, cy"), ShouldEqual, English)
+	})
+
+	Convey("language that is not acceptable is not chosen", t, func() {
+		So(FromAcceptLanguage("cy;q=0, fr"), ShouldEqual, English)
+	})
+
+	Convey("language is chosen from the request", t, func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", "cy")
+		So(FromRequest(req), ShouldEqual, Welsh)
+	})
+
+	Convey("language stored for the resource is chosen when the request has none", t, func() {
+		lookups := 0
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(WithStoredLanguage(req.Context(), func() string {
+			lookups++
+			return Welsh
+		}))
+		So(FromRequest(req), ShouldEqual, Welsh)
+		So(FromRequest(req), ShouldEqual, Welsh)
+		So(lookups, ShouldEqual, 1)
+
+		req.Header.Set("Accept-Language", "en")
+		So(FromRequest(req), ShouldEqual, English)
+	})
+
+	Convey("English when the language stored for the resource is not supported", t, func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(WithStoredLanguage(req.Context(), func() string { return "" }))
+		So(FromRequest(req), ShouldEqual, English)
+	})
+}
+
+func TestUnitTranslate(t *testing.T) {
+	Convey("message is translated into Welsh", t, func() {
+		So(Translate(Welsh, "invalid request body"), ShouldEqual, "corff cais annilys")
+	})
+
+	Convey("message is unchanged in English", t, func() {
+		So(Translate(English, "invalid request body"), ShouldEqual, "invalid request body")
+	})
+
+	Convey("message without a translation is unchanged", t, func() {
+		So(Translate(Welsh, "not in the catalogue"), ShouldEqual, "not in the catalogue")
+	})
+
+	Convey("format is translated before the arguments are formatted", t, func() {
+		So(Sprintf(Welsh, "invalid query parameters: %v", "page"), ShouldEqual, "paramedrau ymholiad annilys: page")
+		So(Sprintf(English, "invalid query parameters: %v", "page"), ShouldEqual, "invalid query parameters: page")
+	})
+
+	Convey("every translation has the same verbs as its message", t, func() {
+		verbs := regexp.MustCompile(`%[a-z]`)
+		for message, translated := range welsh {
+			So(verbs.FindAllString(translated, -1), ShouldResemble, verbs.FindAllString(message, -1))
+		}
+	})
+}
+
+func TestUnitFormatDate(t *testing.T) {
+	date := time.Date(2017, time.February, 28, 0, 0, 0, 0, time.UTC)
+
+	Convey("date is written in English", t, func() {
+		So(FormatDate(English, date), ShouldEqual, "28 February 2017")
+	})
+
+	Convey("date is written in Welsh", t, func() {
+		So(FormatDate(Welsh, date), ShouldEqual, "28 Chwefror 2017")
+		So(FormatDate(Welsh, time.Date(2018, time.June, 1, 0, 0, 0, 0, time.UTC)), ShouldEqual, "1 Mehefin 2018")
+	})
+}
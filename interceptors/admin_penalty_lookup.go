@@ -0,0 +1,13 @@
+package interceptors
+
+import (
+	"net/http"
+
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// AdminPenaltyLookupIntercept only lets through GET requests from users with the admin penalty lookup role or from
+// internal API keys with elevated privileges, for routes that expose every payable resource of a company
+func AdminPenaltyLookupIntercept(next http.Handler) http.Handler {
+	return adminRoleIntercept("AdminPenaltyLookupInterceptor", http.MethodGet, utils.AdminPenaltyLookupRole, true, next)
+}
@@ -0,0 +1,72 @@
+package interceptors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveAdminPenaltyLookupIntercept(method string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/company/12345678/penalties/late-filing/payable", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+
+	AdminPenaltyLookupIntercept(GetTestHandler()).ServeHTTP(w, req)
+
+	return w
+}
+
+func TestUnitAdminPenaltyLookupIntercept(t *testing.T) {
+	Convey("user with the admin penalty lookup role is allowed through", t, func() {
+		w := serveAdminPenaltyLookupIntercept(http.MethodGet, map[string]string{
+			"Eric-Identity":         "authorised_identity",
+			"Eric-Identity-Type":    "oauth2",
+			"ERIC-Authorised-Roles": "/admin/penalty-lookup",
+		})
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("user without the admin penalty lookup role is forbidden", t, func() {
+		w := serveAdminPenaltyLookupIntercept(http.MethodGet, map[string]string{
+			"Eric-Identity":         "authorised_identity",
+			"Eric-Identity-Type":    "oauth2",
+			"ERIC-Authorised-Roles": "/admin/payment-lookup",
+		})
+		So(w.Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("api key with elevated privileges is allowed through", t, func() {
+		w := serveAdminPenaltyLookupIntercept(http.MethodGet, map[string]string{
+			"Eric-Identity":             "api_key",
+			"Eric-Identity-Type":        "key",
+			"ERIC-Authorised-Key-Roles": "*",
+		})
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("api key without elevated privileges is forbidden", t, func() {
+		w := serveAdminPenaltyLookupIntercept(http.MethodGet, map[string]string{
+			"Eric-Identity":      "api_key",
+			"Eric-Identity-Type": "key",
+		})
+		So(w.Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("requests other than GET are not allowed", t, func() {
+		w := serveAdminPenaltyLookupIntercept(http.MethodPost, map[string]string{
+			"Eric-Identity":         "authorised_identity",
+			"Eric-Identity-Type":    "oauth2",
+			"ERIC-Authorised-Roles": "/admin/penalty-lookup",
+		})
+		So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+	})
+
+	Convey("request without a user or api key is unauthorised", t, func() {
+		w := serveAdminPenaltyLookupIntercept(http.MethodGet, map[string]string{})
+		So(w.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+}
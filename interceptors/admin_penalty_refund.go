@@ -0,0 +1,13 @@
+package interceptors
+
+import (
+	"net/http"
+
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// AdminPenaltyRefundIntercept only lets through POST requests from users with the admin penalty refund role, for
+// routes that refund the payment for a penalty. As with settling, API keys are not allowed.
+func AdminPenaltyRefundIntercept(next http.Handler) http.Handler {
+	return adminRoleIntercept("AdminPenaltyRefundInterceptor", http.MethodPost, utils.AdminPenaltyRefundRole, false, next)
+}
@@ -0,0 +1,35 @@
+package interceptors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitAdminPenaltyRefundIntercept(t *testing.T) {
+	serve := func(method string, roles string) int {
+		req := httptest.NewRequest(method, "/company/12345678/penalties/late-filing/payable/321/refund", nil)
+		req.Header.Set("Eric-Identity", "authorised_identity")
+		req.Header.Set("Eric-Identity-Type", "oauth2")
+		req.Header.Set("ERIC-Authorised-Roles", roles)
+		w := httptest.NewRecorder()
+
+		AdminPenaltyRefundIntercept(GetTestHandler()).ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	Convey("user with the admin penalty refund role is allowed through", t, func() {
+		So(serve(http.MethodPost, "/admin/penalty-refund"), ShouldEqual, http.StatusOK)
+	})
+
+	Convey("user with only the admin penalty settle role is forbidden", t, func() {
+		So(serve(http.MethodPost, "/admin/penalty-settle"), ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("requests other than POST are not allowed", t, func() {
+		So(serve(http.MethodGet, "/admin/penalty-refund"), ShouldEqual, http.StatusMethodNotAllowed)
+	})
+}
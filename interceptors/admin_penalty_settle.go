@@ -0,0 +1,14 @@
+package interceptors
+
+import (
+	"net/http"
+
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// AdminPenaltySettleIntercept only lets through POST requests from users with the admin penalty settle role, for
+// routes that record a penalty as paid outside of the service. API keys are not allowed as the settlement must be
+// attributed to a member of staff.
+func AdminPenaltySettleIntercept(next http.Handler) http.Handler {
+	return adminRoleIntercept("AdminPenaltySettleInterceptor", http.MethodPost, utils.AdminPenaltySettleRole, false, next)
+}
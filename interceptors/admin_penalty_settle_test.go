@@ -0,0 +1,64 @@
+package interceptors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveAdminPenaltySettleIntercept(method string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/company/12345678/penalties/late-filing/payable/321/offline-payment", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+
+	AdminPenaltySettleIntercept(GetTestHandler()).ServeHTTP(w, req)
+
+	return w
+}
+
+func TestUnitAdminPenaltySettleIntercept(t *testing.T) {
+	Convey("user with the admin penalty settle role is allowed through", t, func() {
+		w := serveAdminPenaltySettleIntercept(http.MethodPost, map[string]string{
+			"Eric-Identity":         "authorised_identity",
+			"Eric-Identity-Type":    "oauth2",
+			"ERIC-Authorised-Roles": "/admin/penalty-settle",
+		})
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("user with only the admin penalty lookup role is forbidden", t, func() {
+		w := serveAdminPenaltySettleIntercept(http.MethodPost, map[string]string{
+			"Eric-Identity":         "authorised_identity",
+			"Eric-Identity-Type":    "oauth2",
+			"ERIC-Authorised-Roles": "/admin/penalty-lookup",
+		})
+		So(w.Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("api key with elevated privileges is forbidden", t, func() {
+		w := serveAdminPenaltySettleIntercept(http.MethodPost, map[string]string{
+			"Eric-Identity":             "api_key",
+			"Eric-Identity-Type":        "key",
+			"ERIC-Authorised-Key-Roles": "*",
+		})
+		So(w.Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("requests other than POST are not allowed", t, func() {
+		w := serveAdminPenaltySettleIntercept(http.MethodGet, map[string]string{
+			"Eric-Identity":         "authorised_identity",
+			"Eric-Identity-Type":    "oauth2",
+			"ERIC-Authorised-Roles": "/admin/penalty-settle",
+		})
+		So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+	})
+
+	Convey("request without a user or api key is unauthorised", t, func() {
+		w := serveAdminPenaltySettleIntercept(http.MethodPost, map[string]string{})
+		So(w.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,40 @@
+package interceptors
+
+import (
+	"net/http"
+
+	"github.com/companieshouse/chs.go/authentication"
+	"github.com/companieshouse/chs.go/log"
+)
+
+// adminRoleIntercept only lets through requests with the given method from oauth2 users with the given role and, if
+// allowed for the route, from API keys with elevated privileges. A request that is not from a user or API key is
+// unauthorised, and one from a user or API key that does not have permission is forbidden.
+func adminRoleIntercept(name, method, role string, allowAPIKeys bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identityType := authentication.GetAuthorisedIdentityType(r)
+
+		debugMap := log.Data{
+			"identity_type":  identityType,
+			"request_method": r.Method,
+		}
+
+		switch {
+		case isUnauthorizedIdentityType(identityType):
+			log.InfoR(r, name+" unauthorised: not oauth2 or API key identity type", debugMap)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method != method:
+			log.InfoR(r, name+" method not allowed: only "+method+" requests are allowed", debugMap)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case identityType == authentication.Oauth2IdentityType && authentication.IsRoleAuthorised(r, role):
+			log.InfoR(r, name+" authorised as "+role+" role", debugMap)
+			next.ServeHTTP(w, r)
+		case allowAPIKeys && isAuthorizedApiKeyRequest(identityType == authentication.APIKeyIdentityType, authentication.IsKeyElevatedPrivilegesAuthorised(r)):
+			log.InfoR(r, name+" authorised as api key elevated user", debugMap)
+			next.ServeHTTP(w, r)
+		default:
+			log.InfoR(r, name+" forbidden", debugMap)
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+}
@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/companieshouse/chs.go/authentication"
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 	"github.com/companieshouse/lfp-pay-api/service"
 	"github.com/companieshouse/lfp-pay-api/utils"
 	"github.com/gorilla/mux"
@@ -57,6 +57,11 @@ func (payableAuthInterceptor *PayableAuthenticationInterceptor) PayableAuthentic
 		// Store payable_resource in context to use later in the handler
 		ctx := context.WithValue(r.Context(), config.PayableResource, payableResource)
 
+		// clients that do not ask for a language are written to in the one stored for the resource
+		ctx = i18n.WithStoredLanguage(ctx, func() string {
+			return payableAuthInterceptor.Service.GetLanguage(*payableResource)
+		})
+
 		// Set up variables that are used to determine authorisation below
 		isGetRequest := http.MethodGet == r.Method
 		authUserIsPayableResourceCreator := authorisedUser == payableResource.CreatedBy.ID
@@ -85,12 +90,17 @@ func (payableAuthInterceptor *PayableAuthenticationInterceptor) PayableAuthentic
 func preCheckRequest(w http.ResponseWriter, r *http.Request) (string, string, string, bool) {
 	// Check for a company_number and payable_id in request
 	vars := mux.Vars(r)
-	companyNumber := strings.ToUpper(vars["company_number"])
-	if companyNumber == "" {
+	if vars["company_number"] == "" {
 		log.InfoR(r, "PayableAuthenticationInterceptor error: no company_number")
 		w.WriteHeader(http.StatusBadRequest)
 		return "", "", "", true
 	}
+	companyNumber, err := utils.NormaliseCompanyNumber(vars["company_number"])
+	if err != nil {
+		log.InfoR(r, "PayableAuthenticationInterceptor error: invalid company_number", log.Data{"company_number": vars["company_number"]})
+		w.WriteHeader(http.StatusBadRequest)
+		return "", "", "", true
+	}
 	payableID := vars["payable_id"]
 	if payableID == "" {
 		log.InfoR(r, "PayableAuthenticationInterceptor error: no payable_id")
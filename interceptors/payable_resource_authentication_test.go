@@ -13,6 +13,7 @@ import (
 	"github.com/companieshouse/chs.go/authentication"
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 	"github.com/companieshouse/lfp-pay-api/mocks"
 	"github.com/companieshouse/lfp-pay-api/service"
 	"github.com/golang/mock/gomock"
@@ -147,6 +148,34 @@ func TestUnitUserPaymentInterceptor(t *testing.T) {
 		So(w.Code, ShouldEqual, http.StatusNotFound)
 	})
 
+	Convey("Payment in another penalty scheme", t, func() {
+		path := fmt.Sprintf("/company/12345678/penalties/late-filing/payable/%s", "1234")
+		req, err := http.NewRequest("GET", path, nil)
+		So(err, ShouldBeNil)
+		req = mux.SetURLVars(req, map[string]string{"company_number": "12345678", "payable_id": "1234"})
+		req.Header.Set("Eric-Identity", "identity")
+		req.Header.Set("Eric-Identity-Type", "oauth2")
+		req.Header.Set("ERIC-Authorised-User", "test@test.com;test;user")
+		authUserDetails := authentication.AuthUserDetails{
+			ID: "identity",
+		}
+		scheme := mocks.LateFilingScheme()
+		ctx := context.WithValue(req.Context(), authentication.ContextKeyUserDetails, authUserDetails)
+		ctx = context.WithValue(ctx, config.Scheme, scheme)
+
+		mockDAO := mocks.NewMockService(mockCtrl)
+		mockPayableResourceService := createMockPayableResourceService(mockDAO, cfg)
+		payableAuthenticationInterceptor := createPayableAuthenticationInterceptorWithMockService(&mockPayableResourceService)
+
+		mockDAO.EXPECT().GetPayableResource("12345678", "1234").Return(&models.PayableResourceDao{CompanyNumber: "12345678", Reference: "1234"}, nil)
+		mockDAO.EXPECT().IsInScheme("12345678", "1234", scheme).Return(false, nil)
+
+		w := httptest.NewRecorder()
+		test := payableAuthenticationInterceptor.PayableAuthenticationIntercept(GetTestHandler())
+		test.ServeHTTP(w, req.WithContext(ctx))
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
 	Convey("Error reading from DB", t, func() {
 		path := fmt.Sprintf("/company/12345678/penalties/late-filing/payable/%s", "1234")
 		req, err := http.NewRequest("GET", path, nil)
@@ -230,6 +259,57 @@ func TestUnitUserPaymentInterceptor(t *testing.T) {
 		So(w.Code, ShouldEqual, http.StatusOK)
 	})
 
+	Convey("Resource is written about in its stored language when the request has none", t, func() {
+		path := fmt.Sprintf("/company/12345678/penalties/late-filing/payable/%s", "1234")
+		req, err := http.NewRequest("GET", path, nil)
+		So(err, ShouldBeNil)
+		req = mux.SetURLVars(req, map[string]string{"company_number": "12345678", "payable_id": "1234"})
+		req.Header.Set("Eric-Identity", "identity")
+		req.Header.Set("Eric-Identity-Type", "oauth2")
+		req.Header.Set("ERIC-Authorised-User", "test@test.com;test;user")
+		req.Header.Set("ERIC-Authorised-Roles", "noroles")
+		authUserDetails := authentication.AuthUserDetails{
+			ID: "identity",
+		}
+		ctx := context.WithValue(req.Context(), authentication.ContextKeyUserDetails, authUserDetails)
+
+		mockDAO := mocks.NewMockService(mockCtrl)
+		mockPayableResourceService := createMockPayableResourceService(mockDAO, cfg)
+		payableAuthenticationInterceptor := createPayableAuthenticationInterceptorWithMockService(&mockPayableResourceService)
+
+		createdAt := time.Now().Truncate(time.Millisecond)
+		mockDAO.EXPECT().GetPayableResource("12345678", "1234").Return(
+			&models.PayableResourceDao{
+				CompanyNumber: "12345678",
+				Reference:     "1234",
+				Data: models.PayableResourceDataDao{
+					Etag:      "qwertyetag1234",
+					CreatedAt: &createdAt,
+					CreatedBy: models.CreatedByDao{
+						ID: "identity",
+					},
+					Links: models.PayableResourceLinksDao{
+						Self: "/company/12345678/penalties/late-filing/payable/1234",
+					},
+					Payment: models.PaymentDao{
+						Status: constants.Pending.String(),
+						Amount: "5",
+					},
+				},
+			},
+			nil,
+		)
+		mockDAO.EXPECT().GetLanguage("12345678", "1234").Return(i18n.Welsh, nil)
+
+		var lang string
+		w := httptest.NewRecorder()
+		test := payableAuthenticationInterceptor.PayableAuthenticationIntercept(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			lang = i18n.FromRequest(req)
+		}))
+		test.ServeHTTP(w, req.WithContext(ctx))
+		So(lang, ShouldEqual, i18n.Welsh)
+	})
+
 	Convey("Happy path where user is admin and request is GET", t, func() {
 		path := fmt.Sprintf("/company/12345678/penalties/late-filing/payable/%s", "1234")
 		req, err := http.NewRequest("GET", path, nil)
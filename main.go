@@ -11,8 +11,11 @@ import (
 
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/consumer"
 	"github.com/companieshouse/lfp-pay-api/dao"
 	"github.com/companieshouse/lfp-pay-api/handlers"
+	"github.com/companieshouse/lfp-pay-api/spec"
+	"github.com/companieshouse/lfp-pay-api/validators"
 	"github.com/gorilla/mux"
 )
 
@@ -26,11 +29,40 @@ func main() {
 		return
 	}
 
+	schemes, err := config.LoadPenaltySchemes(config.PenaltySchemesFile)
+	if err != nil {
+		log.Error(fmt.Errorf("error loading penalty schemes: %s. Exiting", err), nil)
+		return
+	}
+
+	err = validators.LoadSchemeRuleSets(schemes)
+	if err != nil {
+		log.Error(fmt.Errorf("error loading payability rules: %s. Exiting", err), nil)
+		return
+	}
+
+	svc := dao.NewDAOService(cfg)
+
+	// the same binary runs as the payment-processed consumer instead of the API when configured to
+	if cfg.Mode == consumer.Mode {
+		runConsumer(cfg, svc, schemes)
+		return
+	}
+
+	apiSpec, err := spec.Load(spec.File)
+	if err != nil {
+		log.Error(fmt.Errorf("error loading api spec: %s. Exiting", err), nil)
+		return
+	}
+
 	// Create router
 	mainRouter := mux.NewRouter()
-	svc := dao.NewDAOService(cfg)
 
-	handlers.Register(mainRouter, cfg, svc)
+	handlers.Register(mainRouter, cfg, svc, schemes, apiSpec)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	handlers.StartPaymentJobPool(jobsCtx)
 
 	log.Info("Starting " + namespace)
 
@@ -58,15 +90,44 @@ func main() {
 	<-stop
 
 	log.Info("shutting down server...")
-	svc.Shutdown()
 	timeout := time.Duration(5) * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	// the server is stopped first so that no more jobs are submitted, then the jobs being completed are finished before
+	// the connection to the database they use is closed
 	err = h.Shutdown(ctx)
 	if err != nil {
 		log.Error(fmt.Errorf("failed to shutdown server gracefully: [%v]", err))
 	} else {
 		log.Info("server shutdown gracefully")
 	}
+
+	stopJobs()
+	handlers.WaitPaymentJobPool()
+	svc.Shutdown()
+}
+
+// runConsumer consumes payment-processed messages until the app is shut down
+func runConsumer(cfg *config.Config, svc dao.Service, schemes []*config.PenaltyScheme) {
+	defer svc.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		log.Info("shutting down consumer...")
+		cancel()
+	}()
+
+	err := consumer.Run(ctx, cfg, svc, schemes)
+	if err != nil {
+		log.Error(fmt.Errorf("payment-processed consumer stopped: [%v]", err))
+		return
+	}
+
+	log.Info("consumer shutdown gracefully")
 }
@@ -10,7 +10,8 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// CompanyMiddleware will intercept the company number in the path and stick it into the context
+// CompanyMiddleware will intercept the company number in the path and stick its canonical form into the context.
+// Requests for company numbers that are not in a recognised format are rejected.
 func CompanyMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -20,7 +21,16 @@ func CompanyMiddleware(h http.Handler) http.Handler {
 		// incorrect route.
 		if err != nil {
 			log.ErrorR(r, err)
+		} else {
+			companyNumber, err = utils.NormaliseCompanyNumber(companyNumber)
+			if err != nil {
+				log.InfoR(r, "invalid company number in request", log.Data{"company_number": vars["company_number"]})
+				m := utils.NewErrorResponse(utils.ErrorCodeInvalidCompanyNumber, "invalid company number")
+				utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
+				return
+			}
 		}
+
 		ctx := context.WithValue(r.Context(), config.CompanyNumber, companyNumber)
 		h.ServeHTTP(w, r.WithContext(ctx))
 	})
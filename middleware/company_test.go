@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveCompanyMiddleware(companyNumber string) (*httptest.ResponseRecorder, interface{}) {
+	var contextCompanyNumber interface{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contextCompanyNumber = r.Context().Value(config.CompanyNumber)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/company/"+companyNumber+"/penalties/late-filing", nil)
+	req = mux.SetURLVars(req, map[string]string{"company_number": companyNumber})
+	res := httptest.NewRecorder()
+
+	CompanyMiddleware(next).ServeHTTP(res, req)
+
+	return res, contextCompanyNumber
+}
+
+func TestUnitCompanyMiddleware(t *testing.T) {
+	Convey("canonical company number is put in the context", t, func() {
+		res, companyNumber := serveCompanyMiddleware("sc123")
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(companyNumber, ShouldEqual, "SC000123")
+	})
+
+	Convey("invalid company number is rejected", t, func() {
+		res, companyNumber := serveCompanyMiddleware("ABC12345")
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		So(companyNumber, ShouldBeNil)
+	})
+}
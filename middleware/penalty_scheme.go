@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/gorilla/mux"
+)
+
+// PenaltySchemeMiddleware will put the penalty scheme that the routes are registered under into the context
+func PenaltySchemeMiddleware(scheme *config.PenaltyScheme) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), config.Scheme, scheme)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
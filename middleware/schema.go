@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/spec"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// SchemaValidationMiddleware checks request and response bodies against the operations described in the API spec and
+// logs those that do not match, to catch drift between the spec and the handlers. When reject is set, requests that do
+// not match are rejected with a 400 and responses that do not match are replaced with a 500. Requests for operations
+// that are not in the spec are not checked.
+func SchemaValidationMiddleware(apiSpec *spec.Spec, reject bool) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := apiSpec.FindOperation(r.Method, r.URL.Path)
+			if op == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if op.HasRequestBody() {
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					log.ErrorR(r, fmt.Errorf("error reading request body: [%v]", err))
+					m := utils.NewErrorResponse(utils.ErrorCodeInvalidRequestBody, "failed to read request body")
+					utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+				violations := op.RequestViolations(body)
+				if len(violations) > 0 {
+					log.InfoR(r, "request body does not match the api spec", violationData(r, violations))
+					if reject {
+						m := utils.NewErrorResponsef(utils.ErrorCodeInvalidRequestBody, "the request body does not match the api spec: %s", violations[0].Message)
+						m.Field = violations[0].Path
+						utils.WriteJSONWithStatus(w, r, m, http.StatusBadRequest)
+						return
+					}
+				}
+			}
+
+			buffer := &responseBuffer{header: http.Header{}}
+			h.ServeHTTP(buffer, r)
+
+			if strings.HasPrefix(buffer.sentHeader().Get("Content-Type"), "application/json") {
+				violations := op.ResponseViolations(buffer.statusCode(), buffer.body.Bytes())
+				if len(violations) > 0 {
+					log.ErrorR(r, fmt.Errorf("response body does not match the api spec"), violationData(r, violations))
+					if reject {
+						m := utils.NewErrorResponsef(utils.ErrorCodeInternalError, "the response body does not match the api spec: %s", violations[0].String())
+						utils.WriteJSONWithStatus(w, r, m, http.StatusInternalServerError)
+						return
+					}
+				}
+			}
+
+			buffer.writeTo(w)
+		})
+	}
+}
+
+// violationData is the log data for the parts of a body that do not match the spec
+func violationData(r *http.Request, violations []spec.Violation) log.Data {
+	described := make([]string, 0, len(violations))
+	for _, v := range violations {
+		described = append(described, v.String())
+	}
+	return log.Data{"method": r.Method, "path": r.URL.Path, "violations": described}
+}
+
+// responseBuffer holds a response until it has been checked against the spec. As with a http.ResponseWriter, only the
+// first status written is kept and headers changed after it has been written are not sent.
+type responseBuffer struct {
+	mtx     sync.Mutex
+	header  http.Header
+	written http.Header
+	status  int
+	body    bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.status == 0 {
+		b.status = status
+		b.written = b.header.Clone()
+	}
+}
+
+func (b *responseBuffer) Write(data []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.body.Write(data)
+}
+
+// statusCode is the status of the response, which is 200 if the handler did not write one
+func (b *responseBuffer) statusCode() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// sentHeader is the header as it would have been sent with the response
+func (b *responseBuffer) sentHeader() http.Header {
+	if b.written == nil {
+		return b.header
+	}
+	return b.written
+}
+
+// writeTo writes the held response
+func (b *responseBuffer) writeTo(w http.ResponseWriter) {
+	for key, values := range b.sentHeader() {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(b.statusCode())
+	w.Write(b.body.Bytes())
+}
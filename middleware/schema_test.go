@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/spec"
+	"github.com/companieshouse/lfp-pay-api/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testSpec = `
+{
+  "swagger": "2.0",
+  "basePath": "/company/{company_number}/penalties/late-filing",
+  "paths": {
+    "/payable": {
+      "post": {
+        "parameters": [
+          {"in": "body", "name": "body", "schema": {"type": "object", "properties": {"reference": {"type": "string"}}}}
+        ],
+        "responses": {
+          "201": {"schema": {"type": "object", "required": ["id"], "properties": {"id": {"type": "string"}}}}
+        }
+      }
+    }
+  }
+}
+`
+
+func serveSchemaValidationMiddleware(path, body, response string, reject bool) (*httptest.ResponseRecorder, string) {
+	apiSpec, _ := spec.New([]byte(testSpec))
+
+	var received string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(response))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	res := httptest.NewRecorder()
+
+	SchemaValidationMiddleware(apiSpec, reject)(next).ServeHTTP(res, req)
+
+	return res, received
+}
+
+func TestUnitSchemaValidationMiddleware(t *testing.T) {
+	path := "/company/10000024/penalties/late-filing/payable"
+
+	Convey("requests and responses that match the spec are passed through", t, func() {
+		res, received := serveSchemaValidationMiddleware(path, `{"reference": "123"}`, `{"id": "LP123456"}`, true)
+
+		So(received, ShouldEqual, `{"reference": "123"}`)
+		So(res.Code, ShouldEqual, http.StatusCreated)
+		So(res.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(res.Body.String(), ShouldEqual, `{"id": "LP123456"}`)
+	})
+
+	Convey("a request that does not match is rejected", t, func() {
+		res, received := serveSchemaValidationMiddleware(path, `{"reference": 123}`, `{"id": "LP123456"}`, true)
+
+		So(received, ShouldBeEmpty)
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		var body utils.ErrorResponse
+		So(json.NewDecoder(res.Body).Decode(&body), ShouldBeNil)
+		So(body.ErrorCode, ShouldEqual, utils.ErrorCodeInvalidRequestBody)
+		So(body.Field, ShouldEqual, "reference")
+	})
+
+	Convey("a response that does not match is replaced", t, func() {
+		res, _ := serveSchemaValidationMiddleware(path, `{"reference": "123"}`, `{}`, true)
+
+		So(res.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("requests and responses that do not match are only logged when not rejecting", t, func() {
+		res, received := serveSchemaValidationMiddleware(path, `{"reference": 123}`, `{}`, false)
+
+		So(received, ShouldEqual, `{"reference": 123}`)
+		So(res.Code, ShouldEqual, http.StatusCreated)
+		So(res.Body.String(), ShouldEqual, `{}`)
+	})
+
+	Convey("operations that are not in the spec are not checked", t, func() {
+		res, _ := serveSchemaValidationMiddleware(path+"/LP123456/refund", `{"reference": 123}`, `{}`, true)
+
+		So(res.Code, ShouldEqual, http.StatusCreated)
+	})
+}
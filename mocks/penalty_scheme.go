@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+
+	"github.com/companieshouse/lfp-pay-api/config"
+)
+
+// LateFilingScheme loads the late filing penalty scheme from the assets shipped with the service, for tests in any
+// package to use. It panics if the assets cannot be read, as no test that uses it could pass.
+func LateFilingScheme() *config.PenaltyScheme {
+	// the assets are found relative to this file, as tests run in the directory of the package being tested
+	_, file, _, _ := runtime.Caller(0)
+	schemes, err := config.LoadPenaltySchemes(filepath.Join(filepath.Dir(file), "..", config.PenaltySchemesFile))
+	if err != nil {
+		panic(fmt.Sprintf("error loading penalty schemes: [%v]", err))
+	}
+	return schemes[0]
+}
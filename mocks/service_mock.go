@@ -6,9 +6,12 @@ package mocks
 
 import (
 	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
 	"github.com/companieshouse/lfp-pay-api/e5"
 	"github.com/golang/mock/gomock"
 	"reflect"
+	"time"
 )
 
 // MockService is a mock of Service interface
@@ -35,15 +38,15 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 }
 
 // CreatePayableResource mocks base method
-func (m *MockService) CreatePayableResource(dao *models.PayableResourceDao) error {
-	ret := m.ctrl.Call(m, "CreatePayableResource", dao)
+func (m *MockService) CreatePayableResource(dao *models.PayableResourceDao, scheme string) error {
+	ret := m.ctrl.Call(m, "CreatePayableResource", dao, scheme)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreatePayableResource indicates an expected call of CreatePayableResource
-func (mr *MockServiceMockRecorder) CreatePayableResource(dao interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayableResource", reflect.TypeOf((*MockService)(nil).CreatePayableResource), dao)
+func (mr *MockServiceMockRecorder) CreatePayableResource(dao, scheme interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayableResource", reflect.TypeOf((*MockService)(nil).CreatePayableResource), dao, scheme)
 }
 
 // GetPayableResource mocks base method
@@ -59,6 +62,19 @@ func (mr *MockServiceMockRecorder) GetPayableResource(companyNumber, reference i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayableResource", reflect.TypeOf((*MockService)(nil).GetPayableResource), companyNumber, reference)
 }
 
+// IsInScheme mocks base method
+func (m *MockService) IsInScheme(companyNumber, reference string, scheme *config.PenaltyScheme) (bool, error) {
+	ret := m.ctrl.Call(m, "IsInScheme", companyNumber, reference, scheme)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsInScheme indicates an expected call of IsInScheme
+func (mr *MockServiceMockRecorder) IsInScheme(companyNumber, reference, scheme interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsInScheme", reflect.TypeOf((*MockService)(nil).IsInScheme), companyNumber, reference, scheme)
+}
+
 // UpdatePaymentDetails mocks base method
 func (m *MockService) UpdatePaymentDetails(dao *models.PayableResourceDao) error {
 	ret := m.ctrl.Call(m, "UpdatePaymentDetails", dao)
@@ -71,6 +87,117 @@ func (mr *MockServiceMockRecorder) UpdatePaymentDetails(dao interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePaymentDetails", reflect.TypeOf((*MockService)(nil).UpdatePaymentDetails), dao)
 }
 
+// MarkAsPaid mocks base method
+func (m *MockService) MarkAsPaid(payable *models.PayableResourceDao, completion *dao.PaymentCompletionDao) error {
+	ret := m.ctrl.Call(m, "MarkAsPaid", payable, completion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkAsPaid indicates an expected call of MarkAsPaid
+func (mr *MockServiceMockRecorder) MarkAsPaid(payable, completion interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkAsPaid", reflect.TypeOf((*MockService)(nil).MarkAsPaid), payable, completion)
+}
+
+// ClaimPaymentCompletion mocks base method
+func (m *MockService) ClaimPaymentCompletion(companyNumber, reference string, staleBefore time.Time) (*dao.PaymentCompletionDao, error) {
+	ret := m.ctrl.Call(m, "ClaimPaymentCompletion", companyNumber, reference, staleBefore)
+	ret0, _ := ret[0].(*dao.PaymentCompletionDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimPaymentCompletion indicates an expected call of ClaimPaymentCompletion
+func (mr *MockServiceMockRecorder) ClaimPaymentCompletion(companyNumber, reference, staleBefore interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimPaymentCompletion", reflect.TypeOf((*MockService)(nil).ClaimPaymentCompletion), companyNumber, reference, staleBefore)
+}
+
+// UpdatePaymentCompletion mocks base method
+func (m *MockService) UpdatePaymentCompletion(companyNumber, reference string, completion *dao.PaymentCompletionDao) error {
+	ret := m.ctrl.Call(m, "UpdatePaymentCompletion", companyNumber, reference, completion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePaymentCompletion indicates an expected call of UpdatePaymentCompletion
+func (mr *MockServiceMockRecorder) UpdatePaymentCompletion(companyNumber, reference, completion interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePaymentCompletion", reflect.TypeOf((*MockService)(nil).UpdatePaymentCompletion), companyNumber, reference, completion)
+}
+
+// SettleOffline mocks base method
+func (m *MockService) SettleOffline(payable *models.PayableResourceDao, entry *dao.AuditEntryDao) error {
+	ret := m.ctrl.Call(m, "SettleOffline", payable, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SettleOffline indicates an expected call of SettleOffline
+func (mr *MockServiceMockRecorder) SettleOffline(payable, entry interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SettleOffline", reflect.TypeOf((*MockService)(nil).SettleOffline), payable, entry)
+}
+
+// ClearE5Error mocks base method
+func (m *MockService) ClearE5Error(companyNumber, reference string) error {
+	ret := m.ctrl.Call(m, "ClearE5Error", companyNumber, reference)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearE5Error indicates an expected call of ClearE5Error
+func (mr *MockServiceMockRecorder) ClearE5Error(companyNumber, reference interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearE5Error", reflect.TypeOf((*MockService)(nil).ClearE5Error), companyNumber, reference)
+}
+
+// SaveE5PaymentID mocks base method
+func (m *MockService) SaveE5PaymentID(companyNumber, reference, paymentID string) error {
+	ret := m.ctrl.Call(m, "SaveE5PaymentID", companyNumber, reference, paymentID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveE5PaymentID indicates an expected call of SaveE5PaymentID
+func (mr *MockServiceMockRecorder) SaveE5PaymentID(companyNumber, reference, paymentID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveE5PaymentID", reflect.TypeOf((*MockService)(nil).SaveE5PaymentID), companyNumber, reference, paymentID)
+}
+
+// GetE5PaymentID mocks base method
+func (m *MockService) GetE5PaymentID(companyNumber, reference string) (string, error) {
+	ret := m.ctrl.Call(m, "GetE5PaymentID", companyNumber, reference)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetE5PaymentID indicates an expected call of GetE5PaymentID
+func (mr *MockServiceMockRecorder) GetE5PaymentID(companyNumber, reference interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetE5PaymentID", reflect.TypeOf((*MockService)(nil).GetE5PaymentID), companyNumber, reference)
+}
+
+// SaveLanguage mocks base method
+func (m *MockService) SaveLanguage(companyNumber, reference, lang string) error {
+	ret := m.ctrl.Call(m, "SaveLanguage", companyNumber, reference, lang)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveLanguage indicates an expected call of SaveLanguage
+func (mr *MockServiceMockRecorder) SaveLanguage(companyNumber, reference, lang interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveLanguage", reflect.TypeOf((*MockService)(nil).SaveLanguage), companyNumber, reference, lang)
+}
+
+// GetLanguage mocks base method
+func (m *MockService) GetLanguage(companyNumber, reference string) (string, error) {
+	ret := m.ctrl.Call(m, "GetLanguage", companyNumber, reference)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLanguage indicates an expected call of GetLanguage
+func (mr *MockServiceMockRecorder) GetLanguage(companyNumber, reference interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLanguage", reflect.TypeOf((*MockService)(nil).GetLanguage), companyNumber, reference)
+}
+
 // SaveE5Error mocks base method
 func (m *MockService) SaveE5Error(companyNumber, reference string, action e5.Action) error {
 	ret := m.ctrl.Call(m, "SaveE5Error", companyNumber, reference, action)
@@ -83,6 +210,236 @@ func (mr *MockServiceMockRecorder) SaveE5Error(companyNumber, reference, action
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveE5Error", reflect.TypeOf((*MockService)(nil).SaveE5Error), companyNumber, reference, action)
 }
 
+// CountE5CommandErrors mocks base method
+func (m *MockService) CountE5CommandErrors(companyNumber string, scheme *config.PenaltyScheme, actions []e5.Action) (int64, error) {
+	ret := m.ctrl.Call(m, "CountE5CommandErrors", companyNumber, scheme, actions)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountE5CommandErrors indicates an expected call of CountE5CommandErrors
+func (mr *MockServiceMockRecorder) CountE5CommandErrors(companyNumber, scheme, actions interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountE5CommandErrors", reflect.TypeOf((*MockService)(nil).CountE5CommandErrors), companyNumber, scheme, actions)
+}
+
+// GetPayableResourcesForTransaction mocks base method
+func (m *MockService) GetPayableResourcesForTransaction(companyNumber string, scheme *config.PenaltyScheme, transactionID string) ([]models.PayableResourceDao, error) {
+	ret := m.ctrl.Call(m, "GetPayableResourcesForTransaction", companyNumber, scheme, transactionID)
+	ret0, _ := ret[0].([]models.PayableResourceDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPayableResourcesForTransaction indicates an expected call of GetPayableResourcesForTransaction
+func (mr *MockServiceMockRecorder) GetPayableResourcesForTransaction(companyNumber, scheme, transactionID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayableResourcesForTransaction", reflect.TypeOf((*MockService)(nil).GetPayableResourcesForTransaction), companyNumber, scheme, transactionID)
+}
+
+// ListPayableResources mocks base method
+func (m *MockService) ListPayableResources(companyNumber string, filter dao.PayableResourceListFilter) ([]models.PayableResourceDao, int64, error) {
+	ret := m.ctrl.Call(m, "ListPayableResources", companyNumber, filter)
+	ret0, _ := ret[0].([]models.PayableResourceDao)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListPayableResources indicates an expected call of ListPayableResources
+func (mr *MockServiceMockRecorder) ListPayableResources(companyNumber, filter interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPayableResources", reflect.TypeOf((*MockService)(nil).ListPayableResources), companyNumber, filter)
+}
+
+// ListPayableResourcesCreatedBy mocks base method
+func (m *MockService) ListPayableResourcesCreatedBy(userID string, filter dao.PayableResourceListFilter) ([]models.PayableResourceDao, int64, error) {
+	ret := m.ctrl.Call(m, "ListPayableResourcesCreatedBy", userID, filter)
+	ret0, _ := ret[0].([]models.PayableResourceDao)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListPayableResourcesCreatedBy indicates an expected call of ListPayableResourcesCreatedBy
+func (mr *MockServiceMockRecorder) ListPayableResourcesCreatedBy(userID, filter interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPayableResourcesCreatedBy", reflect.TypeOf((*MockService)(nil).ListPayableResourcesCreatedBy), userID, filter)
+}
+
+// SavePaymentCard mocks base method
+func (m *MockService) SavePaymentCard(companyNumber, reference string, card *dao.PaymentCardDao) error {
+	ret := m.ctrl.Call(m, "SavePaymentCard", companyNumber, reference, card)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePaymentCard indicates an expected call of SavePaymentCard
+func (mr *MockServiceMockRecorder) SavePaymentCard(companyNumber, reference, card interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePaymentCard", reflect.TypeOf((*MockService)(nil).SavePaymentCard), companyNumber, reference, card)
+}
+
+// GetPaymentCard mocks base method
+func (m *MockService) GetPaymentCard(companyNumber, reference string) (*dao.PaymentCardDao, error) {
+	ret := m.ctrl.Call(m, "GetPaymentCard", companyNumber, reference)
+	ret0, _ := ret[0].(*dao.PaymentCardDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentCard indicates an expected call of GetPaymentCard
+func (mr *MockServiceMockRecorder) GetPaymentCard(companyNumber, reference interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentCard", reflect.TypeOf((*MockService)(nil).GetPaymentCard), companyNumber, reference)
+}
+
+// CreateRefund mocks base method
+func (m *MockService) CreateRefund(companyNumber, reference string, refund *dao.RefundDao) error {
+	ret := m.ctrl.Call(m, "CreateRefund", companyNumber, reference, refund)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefund indicates an expected call of CreateRefund
+func (mr *MockServiceMockRecorder) CreateRefund(companyNumber, reference, refund interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefund", reflect.TypeOf((*MockService)(nil).CreateRefund), companyNumber, reference, refund)
+}
+
+// UpdateRefund mocks base method
+func (m *MockService) UpdateRefund(companyNumber, reference string, refund *dao.RefundDao) error {
+	ret := m.ctrl.Call(m, "UpdateRefund", companyNumber, reference, refund)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRefund indicates an expected call of UpdateRefund
+func (mr *MockServiceMockRecorder) UpdateRefund(companyNumber, reference, refund interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRefund", reflect.TypeOf((*MockService)(nil).UpdateRefund), companyNumber, reference, refund)
+}
+
+// GetRefund mocks base method
+func (m *MockService) GetRefund(companyNumber, reference string) (*dao.RefundDao, error) {
+	ret := m.ctrl.Call(m, "GetRefund", companyNumber, reference)
+	ret0, _ := ret[0].(*dao.RefundDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefund indicates an expected call of GetRefund
+func (mr *MockServiceMockRecorder) GetRefund(companyNumber, reference interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefund", reflect.TypeOf((*MockService)(nil).GetRefund), companyNumber, reference)
+}
+
+// RecordCancelledPayment mocks base method
+func (m *MockService) RecordCancelledPayment(companyNumber, reference string, payment *dao.CancelledPaymentDao) error {
+	ret := m.ctrl.Call(m, "RecordCancelledPayment", companyNumber, reference, payment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordCancelledPayment indicates an expected call of RecordCancelledPayment
+func (mr *MockServiceMockRecorder) RecordCancelledPayment(companyNumber, reference, payment interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordCancelledPayment", reflect.TypeOf((*MockService)(nil).RecordCancelledPayment), companyNumber, reference, payment)
+}
+
+// GetCancelledPayment mocks base method
+func (m *MockService) GetCancelledPayment(companyNumber, reference string) (*dao.CancelledPaymentDao, error) {
+	ret := m.ctrl.Call(m, "GetCancelledPayment", companyNumber, reference)
+	ret0, _ := ret[0].(*dao.CancelledPaymentDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCancelledPayment indicates an expected call of GetCancelledPayment
+func (mr *MockServiceMockRecorder) GetCancelledPayment(companyNumber, reference interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCancelledPayment", reflect.TypeOf((*MockService)(nil).GetCancelledPayment), companyNumber, reference)
+}
+
+// ListUnrefundedCancelledPayments mocks base method
+func (m *MockService) ListUnrefundedCancelledPayments(scheme *config.PenaltyScheme) ([]dao.CancelledPaymentItemDao, error) {
+	ret := m.ctrl.Call(m, "ListUnrefundedCancelledPayments", scheme)
+	ret0, _ := ret[0].([]dao.CancelledPaymentItemDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnrefundedCancelledPayments indicates an expected call of ListUnrefundedCancelledPayments
+func (mr *MockServiceMockRecorder) ListUnrefundedCancelledPayments(scheme interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnrefundedCancelledPayments", reflect.TypeOf((*MockService)(nil).ListUnrefundedCancelledPayments), scheme)
+}
+
+// ListPendingE5Reversals mocks base method
+func (m *MockService) ListPendingE5Reversals(scheme *config.PenaltyScheme) ([]dao.PendingE5ReversalDao, error) {
+	ret := m.ctrl.Call(m, "ListPendingE5Reversals", scheme)
+	ret0, _ := ret[0].([]dao.PendingE5ReversalDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingE5Reversals indicates an expected call of ListPendingE5Reversals
+func (mr *MockServiceMockRecorder) ListPendingE5Reversals(scheme interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingE5Reversals", reflect.TypeOf((*MockService)(nil).ListPendingE5Reversals), scheme)
+}
+
+// CreatePaymentJob mocks base method
+func (m *MockService) CreatePaymentJob(companyNumber, reference string, job *dao.PaymentJobDao) error {
+	ret := m.ctrl.Call(m, "CreatePaymentJob", companyNumber, reference, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePaymentJob indicates an expected call of CreatePaymentJob
+func (mr *MockServiceMockRecorder) CreatePaymentJob(companyNumber, reference, job interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentJob", reflect.TypeOf((*MockService)(nil).CreatePaymentJob), companyNumber, reference, job)
+}
+
+// ClaimPaymentJob mocks base method
+func (m *MockService) ClaimPaymentJob(companyNumber, reference string, staleBefore time.Time) (*dao.PaymentJobDao, error) {
+	ret := m.ctrl.Call(m, "ClaimPaymentJob", companyNumber, reference, staleBefore)
+	ret0, _ := ret[0].(*dao.PaymentJobDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimPaymentJob indicates an expected call of ClaimPaymentJob
+func (mr *MockServiceMockRecorder) ClaimPaymentJob(companyNumber, reference, staleBefore interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimPaymentJob", reflect.TypeOf((*MockService)(nil).ClaimPaymentJob), companyNumber, reference, staleBefore)
+}
+
+// UpdatePaymentJob mocks base method
+func (m *MockService) UpdatePaymentJob(companyNumber, reference string, job *dao.PaymentJobDao) error {
+	ret := m.ctrl.Call(m, "UpdatePaymentJob", companyNumber, reference, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePaymentJob indicates an expected call of UpdatePaymentJob
+func (mr *MockServiceMockRecorder) UpdatePaymentJob(companyNumber, reference, job interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePaymentJob", reflect.TypeOf((*MockService)(nil).UpdatePaymentJob), companyNumber, reference, job)
+}
+
+// GetPaymentJob mocks base method
+func (m *MockService) GetPaymentJob(companyNumber, reference string) (*dao.PaymentJobDao, error) {
+	ret := m.ctrl.Call(m, "GetPaymentJob", companyNumber, reference)
+	ret0, _ := ret[0].(*dao.PaymentJobDao)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentJob indicates an expected call of GetPaymentJob
+func (mr *MockServiceMockRecorder) GetPaymentJob(companyNumber, reference interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentJob", reflect.TypeOf((*MockService)(nil).GetPaymentJob), companyNumber, reference)
+}
+
+// ListClaimablePaymentJobs mocks base method
+func (m *MockService) ListClaimablePaymentJobs(staleBefore time.Time) ([]dao.PaymentJobKey, error) {
+	ret := m.ctrl.Call(m, "ListClaimablePaymentJobs", staleBefore)
+	ret0, _ := ret[0].([]dao.PaymentJobKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListClaimablePaymentJobs indicates an expected call of ListClaimablePaymentJobs
+func (mr *MockServiceMockRecorder) ListClaimablePaymentJobs(staleBefore interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListClaimablePaymentJobs", reflect.TypeOf((*MockService)(nil).ListClaimablePaymentJobs), staleBefore)
+}
+
 // Shutdown mocks base method
 func (m *MockService) Shutdown() {
 	m.ctrl.Call(m, "Shutdown")
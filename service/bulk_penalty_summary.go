@@ -0,0 +1,87 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// Defaults used when the bulk lookup limits are not configured
+const (
+	DefaultBulkLookupMaxCompanies = 100
+	DefaultBulkLookupConcurrency  = 10
+)
+
+// BulkPenaltySummaryResult is the summary of a single company's penalties in a bulk lookup, or the reason that it
+// could not be summarised
+type BulkPenaltySummaryResult struct {
+	CompanyNumber string          `json:"company_number"`
+	Summary       *PenaltySummary `json:"summary,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// BulkLookupMaxCompanies is the most companies that can be looked up in a single request
+func (s *PayableResourceService) BulkLookupMaxCompanies() int {
+	if s.Config == nil || s.Config.BulkLookupMaxCompanies <= 0 {
+		return DefaultBulkLookupMaxCompanies
+	}
+	return s.Config.BulkLookupMaxCompanies
+}
+
+// bulkLookupConcurrency is the most companies that are looked up in e5 at the same time
+func (s *PayableResourceService) bulkLookupConcurrency() int {
+	if s.Config == nil || s.Config.BulkLookupConcurrency <= 0 {
+		return DefaultBulkLookupConcurrency
+	}
+	return s.Config.BulkLookupConcurrency
+}
+
+// GetPenaltySummaries summarises the penalties of each of the companies. The companies are looked up concurrently,
+// but no more than the configured number at once so that e5 is not flooded. A company that cannot be summarised does
+// not fail the others; its result carries the error instead. Results are in the same order as the company numbers.
+func (s *PayableResourceService) GetPenaltySummaries(companyNumbers []string, scheme *config.PenaltyScheme, isPayable PayabilityCheck) []BulkPenaltySummaryResult {
+	results := make([]BulkPenaltySummaryResult, len(companyNumbers))
+	semaphore := make(chan struct{}, s.bulkLookupConcurrency())
+	var wg sync.WaitGroup
+
+	for i, companyNumber := range companyNumbers {
+		wg.Add(1)
+		go func(i int, companyNumber string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = s.getBulkPenaltySummary(companyNumber, scheme, isPayable)
+		}(i, companyNumber)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func (s *PayableResourceService) getBulkPenaltySummary(companyNumber string, scheme *config.PenaltyScheme, isPayable PayabilityCheck) BulkPenaltySummaryResult {
+	normalised, err := utils.NormaliseCompanyNumber(companyNumber)
+	if err != nil {
+		return BulkPenaltySummaryResult{CompanyNumber: companyNumber, Error: "invalid company number"}
+	}
+
+	result := BulkPenaltySummaryResult{CompanyNumber: normalised}
+
+	summary, responseType, err := s.GetPenaltySummary(normalised, scheme, isPayable)
+	if err != nil {
+		log.Error(err, log.Data{"company_number": normalised})
+		switch responseType {
+		case InvalidData:
+			result.Error = "failed to read finance transactions"
+		default:
+			result.Error = "there was a problem summarising the penalties"
+		}
+		return result
+	}
+
+	result.Summary = summary
+	return result
+}
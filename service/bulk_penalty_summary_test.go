@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitBulkLookupLimits(t *testing.T) {
+	Convey("defaults are used when the limits are not configured", t, func() {
+		svc := &PayableResourceService{Config: &config.Config{}}
+		So(svc.BulkLookupMaxCompanies(), ShouldEqual, DefaultBulkLookupMaxCompanies)
+		So(svc.bulkLookupConcurrency(), ShouldEqual, DefaultBulkLookupConcurrency)
+	})
+
+	Convey("configured limits are used", t, func() {
+		svc := &PayableResourceService{Config: &config.Config{BulkLookupMaxCompanies: 5, BulkLookupConcurrency: 2}}
+		So(svc.BulkLookupMaxCompanies(), ShouldEqual, 5)
+		So(svc.bulkLookupConcurrency(), ShouldEqual, 2)
+	})
+}
+
+func TestUnitGetPenaltySummaries(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+
+	Convey("each company gets its own result, in order, with bounded concurrency", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		var mtx sync.Mutex
+		inFlight, maxInFlight, calls := 0, 0, 0
+		e5Responder := func(req *http.Request) (*http.Response, error) {
+			mtx.Lock()
+			inFlight++
+			calls++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mtx.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mtx.Lock()
+			inFlight--
+			mtx.Unlock()
+			return httpmock.NewStringResponse(http.StatusInternalServerError, ""), nil
+		}
+		for _, companyNumber := range []string{"10000024", "00006400", "SC000123", "10000025", "10000026"} {
+			url := "https://e5/arTransactions/" + companyNumber + "?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+			httpmock.RegisterResponder(http.MethodGet, url, e5Responder)
+		}
+
+		svc := &PayableResourceService{Config: &config.Config{BulkLookupConcurrency: 2}}
+		companyNumbers := []string{"10000024", "6400", "ABC12345", "sc123", "10000025", "10000026"}
+
+		results := svc.GetPenaltySummaries(companyNumbers, mocks.LateFilingScheme(), payableTransactions)
+
+		So(results, ShouldHaveLength, 6)
+		So(results[0].CompanyNumber, ShouldEqual, "10000024")
+		So(results[1].CompanyNumber, ShouldEqual, "00006400")
+		So(results[3].CompanyNumber, ShouldEqual, "SC000123")
+		So(results[5].CompanyNumber, ShouldEqual, "10000026")
+		for _, result := range results {
+			So(result.Summary, ShouldBeNil)
+		}
+		So(results[0].Error, ShouldEqual, "there was a problem summarising the penalties")
+
+		// the invalid company number is rejected without calling e5
+		So(results[2], ShouldResemble, BulkPenaltySummaryResult{CompanyNumber: "ABC12345", Error: "invalid company number"})
+		So(calls, ShouldEqual, 5)
+		So(maxInFlight, ShouldBeLessThanOrEqualTo, 2)
+	})
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+)
+
+// PaymentStatusCancelled is the payment status of a payable resource that has been abandoned. The resource is kept so
+// that there is a record of the attempt, but it can no longer be paid.
+const PaymentStatusCancelled = "cancelled"
+
+var (
+	// ErrAlreadyCancelled is returned when cancelling a payable resource that has already been cancelled
+	ErrAlreadyCancelled = errors.New("the payable resource has already been cancelled")
+	// ErrPaymentInProgress is returned when cancelling a payable resource whose payment is still being processed in E5
+	ErrPaymentInProgress = errors.New("the payable resource is being paid for")
+	// ErrPaidAfterCancellation is returned when the payment platform has taken a payment for a payable resource that
+	// was cancelled while it was being paid for. The payment is recorded so that it can be refunded.
+	ErrPaidAfterCancellation = errors.New("the payable resource was cancelled before it was paid for, so the payment has been recorded to be refunded")
+)
+
+// CancelPayableResource marks a pending payable resource as cancelled. If paying for it failed part way through and
+// left the company's account locked in E5, the E5 payment is timed out first to release the lock. A resource that has
+// been paid, or whose payment may still be in progress in E5, cannot be cancelled.
+func (s *PayableResourceService) CancelPayableResource(resource *models.PayableResource, client *e5.Client, scheme *config.PenaltyScheme) error {
+	logData := log.Data{"lfp_reference": resource.Reference, "company_number": resource.CompanyNumber}
+
+	model, err := s.DAO.GetPayableResource(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting payable resource from db: [%v]", err)
+		log.Error(err, logData)
+		return err
+	}
+	if model == nil {
+		return ErrLFPNotFound
+	}
+
+	if model.IsPaid() {
+		return ErrAlreadyPaid
+	}
+	if model.Data.Payment.Status == PaymentStatusCancelled {
+		return ErrAlreadyCancelled
+	}
+	if model.Data.Payment.Status == PaymentStatusRefunded {
+		return ErrAlreadyRefunded
+	}
+
+	timedOut, err := s.timeoutE5Payment(model, client, scheme, logData)
+	if err != nil {
+		return err
+	}
+
+	model.Data.Payment.Status = PaymentStatusCancelled
+
+	err = s.DAO.UpdatePaymentDetails(model)
+	if err != nil {
+		return err
+	}
+
+	if timedOut {
+		s.clearE5Lock(model, logData)
+	}
+
+	log.Info("payable resource cancelled", logData)
+
+	return nil
+}
+
+// recordCancelledPayment records a payment that the payment platform has taken for a cancelled payable resource, which
+// cannot be marked as paid or posted to E5 as the penalty is no longer being paid for through the service. The card has
+// still been charged, so the payment is kept for finance to refund and ErrPaidAfterCancellation is returned.
+func (s *PayableResourceService) recordCancelledPayment(resource models.PayableResource, payment validators.PaymentInformation) error {
+	logData := log.Data{
+		"lfp_reference":  resource.Reference,
+		"company_number": resource.CompanyNumber,
+		"payment_id":     payment.PaymentID,
+	}
+
+	err := s.DAO.RecordCancelledPayment(resource.CompanyNumber, resource.Reference, &dao.CancelledPaymentDao{
+		PaymentID:  payment.PaymentID,
+		Reference:  payment.Reference,
+		Amount:     payment.Amount,
+		PaidAt:     payment.CompletedAt,
+		RecordedAt: time.Now(),
+	})
+	if err != nil {
+		err = fmt.Errorf("error recording payment for cancelled payable resource: [%v]", err)
+		log.Error(err, logData)
+		return err
+	}
+
+	log.Error(ErrPaidAfterCancellation, logData)
+
+	return ErrPaidAfterCancellation
+}
+
+// timeoutE5Payment times out the payment in E5 for a payable resource that is no longer going to be paid for through
+// the service, releasing the lock it left on the company's account, and returns whether there was a payment to time
+// out. A payment in E5 without a failed command may still be being confirmed, or may have been confirmed without the
+// resource being updated, so it is not safe to time it out and ErrPaymentInProgress is returned.
+func (s *PayableResourceService) timeoutE5Payment(model *models.PayableResourceDao, client *e5.Client, scheme *config.PenaltyScheme, logData log.Data) (bool, error) {
+	e5PaymentID, err := s.DAO.GetE5PaymentID(model.CompanyNumber, model.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting e5 payment id from db: [%v]", err)
+		log.Error(err, logData)
+		return false, err
+	}
+	if e5PaymentID == "" {
+		return false, nil
+	}
+
+	logData["e5_payment_id"] = e5PaymentID
+	logData["e5_command_error"] = model.E5CommandError
+
+	if !isLockingE5Action(model.E5CommandError) {
+		log.Info("payable resource has a payment in e5 that may be in progress", logData)
+		return false, ErrPaymentInProgress
+	}
+
+	err = client.TimeoutPayment(&e5.PaymentActionInput{
+		CompanyCode: scheme.CompanyCode,
+		PaymentID:   e5PaymentID,
+	})
+	if err != nil {
+		err = fmt.Errorf("error timing out payment in e5: [%v]", err)
+		log.Error(err, logData)
+		return false, err
+	}
+
+	log.Info("timed out payment in e5", logData)
+
+	return true, nil
+}
+
+// clearE5Lock removes the failed E5 command from a payable resource whose E5 payment has been timed out, as the
+// account is no longer locked by it. The resource has already been updated so a failure is only logged.
+func (s *PayableResourceService) clearE5Lock(model *models.PayableResourceDao, logData log.Data) {
+	err := s.DAO.ClearE5Error(model.CompanyNumber, model.Reference)
+	if err != nil {
+		log.Error(fmt.Errorf("error clearing e5 command error: [%v]", err), logData)
+	}
+}
+
+// isLockingE5Action checks whether the failed E5 command leaves the company's account locked
+func isLockingE5Action(action string) bool {
+	for _, locking := range lockingE5Actions {
+		if string(locking) == action {
+			return true
+		}
+	}
+	return false
+}
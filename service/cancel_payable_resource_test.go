@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitCancelPayableResource(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+	resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+	client := e5.NewClient("SYSTEM", "https://e5")
+
+	pendingResource := func(e5CommandError string) *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber:  "10000024",
+			Reference:      "LP123456",
+			E5CommandError: e5CommandError,
+			Data:           models.PayableResourceDataDao{Payment: models.PaymentDao{Status: "pending"}},
+		}
+	}
+
+	Convey("error getting the payable resource from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(nil, errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.CancelPayableResource(resource, client, scheme), ShouldNotBeNil)
+	})
+
+	Convey("a paid resource cannot be cancelled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		paid := pendingResource("")
+		paid.Data.Payment.Status = "paid"
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.CancelPayableResource(resource, client, scheme), ShouldEqual, ErrAlreadyPaid)
+	})
+
+	Convey("a cancelled resource cannot be cancelled again", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		cancelled := pendingResource("")
+		cancelled.Data.Payment.Status = PaymentStatusCancelled
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(cancelled, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.CancelPayableResource(resource, client, scheme), ShouldEqual, ErrAlreadyCancelled)
+	})
+
+	Convey("a resource without a payment in e5 is cancelled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		model := pendingResource("")
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(model, nil)
+		mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+		mockDaoService.EXPECT().UpdatePaymentDetails(model).Return(nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.CancelPayableResource(resource, client, scheme), ShouldBeNil)
+		So(model.Data.Payment.Status, ShouldEqual, PaymentStatusCancelled)
+	})
+
+	Convey("a resource with a payment in e5 that may be in progress is not cancelled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pendingResource(""), nil)
+		mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("X123", nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.CancelPayableResource(resource, client, scheme), ShouldEqual, ErrPaymentInProgress)
+	})
+
+	Convey("a resource that left e5 locked", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		Convey("has its e5 payment timed out and is cancelled", func() {
+			defer httpmock.Reset()
+			httpmock.RegisterResponder(http.MethodPost, "https://e5/arTransactions/payment/timeout", httpmock.NewBytesResponder(http.StatusOK, nil))
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			model := pendingResource(string(e5.ConfirmAction))
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(model, nil)
+			mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("X123", nil)
+			mockDaoService.EXPECT().UpdatePaymentDetails(model).Return(nil)
+			mockDaoService.EXPECT().ClearE5Error("10000024", "LP123456").Return(nil)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			So(svc.CancelPayableResource(resource, client, scheme), ShouldBeNil)
+			So(model.Data.Payment.Status, ShouldEqual, PaymentStatusCancelled)
+		})
+
+		Convey("is not cancelled when the e5 payment cannot be timed out", func() {
+			defer httpmock.Reset()
+			httpmock.RegisterResponder(http.MethodPost, "https://e5/arTransactions/payment/timeout", httpmock.NewStringResponder(http.StatusBadRequest, e5ValidationError))
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pendingResource(string(e5.AuthoriseAction)), nil)
+			mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("X123", nil)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			So(svc.CancelPayableResource(resource, client, scheme), ShouldNotBeNil)
+		})
+	})
+}
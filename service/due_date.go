@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	// embed the timezone database so that UK time is available whether or not the host has zoneinfo installed
+	_ "time/tzdata"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+)
+
+// ukTime is the timezone that E5 dates are in. A penalty is due until the end of its due date in the UK.
+var ukTime = mustLoadLocation("Europe/London")
+
+// now returns the current time. It can be replaced in tests to fix the date that due dates are compared against.
+var now = time.Now
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("error loading timezone [%s]: [%v]", name, err))
+	}
+	return location
+}
+
+// DueStatus is how long is left before a penalty is due or how long it has been overdue, as of today in the UK
+type DueStatus struct {
+	DaysUntilDue int  `json:"days_until_due"`
+	IsOverdue    bool `json:"is_overdue"`
+	DaysOverdue  int  `json:"days_overdue"`
+	IsUnknown    bool `json:"is_due_status_unknown,omitempty"`
+}
+
+// ParseE5Date parses a date returned by E5 as midnight at the start of that day in the UK
+func ParseE5Date(value string) (time.Time, error) {
+	return time.ParseInLocation(e5DateLayout, value, ukTime)
+}
+
+// GetDueStatus works out the due status of the transaction as of the given time. A paid transaction is never overdue
+// and has no days until it is due. The due status of an unpaid transaction with a missing or invalid due date is
+// unknown, so that one bad date from E5 does not stop the rest of the transactions being shown.
+func GetDueStatus(transaction models.TransactionListItem, at time.Time) DueStatus {
+	if transaction.IsPaid {
+		return DueStatus{}
+	}
+
+	dueDate, err := ParseE5Date(transaction.DueDate)
+	if err != nil {
+		log.Error(fmt.Errorf("error parsing due date for transaction [%s]: [%v]", transaction.ID, err),
+			log.Data{"transaction_id": transaction.ID, "due_date": transaction.DueDate})
+		return DueStatus{IsUnknown: true}
+	}
+
+	days := daysBetween(at.In(ukTime), dueDate)
+	if days < 0 {
+		return DueStatus{IsOverdue: true, DaysOverdue: -days}
+	}
+
+	return DueStatus{DaysUntilDue: days}
+}
+
+// GetCurrentDueStatus works out the due status of the transaction as of today
+func GetCurrentDueStatus(transaction models.TransactionListItem) DueStatus {
+	return GetDueStatus(transaction, now())
+}
+
+// daysBetween counts the calendar days from one date to another, ignoring the time of day. The dates are compared in
+// UTC so that a clock change between them does not make a day 23 or 25 hours long.
+func daysBetween(from, to time.Time) int {
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
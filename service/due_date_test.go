@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitGetDueStatus(t *testing.T) {
+	penalty := models.TransactionListItem{ID: "00378420", Type: "penalty", DueDate: "2019-07-11"}
+
+	Convey("days until due are counted from today", t, func() {
+		status := GetDueStatus(penalty, time.Date(2019, 7, 1, 12, 0, 0, 0, ukTime))
+		So(status, ShouldResemble, DueStatus{DaysUntilDue: 10})
+	})
+
+	Convey("a penalty is not overdue on its due date", t, func() {
+		status := GetDueStatus(penalty, time.Date(2019, 7, 11, 23, 59, 0, 0, ukTime))
+		So(status, ShouldResemble, DueStatus{})
+	})
+
+	Convey("the due date is in UK time", t, func() {
+		// half past midnight on the 12th in the UK is still the 11th in UTC during British Summer Time
+		status := GetDueStatus(penalty, time.Date(2019, 7, 11, 23, 30, 0, 0, time.UTC))
+		So(status, ShouldResemble, DueStatus{IsOverdue: true, DaysOverdue: 1})
+	})
+
+	Convey("days overdue are counted across a clock change", t, func() {
+		status := GetDueStatus(penalty, time.Date(2019, 11, 11, 9, 0, 0, 0, ukTime))
+		So(status, ShouldResemble, DueStatus{IsOverdue: true, DaysOverdue: 123})
+	})
+
+	Convey("a paid penalty is never overdue", t, func() {
+		paid := penalty
+		paid.IsPaid = true
+		status := GetDueStatus(paid, time.Date(2019, 11, 11, 9, 0, 0, 0, ukTime))
+		So(status, ShouldResemble, DueStatus{})
+	})
+
+	Convey("the due status is unknown when the due date is invalid or missing", t, func() {
+		for _, dueDate := range []string{"11/07/2019", ""} {
+			invalid := penalty
+			invalid.DueDate = dueDate
+			So(GetDueStatus(invalid, time.Now()), ShouldResemble, DueStatus{IsUnknown: true})
+		}
+	})
+
+	Convey("a paid penalty without a due date is not unknown", t, func() {
+		paid := penalty
+		paid.IsPaid = true
+		paid.DueDate = ""
+		So(GetDueStatus(paid, time.Now()), ShouldResemble, DueStatus{})
+	})
+
+	Convey("the current due status is worked out as of today", t, func() {
+		now = func() time.Time { return time.Date(2019, 7, 1, 12, 0, 0, 0, ukTime) }
+		defer func() { now = time.Now }()
+		So(GetCurrentDueStatus(penalty), ShouldResemble, DueStatus{DaysUntilDue: 10})
+	})
+}
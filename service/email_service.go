@@ -13,20 +13,46 @@ import (
 	"github.com/companieshouse/filing-notification-sender/util"
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 )
 
-const lfpReceivedAppID = "lfp-pay-api.late_filing_penalty_received_email"
-const lfpFilingDescription = "Late Filing Penalty"
-const lfpMessageType = "late_filing_penalty_received_email"
-
 // ProducerTopic is the topic to which the email-send kafka message is sent
 const ProducerTopic = "email-send"
 
 // ProducerSchemaName is the schema which will be used to send the email-send kafka message with
 const ProducerSchemaName = "email-send"
 
-// SendEmailKafkaMessage sends a kafka message to the email-sender to send an email
-func SendEmailKafkaMessage(payableResource models.PayableResource, req *http.Request) error {
+// emailData is the data used in the confirmation email, listing every penalty that was paid for. The language is the
+// one the email is written in, so that the email-sender can choose the template for it.
+type emailData struct {
+	models.DataField
+	Language  string         `json:"language"`
+	Penalties []emailPenalty `json:"penalties"`
+}
+
+// refundEmailData is the data used in the refund email
+type refundEmailData struct {
+	models.DataField
+	Language string `json:"language"`
+}
+
+// emailPenalty is a penalty that was paid for, with its dates in a readable format for the email
+type emailPenalty struct {
+	TransactionID   string `json:"transaction_id"`
+	MadeUpDate      string `json:"made_up_date"`
+	TransactionDate string `json:"transaction_date"`
+	Amount          string `json:"amount"`
+}
+
+// SendEmailKafkaMessage sends a kafka message to the email-sender to send an email written in the language
+func SendEmailKafkaMessage(payableResource models.PayableResource, lang string, req *http.Request) error {
+	return sendEmailKafkaMessage(func(emailSendSchema avro.Schema) (*producer.Message, error) {
+		return prepareKafkaMessage(emailSendSchema, payableResource, lang, req)
+	})
+}
+
+// sendEmailKafkaMessage sends the email-send kafka message prepared with the email-send avro schema
+func sendEmailKafkaMessage(prepare func(emailSendSchema avro.Schema) (*producer.Message, error)) error {
 	cfg, err := config.Get()
 	if err != nil {
 		err = fmt.Errorf("error getting config for kafka message production: [%v]", err)
@@ -49,7 +75,7 @@ func SendEmailKafkaMessage(payableResource models.PayableResource, req *http.Req
 	}
 
 	// Prepare a message with the avro schema
-	message, err := prepareKafkaMessage(*producerSchema, payableResource, req)
+	message, err := prepare(*producerSchema)
 	if err != nil {
 		err = fmt.Errorf("error preparing kafka message with schema: [%v]", err)
 		return err
@@ -64,14 +90,21 @@ func SendEmailKafkaMessage(payableResource models.PayableResource, req *http.Req
 	return nil
 }
 
-// prepareKafkaMessage generates the kafka message that is to be sent
-func prepareKafkaMessage(emailSendSchema avro.Schema, payableResource models.PayableResource, req *http.Request) (*producer.Message, error) {
+// prepareKafkaMessage generates the kafka message that is to be sent, with the subject, description and dates of the
+// email in the language
+func prepareKafkaMessage(emailSendSchema avro.Schema, payableResource models.PayableResource, lang string, req *http.Request) (*producer.Message, error) {
 	cfg, err := config.Get()
 	if err != nil {
 		err = fmt.Errorf("error getting config: [%v]", err)
 		return nil, err
 	}
 
+	// the app id, message type and description of the email depend on the penalty scheme being paid for
+	scheme, err := config.GetPenaltyScheme(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
 	// Access Company Name to be included in the email
 	companyName, err := GetCompanyName(payableResource.CompanyNumber, req)
 	if err != nil {
@@ -79,37 +112,53 @@ func prepareKafkaMessage(emailSendSchema avro.Schema, payableResource models.Pay
 		return nil, err
 	}
 
-	// Access specific transaction that was paid for
-	payedTransaction, err := GetTransactionForPenalty(payableResource.CompanyNumber, payableResource.Transactions[0].TransactionID)
-	if err != nil {
-		err = fmt.Errorf("error getting transaction for LFP: [%v]", err)
+	if len(payableResource.Transactions) == 0 {
+		err = fmt.Errorf("no transactions in payable resource [%s]", payableResource.Reference)
 		return nil, err
 	}
 
-	// Convert madeUpDate and transactionDate to readable format for email
-	madeUpDate, err := time.Parse("2006-01-02", payedTransaction.MadeUpDate)
+	// Access every transaction that was paid for
+	transactions, _, err := GetPenalties(payableResource.CompanyNumber, scheme)
 	if err != nil {
-		err = fmt.Errorf("error parsing made up date: [%v]", err)
+		err = fmt.Errorf("error getting transactions for LFP: [%v]", err)
 		return nil, err
 	}
-	transactionDate, err := time.Parse("2006-01-02", payedTransaction.TransactionDate)
-	if err != nil {
-		err = fmt.Errorf("error parsing penalty date: [%v]", err)
-		return nil, err
+
+	var paidPenalties []emailPenalty
+	var totalAmount float64
+	for _, tx := range payableResource.Transactions {
+		paidTransaction := FindTransaction(transactions, tx.TransactionID)
+		if paidTransaction == nil {
+			err = fmt.Errorf("error getting transaction for LFP: [cannot find lfp transaction for penalty number [%v]]", tx.TransactionID)
+			return nil, err
+		}
+
+		// the amount paid off the penalty is less than its original amount when it has been part paid
+		penalty, err := newEmailPenalty(paidTransaction, tx.Amount, lang)
+		if err != nil {
+			return nil, err
+		}
+		paidPenalties = append(paidPenalties, *penalty)
+		totalAmount += tx.Amount
 	}
 
-	// Set dataField to be used in the avro schema.
-	dataFieldMessage := models.DataField{
-		PayableResource:   payableResource,
-		TransactionID:     payableResource.Transactions[0].TransactionID,
-		MadeUpDate:        madeUpDate.Format("2 January 2006"),
-		TransactionDate:   transactionDate.Format("2 January 2006"),
-		Amount:            fmt.Sprintf("%g", payedTransaction.OriginalAmount),
-		CompanyName:       companyName,
-		FilingDescription: lfpFilingDescription,
-		To:                payableResource.CreatedBy.Email,
-		Subject:           fmt.Sprintf("Confirmation of your Companies House penalty payment"),
-		CHSURL:            cfg.CHSURL,
+	// Set dataField to be used in the avro schema. The single penalty fields describe the first penalty, with the
+	// amount being the total paid, so that emails for one penalty are unchanged.
+	dataFieldMessage := emailData{
+		DataField: models.DataField{
+			PayableResource:   payableResource,
+			TransactionID:     paidPenalties[0].TransactionID,
+			MadeUpDate:        paidPenalties[0].MadeUpDate,
+			TransactionDate:   paidPenalties[0].TransactionDate,
+			Amount:            fmt.Sprintf("%g", totalAmount),
+			CompanyName:       companyName,
+			FilingDescription: scheme.LocalisedDescription(lang),
+			To:                payableResource.CreatedBy.Email,
+			Subject:           i18n.Translate(lang, "Confirmation of your Companies House penalty payment"),
+			CHSURL:            cfg.CHSURL,
+		},
+		Language:  lang,
+		Penalties: paidPenalties,
 	}
 
 	dataBytes, err := json.Marshal(dataFieldMessage)
@@ -118,13 +167,19 @@ func prepareKafkaMessage(emailSendSchema avro.Schema, payableResource models.Pay
 		return nil, err
 	}
 
+	return newEmailSendMessage(emailSendSchema, scheme.EmailAppID, scheme.EmailMessageType, payableResource, dataBytes)
+}
+
+// newEmailSendMessage generates the email-send kafka message for an email about the payable resource, sent to the user
+// that created it
+func newEmailSendMessage(emailSendSchema avro.Schema, appID, messageType string, payableResource models.PayableResource, data []byte) (*producer.Message, error) {
 	messageID := "<" + payableResource.Reference + "." + strconv.Itoa(util.Random(0, 100000)) + "@companieshouse.gov.uk>"
 
 	emailSendMessage := models.EmailSend{
-		AppID:        lfpReceivedAppID,
+		AppID:        appID,
 		MessageID:    messageID,
-		MessageType:  lfpMessageType,
-		Data:         string(dataBytes),
+		MessageType:  messageType,
+		Data:         string(data),
 		EmailAddress: payableResource.CreatedBy.Email,
 		CreatedAt:    time.Now().String(),
 	}
@@ -141,3 +196,74 @@ func prepareKafkaMessage(emailSendSchema avro.Schema, payableResource models.Pay
 	}
 	return producerMessage, nil
 }
+
+// newEmailPenalty converts the made up date and transaction date of the transaction to a readable format for the email,
+// as dates are written in the language, along with the amount paid off it
+func newEmailPenalty(transaction *models.TransactionListItem, amount float64, lang string) (*emailPenalty, error) {
+	madeUpDate, err := time.Parse("2006-01-02", transaction.MadeUpDate)
+	if err != nil {
+		err = fmt.Errorf("error parsing made up date: [%v]", err)
+		return nil, err
+	}
+	transactionDate, err := time.Parse("2006-01-02", transaction.TransactionDate)
+	if err != nil {
+		err = fmt.Errorf("error parsing penalty date: [%v]", err)
+		return nil, err
+	}
+
+	return &emailPenalty{
+		TransactionID:   transaction.ID,
+		MadeUpDate:      i18n.FormatDate(lang, madeUpDate),
+		TransactionDate: i18n.FormatDate(lang, transactionDate),
+		Amount:          fmt.Sprintf("%g", amount),
+	}, nil
+}
+
+// SendRefundEmailKafkaMessage sends a kafka message to the email-sender to tell the user that paid for the payable
+// resource that their payment is being refunded, in the language
+func SendRefundEmailKafkaMessage(payableResource models.PayableResource, refund *Refund, lang string, req *http.Request) error {
+	return sendEmailKafkaMessage(func(emailSendSchema avro.Schema) (*producer.Message, error) {
+		return prepareRefundKafkaMessage(emailSendSchema, payableResource, refund, lang, req)
+	})
+}
+
+// prepareRefundKafkaMessage generates the kafka message for the refund email in the language
+func prepareRefundKafkaMessage(emailSendSchema avro.Schema, payableResource models.PayableResource, refund *Refund, lang string, req *http.Request) (*producer.Message, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		err = fmt.Errorf("error getting config: [%v]", err)
+		return nil, err
+	}
+
+	scheme, err := config.GetPenaltyScheme(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	companyName, err := GetCompanyName(payableResource.CompanyNumber, req)
+	if err != nil {
+		err = fmt.Errorf("error getting company name: [%v]", err)
+		return nil, err
+	}
+
+	dataFieldMessage := refundEmailData{
+		DataField: models.DataField{
+			PayableResource:   payableResource,
+			Amount:            refund.Amount,
+			CompanyName:       companyName,
+			FilingDescription: scheme.LocalisedDescription(lang),
+			To:                payableResource.CreatedBy.Email,
+			Subject:           i18n.Translate(lang, "Your Companies House penalty payment is being refunded"),
+			CHSURL:            cfg.CHSURL,
+		},
+		Language: lang,
+	}
+
+	dataBytes, err := json.Marshal(dataFieldMessage)
+	if err != nil {
+		err = fmt.Errorf("error marshalling dataFieldMessage: [%v]", err)
+		return nil, err
+	}
+
+	return newEmailSendMessage(emailSendSchema, scheme.RefundEmailAppID, scheme.RefundEmailMessageType, payableResource, dataBytes)
+}
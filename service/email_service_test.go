@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/i18n"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitNewEmailPenalty(t *testing.T) {
+	Convey("dates are converted to a readable format", t, func() {
+		penalty, err := newEmailPenalty(&models.TransactionListItem{
+			ID:              "00378420",
+			MadeUpDate:      "2017-02-28",
+			TransactionDate: "2017-11-28",
+			OriginalAmount:  150,
+		}, 150, i18n.English)
+
+		So(err, ShouldBeNil)
+		So(penalty.TransactionID, ShouldEqual, "00378420")
+		So(penalty.MadeUpDate, ShouldEqual, "28 February 2017")
+		So(penalty.TransactionDate, ShouldEqual, "28 November 2017")
+		So(penalty.Amount, ShouldEqual, "150")
+	})
+
+	Convey("dates are written in Welsh for Welsh emails", t, func() {
+		penalty, err := newEmailPenalty(&models.TransactionListItem{
+			ID:              "00378420",
+			MadeUpDate:      "2017-02-28",
+			TransactionDate: "2017-11-28",
+			OriginalAmount:  150,
+		}, 150, i18n.Welsh)
+
+		So(err, ShouldBeNil)
+		So(penalty.MadeUpDate, ShouldEqual, "28 Chwefror 2017")
+		So(penalty.TransactionDate, ShouldEqual, "28 Tachwedd 2017")
+	})
+
+	Convey("the amount is what was paid off a part paid penalty", t, func() {
+		penalty, err := newEmailPenalty(&models.TransactionListItem{
+			ID:              "00378420",
+			MadeUpDate:      "2017-02-28",
+			TransactionDate: "2017-11-28",
+			OriginalAmount:  150,
+		}, 50, i18n.English)
+
+		So(err, ShouldBeNil)
+		So(penalty.Amount, ShouldEqual, "50")
+	})
+
+	Convey("error when a date cannot be parsed", t, func() {
+		penalty, err := newEmailPenalty(&models.TransactionListItem{MadeUpDate: "28/02/2017", TransactionDate: "2017-11-28"}, 150, i18n.English)
+		So(penalty, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+)
+
+// ErrInvalidExternalReference is returned when the external reference of an offline settlement is not an E5 payment ID
+// of the penalty scheme, which starts with the scheme's company code
+var ErrInvalidExternalReference = errors.New("the external reference is not a payment ID for the penalty scheme")
+
+// OfflineSettlement describes a payment for a payable resource that was made outside of the service, e.g. by cheque or
+// bank transfer, and the member of finance staff recording it
+type OfflineSettlement struct {
+	Reason            string
+	ExternalReference string
+	UserID            string
+	Email             string
+}
+
+// SettleOffline marks a payable resource as paid because it has been settled outside of the service. The finance
+// system already holds the payment, whose ID is the external reference, so unlike UpdateAsPaid no payment is sent to
+// E5. If an earlier attempt to pay online left the company's account locked in E5, that payment is timed out to
+// release the lock, as for a cancellation. The settlement is recorded against the resource in an audit entry.
+func (s *PayableResourceService) SettleOffline(resource *models.PayableResource, settlement OfflineSettlement, client *e5.Client, scheme *config.PenaltyScheme) error {
+	logData := log.Data{
+		"lfp_reference":      resource.Reference,
+		"company_number":     resource.CompanyNumber,
+		"external_reference": settlement.ExternalReference,
+		"user_id":            settlement.UserID,
+	}
+
+	if len(settlement.ExternalReference) <= len(scheme.CompanyCode) || !strings.HasPrefix(settlement.ExternalReference, scheme.CompanyCode) {
+		log.Info("external reference is not an e5 payment id for the penalty scheme", logData)
+		return ErrInvalidExternalReference
+	}
+
+	model, err := s.DAO.GetPayableResource(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting payable resource from db: [%v]", err)
+		log.Error(err, logData)
+		return err
+	}
+	if model == nil {
+		return ErrLFPNotFound
+	}
+
+	if model.IsPaid() {
+		return ErrAlreadyPaid
+	}
+	if model.Data.Payment.Status == PaymentStatusCancelled {
+		return ErrAlreadyCancelled
+	}
+	if model.Data.Payment.Status == PaymentStatusRefunded {
+		return ErrAlreadyRefunded
+	}
+
+	// the settlement must be for the version of the resource the caller looked at
+	if resource.Etag != "" && model.Data.Etag != resource.Etag {
+		log.Info("payable resource has been modified since it was read", logData)
+		return dao.ErrEtagMismatch
+	}
+
+	timedOut, err := s.timeoutE5Payment(model, client, scheme, logData)
+	if err != nil {
+		return err
+	}
+
+	var total float64
+	for _, tx := range model.Data.Transactions {
+		total += tx.Amount
+	}
+
+	now := time.Now()
+
+	model.Data.Payment.Status = constants.Paid.String()
+	model.Data.Payment.Reference = settlement.ExternalReference
+	model.Data.Payment.PaidAt = &now
+	model.Data.Payment.Amount = fmt.Sprintf("%.2f", total)
+
+	err = s.DAO.SettleOffline(model, &dao.AuditEntryDao{
+		Action:            dao.AuditActionSettledOffline,
+		UserID:            settlement.UserID,
+		Email:             settlement.Email,
+		Reason:            settlement.Reason,
+		ExternalReference: settlement.ExternalReference,
+		CreatedAt:         now,
+	})
+	if err != nil {
+		return err
+	}
+
+	if timedOut {
+		s.clearE5Lock(model, logData)
+	}
+
+	log.Info("payable resource settled offline", logData)
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitSettleOffline(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+	client := e5.NewClient("SYSTEM", "https://e5")
+	resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456", Etag: "etag"}
+	settlement := OfflineSettlement{
+		Reason:            "paid by cheque",
+		ExternalReference: "LP0001234",
+		UserID:            "user-id",
+		Email:             "finance@companieshouse.gov.uk",
+	}
+
+	pendingResource := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber: "10000024",
+			Reference:     "LP123456",
+			Data: models.PayableResourceDataDao{
+				Etag: "etag",
+				Transactions: map[string]models.TransactionDao{
+					"A0000001": {Amount: 150},
+					"A0000002": {Amount: 50.5},
+				},
+				Payment: models.PaymentDao{Status: "pending"},
+			},
+		}
+	}
+
+	Convey("an external reference that is not an e5 payment id for the scheme is rejected", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		svc := &PayableResourceService{DAO: mocks.NewMockService(mockCtrl)}
+
+		for _, reference := range []string{"CHQ-0001", "LP", "lp0001234"} {
+			invalid := settlement
+			invalid.ExternalReference = reference
+			So(svc.SettleOffline(resource, invalid, client, scheme), ShouldEqual, ErrInvalidExternalReference)
+		}
+	})
+
+	Convey("error getting the payable resource from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(nil, errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldNotBeNil)
+	})
+
+	Convey("payable resource not found", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(nil, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldEqual, ErrLFPNotFound)
+	})
+
+	Convey("a paid resource cannot be settled offline", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		paid := pendingResource()
+		paid.Data.Payment.Status = "paid"
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldEqual, ErrAlreadyPaid)
+	})
+
+	Convey("a cancelled resource cannot be settled offline", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		cancelled := pendingResource()
+		cancelled.Data.Payment.Status = PaymentStatusCancelled
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(cancelled, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldEqual, ErrAlreadyCancelled)
+	})
+
+	Convey("a resource modified since it was read cannot be settled offline", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		modified := pendingResource()
+		modified.Data.Etag = "newetag"
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(modified, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldEqual, dao.ErrEtagMismatch)
+	})
+
+	Convey("a resource with a payment in e5 that may be in progress cannot be settled offline", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pendingResource(), nil)
+		mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("X123", nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldEqual, ErrPaymentInProgress)
+	})
+
+	Convey("a resource that left e5 locked", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		locked := func() *models.PayableResourceDao {
+			model := pendingResource()
+			model.E5CommandError = string(e5.ConfirmAction)
+			return model
+		}
+
+		Convey("has its e5 payment timed out and is settled offline", func() {
+			defer httpmock.Reset()
+			httpmock.RegisterResponder(http.MethodPost, "https://e5/arTransactions/payment/timeout", httpmock.NewBytesResponder(http.StatusOK, nil))
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(locked(), nil)
+			mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("X123", nil)
+			mockDaoService.EXPECT().SettleOffline(gomock.Any(), gomock.Any()).Return(nil)
+			mockDaoService.EXPECT().ClearE5Error("10000024", "LP123456").Return(nil)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			So(svc.SettleOffline(resource, settlement, client, scheme), ShouldBeNil)
+		})
+
+		Convey("is not settled offline when the e5 payment cannot be timed out", func() {
+			defer httpmock.Reset()
+			httpmock.RegisterResponder(http.MethodPost, "https://e5/arTransactions/payment/timeout", httpmock.NewStringResponder(http.StatusBadRequest, e5ValidationError))
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(locked(), nil)
+			mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("X123", nil)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			So(svc.SettleOffline(resource, settlement, client, scheme), ShouldNotBeNil)
+		})
+	})
+
+	Convey("error saving the settlement", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pendingResource(), nil)
+		mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+		mockDaoService.EXPECT().SettleOffline(gomock.Any(), gomock.Any()).Return(dao.ErrEtagMismatch)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldEqual, dao.ErrEtagMismatch)
+	})
+
+	Convey("payable resource settled offline with an audit entry", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pendingResource(), nil)
+		mockDaoService.EXPECT().GetE5PaymentID("10000024", "LP123456").Return("", nil)
+
+		var saved *models.PayableResourceDao
+		var entry *dao.AuditEntryDao
+		mockDaoService.EXPECT().SettleOffline(gomock.Any(), gomock.Any()).DoAndReturn(func(d *models.PayableResourceDao, e *dao.AuditEntryDao) error {
+			saved, entry = d, e
+			return nil
+		})
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		So(svc.SettleOffline(resource, settlement, client, scheme), ShouldBeNil)
+
+		So(saved.Data.Payment.Status, ShouldEqual, "paid")
+		So(saved.Data.Payment.Reference, ShouldEqual, "LP0001234")
+		So(saved.Data.Payment.Amount, ShouldEqual, "200.50")
+		So(saved.Data.Payment.PaidAt, ShouldNotBeNil)
+
+		So(entry.Action, ShouldEqual, dao.AuditActionSettledOffline)
+		So(entry.UserID, ShouldEqual, "user-id")
+		So(entry.Email, ShouldEqual, "finance@companieshouse.gov.uk")
+		So(entry.Reason, ShouldEqual, "paid by cheque")
+		So(entry.ExternalReference, ShouldEqual, "LP0001234")
+		So(entry.CreatedAt, ShouldEqual, *saved.Data.Payment.PaidAt)
+	})
+}
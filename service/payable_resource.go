@@ -11,6 +11,7 @@ import (
 	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/dao"
 	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 	"github.com/companieshouse/lfp-pay-api/transformers"
 )
 
@@ -25,6 +26,10 @@ var (
 	ErrPayment = errors.New("there was a problem validating the payment")
 )
 
+// maxPaidUpdateAttempts is the number of times recording a payment is attempted when the resource is modified by
+// another writer in between reading and updating it
+const maxPaidUpdateAttempts = 3
+
 // PayableResourceService contains the DAO for db access
 type PayableResourceService struct {
 	DAO    dao.Service
@@ -44,12 +49,27 @@ func (s *PayableResourceService) GetPayableResource(req *http.Request, companyNu
 		return nil, NotFound, nil
 	}
 
+	// a resource is only found through the routes of the penalty scheme it was created under
+	if scheme, err := config.GetPenaltyScheme(req.Context()); err == nil {
+		inScheme, err := s.DAO.IsInScheme(companyNumber, reference, scheme)
+		if err != nil {
+			err = fmt.Errorf("error checking penalty scheme of payable resource in db: [%v]", err)
+			log.ErrorR(req, err)
+			return nil, Error, err
+		}
+		if !inScheme {
+			log.InfoR(req, "payable resource is not in the penalty scheme", log.Data{"company_number": companyNumber, "reference": reference, "scheme": scheme.Name})
+			return nil, NotFound, nil
+		}
+	}
+
 	payableRest := transformers.PayableResourceDBToRequest(payable)
 
 	return payableRest, Success, nil
 }
 
-// UpdateAsPaid will update the resource as paid and persist the changes in the database
+// UpdateAsPaid will update the resource as paid and persist the changes in the database, along with the steps still
+// to be taken to complete the payment
 func (s *PayableResourceService) UpdateAsPaid(resource models.PayableResource, payment validators.PaymentInformation) error {
 	model, err := s.DAO.GetPayableResource(resource.CompanyNumber, resource.Reference)
 	if err != nil {
@@ -61,8 +81,8 @@ func (s *PayableResourceService) UpdateAsPaid(resource models.PayableResource, p
 		return ErrLFPNotFound
 	}
 
-	// check if this resource has already been paid
-	if model.IsPaid() {
+	// check if this resource has already been paid, including if it has since been refunded
+	if model.IsPaid() || model.Data.Payment.Status == PaymentStatusRefunded {
 		err = errors.New("this LFP has already been paid")
 		log.Error(err, log.Data{
 			"lfp_reference":  model.Reference,
@@ -71,16 +91,117 @@ func (s *PayableResourceService) UpdateAsPaid(resource models.PayableResource, p
 		})
 		return ErrAlreadyPaid
 	}
+	if model.Data.Payment.Status == PaymentStatusCancelled {
+		log.Info("payable resource has been cancelled", log.Data{
+			"lfp_reference":  model.Reference,
+			"company_number": model.CompanyNumber,
+		})
+		return ErrAlreadyCancelled
+	}
+
+	// the stored resource must be the version the payment was validated against, otherwise changes made since then
+	// would be overwritten
+	if resource.Etag != "" && model.Data.Etag != resource.Etag {
+		log.Info("payable resource has been modified since the payment was validated", log.Data{
+			"lfp_reference":  model.Reference,
+			"company_number": model.CompanyNumber,
+			"expected_etag":  resource.Etag,
+			"etag":           model.Data.Etag,
+		})
+		return dao.ErrEtagMismatch
+	}
 
 	model.Data.Payment.Reference = payment.Reference
 	model.Data.Payment.Status = payment.Status
 	model.Data.Payment.PaidAt = &payment.CompletedAt
 	model.Data.Payment.Amount = payment.Amount
 
-	return s.DAO.UpdatePaymentDetails(model)
+	// the steps to complete the payment are stored with it so that they are finished even if this process stops
+	err = s.DAO.MarkAsPaid(model, newPaymentCompletion(payment))
+	if err != nil {
+		return err
+	}
+
+	// the card is only needed for receipts so the payment is still recorded if it cannot be saved
+	err = s.DAO.SavePaymentCard(model.CompanyNumber, model.Reference, &dao.PaymentCardDao{
+		PaymentID: payment.PaymentID,
+		CardType:  payment.CardType,
+	})
+	if err != nil {
+		log.Error(fmt.Errorf("error saving payment card: [%v]", err), log.Data{
+			"lfp_reference":  model.Reference,
+			"company_number": model.CompanyNumber,
+			"payment_id":     payment.PaymentID,
+		})
+	}
+
+	return nil
+}
+
+// RecordPayment marks the resource as paid for a payment that the payment platform has already taken. The payment must
+// be recorded whichever version of the resource it was validated against, so if the resource is modified in between
+// reading and updating it the update is retried against the stored version.
+func (s *PayableResourceService) RecordPayment(resource models.PayableResource, payment validators.PaymentInformation) error {
+	var err error
+	for attempt := 1; attempt <= maxPaidUpdateAttempts; attempt++ {
+		err = s.UpdateAsPaid(resource, payment)
+		if err != dao.ErrEtagMismatch {
+			return err
+		}
+		log.Info("payable resource modified whilst recording payment", log.Data{
+			"lfp_reference":  resource.Reference,
+			"company_number": resource.CompanyNumber,
+			"attempt":        attempt,
+		})
+		resource.Etag = ""
+	}
+
+	return err
 }
 
 // RecordE5CommandError will mark the resource as having failed to update E5.
 func (s *PayableResourceService) RecordE5CommandError(resource models.PayableResource, action e5.Action) error {
 	return s.DAO.SaveE5Error(resource.CompanyNumber, resource.Reference, action)
 }
+
+// ClearE5CommandError will mark the resource as no longer having a payment that failed in E5, once the payment has
+// been completed in E5 or timed out and so no longer locks the company's account
+func (s *PayableResourceService) ClearE5CommandError(resource models.PayableResource) error {
+	return s.DAO.ClearE5Error(resource.CompanyNumber, resource.Reference)
+}
+
+// GetLanguage returns the language that the user that created the resource would like to be written to in. Resources
+// created before the language was stored, or whose language cannot be read, are written about in English.
+func (s *PayableResourceService) GetLanguage(resource models.PayableResource) string {
+	lang, err := s.DAO.GetLanguage(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		log.Error(fmt.Errorf("error getting language: [%v]", err), log.Data{
+			"lfp_reference":  resource.Reference,
+			"company_number": resource.CompanyNumber,
+		})
+		return i18n.English
+	}
+
+	if !i18n.IsSupported(lang) {
+		return i18n.English
+	}
+	return lang
+}
+
+// GetPayableResourceLinks returns the self links of every payable resource for the company in the penalty scheme that
+// includes the transaction
+func (s *PayableResourceService) GetPayableResourceLinks(companyNumber string, scheme *config.PenaltyScheme, transactionID string) ([]string, error) {
+	resources, err := s.DAO.GetPayableResourcesForTransaction(companyNumber, scheme, transactionID)
+	if err != nil {
+		err = fmt.Errorf("error getting payable resources from db: [%v]", err)
+		log.Error(err, log.Data{"company_number": companyNumber, "transaction_id": transactionID})
+		return nil, err
+	}
+
+	links := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		links = append(links, resource.Data.Links.Self)
+	}
+
+	return links, nil
+}
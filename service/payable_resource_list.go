@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/transformers"
+)
+
+// PayableResourceStatuses are the payment statuses that payable resources can be filtered on
+var PayableResourceStatuses = []string{constants.Pending.String(), constants.Paid.String(), PaymentStatusCancelled, PaymentStatusRefunded}
+
+// PayableResourceListOptions contains the filtering and pagination to apply to a company's payable resources. From
+// and To are dates, and include resources created at any time on them in the UK.
+type PayableResourceListOptions struct {
+	Status       string
+	From         *time.Time
+	To           *time.Time
+	Page         int
+	ItemsPerPage int
+}
+
+// PayableResourceListResponse is a single page of a company's payable resources, newest first
+type PayableResourceListResponse struct {
+	TotalResults int                       `json:"total_results"`
+	Items        []PayableResourceListItem `json:"items"`
+	StartIndex   int                       `json:"start_index"`
+	ItemsPerPage int                       `json:"items_per_page"`
+	Links        PenaltyListLinks          `json:"links"`
+}
+
+// PayableResourceListItem is a payable resource along with the E5 command, if any, that failed when it was paid
+type PayableResourceListItem struct {
+	models.PayableResource
+	E5CommandError string `json:"e5_command_error,omitempty"`
+}
+
+// ListPayableResources gets the requested page of the company's payable resources. The path and query are used to
+// build the links to the current, next and previous pages.
+func (s *PayableResourceService) ListPayableResources(companyNumber string, options PayableResourceListOptions, path string, query url.Values) (*PayableResourceListResponse, error) {
+	options = options.withDefaults()
+
+	resources, total, err := s.DAO.ListPayableResources(companyNumber, options.filter())
+	if err != nil {
+		err = fmt.Errorf("error listing payable resources from db: [%v]", err)
+		log.Error(err, log.Data{"company_number": companyNumber})
+		return nil, err
+	}
+
+	return newPayableResourceListResponse(resources, total, options, path, query), nil
+}
+
+// ListUserPayableResources gets the requested page of the payable resources created by the user, for any company.
+// The path and query are used to build the links to the current, next and previous pages.
+func (s *PayableResourceService) ListUserPayableResources(userID string, options PayableResourceListOptions, path string, query url.Values) (*PayableResourceListResponse, error) {
+	options = options.withDefaults()
+
+	resources, total, err := s.DAO.ListPayableResourcesCreatedBy(userID, options.filter())
+	if err != nil {
+		err = fmt.Errorf("error listing user's payable resources from db: [%v]", err)
+		log.Error(err, log.Data{"user_id": userID})
+		return nil, err
+	}
+
+	response := newPayableResourceListResponse(resources, total, options, path, query)
+
+	// failures in e5 are for support staff to resolve and are not shown to the user
+	for i := range response.Items {
+		response.Items[i].E5CommandError = ""
+	}
+
+	return response, nil
+}
+
+// withDefaults fills in the first page and default page size when they are not set
+func (options PayableResourceListOptions) withDefaults() PayableResourceListOptions {
+	if options.Page < 1 {
+		options.Page = 1
+	}
+	if options.ItemsPerPage < 1 {
+		options.ItemsPerPage = DefaultItemsPerPage
+	}
+	return options
+}
+
+// filter is the db filter for the options. Resources created at any time on the From and To dates in the UK are
+// included.
+func (options PayableResourceListOptions) filter() dao.PayableResourceListFilter {
+	filter := dao.PayableResourceListFilter{
+		Status: options.Status,
+		Skip:   int64((options.Page - 1) * options.ItemsPerPage),
+		Limit:  int64(options.ItemsPerPage),
+	}
+	if options.From != nil {
+		from := startOfUKDay(*options.From)
+		filter.CreatedFrom = &from
+	}
+	if options.To != nil {
+		before := startOfUKDay(*options.To).AddDate(0, 0, 1)
+		filter.CreatedBefore = &before
+	}
+	return filter
+}
+
+// newPayableResourceListResponse builds the page of payable resources along with the links to the current, next and
+// previous pages
+func newPayableResourceListResponse(resources []models.PayableResourceDao, total int64, options PayableResourceListOptions, path string, query url.Values) *PayableResourceListResponse {
+	startIndex := (options.Page - 1) * options.ItemsPerPage
+	response := &PayableResourceListResponse{
+		TotalResults: int(total),
+		Items:        []PayableResourceListItem{},
+		StartIndex:   startIndex,
+		ItemsPerPage: options.ItemsPerPage,
+		Links: PenaltyListLinks{
+			Self: pageLink(path, query, options.Page),
+		},
+	}
+
+	for i := range resources {
+		response.Items = append(response.Items, PayableResourceListItem{
+			PayableResource: *transformers.PayableResourceDBToRequest(&resources[i]),
+			E5CommandError:  resources[i].E5CommandError,
+		})
+	}
+
+	if startIndex+len(resources) < response.TotalResults {
+		response.Links.Next = pageLink(path, query, options.Page+1)
+	}
+	if options.Page > 1 {
+		response.Links.Previous = pageLink(path, query, options.Page-1)
+	}
+
+	return response
+}
+
+// startOfUKDay is midnight in the UK at the start of the date
+func startOfUKDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, ukTime)
+}
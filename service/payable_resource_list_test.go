@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitListPayableResources(t *testing.T) {
+	path := "/company/10000024/penalties/late-filing/payable"
+
+	Convey("error listing payable resources from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListPayableResources("10000024", gomock.Any()).Return(nil, int64(0), errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		response, err := svc.ListPayableResources("10000024", PayableResourceListOptions{}, path, url.Values{})
+
+		So(response, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("no payable resources", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListPayableResources("10000024", dao.PayableResourceListFilter{Limit: DefaultItemsPerPage}).Return([]models.PayableResourceDao{}, int64(0), nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		response, err := svc.ListPayableResources("10000024", PayableResourceListOptions{}, path, url.Values{})
+
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 0)
+		So(response.Items, ShouldBeEmpty)
+		So(response.Links.Self, ShouldEqual, path+"?page=1")
+		So(response.Links.Next, ShouldBeEmpty)
+		So(response.Links.Previous, ShouldBeEmpty)
+	})
+
+	Convey("filters are passed to the db and a page of resources is returned", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+
+		from := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2019, 6, 30, 0, 0, 0, 0, time.UTC)
+		createdFrom := time.Date(2019, 6, 1, 0, 0, 0, 0, ukTime)
+		createdBefore := time.Date(2019, 7, 1, 0, 0, 0, 0, ukTime)
+		createdAt := time.Date(2019, 6, 12, 9, 0, 0, 0, time.UTC)
+
+		mockDaoService.EXPECT().ListPayableResources("10000024", dao.PayableResourceListFilter{
+			Status:        "paid",
+			CreatedFrom:   &createdFrom,
+			CreatedBefore: &createdBefore,
+			Skip:          2,
+			Limit:         2,
+		}).Return([]models.PayableResourceDao{
+			{
+				CompanyNumber:  "10000024",
+				Reference:      "LP123456",
+				E5CommandError: "authorise",
+				Data: models.PayableResourceDataDao{
+					Transactions: map[string]models.TransactionDao{"00378420": {Amount: 150}},
+					Payment:      models.PaymentDao{Status: "paid", Amount: "150"},
+					CreatedAt:    &createdAt,
+					CreatedBy:    models.CreatedByDao{ID: "abc", Email: "test@example.com"},
+					Links:        models.PayableResourceLinksDao{Self: path + "/LP123456"},
+				},
+			},
+			{CompanyNumber: "10000024", Reference: "LP654321"},
+		}, int64(5), nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		options := PayableResourceListOptions{Status: "paid", From: &from, To: &to, Page: 2, ItemsPerPage: 2}
+		response, err := svc.ListPayableResources("10000024", options, path, url.Values{"status": {"paid"}})
+
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 5)
+		So(response.StartIndex, ShouldEqual, 2)
+		So(response.ItemsPerPage, ShouldEqual, 2)
+		So(response.Items, ShouldHaveLength, 2)
+
+		item := response.Items[0]
+		So(item.Reference, ShouldEqual, "LP123456")
+		So(item.Payment.Status, ShouldEqual, "paid")
+		So(item.Transactions, ShouldResemble, []models.TransactionItem{{TransactionID: "00378420", Amount: 150}})
+		So(item.CreatedBy.Email, ShouldEqual, "test@example.com")
+		So(*item.CreatedAt, ShouldEqual, createdAt)
+		So(item.E5CommandError, ShouldEqual, "authorise")
+		So(item.Links.Self, ShouldEqual, path+"/LP123456")
+
+		So(response.Links.Self, ShouldEqual, path+"?page=2&status=paid")
+		So(response.Links.Next, ShouldEqual, path+"?page=3&status=paid")
+		So(response.Links.Previous, ShouldEqual, path+"?page=1&status=paid")
+	})
+}
+
+func TestUnitListUserPayableResources(t *testing.T) {
+	path := "/user/penalties/payable"
+
+	Convey("error listing the user's payable resources from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListPayableResourcesCreatedBy("abc", gomock.Any()).Return(nil, int64(0), errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		response, err := svc.ListUserPayableResources("abc", PayableResourceListOptions{}, path, url.Values{})
+
+		So(response, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("the user's payable resources are returned without e5 errors", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListPayableResourcesCreatedBy("abc", dao.PayableResourceListFilter{Limit: 10}).Return([]models.PayableResourceDao{
+			{
+				CompanyNumber:  "10000024",
+				Reference:      "LP123456",
+				E5CommandError: "confirm",
+				Data: models.PayableResourceDataDao{
+					Payment: models.PaymentDao{Status: "pending"},
+					Links:   models.PayableResourceLinksDao{ResumeJourney: "/late-filing-penalty/company/10000024/penalty/00378420/view-penalties"},
+				},
+			},
+			{CompanyNumber: "00006400", Reference: "LP654321"},
+		}, int64(2), nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		response, err := svc.ListUserPayableResources("abc", PayableResourceListOptions{ItemsPerPage: 10}, path, url.Values{})
+
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 2)
+		So(response.Items, ShouldHaveLength, 2)
+		So(response.Items[0].CompanyNumber, ShouldEqual, "10000024")
+		So(response.Items[0].Links.ResumeJourney, ShouldEqual, "/late-filing-penalty/company/10000024/penalty/00378420/view-penalties")
+		So(response.Items[0].E5CommandError, ShouldBeEmpty)
+		So(response.Items[1].CompanyNumber, ShouldEqual, "00006400")
+		So(response.Links.Next, ShouldBeEmpty)
+	})
+}
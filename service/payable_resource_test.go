@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http/httptest"
@@ -11,6 +12,9 @@ import (
 	"github.com/companieshouse/lfp-pay-api-core/models"
 	"github.com/companieshouse/lfp-pay-api-core/validators"
 	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 	"github.com/companieshouse/lfp-pay-api/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/jarcoal/httpmock"
@@ -54,6 +58,38 @@ func TestUnitGetPayableResource(t *testing.T) {
 		So(err, ShouldBeNil)
 	})
 
+	Convey("Payable resource in another penalty scheme is not found", t, func() {
+		mock := mocks.NewMockService(mockCtrl)
+		mockPayableService := createMockPayableResourceService(mock, cfg)
+		scheme := mocks.LateFilingScheme()
+		mock.EXPECT().GetPayableResource("12345678", "1234").Return(&models.PayableResourceDao{CompanyNumber: "12345678", Reference: "1234"}, nil)
+		mock.EXPECT().IsInScheme("12345678", "1234", scheme).Return(false, nil)
+
+		req := httptest.NewRequest("Get", "/test", nil)
+		req = req.WithContext(context.WithValue(req.Context(), config.Scheme, scheme))
+
+		payableResource, status, err := mockPayableService.GetPayableResource(req, "12345678", "1234")
+		So(payableResource, ShouldBeNil)
+		So(status, ShouldEqual, NotFound)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Error checking the penalty scheme of the payable resource", t, func() {
+		mock := mocks.NewMockService(mockCtrl)
+		mockPayableService := createMockPayableResourceService(mock, cfg)
+		scheme := mocks.LateFilingScheme()
+		mock.EXPECT().GetPayableResource("12345678", "1234").Return(&models.PayableResourceDao{CompanyNumber: "12345678", Reference: "1234"}, nil)
+		mock.EXPECT().IsInScheme("12345678", "1234", scheme).Return(false, errors.New("any error"))
+
+		req := httptest.NewRequest("Get", "/test", nil)
+		req = req.WithContext(context.WithValue(req.Context(), config.Scheme, scheme))
+
+		payableResource, status, err := mockPayableService.GetPayableResource(req, "12345678", "1234")
+		So(payableResource, ShouldBeNil)
+		So(status, ShouldEqual, Error)
+		So(err, ShouldNotBeNil)
+	})
+
 	Convey("Get Payable resource - success - Single transaction", t, func() {
 		mock := mocks.NewMockService(mockCtrl)
 		mockPayableService := createMockPayableResourceService(mock, cfg)
@@ -215,6 +251,21 @@ func TestUnitPayableResourceService_UpdateAsPaid(t *testing.T) {
 			So(err, ShouldBeError, ErrAlreadyPaid)
 		})
 
+		Convey("LFP payable resource must not have been modified since it was validated", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			dataModel := &models.PayableResourceDao{
+				Data: models.PayableResourceDataDao{
+					Etag: "newetag",
+				},
+			}
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(dataModel, nil)
+			svc := PayableResourceService{DAO: mockDaoService}
+			err := svc.UpdateAsPaid(models.PayableResource{Etag: "oldetag"}, validators.PaymentInformation{Status: constants.Paid.String()})
+			So(err, ShouldBeError, dao.ErrEtagMismatch)
+		})
+
 		Convey("payment details are saved to db", func() {
 			mockCtrl := gomock.NewController(t)
 			defer mockCtrl.Finish()
@@ -226,7 +277,15 @@ func TestUnitPayableResourceService_UpdateAsPaid(t *testing.T) {
 			}
 			mockDaoService := mocks.NewMockService(mockCtrl)
 			mockDaoService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(dataModel, nil)
-			mockDaoService.EXPECT().UpdatePaymentDetails(gomock.Any()).Times(1)
+			mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ *models.PayableResourceDao, completion *dao.PaymentCompletionDao) error {
+					So(completion.Payment.Reference, ShouldEqual, "123")
+					So(completion.E5Pending, ShouldBeTrue)
+					So(completion.E5Action, ShouldEqual, e5.CreateAction)
+					So(completion.EmailPending, ShouldBeTrue)
+					return nil
+				})
+			mockDaoService.EXPECT().SavePaymentCard(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 			svc := PayableResourceService{DAO: mockDaoService}
 
 			layout := "2006-01-02T15:04:05.000Z"
@@ -251,3 +310,77 @@ func TestUnitPayableResourceService_UpdateAsPaid(t *testing.T) {
 		})
 	})
 }
+
+func TestUnitPayableResourceService_RecordPayment(t *testing.T) {
+	Convey("PayableResourceService.RecordPayment", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		svc := PayableResourceService{DAO: mockDaoService}
+
+		Convey("payment is recorded against a resource modified since it was validated", func() {
+			modified := &models.PayableResourceDao{Data: models.PayableResourceDataDao{Etag: "newetag"}}
+			mockDaoService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(modified, nil).Times(2)
+			mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).Return(nil)
+			mockDaoService.EXPECT().SavePaymentCard(gomock.Any(), gomock.Any(), gomock.Any())
+
+			err := svc.RecordPayment(models.PayableResource{Etag: "oldetag"}, validators.PaymentInformation{Status: constants.Paid.String()})
+
+			So(err, ShouldBeNil)
+		})
+
+		Convey("payment is retried when the resource is modified whilst it is recorded", func() {
+			mockDaoService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).DoAndReturn(func(companyNumber, reference string) (*models.PayableResourceDao, error) {
+				return &models.PayableResourceDao{Data: models.PayableResourceDataDao{Etag: "etag"}}, nil
+			}).Times(maxPaidUpdateAttempts)
+			mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).Return(dao.ErrEtagMismatch).Times(maxPaidUpdateAttempts)
+
+			err := svc.RecordPayment(models.PayableResource{Etag: "etag"}, validators.PaymentInformation{Status: constants.Paid.String()})
+
+			So(err, ShouldBeError, dao.ErrEtagMismatch)
+		})
+
+		Convey("an already paid resource is not retried", func() {
+			paid := &models.PayableResourceDao{Data: models.PayableResourceDataDao{Payment: models.PaymentDao{Status: constants.Paid.String()}}}
+			mockDaoService.EXPECT().GetPayableResource(gomock.Any(), gomock.Any()).Return(paid, nil)
+
+			err := svc.RecordPayment(models.PayableResource{}, validators.PaymentInformation{Status: constants.Paid.String()})
+
+			So(err, ShouldBeError, ErrAlreadyPaid)
+		})
+	})
+}
+
+func TestUnitPayableResourceService_GetLanguage(t *testing.T) {
+	resource := models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+
+	Convey("stored language is returned", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetLanguage("10000024", "LP123456").Return(i18n.Welsh, nil)
+		svc := PayableResourceService{DAO: mockDaoService}
+
+		So(svc.GetLanguage(resource), ShouldEqual, i18n.Welsh)
+	})
+
+	Convey("English when no language is stored", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetLanguage("10000024", "LP123456").Return("", nil)
+		svc := PayableResourceService{DAO: mockDaoService}
+
+		So(svc.GetLanguage(resource), ShouldEqual, i18n.English)
+	})
+
+	Convey("English when the language cannot be read", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetLanguage("10000024", "LP123456").Return("", errors.New("any error"))
+		svc := PayableResourceService{DAO: mockDaoService}
+
+		So(svc.GetLanguage(resource), ShouldEqual, i18n.English)
+	})
+}
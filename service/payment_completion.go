@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+)
+
+// paymentCompletionStaleAfter is how long the steps of a payment are left to the process that claimed them before
+// another process can finish them, in case it stopped part way through
+const paymentCompletionStaleAfter = 5 * time.Minute
+
+// ErrPaymentIncomplete is returned when a payment has been recorded against the payable resource but E5 could not be
+// updated or the confirmation email could not be sent. Completing the payment again finishes the steps that failed.
+var ErrPaymentIncomplete = errors.New("the payment has been recorded but could not be completed")
+
+// the calls to other services are variables so that they can be mocked in unit tests
+var (
+	completePaymentInE5 = MarkTransactionsAsPaid
+	sendPaymentEmail    = SendEmailKafkaMessage
+)
+
+// newPaymentCompletion returns the steps to be taken to complete a payment that is being recorded, claimed by the
+// process recording it
+func newPaymentCompletion(payment validators.PaymentInformation) *dao.PaymentCompletionDao {
+	return &dao.PaymentCompletionDao{
+		Payment:      payment,
+		E5Pending:    true,
+		E5Action:     e5.CreateAction,
+		EmailPending: true,
+		ClaimedAt:    time.Now(),
+	}
+}
+
+// CompletePayment records a payment that the payment platform has taken against the payable resource, then marks the
+// transactions as paid in E5 and sends the confirmation email. E5 is only updated by the call that marks the resource
+// as paid, so a payment completed more than once, e.g. by both the API callback and the payment-processed message, is
+// only posted to E5 once. The steps after the resource is marked as paid are stored with it until they succeed, and
+// completing a payment for a resource that has already been paid finishes any that failed, using the payment that was
+// recorded. A payment for a resource that was cancelled while it was being paid for is recorded to be refunded instead,
+// and ErrPaidAfterCancellation is returned. ErrAlreadyPaid is returned if there was nothing left to do, and
+// ErrPaymentIncomplete if a step failed.
+func (s *PayableResourceService) CompletePayment(client *e5.Client, resource models.PayableResource, payment validators.PaymentInformation, scheme *config.PenaltyScheme, req *http.Request) error {
+	err := s.RecordPayment(resource, payment)
+	switch err {
+	case nil:
+		log.Info("payment resource is now marked as paid in db", log.Data{
+			"lfp_reference":  resource.Reference,
+			"company_number": resource.CompanyNumber,
+		})
+	case ErrAlreadyPaid:
+		return s.FinishPayment(client, resource, scheme, req)
+	case ErrAlreadyCancelled:
+		return s.recordCancelledPayment(resource, payment)
+	default:
+		return err
+	}
+
+	return s.finishPayment(client, resource, newPaymentCompletion(payment), scheme, req)
+}
+
+// FinishPayment takes any steps to complete the payment for a paid payable resource that failed when it was
+// completed, unless another process is taking them. ErrAlreadyPaid is returned if there are none, and
+// ErrPaymentIncomplete if a step failed again.
+func (s *PayableResourceService) FinishPayment(client *e5.Client, resource models.PayableResource, scheme *config.PenaltyScheme, req *http.Request) error {
+	completion, err := s.DAO.ClaimPaymentCompletion(resource.CompanyNumber, resource.Reference, time.Now().Add(-paymentCompletionStaleAfter))
+	if err != nil {
+		return fmt.Errorf("error claiming payment completion: [%v]", err)
+	}
+	if completion == nil {
+		return ErrAlreadyPaid
+	}
+
+	log.Info("finishing payment for payable resource that has already been paid", log.Data{
+		"lfp_reference":  resource.Reference,
+		"company_number": resource.CompanyNumber,
+	})
+
+	return s.finishPayment(client, resource, completion, scheme, req)
+}
+
+// finishPayment takes the steps that are still to be taken to complete a payment that has been recorded against the
+// payable resource, then stores which of them failed and releases them so that they can be finished again straight
+// away
+func (s *PayableResourceService) finishPayment(client *e5.Client, resource models.PayableResource, completion *dao.PaymentCompletionDao, scheme *config.PenaltyScheme, req *http.Request) error {
+	logData := log.Data{"lfp_reference": resource.Reference, "company_number": resource.CompanyNumber}
+
+	var e5Err, emailErr error
+	if completion.E5Pending {
+		if completion.E5Action == "" {
+			completion.E5Action = e5.CreateAction
+		}
+		completion.E5Action, e5Err = completePaymentInE5(s, client, resource, completion.Payment, scheme, completion.E5Action)
+		completion.E5Pending = e5Err != nil
+	}
+	if completion.EmailPending {
+		emailErr = sendPaymentEmail(resource, s.GetLanguage(resource), req)
+		completion.EmailPending = emailErr != nil
+		if emailErr == nil {
+			log.Info("confirmation email sent to customer", logData)
+		}
+	}
+
+	completion.ClaimedAt = time.Time{}
+	err := s.DAO.UpdatePaymentCompletion(resource.CompanyNumber, resource.Reference, completion)
+	if err != nil {
+		log.Error(fmt.Errorf("error saving payment completion: [%v]", err), logData)
+	}
+
+	if e5Err != nil || emailErr != nil {
+		return fmt.Errorf("%w: e5: [%v], email: [%v]", ErrPaymentIncomplete, e5Err, emailErr)
+	}
+
+	return err
+}
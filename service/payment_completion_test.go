@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitCompletePayment(t *testing.T) {
+	resource := models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+	payment := validators.PaymentInformation{Reference: "late_filing_penalty_123", PaymentID: "P123", Status: "paid", Amount: "150"}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	var e5From []e5.Action
+	var e5Payments []validators.PaymentInformation
+	var e5Err, emailErr error
+	var emailCalls int
+	completePaymentInE5 = func(_ *PayableResourceService, _ *e5.Client, _ models.PayableResource, p validators.PaymentInformation, _ *config.PenaltyScheme, from e5.Action) (e5.Action, error) {
+		e5From = append(e5From, from)
+		e5Payments = append(e5Payments, p)
+		if e5Err != nil {
+			return e5.AuthoriseAction, e5Err
+		}
+		return "", nil
+	}
+	sendPaymentEmail = func(models.PayableResource, string, *http.Request) error {
+		emailCalls++
+		return emailErr
+	}
+	defer func() {
+		completePaymentInE5 = MarkTransactionsAsPaid
+		sendPaymentEmail = SendEmailKafkaMessage
+	}()
+
+	reset := func() {
+		e5From, e5Payments, e5Err, emailErr, emailCalls = nil, nil, nil, nil, 0
+	}
+
+	pending := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{CompanyNumber: "10000024", Reference: "LP123456"}
+	}
+	paid := func() *models.PayableResourceDao {
+		model := pending()
+		model.Data.Payment.Status = constants.Paid.String()
+		return model
+	}
+
+	Convey("the resource is marked as paid before E5 is updated and the email sent", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		gomock.InOrder(
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil),
+			mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).Return(nil),
+			mockDaoService.EXPECT().SavePaymentCard("10000024", "LP123456", gomock.Any()).Return(nil),
+			mockDaoService.EXPECT().GetLanguage("10000024", "LP123456").Return("", nil),
+			mockDaoService.EXPECT().UpdatePaymentCompletion("10000024", "LP123456", gomock.Any()).DoAndReturn(
+				func(_, _ string, completion *dao.PaymentCompletionDao) error {
+					So(completion.E5Pending, ShouldBeFalse)
+					So(completion.EmailPending, ShouldBeFalse)
+					So(completion.ClaimedAt.IsZero(), ShouldBeTrue)
+					return nil
+				}),
+		)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		err := svc.CompletePayment(nil, resource, payment, &config.PenaltyScheme{}, req)
+
+		So(err, ShouldBeNil)
+		So(e5From, ShouldResemble, []e5.Action{e5.CreateAction})
+		So(emailCalls, ShouldEqual, 1)
+	})
+
+	Convey("E5 is not updated if the resource cannot be marked as paid", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil)
+		mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).Return(errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		err := svc.CompletePayment(nil, resource, payment, &config.PenaltyScheme{}, req)
+
+		So(err, ShouldNotBeNil)
+		So(e5From, ShouldBeEmpty)
+		So(emailCalls, ShouldEqual, 0)
+	})
+
+	Convey("a payment for a resource cancelled while it was being paid for is recorded to be refunded", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		cancelled := pending()
+		cancelled.Data.Payment.Status = PaymentStatusCancelled
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(cancelled, nil)
+
+		var recorded *dao.CancelledPaymentDao
+		mockDaoService.EXPECT().RecordCancelledPayment("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(_, _ string, p *dao.CancelledPaymentDao) error {
+				recorded = p
+				return nil
+			})
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		err := svc.CompletePayment(nil, resource, payment, &config.PenaltyScheme{}, req)
+
+		So(err, ShouldEqual, ErrPaidAfterCancellation)
+		So(recorded.PaymentID, ShouldEqual, "P123")
+		So(recorded.Reference, ShouldEqual, "late_filing_penalty_123")
+		So(recorded.Amount, ShouldEqual, "150")
+		So(e5From, ShouldBeEmpty)
+		So(emailCalls, ShouldEqual, 0)
+	})
+
+	Convey("a failed step is stored as pending with the E5 command to resume from", t, func() {
+		reset()
+		e5Err = errors.New("e5 unavailable")
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending(), nil)
+		mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).Return(nil)
+		mockDaoService.EXPECT().SavePaymentCard("10000024", "LP123456", gomock.Any()).Return(nil)
+		mockDaoService.EXPECT().GetLanguage("10000024", "LP123456").Return("", nil)
+		mockDaoService.EXPECT().UpdatePaymentCompletion("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(_, _ string, completion *dao.PaymentCompletionDao) error {
+				So(completion.E5Pending, ShouldBeTrue)
+				So(completion.E5Action, ShouldEqual, e5.AuthoriseAction)
+				So(completion.EmailPending, ShouldBeFalse)
+				return nil
+			})
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		err := svc.CompletePayment(nil, resource, payment, &config.PenaltyScheme{}, req)
+
+		So(errors.Is(err, ErrPaymentIncomplete), ShouldBeTrue)
+		So(emailCalls, ShouldEqual, 1)
+	})
+
+	Convey("a payment that has already been completed is not posted to E5 again", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid(), nil)
+		mockDaoService.EXPECT().ClaimPaymentCompletion("10000024", "LP123456", gomock.Any()).Return(nil, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		err := svc.CompletePayment(nil, resource, payment, &config.PenaltyScheme{}, req)
+
+		So(err, ShouldEqual, ErrAlreadyPaid)
+		So(e5From, ShouldBeEmpty)
+		So(emailCalls, ShouldEqual, 0)
+	})
+
+	Convey("completing a payment again only finishes the steps that failed, with the payment that was recorded", t, func() {
+		reset()
+		recorded := validators.PaymentInformation{Reference: "late_filing_penalty_123", PaymentID: "P-recorded"}
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid(), nil)
+		mockDaoService.EXPECT().ClaimPaymentCompletion("10000024", "LP123456", gomock.Any()).Return(&dao.PaymentCompletionDao{
+			Payment:   recorded,
+			E5Pending: true,
+			E5Action:  e5.ConfirmAction,
+		}, nil)
+		mockDaoService.EXPECT().UpdatePaymentCompletion("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(_, _ string, completion *dao.PaymentCompletionDao) error {
+				So(completion.E5Pending, ShouldBeFalse)
+				return nil
+			})
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		err := svc.CompletePayment(nil, resource, payment, &config.PenaltyScheme{}, req)
+
+		So(err, ShouldBeNil)
+		So(e5From, ShouldResemble, []e5.Action{e5.ConfirmAction})
+		So(e5Payments[0].PaymentID, ShouldEqual, "P-recorded")
+		So(emailCalls, ShouldEqual, 0)
+	})
+}
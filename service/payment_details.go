@@ -6,6 +6,7 @@ import (
 
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/transformers"
 )
 
@@ -15,8 +16,8 @@ type PaymentDetailsService struct {
 }
 
 // GetPaymentDetailsFromPayableResource transforms a PayableResource into its corresponding Payment details resource
-func (service *PaymentDetailsService) GetPaymentDetailsFromPayableResource(req *http.Request, payable *models.PayableResource) (*models.PaymentDetails, ResponseType, error) {
-	paymentDetails := transformers.PayableResourceToPaymentDetails(payable)
+func (service *PaymentDetailsService) GetPaymentDetailsFromPayableResource(req *http.Request, payable *models.PayableResource, scheme *config.PenaltyScheme) (*models.PaymentDetails, ResponseType, error) {
+	paymentDetails := transformers.PayableResourceToPaymentDetails(payable, scheme)
 
 	if len(paymentDetails.Items) == 0 {
 		err := fmt.Errorf("no items in payment details transformed from payable resource [%s]", payable.Reference)
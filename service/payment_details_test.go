@@ -8,10 +8,12 @@ import (
 
 	"github.com/companieshouse/lfp-pay-api-core/models"
 
+	"github.com/companieshouse/lfp-pay-api/mocks"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestUnitGetPaymentDetailsFromPayableResource(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
 
 	Convey("Get payment details no transactions - invalid data", t, func() {
 
@@ -42,7 +44,7 @@ func TestUnitGetPaymentDetailsFromPayableResource(t *testing.T) {
 
 		service := &PaymentDetailsService{}
 
-		paymentDetails, responseType, err := service.GetPaymentDetailsFromPayableResource(req, &payable)
+		paymentDetails, responseType, err := service.GetPaymentDetailsFromPayableResource(req, &payable, scheme)
 
 		So(paymentDetails, ShouldBeNil)
 		So(responseType, ShouldEqual, InvalidData)
@@ -85,7 +87,7 @@ func TestUnitGetPaymentDetailsFromPayableResource(t *testing.T) {
 
 		service := &PaymentDetailsService{}
 
-		paymentDetails, responseType, err := service.GetPaymentDetailsFromPayableResource(req, &payable)
+		paymentDetails, responseType, err := service.GetPaymentDetailsFromPayableResource(req, &payable, scheme)
 
 		expectedCost := models.Cost{
 			Description:             "Late Filing Penalty",
@@ -149,7 +151,7 @@ func TestUnitGetPaymentDetailsFromPayableResource(t *testing.T) {
 
 		service := &PaymentDetailsService{}
 
-		paymentDetails, responseType, err := service.GetPaymentDetailsFromPayableResource(req, &payable)
+		paymentDetails, responseType, err := service.GetPaymentDetailsFromPayableResource(req, &payable, scheme)
 
 		expectedCost := models.Cost{
 			Description:             "Late Filing Penalty",
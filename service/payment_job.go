@@ -0,0 +1,439 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+)
+
+const (
+	// PaymentJobStatusPending is the status of a payment job that is waiting for a worker
+	PaymentJobStatusPending = "pending"
+	// PaymentJobStatusProcessing is the status of a payment job that a worker is completing
+	PaymentJobStatusProcessing = "processing"
+	// PaymentJobStatusSucceeded is the status of a payment job whose payment has been completed
+	PaymentJobStatusSucceeded = "succeeded"
+	// PaymentJobStatusFailed is the status of a payment job that could not be completed. The payment can be submitted
+	// again.
+	PaymentJobStatusFailed = "failed"
+
+	// DefaultPaymentJobWorkers is the number of payment jobs completed at once when it is not configured
+	DefaultPaymentJobWorkers = 4
+
+	// paymentJobQueueSize is the number of payment jobs that can wait for a worker before new jobs are left for the
+	// next sweep to pick up
+	paymentJobQueueSize = 100
+
+	// maxPaymentJobAttempts is the most times a worker tries to finish a payment that has been recorded but could not
+	// be completed before the job is failed
+	maxPaymentJobAttempts = 5
+
+	// paymentJobCallbackAttempts is the most times the finished job is posted to its callback URL
+	paymentJobCallbackAttempts = 3
+)
+
+var (
+	// ErrPaymentJobInProgress is returned when submitting a payment for a payable resource whose last payment job has
+	// not failed
+	ErrPaymentJobInProgress = errors.New("the payable resource already has a payment in progress")
+	// ErrPaymentJobNotFound is returned when getting the payment job of a payable resource that was not paid for
+	// asynchronously
+	ErrPaymentJobNotFound = errors.New("the payable resource has no payment job")
+	// ErrCallbackNotAllowed is returned when submitting a payment with a callback URL that is not https or is not on
+	// one of the configured hosts
+	ErrCallbackNotAllowed = errors.New("the callback URL is not allowed")
+)
+
+// the timings of the pool and the calls to other services are variables so that they can be changed in unit tests
+var (
+	paymentJobSweepInterval      = time.Minute
+	paymentJobStaleAfter         = 5 * time.Minute
+	paymentJobCallbackRetryDelay = 2 * time.Second
+
+	postPaymentCallback = postPaymentJobCallback
+)
+
+// paymentJobCallbackClient posts finished jobs to their callback URLs. The timeout stops a callback that does not
+// respond from holding up a worker.
+var paymentJobCallbackClient = &http.Client{Timeout: 10 * time.Second}
+
+// PaymentJobLinks are the links of a payment job
+type PaymentJobLinks struct {
+	Self     string `json:"self"`
+	Resource string `json:"resource"`
+}
+
+// PaymentJob is a payment for a payable resource that is being completed in the background
+type PaymentJob struct {
+	Status           string          `json:"status"`
+	PaymentReference string          `json:"payment_reference"`
+	Error            string          `json:"error,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	Links            PaymentJobLinks `json:"links"`
+}
+
+// PaymentJobPool completes validated payments in the background. Jobs are stored against the payable resource before
+// they are queued, so a job that is not completed by this instance, e.g. because it was shut down, is picked up again
+// by the periodic sweep of any instance.
+type PaymentJobPool struct {
+	Service  *PayableResourceService
+	E5Client *e5.Client
+	Schemes  []*config.PenaltyScheme
+	jobs     chan dao.PaymentJobKey
+	running  sync.WaitGroup
+}
+
+// NewPaymentJobPool creates a pool that completes payments for the given penalty schemes. It does nothing until it is
+// started.
+func NewPaymentJobPool(svc *PayableResourceService, e5Client *e5.Client, schemes []*config.PenaltyScheme) *PaymentJobPool {
+	return &PaymentJobPool{
+		Service:  svc,
+		E5Client: e5Client,
+		Schemes:  schemes,
+		jobs:     make(chan dao.PaymentJobKey, paymentJobQueueSize),
+	}
+}
+
+// Start starts the workers and the sweep for jobs that are waiting, which run until the context is cancelled. A job
+// that a worker is completing when the context is cancelled is finished first.
+func (p *PaymentJobPool) Start(ctx context.Context) {
+	workers := p.workers()
+	p.running.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.running.Done()
+			p.work(ctx)
+		}()
+	}
+	go func() {
+		defer p.running.Done()
+		p.sweep(ctx)
+	}()
+
+	log.Info("payment job pool started", log.Data{"workers": workers})
+}
+
+// Wait blocks until the workers and the sweep have stopped once the context they were started with is cancelled
+func (p *PaymentJobPool) Wait() {
+	p.running.Wait()
+}
+
+// workers returns the configured number of workers, or the default if it has not been configured
+func (p *PaymentJobPool) workers() int {
+	if p.Service.Config == nil || p.Service.Config.PaymentJobWorkers <= 0 {
+		return DefaultPaymentJobWorkers
+	}
+	return p.Service.Config.PaymentJobWorkers
+}
+
+// Submit stores a job to complete a validated payment for the payable resource and queues it for a worker. The
+// callback URL, if there is one, is sent the job once it has succeeded or failed. ErrCallbackNotAllowed is returned if
+// the callback URL is not one that jobs can be posted to.
+func (p *PaymentJobPool) Submit(resource *models.PayableResource, payment *validators.PaymentInformation, scheme *config.PenaltyScheme, callbackURL string) (*PaymentJob, error) {
+	if callbackURL != "" && !p.callbackAllowed(callbackURL) {
+		return nil, ErrCallbackNotAllowed
+	}
+
+	now := time.Now()
+	job := &dao.PaymentJobDao{
+		Status:      PaymentJobStatusPending,
+		Payment:     *payment,
+		Scheme:      scheme.Name,
+		CallbackURL: callbackURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err := p.Service.DAO.CreatePaymentJob(resource.CompanyNumber, resource.Reference, job)
+	if err == dao.ErrPaymentJobExists {
+		return nil, ErrPaymentJobInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := dao.PaymentJobKey{CompanyNumber: resource.CompanyNumber, Reference: resource.Reference}
+	select {
+	case p.jobs <- key:
+	default:
+		log.Info("payment job queue is full, leaving job for the next sweep", log.Data{
+			"lfp_reference":  resource.Reference,
+			"company_number": resource.CompanyNumber,
+		})
+	}
+
+	return newPaymentJob(job, resource.Links.Self), nil
+}
+
+// work completes queued jobs until the context is cancelled. Each job is completed with a context of its own, so
+// that a job that is being completed when the pool is stopped is not left part way through.
+func (p *PaymentJobPool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case key := <-p.jobs:
+			p.Process(context.Background(), key)
+		}
+	}
+}
+
+// sweep periodically queues the jobs that are waiting, including those left by instances that stopped part way
+// through, until the context is cancelled
+func (p *PaymentJobPool) sweep(ctx context.Context) {
+	ticker := time.NewTicker(paymentJobSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		keys, err := p.Service.DAO.ListClaimablePaymentJobs(time.Now().Add(-paymentJobStaleAfter))
+		if err != nil {
+			log.Error(fmt.Errorf("error listing payment jobs: [%v]", err))
+		}
+		for _, key := range keys {
+			select {
+			case <-ctx.Done():
+				return
+			case p.jobs <- key:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process completes the payment job of a payable resource, unless another worker has already claimed it. A job whose
+// payment has been recorded, but whose E5 update or confirmation email failed, is left pending so that the next sweep
+// finishes it, and is only failed once it has been tried maxPaymentJobAttempts times.
+func (p *PaymentJobPool) Process(ctx context.Context, key dao.PaymentJobKey) {
+	logData := log.Data{"lfp_reference": key.Reference, "company_number": key.CompanyNumber}
+
+	job, err := p.Service.DAO.ClaimPaymentJob(key.CompanyNumber, key.Reference, time.Now().Add(-paymentJobStaleAfter))
+	if err != nil {
+		log.Error(fmt.Errorf("error claiming payment job: [%v]", err), logData)
+		return
+	}
+	if job == nil {
+		log.Trace("payment job has already been claimed", logData)
+		return
+	}
+	logData["payment_id"] = job.Payment.Reference
+
+	resource, err := p.complete(ctx, key, job)
+	job.UpdatedAt = time.Now()
+	job.Attempts++
+	if errors.Is(err, ErrPaymentIncomplete) || paidBy(resource, job.Payment) {
+		job.Recorded = true
+	}
+	logData["attempts"] = job.Attempts
+
+	switch {
+	case err == nil, job.Recorded && err == ErrAlreadyPaid:
+		// a job whose payment was recorded by an earlier attempt has nothing left to do once it has been finished
+		log.Info("payment job completed", logData)
+		job.Status = PaymentJobStatusSucceeded
+		job.Error = ""
+	case job.Recorded && job.Attempts < maxPaymentJobAttempts:
+		log.Info("payment job recorded the payment but could not complete it, leaving it for the next sweep", logData)
+		job.Status = PaymentJobStatusPending
+		job.Error = err.Error()
+	default:
+		log.Error(fmt.Errorf("error completing payment job: [%v]", err), logData)
+		job.Status = PaymentJobStatusFailed
+		job.Error = err.Error()
+	}
+
+	err = p.Service.DAO.UpdatePaymentJob(key.CompanyNumber, key.Reference, job)
+	if err != nil {
+		log.Error(fmt.Errorf("error saving payment job: [%v]", err), logData)
+		return
+	}
+
+	if job.CallbackURL == "" || job.Status == PaymentJobStatusPending {
+		return
+	}
+
+	// the allowed hosts are checked again in case they have changed since the job was submitted
+	if !p.callbackAllowed(job.CallbackURL) {
+		log.Info("payment job callback URL is no longer allowed", logData)
+		return
+	}
+
+	self := ""
+	if resource != nil {
+		self = resource.Links.Self
+	}
+	err = postPaymentCallback(ctx, job.CallbackURL, newPaymentJob(job, self))
+	if err != nil {
+		log.Error(fmt.Errorf("error sending payment job callback: [%v]", err), logData)
+	}
+}
+
+// complete marks the payable resource as paid, updates E5 and sends the confirmation email, or finishes whichever of
+// them failed when the job was last processed. The resource is returned once it has been read, even if the payment
+// could not be completed.
+func (p *PaymentJobPool) complete(ctx context.Context, key dao.PaymentJobKey, job *dao.PaymentJobDao) (*models.PayableResource, error) {
+	scheme := p.scheme(job.Scheme)
+	if scheme == nil {
+		return nil, fmt.Errorf("penalty scheme [%s] not found", job.Scheme)
+	}
+
+	req, err := p.newRequest(ctx, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, responseType, err := p.Service.GetPayableResource(req, key.CompanyNumber, key.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if responseType == NotFound {
+		return nil, ErrLFPNotFound
+	}
+
+	return resource, p.Service.CompletePayment(p.E5Client, *resource, job.Payment, scheme, req)
+}
+
+// paidBy checks whether the payable resource was already paid by the payment, e.g. by an earlier job for it that failed
+// once the payment had been recorded
+func paidBy(resource *models.PayableResource, payment validators.PaymentInformation) bool {
+	return resource != nil && resource.Payment.Status == constants.Paid.String() && resource.Payment.Reference == payment.Reference
+}
+
+// callbackAllowed checks that finished jobs can be posted to the callback URL, which must be https and on one of the
+// configured hosts so that the API cannot be used to make requests to other services
+func (p *PaymentJobPool) callbackAllowed(callbackURL string) bool {
+	u, err := url.Parse(callbackURL)
+	if err != nil || u.Scheme != "https" || p.Service.Config == nil {
+		return false
+	}
+
+	for _, host := range p.Service.Config.PaymentJobCallbackHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheme finds the penalty scheme with the given name
+func (p *PaymentJobPool) scheme(name string) *config.PenaltyScheme {
+	for _, scheme := range p.Schemes {
+		if scheme.Name == name {
+			return scheme
+		}
+	}
+	return nil
+}
+
+// newRequest creates the request used to send the confirmation email. go-sdk-manager takes its credentials from the
+// request, so it carries the API key of the service, and the email depends on the penalty scheme in its context.
+func (p *PaymentJobPool) newRequest(ctx context.Context, scheme *config.PenaltyScheme) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, config.Scheme, scheme), http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.Service.Config != nil {
+		req.SetBasicAuth(p.Service.Config.CHSAPIKey, "")
+	}
+	return req, nil
+}
+
+// GetPaymentJob gets the payment job of a payable resource. ErrPaymentJobNotFound is returned if it was not paid for
+// asynchronously.
+func (s *PayableResourceService) GetPaymentJob(resource *models.PayableResource) (*PaymentJob, error) {
+	job, err := s.DAO.GetPaymentJob(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting payment job from db: [%v]", err)
+		log.Error(err, log.Data{"lfp_reference": resource.Reference, "company_number": resource.CompanyNumber})
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrPaymentJobNotFound
+	}
+
+	return newPaymentJob(job, resource.Links.Self), nil
+}
+
+// PaymentJobPath is the path of the payment job of the payable resource with the given self link
+func PaymentJobPath(resourceSelf string) string {
+	return resourceSelf + "/payment/job"
+}
+
+// newPaymentJob converts the stored payment job into the payment job returned to clients
+func newPaymentJob(job *dao.PaymentJobDao, resourceSelf string) *PaymentJob {
+	return &PaymentJob{
+		Status:           job.Status,
+		PaymentReference: job.Payment.Reference,
+		Error:            job.Error,
+		CreatedAt:        job.CreatedAt,
+		UpdatedAt:        job.UpdatedAt,
+		Links: PaymentJobLinks{
+			Self:     PaymentJobPath(resourceSelf),
+			Resource: resourceSelf,
+		},
+	}
+}
+
+// postPaymentJobCallback sends the finished payment job to the callback URL given when it was submitted. It is sent
+// again, up to paymentJobCallbackAttempts times, if the callback cannot be reached or responds with a server error.
+func postPaymentJobCallback(ctx context.Context, callbackURL string, job *PaymentJob) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		var retryable bool
+		retryable, err = sendPaymentJobCallback(ctx, callbackURL, body)
+		if err == nil || !retryable || attempt == paymentJobCallbackAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(paymentJobCallbackRetryDelay * time.Duration(attempt)):
+		}
+	}
+}
+
+// sendPaymentJobCallback posts the body to the callback URL once, returning whether a failure is worth retrying
+func sendPaymentJobCallback(ctx context.Context, callbackURL string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := paymentJobCallbackClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode >= http.StatusInternalServerError, fmt.Errorf("callback responded with status [%d]", resp.StatusCode)
+	}
+
+	return false, nil
+}
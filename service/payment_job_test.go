@@ -0,0 +1,495 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api-core/validators"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/e5"
+	"github.com/companieshouse/lfp-pay-api/i18n"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// callbackConfig allows payment jobs to be posted back to example.com
+var callbackConfig = &config.Config{PaymentJobCallbackHosts: []string{"example.com"}}
+
+func TestUnitSubmitPaymentJob(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+	resource := &models.PayableResource{
+		CompanyNumber: "10000024",
+		Reference:     "LP123456",
+		Etag:          "etag",
+		Links:         models.PayableResourceLinks{Self: "/company/10000024/penalties/late-filing/payable/LP123456"},
+	}
+	payment := &validators.PaymentInformation{Reference: "late_filing_penalty_123", Status: "paid", Amount: "150"}
+
+	Convey("the job is stored and queued for a worker", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().CreatePaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+				So(job.Status, ShouldEqual, PaymentJobStatusPending)
+				So(job.Payment, ShouldResemble, *payment)
+				So(job.Scheme, ShouldEqual, scheme.Name)
+				So(job.CallbackURL, ShouldEqual, "https://example.com/callback")
+				return nil
+			})
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		job, err := pool.Submit(resource, payment, scheme, "https://example.com/callback")
+		So(err, ShouldBeNil)
+		So(job.Status, ShouldEqual, PaymentJobStatusPending)
+		So(job.PaymentReference, ShouldEqual, "late_filing_penalty_123")
+		So(job.Links.Self, ShouldEqual, "/company/10000024/penalties/late-filing/payable/LP123456/payment/job")
+		So(job.Links.Resource, ShouldEqual, "/company/10000024/penalties/late-filing/payable/LP123456")
+		So(<-pool.jobs, ShouldResemble, dao.PaymentJobKey{CompanyNumber: "10000024", Reference: "LP123456"})
+	})
+
+	Convey("a resource cannot have two payment jobs in progress", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().CreatePaymentJob("10000024", "LP123456", gomock.Any()).Return(dao.ErrPaymentJobExists)
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		job, err := pool.Submit(resource, payment, scheme, "")
+		So(job, ShouldBeNil)
+		So(err, ShouldEqual, ErrPaymentJobInProgress)
+		So(pool.jobs, ShouldBeEmpty)
+	})
+
+	Convey("the callback URL must be https on an allowed host", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mocks.NewMockService(mockCtrl), Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		for _, callbackURL := range []string{"http://example.com/callback", "https://internal.example.org/callback", "https://169.254.169.254/latest"} {
+			job, err := pool.Submit(resource, payment, scheme, callbackURL)
+			So(job, ShouldBeNil)
+			So(err, ShouldEqual, ErrCallbackNotAllowed)
+		}
+		So(pool.jobs, ShouldBeEmpty)
+	})
+
+	Convey("error storing the job", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().CreatePaymentJob("10000024", "LP123456", gomock.Any()).Return(errors.New("any error"))
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		_, err := pool.Submit(resource, payment, scheme, "")
+		So(err, ShouldNotBeNil)
+		So(pool.jobs, ShouldBeEmpty)
+	})
+}
+
+func TestUnitProcessPaymentJob(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+	key := dao.PaymentJobKey{CompanyNumber: "10000024", Reference: "LP123456"}
+	payment := validators.PaymentInformation{Reference: "late_filing_penalty_123", Status: "paid", Amount: "150", PaymentID: "123"}
+
+	newJob := func() *dao.PaymentJobDao {
+		return &dao.PaymentJobDao{
+			Status:      PaymentJobStatusProcessing,
+			Payment:     payment,
+			Scheme:      scheme.Name,
+			CallbackURL: "https://example.com/callback",
+		}
+	}
+	newModel := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber: "10000024",
+			Reference:     "LP123456",
+			Data: models.PayableResourceDataDao{
+				Etag:  "etag",
+				Links: models.PayableResourceLinksDao{Self: "/company/10000024/penalties/late-filing/payable/LP123456"},
+			},
+		}
+	}
+
+	var e5Calls, emailCalls int
+	var emailLang string
+	var callback *PaymentJob
+	completePaymentInE5 = func(*PayableResourceService, *e5.Client, models.PayableResource, validators.PaymentInformation, *config.PenaltyScheme, e5.Action) (e5.Action, error) {
+		e5Calls++
+		return "", nil
+	}
+	sendPaymentEmail = func(_ models.PayableResource, lang string, req *http.Request) error {
+		emailCalls++
+		emailLang = lang
+		s, err := config.GetPenaltyScheme(req.Context())
+		if err != nil || s != scheme {
+			return errors.New("penalty scheme is not in request context")
+		}
+		return nil
+	}
+	postPaymentCallback = func(_ context.Context, _ string, job *PaymentJob) error {
+		callback = job
+		return nil
+	}
+	defer func() {
+		completePaymentInE5 = MarkTransactionsAsPaid
+		sendPaymentEmail = SendEmailKafkaMessage
+		postPaymentCallback = postPaymentJobCallback
+	}()
+
+	reset := func() {
+		e5Calls, emailCalls, emailLang, callback = 0, 0, "", nil
+	}
+
+	Convey("a job that has already been claimed is not processed", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(nil, nil)
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(e5Calls, ShouldEqual, 0)
+		So(callback, ShouldBeNil)
+	})
+
+	Convey("the payment is completed and the callback sent", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		gomock.InOrder(
+			mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(newJob(), nil),
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(newModel(), nil),
+			mockDaoService.EXPECT().IsInScheme("10000024", "LP123456", scheme).Return(true, nil),
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(newModel(), nil),
+			mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).Return(nil),
+			mockDaoService.EXPECT().SavePaymentCard("10000024", "LP123456", gomock.Any()).Return(nil),
+			mockDaoService.EXPECT().GetLanguage("10000024", "LP123456").Return(i18n.Welsh, nil),
+			mockDaoService.EXPECT().UpdatePaymentCompletion("10000024", "LP123456", gomock.Any()).Return(nil),
+			mockDaoService.EXPECT().UpdatePaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+				func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+					So(job.Status, ShouldEqual, PaymentJobStatusSucceeded)
+					So(job.Error, ShouldBeEmpty)
+					return nil
+				}),
+		)
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(e5Calls, ShouldEqual, 1)
+		So(emailCalls, ShouldEqual, 1)
+		So(emailLang, ShouldEqual, i18n.Welsh)
+		So(callback, ShouldNotBeNil)
+		So(callback.Status, ShouldEqual, PaymentJobStatusSucceeded)
+		So(callback.Links.Self, ShouldEqual, "/company/10000024/penalties/late-filing/payable/LP123456/payment/job")
+	})
+
+	Convey("a resource that has already been paid and completed is not posted to E5 again", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		paid := newModel()
+		paid.Data.Payment.Status = "paid"
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(newJob(), nil)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil).Times(2)
+		mockDaoService.EXPECT().IsInScheme("10000024", "LP123456", scheme).Return(true, nil)
+		mockDaoService.EXPECT().ClaimPaymentCompletion("10000024", "LP123456", gomock.Any()).Return(nil, nil)
+		mockDaoService.EXPECT().UpdatePaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+				So(job.Status, ShouldEqual, PaymentJobStatusFailed)
+				So(job.Error, ShouldEqual, ErrAlreadyPaid.Error())
+				return nil
+			})
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(e5Calls, ShouldEqual, 0)
+		So(emailCalls, ShouldEqual, 0)
+		So(callback.Status, ShouldEqual, PaymentJobStatusFailed)
+	})
+
+	Convey("a failure to update E5 once the resource is paid leaves the job for the next sweep", t, func() {
+		reset()
+		completePaymentInE5 = func(*PayableResourceService, *e5.Client, models.PayableResource, validators.PaymentInformation, *config.PenaltyScheme, e5.Action) (e5.Action, error) {
+			e5Calls++
+			return e5.CreateAction, errors.New("e5 unavailable")
+		}
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(newJob(), nil)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(newModel(), nil).Times(2)
+		mockDaoService.EXPECT().IsInScheme("10000024", "LP123456", scheme).Return(true, nil)
+		mockDaoService.EXPECT().MarkAsPaid(gomock.Any(), gomock.Any()).Return(nil)
+		mockDaoService.EXPECT().SavePaymentCard("10000024", "LP123456", gomock.Any()).Return(nil)
+		mockDaoService.EXPECT().GetLanguage("10000024", "LP123456").Return("", nil)
+		mockDaoService.EXPECT().UpdatePaymentCompletion("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, completion *dao.PaymentCompletionDao) error {
+				So(completion.E5Pending, ShouldBeTrue)
+				So(completion.E5Action, ShouldEqual, e5.CreateAction)
+				So(completion.EmailPending, ShouldBeFalse)
+				return nil
+			})
+		mockDaoService.EXPECT().UpdatePaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+				So(job.Status, ShouldEqual, PaymentJobStatusPending)
+				So(job.Recorded, ShouldBeTrue)
+				So(job.Attempts, ShouldEqual, 1)
+				So(job.Error, ShouldContainSubstring, "e5 unavailable")
+				return nil
+			})
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(e5Calls, ShouldEqual, 1)
+		So(emailCalls, ShouldEqual, 1)
+		So(callback, ShouldBeNil)
+	})
+
+	Convey("a recorded job is finished from the step that failed", t, func() {
+		reset()
+		var from e5.Action
+		completePaymentInE5 = func(_ *PayableResourceService, _ *e5.Client, _ models.PayableResource, _ validators.PaymentInformation, _ *config.PenaltyScheme, action e5.Action) (e5.Action, error) {
+			e5Calls++
+			from = action
+			return "", nil
+		}
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		job := newJob()
+		job.Recorded = true
+		job.Attempts = 1
+		paid := newModel()
+		paid.Data.Payment.Status = "paid"
+		paid.Data.Payment.Reference = payment.Reference
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(job, nil)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil).Times(2)
+		mockDaoService.EXPECT().IsInScheme("10000024", "LP123456", scheme).Return(true, nil)
+		mockDaoService.EXPECT().ClaimPaymentCompletion("10000024", "LP123456", gomock.Any()).Return(
+			&dao.PaymentCompletionDao{Payment: payment, E5Pending: true, E5Action: e5.ConfirmAction}, nil)
+		mockDaoService.EXPECT().UpdatePaymentCompletion("10000024", "LP123456", gomock.Any()).Return(nil)
+		mockDaoService.EXPECT().UpdatePaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+				So(job.Status, ShouldEqual, PaymentJobStatusSucceeded)
+				So(job.Error, ShouldBeEmpty)
+				return nil
+			})
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(e5Calls, ShouldEqual, 1)
+		So(from, ShouldEqual, e5.ConfirmAction)
+		So(emailCalls, ShouldEqual, 0)
+		So(callback.Status, ShouldEqual, PaymentJobStatusSucceeded)
+	})
+
+	Convey("a recorded job that still cannot be completed fails after the last attempt", t, func() {
+		reset()
+		completePaymentInE5 = func(*PayableResourceService, *e5.Client, models.PayableResource, validators.PaymentInformation, *config.PenaltyScheme, e5.Action) (e5.Action, error) {
+			e5Calls++
+			return e5.ConfirmAction, errors.New("e5 unavailable")
+		}
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		job := newJob()
+		job.Recorded = true
+		job.Attempts = maxPaymentJobAttempts - 1
+		paid := newModel()
+		paid.Data.Payment.Status = "paid"
+		paid.Data.Payment.Reference = payment.Reference
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(job, nil)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paid, nil).Times(2)
+		mockDaoService.EXPECT().IsInScheme("10000024", "LP123456", scheme).Return(true, nil)
+		mockDaoService.EXPECT().ClaimPaymentCompletion("10000024", "LP123456", gomock.Any()).Return(
+			&dao.PaymentCompletionDao{Payment: payment, E5Pending: true, E5Action: e5.ConfirmAction}, nil)
+		mockDaoService.EXPECT().UpdatePaymentCompletion("10000024", "LP123456", gomock.Any()).Return(nil)
+		mockDaoService.EXPECT().UpdatePaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+				So(job.Status, ShouldEqual, PaymentJobStatusFailed)
+				So(job.Error, ShouldContainSubstring, "e5 unavailable")
+				return nil
+			})
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(callback.Status, ShouldEqual, PaymentJobStatusFailed)
+	})
+
+	Convey("the callback is not sent to a host that is no longer allowed", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		job := newJob()
+		job.Scheme = "unknown"
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(job, nil)
+		mockDaoService.EXPECT().UpdatePaymentJob("10000024", "LP123456", gomock.Any()).Return(nil)
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: &config.Config{}}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(callback, ShouldBeNil)
+	})
+
+	Convey("a job for an unknown penalty scheme fails", t, func() {
+		reset()
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		job := newJob()
+		job.Scheme = "unknown"
+		job.CallbackURL = ""
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).Return(job, nil)
+		mockDaoService.EXPECT().UpdatePaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, job *dao.PaymentJobDao) error {
+				So(job.Status, ShouldEqual, PaymentJobStatusFailed)
+				return nil
+			})
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService, Config: callbackConfig}, nil, []*config.PenaltyScheme{scheme})
+
+		pool.Process(context.Background(), key)
+		So(callback, ShouldBeNil)
+	})
+}
+
+func TestUnitPaymentJobPoolStart(t *testing.T) {
+	Convey("jobs found by the sweep are processed by the workers", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+
+		claimed := make(chan dao.PaymentJobKey, 1)
+		mockDaoService.EXPECT().ListClaimablePaymentJobs(gomock.Any()).Return([]dao.PaymentJobKey{{CompanyNumber: "10000024", Reference: "LP123456"}}, nil)
+		mockDaoService.EXPECT().ClaimPaymentJob("10000024", "LP123456", gomock.Any()).DoAndReturn(
+			func(companyNumber, reference string, staleBefore time.Time) (*dao.PaymentJobDao, error) {
+				claimed <- dao.PaymentJobKey{CompanyNumber: companyNumber, Reference: reference}
+				return nil, nil
+			})
+
+		pool := NewPaymentJobPool(&PayableResourceService{DAO: mockDaoService}, nil, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		pool.Start(ctx)
+
+		select {
+		case key := <-claimed:
+			So(key.Reference, ShouldEqual, "LP123456")
+		case <-time.After(5 * time.Second):
+			t.Fatal("the job was not processed")
+		}
+		cancel()
+		pool.Wait()
+	})
+}
+
+func TestUnitPostPaymentJobCallback(t *testing.T) {
+	paymentJobCallbackRetryDelay = 0
+	defer func() {
+		paymentJobCallbackRetryDelay = 2 * time.Second
+		paymentJobCallbackClient = &http.Client{Timeout: 10 * time.Second}
+	}()
+
+	job := &PaymentJob{Status: PaymentJobStatusSucceeded, PaymentReference: "late_filing_penalty_123"}
+
+	// callback responds with each of the statuses in turn and counts the requests it is sent
+	callback := func(statuses ...int) (*httptest.Server, *int) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(statuses[requests-1])
+		}))
+		paymentJobCallbackClient = server.Client()
+		return server, &requests
+	}
+
+	Convey("the job is posted to the callback", t, func() {
+		server, requests := callback(http.StatusOK)
+		defer server.Close()
+
+		So(postPaymentJobCallback(context.Background(), server.URL, job), ShouldBeNil)
+		So(*requests, ShouldEqual, 1)
+	})
+
+	Convey("a server error is retried", t, func() {
+		server, requests := callback(http.StatusServiceUnavailable, http.StatusNoContent)
+		defer server.Close()
+
+		So(postPaymentJobCallback(context.Background(), server.URL, job), ShouldBeNil)
+		So(*requests, ShouldEqual, 2)
+	})
+
+	Convey("the callback is only retried a limited number of times", t, func() {
+		server, requests := callback(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		defer server.Close()
+
+		So(postPaymentJobCallback(context.Background(), server.URL, job), ShouldNotBeNil)
+		So(*requests, ShouldEqual, paymentJobCallbackAttempts)
+	})
+
+	Convey("a client error is not retried", t, func() {
+		server, requests := callback(http.StatusBadRequest)
+		defer server.Close()
+
+		So(postPaymentJobCallback(context.Background(), server.URL, job), ShouldNotBeNil)
+		So(*requests, ShouldEqual, 1)
+	})
+}
+
+func TestUnitGetPaymentJob(t *testing.T) {
+	resource := &models.PayableResource{
+		CompanyNumber: "10000024",
+		Reference:     "LP123456",
+		Links:         models.PayableResourceLinks{Self: "/company/10000024/penalties/late-filing/payable/LP123456"},
+	}
+
+	Convey("error getting the payment job from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPaymentJob("10000024", "LP123456").Return(nil, errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.GetPaymentJob(resource)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("a resource that was not paid for asynchronously has no payment job", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPaymentJob("10000024", "LP123456").Return(nil, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.GetPaymentJob(resource)
+		So(err, ShouldEqual, ErrPaymentJobNotFound)
+	})
+
+	Convey("the payment job is returned", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPaymentJob("10000024", "LP123456").Return(&dao.PaymentJobDao{
+			Status:  PaymentJobStatusFailed,
+			Payment: validators.PaymentInformation{Reference: "late_filing_penalty_123"},
+			Error:   "the LFP has already been paid",
+		}, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		job, err := svc.GetPaymentJob(resource)
+		So(err, ShouldBeNil)
+		So(job.Status, ShouldEqual, PaymentJobStatusFailed)
+		So(job.PaymentReference, ShouldEqual, "late_filing_penalty_123")
+		So(job.Error, ShouldEqual, "the LFP has already been paid")
+		So(job.Links.Self, ShouldEqual, "/company/10000024/penalties/late-filing/payable/LP123456/payment/job")
+	})
+}
@@ -1,8 +1,13 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/companieshouse/api-sdk-go/companieshouseapi"
+	"github.com/companieshouse/api-sdk-go/privatesdk"
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/go-sdk-manager/manager"
 	"github.com/companieshouse/lfp-pay-api-core/validators"
@@ -48,3 +53,108 @@ func GetPaymentInformation(id string, req *http.Request) (*validators.PaymentInf
 
 	return paymentInformation, nil
 }
+
+// GetPaymentResourceLink gets the link to the resource that a payment on the payment platform was made for, which for
+// a penalty payment is the payment details of the payable resource
+func GetPaymentResourceLink(id string, req *http.Request) (string, error) {
+	publicSDK, err := manager.GetSDK(req)
+	if err != nil {
+		log.Error(err, log.Data{"payment_id": id})
+		return "", err
+	}
+
+	paymentResource, err := publicSDK.Payments.Get(id).Do()
+	if err != nil {
+		log.Error(err, log.Data{"payment_id": id})
+		return "", err
+	}
+
+	return paymentResource.Links.Resource, nil
+}
+
+// refundTimeout is how long the payment platform is given to respond when refunding a payment or listing its refunds
+const refundTimeout = 30 * time.Second
+
+// paymentRefundFailed is the status of a refund on the payment platform that was not made
+const paymentRefundFailed = "failed"
+
+// PaymentRefund is the refund of a payment created on the payment platform
+type PaymentRefund struct {
+	RefundID string `json:"refund_id"`
+	Status   string `json:"status"`
+}
+
+// RefundPayment asks the payment platform to refund the given amount, in pence, of a payment. The refund is made with
+// the API key of the service rather than the credentials of the member of staff that requested it.
+func RefundPayment(ctx context.Context, apiKey, paymentID string, amount int) (*PaymentRefund, error) {
+	ctx, cancel := context.WithTimeout(ctx, refundTimeout)
+	defer cancel()
+
+	privateSDK, err := newPrivateSDK(ctx, apiKey)
+	if err != nil {
+		log.Error(err, log.Data{"payment_id": paymentID})
+		return nil, err
+	}
+
+	refund, err := privateSDK.Payments.CreateRefund(paymentID, &privatesdk.CreateRefundRequest{Amount: amount}).Context(ctx).Do()
+	if err != nil {
+		log.Error(err, log.Data{"payment_id": paymentID})
+		return nil, err
+	}
+
+	return &PaymentRefund{RefundID: refund.RefundID, Status: refund.Status}, nil
+}
+
+// GetPaymentRefunds gets the refunds that the payment platform holds for a payment, so that a refund whose outcome was
+// not known can be reconciled
+func GetPaymentRefunds(ctx context.Context, apiKey, paymentID string) ([]PaymentRefund, error) {
+	ctx, cancel := context.WithTimeout(ctx, refundTimeout)
+	defer cancel()
+
+	privateSDK, err := newPrivateSDK(ctx, apiKey)
+	if err != nil {
+		log.Error(err, log.Data{"payment_id": paymentID})
+		return nil, err
+	}
+
+	list, err := privateSDK.Payments.GetRefunds(paymentID).Context(ctx).Do()
+	if err != nil {
+		log.Error(err, log.Data{"payment_id": paymentID})
+		return nil, err
+	}
+
+	refunds := make([]PaymentRefund, 0, len(list.Items))
+	for _, item := range list.Items {
+		refunds = append(refunds, PaymentRefund{RefundID: item.RefundID, Status: item.Status})
+	}
+
+	return refunds, nil
+}
+
+// IsRefundRejected reports whether an error refunding a payment means that the payment platform turned the refund
+// down, so it has not been made. Any other error, e.g. a timeout or a server error, leaves the outcome unknown.
+func IsRefundRejected(err error) bool {
+	var apiErr *companieshouseapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Code {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+
+	return apiErr.Code >= http.StatusBadRequest && apiErr.Code < http.StatusInternalServerError
+}
+
+// newPrivateSDK gets the private SDK for calls made by the service itself. go-sdk-manager takes its credentials from
+// the request, so it carries the API key of the service, and the context of the request bounds the call.
+func newPrivateSDK(ctx context.Context, apiKey string) (*privatesdk.Service, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(apiKey, "")
+
+	return manager.GetPrivateSDK(req)
+}
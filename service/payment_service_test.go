@@ -2,9 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"testing"
 
+	"github.com/companieshouse/api-sdk-go/companieshouseapi"
 	"github.com/companieshouse/go-session-handler/httpsession"
 	"github.com/companieshouse/go-session-handler/session"
 	"github.com/companieshouse/lfp-pay-api-core/constants"
@@ -119,3 +121,18 @@ func TestUnitGetPaymentInformation(t *testing.T) {
 		})
 	})
 }
+
+func TestUnitIsRefundRejected(t *testing.T) {
+	Convey("client errors from the payment platform turn the refund down", t, func() {
+		So(IsRefundRejected(&companieshouseapi.Error{Code: http.StatusBadRequest}), ShouldBeTrue)
+		So(IsRefundRejected(&companieshouseapi.Error{Code: http.StatusForbidden}), ShouldBeTrue)
+	})
+
+	Convey("other errors leave the outcome of the refund unknown", t, func() {
+		So(IsRefundRejected(&companieshouseapi.Error{Code: http.StatusInternalServerError}), ShouldBeFalse)
+		So(IsRefundRejected(&companieshouseapi.Error{Code: http.StatusRequestTimeout}), ShouldBeFalse)
+		So(IsRefundRejected(&companieshouseapi.Error{Code: http.StatusConflict}), ShouldBeFalse)
+		So(IsRefundRejected(context.DeadlineExceeded), ShouldBeFalse)
+		So(IsRefundRejected(errors.New("connection refused")), ShouldBeFalse)
+	})
+}
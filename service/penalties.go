@@ -36,15 +36,17 @@ func (transactionType TransactionType) String() string {
 }
 
 // GetPenalties is a function that:
-// 1. makes a request to e5 to get a list of transactions for the specified company
+// 1. makes a request to e5 to get a list of transactions in the penalty scheme for the specified company
 // 2. takes the results of this request and maps them to a format that the lfp-pay-web can consume
-func GetPenalties(companyNumber string) (*models.TransactionListResponse, ResponseType, error) {
+func GetPenalties(companyNumber string, scheme *config.PenaltyScheme) (*models.TransactionListResponse, ResponseType, error) {
 	cfg, err := config.Get()
 	if err != nil {
-		return nil, Error, nil
+		err = fmt.Errorf("error getting config: [%v]", err)
+		log.Error(err)
+		return nil, Error, err
 	}
 	client := e5.NewClient(cfg.E5Username, cfg.E5APIURL)
-	e5Response, err := client.GetTransactions(&e5.GetTransactionsInput{CompanyNumber: companyNumber, CompanyCode: "LP"})
+	e5Response, err := client.GetTransactions(&e5.GetTransactionsInput{CompanyNumber: companyNumber, CompanyCode: scheme.CompanyCode})
 
 	if err != nil {
 		log.Error(fmt.Errorf("error getting transaction list: [%v]", err))
@@ -53,49 +55,65 @@ func GetPenalties(companyNumber string) (*models.TransactionListResponse, Respon
 
 	// Generate the CH preferred format of the results i.e. classify the transactions into payable "penalty" types or
 	// non-payable "other" types
-	generatedTransactionListFromE5Response, err := generateTransactionListFromE5Response(e5Response)
+	generatedTransactionListFromE5Response, err := generateTransactionListFromE5Response(e5Response, scheme)
 	if err != nil {
 		err = fmt.Errorf("error generating transaction list from the e5 response: [%v]", err)
 		log.Error(err)
 		return nil, Error, err
 	}
 
-	log.Info("Completed GetPenalties request and mapped to CH LFP transactions", log.Data{"company_number": companyNumber})
+	log.Info("Completed GetPenalties request and mapped to CH LFP transactions", log.Data{"company_number": companyNumber, "penalty_scheme": scheme.Name})
 	return generatedTransactionListFromE5Response, Success, nil
 }
 
+// PenaltyResponse is a single transaction along with its due status and whether it can currently be paid online
+type PenaltyResponse struct {
+	models.TransactionListItem
+	DueStatus
+	IsPayable        bool         `json:"is_payable"`
+	NotPayableReason string       `json:"not_payable_reason,omitempty"`
+	Links            PenaltyLinks `json:"links"`
+}
+
+// PenaltyLinks links a transaction to itself and to any payable resources that include it
+type PenaltyLinks struct {
+	Self             string   `json:"self"`
+	PayableResources []string `json:"payable_resources,omitempty"`
+}
+
+// FindTransaction returns the transaction with the given id from the list, or nil if it is not there
+func FindTransaction(transactions *models.TransactionListResponse, transactionID string) *models.TransactionListItem {
+	for _, transaction := range transactions.Items {
+		if transaction.ID == transactionID {
+			return &transaction
+		}
+	}
+
+	return nil
+}
+
 // GetTransactionForPenalty returns a single, specified, transaction from e5 for a specific company
-func GetTransactionForPenalty(companyNumber, penaltyNumber string) (*models.TransactionListItem, error) {
-	response, _, err := GetPenalties(companyNumber)
+func GetTransactionForPenalty(companyNumber, penaltyNumber string, scheme *config.PenaltyScheme) (*models.TransactionListItem, error) {
+	response, _, err := GetPenalties(companyNumber, scheme)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 
-	for _, transaction := range response.Items {
-		if transaction.ID == penaltyNumber {
-			return &transaction, nil
-		}
+	if transaction := FindTransaction(response, penaltyNumber); transaction != nil {
+		return transaction, nil
 	}
 
 	return nil, fmt.Errorf("cannot find lfp transaction for penalty number [%v]", penaltyNumber)
 }
 
-func generateTransactionListFromE5Response(e5Response *e5.GetTransactionsResponse) (*models.TransactionListResponse, error) {
+func generateTransactionListFromE5Response(e5Response *e5.GetTransactionsResponse, scheme *config.PenaltyScheme) (*models.TransactionListResponse, error) {
 	// Next, map results to a format that can be used by LFP web
 	payableTransactionList := models.TransactionListResponse{}
-	etag, err := utils.GenerateEtag()
-	if err != nil {
-		err = fmt.Errorf("error generating etag: [%v]", err)
-		log.Error(err)
-		return nil, err
-	}
-
-	payableTransactionList.Etag = etag
 	payableTransactionList.TotalResults = e5Response.Page.TotalElements
 	// Each transaction needs to be checked and identified as a 'penalty' or 'other'. This allows lfp-web to determine
-	// which transactions are payable. This is done using a yaml file to map payable transactions
-	yamlFile, err := ioutil.ReadFile("assets/penalty_types.yml")
+	// which transactions are payable. This is done using the scheme's yaml file to map payable transactions
+	yamlFile, err := ioutil.ReadFile(scheme.AllowedTypesFile)
 	if err != nil {
 		err = fmt.Errorf("error reading penalty types yaml file: [%v]", err)
 		log.Error(err)
@@ -114,14 +132,8 @@ func generateTransactionListFromE5Response(e5Response *e5.GetTransactionsRespons
 	for _, e5Transaction := range e5Response.Transactions {
 		listItem := models.TransactionListItem{}
 		listItem.ID = e5Transaction.TransactionReference
-		listItem.Etag, err = utils.GenerateEtag()
-		if err != nil {
-			err = fmt.Errorf("error generating etag: [%v]", err)
-			log.Error(err)
-			return nil, err
-		}
 		listItem.IsPaid = e5Transaction.IsPaid
-		listItem.Kind = "late-filing-penalty#late-filing-penalty"
+		listItem.Kind = scheme.ResourceKind
 		listItem.IsDCA = e5Transaction.AccountStatus == "DCA"
 		listItem.DueDate = e5Transaction.DueDate
 		listItem.MadeUpDate = e5Transaction.MadeUpDate
@@ -134,23 +146,44 @@ func generateTransactionListFromE5Response(e5Response *e5.GetTransactionsRespons
 		} else {
 			listItem.Type = Other.String()
 		}
+		// the etag is derived from the mapped transaction so it only changes when the transaction does
+		listItem.Etag, err = utils.GenerateEtag(listItem)
+		if err != nil {
+			err = fmt.Errorf("error generating etag: [%v]", err)
+			log.Error(err)
+			return nil, err
+		}
 		payableTransactionList.Items = append(payableTransactionList.Items, listItem)
 	}
+
+	// the list etag covers every item, including their etags, so any change to a transaction changes the list etag
+	payableTransactionList.Etag, err = utils.GenerateEtag(payableTransactionList)
+	if err != nil {
+		err = fmt.Errorf("error generating etag: [%v]", err)
+		log.Error(err)
+		return nil, err
+	}
+
 	return &payableTransactionList, nil
 }
 
-// MarkTransactionsAsPaid will update the transactions in E5 as paid.
+// MarkTransactionsAsPaid will update the transactions in E5 as paid, starting from the given command so that a payment
+// that failed part way through can be finished without being created in E5 again. The command that failed is returned
+// along with the error, or an empty action if the transactions are now paid in E5.
 // resource - is the payable resource from the db representing the late filing penalty(ies)
 // payment - is the information about the payment session
-func MarkTransactionsAsPaid(svc *PayableResourceService, client *e5.Client, resource models.PayableResource, payment validators.PaymentInformation) error {
+// scheme - is the penalty scheme the transactions are held under in E5
+// from - is the first command to make, e.g. create for a payment that has not been made in E5
+func MarkTransactionsAsPaid(svc *PayableResourceService, client *e5.Client, resource models.PayableResource, payment validators.PaymentInformation, scheme *config.PenaltyScheme, from e5.Action) (e5.Action, error) {
 	amountPaid, err := strconv.ParseFloat(payment.Amount, 32)
 	if err != nil {
 		log.Error(err, log.Data{"payment_id": payment.Reference, "amount": payment.Amount})
-		return err
+		return from, err
 	}
 
 	var transactions []*e5.CreatePaymentTransaction
 
+	// each transaction is allocated the amount paid off it, which is less than its outstanding amount for a part payment
 	for _, t := range resource.Transactions {
 		transactions = append(transactions, &e5.CreatePaymentTransaction{
 			Reference: t.TransactionID,
@@ -167,52 +200,65 @@ func MarkTransactionsAsPaid(svc *PayableResourceService, client *e5.Client, reso
 	// the payments and finally 3) confirm the payment. if anyone of these fails, the company account will be locked in
 	// E5. Finance have confirmed that it is better to keep these locked as a cleanup process will happen naturally in
 	// the working day.
-	err = client.CreatePayment(&e5.CreatePaymentInput{
-		CompanyCode:   "LP",
-		CompanyNumber: resource.CompanyNumber,
-		PaymentID:     paymentID,
-		TotalValue:    amountPaid,
-		Transactions:  transactions,
-	})
+	resumed := from != e5.CreateAction
+	if from == e5.CreateAction {
+		err = client.CreatePayment(&e5.CreatePaymentInput{
+			CompanyCode:   scheme.CompanyCode,
+			CompanyNumber: resource.CompanyNumber,
+			PaymentID:     paymentID,
+			TotalValue:    amountPaid,
+			Transactions:  transactions,
+		})
 
-	if err != nil {
-		if svcErr := svc.RecordE5CommandError(resource, e5.CreateAction); svcErr != nil {
+		if err != nil {
+			if svcErr := svc.RecordE5CommandError(resource, e5.CreateAction); svcErr != nil {
+				log.Error(svcErr, log.Data{"payment_id": payment.PaymentID, "lfp_reference": resource.Reference})
+				return e5.CreateAction, err
+			}
+			logE5Error("failed to create payment in E5", err, resource, payment)
+			return e5.CreateAction, err
+		}
+
+		// the payment now exists in E5, so its id is needed to time it out if the resource is cancelled after a later
+		// command fails and leaves the account locked. the payment can still go ahead if the id cannot be stored.
+		if svcErr := svc.DAO.SaveE5PaymentID(resource.CompanyNumber, resource.Reference, paymentID); svcErr != nil {
 			log.Error(svcErr, log.Data{"payment_id": payment.PaymentID, "lfp_reference": resource.Reference})
-			return err
 		}
-		logE5Error("failed to create payment in E5", err, resource, payment)
-		return err
+
+		from = e5.AuthoriseAction
 	}
 
-	err = client.AuthorisePayment(&e5.AuthorisePaymentInput{
-		CompanyCode:   "LP",
-		PaymentID:     paymentID,
-		CardReference: payment.ExternalPaymentID,
-		CardType:      payment.CardType,
-		Email:         payment.CreatedBy,
-	})
+	if from == e5.AuthoriseAction {
+		err = client.AuthorisePayment(&e5.AuthorisePaymentInput{
+			CompanyCode:   scheme.CompanyCode,
+			PaymentID:     paymentID,
+			CardReference: payment.ExternalPaymentID,
+			CardType:      payment.CardType,
+			Email:         payment.CreatedBy,
+		})
 
-	if err != nil {
-		if svcErr := svc.RecordE5CommandError(resource, e5.AuthoriseAction); svcErr != nil {
-			log.Error(svcErr, log.Data{"payment_id": payment.PaymentID, "lfp_reference": resource.Reference})
-			return err
+		if err != nil {
+			if svcErr := svc.RecordE5CommandError(resource, e5.AuthoriseAction); svcErr != nil {
+				log.Error(svcErr, log.Data{"payment_id": payment.PaymentID, "lfp_reference": resource.Reference})
+				return e5.AuthoriseAction, err
+			}
+			logE5Error("failed to authorise payment in E5", err, resource, payment)
+			return e5.AuthoriseAction, err
 		}
-		logE5Error("failed to authorise payment in E5", err, resource, payment)
-		return err
 	}
 
 	err = client.ConfirmPayment(&e5.PaymentActionInput{
-		CompanyCode: "LP",
+		CompanyCode: scheme.CompanyCode,
 		PaymentID:   paymentID,
 	})
 
 	if err != nil {
 		if svcErr := svc.RecordE5CommandError(resource, e5.ConfirmAction); svcErr != nil {
 			log.Error(svcErr, log.Data{"payment_id": payment.PaymentID, "lfp_reference": resource.Reference})
-			return err
+			return e5.ConfirmAction, err
 		}
 		logE5Error("failed to confirm payment in E5", err, resource, payment)
-		return err
+		return e5.ConfirmAction, err
 	}
 
 	log.Info("marked LFP transaction(s) as paid in E5", log.Data{
@@ -221,7 +267,14 @@ func MarkTransactionsAsPaid(svc *PayableResourceService, client *e5.Client, reso
 		"e5_puon":       payment.PaymentID,
 	})
 
-	return nil
+	// a payment resumed after a command failed had locked the account, which confirming it has released
+	if resumed {
+		if svcErr := svc.ClearE5CommandError(resource); svcErr != nil {
+			log.Error(svcErr, log.Data{"payment_id": payment.PaymentID, "lfp_reference": resource.Reference})
+		}
+	}
+
+	return "", nil
 }
 
 func logE5Error(message string, originalError error, resource models.PayableResource, payment validators.PaymentInformation) {
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+const (
+	// DefaultItemsPerPage is the page size used when the client asks for a page without saying how big it is
+	DefaultItemsPerPage = 100
+	// MaxItemsPerPage is the largest page size a client can request
+	MaxItemsPerPage = 100
+
+	// SortByDueDate orders transactions by due date, earliest first
+	SortByDueDate = "due_date"
+	// SortByDueDateDescending orders transactions by due date, latest first
+	SortByDueDateDescending = "-due_date"
+
+	// e5DateLayout is the format of the dates returned by E5
+	e5DateLayout = "2006-01-02"
+)
+
+// PenaltyListOptions contains the filtering, sorting and pagination to apply to a company's list of transactions. The
+// list is only paged when ItemsPerPage is set, otherwise every matching transaction is returned.
+type PenaltyListOptions struct {
+	Type         string
+	Paid         *bool
+	Overdue      *bool
+	From         *time.Time
+	To           *time.Time
+	Sort         string
+	Page         int
+	ItemsPerPage int
+}
+
+// PenaltyListResponse is a single page of a company's transactions
+type PenaltyListResponse struct {
+	Etag         string            `json:"etag"`
+	TotalResults int               `json:"total_results"`
+	Items        []PenaltyListItem `json:"items"`
+	StartIndex   int               `json:"start_index"`
+	ItemsPerPage int               `json:"items_per_page"`
+	Links        PenaltyListLinks  `json:"links"`
+}
+
+// PenaltyListItem is a transaction along with its due status as of today
+type PenaltyListItem struct {
+	models.TransactionListItem
+	DueStatus
+}
+
+// PenaltyListLinks contains the links used to navigate between pages of transactions
+type PenaltyListLinks struct {
+	Self     string `json:"self"`
+	Next     string `json:"next,omitempty"`
+	Previous string `json:"previous,omitempty"`
+}
+
+// FilterPenalties applies the supplied options to the full list of transactions for a company and returns the
+// requested page. The path and query are used to build the links to the current, next and previous pages.
+func FilterPenalties(transactions *models.TransactionListResponse, options PenaltyListOptions, path string, query url.Values) (*PenaltyListResponse, error) {
+	if options.Page < 1 {
+		options.Page = 1
+	}
+
+	today := now()
+	filtered := []PenaltyListItem{}
+	for _, transaction := range transactions.Items {
+		item := PenaltyListItem{TransactionListItem: transaction, DueStatus: GetDueStatus(transaction, today)}
+		include, err := options.includes(item)
+		if err != nil {
+			return nil, err
+		}
+		if include {
+			filtered = append(filtered, item)
+		}
+	}
+
+	if options.Sort != "" {
+		sortByDueDate(filtered, options.Sort == SortByDueDateDescending)
+	}
+
+	response := &PenaltyListResponse{
+		TotalResults: len(filtered),
+		Items:        filtered,
+		ItemsPerPage: len(filtered),
+		Links: PenaltyListLinks{
+			Self: selfLink(path, query),
+		},
+	}
+
+	if options.ItemsPerPage > 0 {
+		startIndex := (options.Page - 1) * options.ItemsPerPage
+		endIndex := startIndex + options.ItemsPerPage
+		if startIndex > len(filtered) {
+			startIndex = len(filtered)
+		}
+		if endIndex > len(filtered) {
+			endIndex = len(filtered)
+		}
+
+		response.Items = filtered[startIndex:endIndex]
+		response.StartIndex = startIndex
+		response.ItemsPerPage = options.ItemsPerPage
+		response.Links.Self = pageLink(path, query, options.Page)
+
+		if endIndex < len(filtered) {
+			response.Links.Next = pageLink(path, query, options.Page+1)
+		}
+		if options.Page > 1 {
+			response.Links.Previous = pageLink(path, query, options.Page-1)
+		}
+	}
+
+	// the etag covers the page being returned so that it changes when either the transactions or the page do
+	etag, err := utils.GenerateEtag(response)
+	if err != nil {
+		return nil, fmt.Errorf("error generating etag: [%v]", err)
+	}
+	response.Etag = etag
+
+	return response, nil
+}
+
+// includes checks whether the transaction matches all of the filters in the options
+func (options PenaltyListOptions) includes(item PenaltyListItem) (bool, error) {
+	if options.Type != "" && item.Type != options.Type {
+		return false, nil
+	}
+
+	if options.Paid != nil && item.IsPaid != *options.Paid {
+		return false, nil
+	}
+
+	// whether a transaction with an unknown due status is overdue is not known, so it matches neither filter
+	if options.Overdue != nil && (item.IsUnknown || item.IsOverdue != *options.Overdue) {
+		return false, nil
+	}
+
+	if options.From == nil && options.To == nil {
+		return true, nil
+	}
+
+	transactionDate, err := ParseE5Date(item.TransactionDate)
+	if err != nil {
+		return false, fmt.Errorf("error parsing transaction date for transaction [%s]: [%v]", item.ID, err)
+	}
+	if options.From != nil && transactionDate.Before(*options.From) {
+		return false, nil
+	}
+	if options.To != nil && transactionDate.After(*options.To) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// sortByDueDate orders the transactions by due date. The sort is stable so transactions due on the same date keep
+// the order returned by E5, and transactions with a missing or invalid due date come last in either order.
+func sortByDueDate(items []PenaltyListItem, descending bool) {
+	// the due dates are parsed once, alongside the transactions they belong to, rather than on every comparison
+	dated := make([]struct {
+		item    PenaltyListItem
+		dueDate time.Time
+		valid   bool
+	}, len(items))
+	for i, item := range items {
+		dueDate, err := ParseE5Date(item.DueDate)
+		dated[i].item = item
+		dated[i].dueDate = dueDate
+		dated[i].valid = err == nil
+	}
+
+	sort.SliceStable(dated, func(i, j int) bool {
+		if dated[i].valid != dated[j].valid {
+			return dated[i].valid
+		}
+		if descending {
+			return dated[i].dueDate.After(dated[j].dueDate)
+		}
+		return dated[i].dueDate.Before(dated[j].dueDate)
+	})
+
+	for i := range dated {
+		items[i] = dated[i].item
+	}
+}
+
+// selfLink builds the link to the unpaged list, keeping the query parameters from the request
+func selfLink(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// pageLink builds the link to the given page, keeping all of the other query parameters from the request
+func pageLink(path string, query url.Values, page int) string {
+	pageQuery := url.Values{}
+	for key, values := range query {
+		pageQuery[key] = values
+	}
+	pageQuery.Set("page", strconv.Itoa(page))
+	return path + "?" + pageQuery.Encode()
+}
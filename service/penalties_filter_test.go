@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func createTransactionList() *models.TransactionListResponse {
+	return &models.TransactionListResponse{
+		TotalResults: 3,
+		Items: []models.TransactionListItem{
+			{ID: "00378420", Type: "penalty", IsPaid: false, TransactionDate: "2017-11-28", DueDate: "2017-12-12"},
+			{ID: "00378421", Type: "other", IsPaid: true, TransactionDate: "2018-04-30", DueDate: "2018-05-14"},
+			{ID: "00378422", Type: "penalty", IsPaid: true, TransactionDate: "2016-01-05", DueDate: "2016-01-19"},
+		},
+	}
+}
+
+func TestUnitFilterPenalties(t *testing.T) {
+	path := "/company/10000024/penalties/late-filing"
+
+	Convey("no options returns every transaction in E5 order", t, func() {
+		response, err := FilterPenalties(createTransactionList(), PenaltyListOptions{}, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 3)
+		So(response.StartIndex, ShouldEqual, 0)
+		So(response.ItemsPerPage, ShouldEqual, 3)
+		So(response.Items[0].ID, ShouldEqual, "00378420")
+		So(response.Links.Self, ShouldEqual, path)
+		So(response.Links.Next, ShouldBeEmpty)
+		So(response.Links.Previous, ShouldBeEmpty)
+		So(len(response.Etag), ShouldEqual, 56)
+	})
+
+	Convey("filter by type and paid status", t, func() {
+		paid := true
+		options := PenaltyListOptions{Type: "penalty", Paid: &paid}
+		response, err := FilterPenalties(createTransactionList(), options, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 1)
+		So(response.Items[0].ID, ShouldEqual, "00378422")
+	})
+
+	Convey("filter by transaction date range", t, func() {
+		from := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2017, 11, 28, 0, 0, 0, 0, time.UTC)
+		options := PenaltyListOptions{From: &from, To: &to}
+		response, err := FilterPenalties(createTransactionList(), options, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 1)
+		So(response.Items[0].ID, ShouldEqual, "00378420")
+	})
+
+	Convey("filter by overdue status", t, func() {
+		now = func() time.Time { return time.Date(2018, 1, 1, 9, 0, 0, 0, ukTime) }
+		defer func() { now = time.Now }()
+
+		overdue := true
+		response, err := FilterPenalties(createTransactionList(), PenaltyListOptions{Overdue: &overdue}, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 1)
+		So(response.Items[0].ID, ShouldEqual, "00378420")
+		So(response.Items[0].DaysOverdue, ShouldEqual, 20)
+
+		overdue = false
+		response, err = FilterPenalties(createTransactionList(), PenaltyListOptions{Overdue: &overdue}, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 2)
+	})
+
+	Convey("a list that is not paged is not truncated", t, func() {
+		transactions := &models.TransactionListResponse{}
+		for i := 0; i < MaxItemsPerPage+50; i++ {
+			transactions.Items = append(transactions.Items, models.TransactionListItem{ID: fmt.Sprintf("%08d", i), Type: "penalty", TransactionDate: "2017-11-28", DueDate: "2017-12-12"})
+		}
+		query := url.Values{"type": []string{"penalty"}}
+		response, err := FilterPenalties(transactions, PenaltyListOptions{Type: "penalty"}, path, query)
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, MaxItemsPerPage+50)
+		So(len(response.Items), ShouldEqual, MaxItemsPerPage+50)
+		So(response.Links.Self, ShouldEqual, path+"?type=penalty")
+		So(response.Links.Next, ShouldBeEmpty)
+	})
+
+	Convey("sort by due date", t, func() {
+		response, err := FilterPenalties(createTransactionList(), PenaltyListOptions{Sort: SortByDueDate}, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.Items[0].ID, ShouldEqual, "00378422")
+		So(response.Items[2].ID, ShouldEqual, "00378421")
+
+		response, err = FilterPenalties(createTransactionList(), PenaltyListOptions{Sort: SortByDueDateDescending}, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.Items[0].ID, ShouldEqual, "00378421")
+	})
+
+	Convey("transactions with the same ID are sorted by their own due dates", t, func() {
+		transactions := &models.TransactionListResponse{
+			Items: []models.TransactionListItem{
+				{ID: "00378420", Type: "penalty", TransactionDate: "2017-11-28", DueDate: "2018-05-14"},
+				{ID: "00378420", Type: "other", TransactionDate: "2017-11-28", DueDate: "2017-12-12"},
+			},
+		}
+		response, err := FilterPenalties(transactions, PenaltyListOptions{Sort: SortByDueDate}, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.Items[0].DueDate, ShouldEqual, "2017-12-12")
+		So(response.Items[1].DueDate, ShouldEqual, "2018-05-14")
+	})
+
+	Convey("pagination returns the requested page and links", t, func() {
+		query := url.Values{"items_per_page": []string{"1"}, "page": []string{"2"}}
+		options := PenaltyListOptions{Page: 2, ItemsPerPage: 1}
+		response, err := FilterPenalties(createTransactionList(), options, path, query)
+		So(err, ShouldBeNil)
+		So(response.TotalResults, ShouldEqual, 3)
+		So(response.StartIndex, ShouldEqual, 1)
+		So(response.ItemsPerPage, ShouldEqual, 1)
+		So(len(response.Items), ShouldEqual, 1)
+		So(response.Items[0].ID, ShouldEqual, "00378421")
+		So(response.Links.Self, ShouldEqual, path+"?items_per_page=1&page=2")
+		So(response.Links.Next, ShouldEqual, path+"?items_per_page=1&page=3")
+		So(response.Links.Previous, ShouldEqual, path+"?items_per_page=1&page=1")
+	})
+
+	Convey("page beyond the end of the list is empty", t, func() {
+		options := PenaltyListOptions{Page: 5, ItemsPerPage: 2}
+		response, err := FilterPenalties(createTransactionList(), options, path, url.Values{})
+		So(err, ShouldBeNil)
+		So(response.Items, ShouldBeEmpty)
+		So(response.Links.Next, ShouldBeEmpty)
+	})
+
+	Convey("a transaction with a due date that cannot be parsed has an unknown due status and is sorted last", t, func() {
+		transactions := createTransactionList()
+		transactions.Items[0].DueDate = "invalid"
+
+		for _, sort := range []string{SortByDueDate, SortByDueDateDescending} {
+			response, err := FilterPenalties(transactions, PenaltyListOptions{Sort: sort}, path, url.Values{})
+			So(err, ShouldBeNil)
+			So(response.Items, ShouldHaveLength, 3)
+			So(response.Items[2].ID, ShouldEqual, "00378420")
+			So(response.Items[2].IsUnknown, ShouldBeTrue)
+		}
+	})
+
+	Convey("a transaction with an unknown due status matches neither overdue filter", t, func() {
+		transactions := createTransactionList()
+		transactions.Items[0].DueDate = ""
+
+		for _, overdue := range []bool{true, false} {
+			overdue := overdue
+			response, err := FilterPenalties(transactions, PenaltyListOptions{Overdue: &overdue}, path, url.Values{})
+			So(err, ShouldBeNil)
+			for _, item := range response.Items {
+				So(item.ID, ShouldNotEqual, "00378420")
+			}
+		}
+	})
+}
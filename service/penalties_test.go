@@ -43,7 +43,7 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 		r := models.PayableResource{}
 		p := validators.PaymentInformation{Amount: "foo"}
 
-		err := MarkTransactionsAsPaid(svc, c, r, p)
+		_, err := MarkTransactionsAsPaid(svc, c, r, p, mocks.LateFilingScheme(), e5.CreateAction)
 		So(err, ShouldNotBeNil)
 	})
 
@@ -73,9 +73,10 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 				},
 			}
 
-			err := MarkTransactionsAsPaid(svc, c, r, p)
+			action, err := MarkTransactionsAsPaid(svc, c, r, p, mocks.LateFilingScheme(), e5.CreateAction)
 
 			So(err, ShouldBeError, e5.ErrE5BadRequest)
+			So(action, ShouldEqual, e5.CreateAction)
 		})
 
 		Convey("failure in authorising a payment", func() {
@@ -85,6 +86,7 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment", okResponder)
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/authorise", e5Responder)
 
+			mockService.EXPECT().SaveE5PaymentID("10000024", "123", "X123").Return(nil)
 			mockService.EXPECT().SaveE5Error("10000024", "123", e5.AuthoriseAction).Return(errors.New(""))
 
 			c := &e5.Client{}
@@ -102,9 +104,10 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 				},
 			}
 
-			err := MarkTransactionsAsPaid(svc, c, r, p)
+			action, err := MarkTransactionsAsPaid(svc, c, r, p, mocks.LateFilingScheme(), e5.CreateAction)
 
 			So(err, ShouldBeError, e5.ErrE5BadRequest)
+			So(action, ShouldEqual, e5.AuthoriseAction)
 		})
 
 		Convey("failure in confirming a payment", func() {
@@ -115,6 +118,7 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/authorise", okResponder)
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/confirm", e5Responder)
 
+			mockService.EXPECT().SaveE5PaymentID("10000024", "123", "X123").Return(nil)
 			mockService.EXPECT().SaveE5Error("10000024", "123", e5.ConfirmAction).Return(errors.New(""))
 
 			c := &e5.Client{}
@@ -132,9 +136,10 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 				},
 			}
 
-			err := MarkTransactionsAsPaid(svc, c, r, p)
+			action, err := MarkTransactionsAsPaid(svc, c, r, p, mocks.LateFilingScheme(), e5.CreateAction)
 
 			So(err, ShouldBeError, e5.ErrE5BadRequest)
+			So(action, ShouldEqual, e5.ConfirmAction)
 		})
 
 		Convey("no errors when all 3 calls to E5 succeed", func() {
@@ -144,6 +149,43 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/authorise", okResponder)
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/confirm", okResponder)
 
+			mockService.EXPECT().SaveE5PaymentID("10000024", "123", "X123").Return(nil)
+
+			c := &e5.Client{}
+			p := validators.PaymentInformation{
+				Amount:    "150",
+				PaymentID: "123",
+				CreatedBy: "test@example.com",
+			}
+
+			r := models.PayableResource{
+				Reference:     "123",
+				CompanyNumber: "10000024",
+				Transactions: []models.TransactionItem{
+					{TransactionID: "123", Amount: 150},
+				},
+			}
+
+			_, err := MarkTransactionsAsPaid(svc, c, r, p, mocks.LateFilingScheme(), e5.CreateAction)
+
+			So(err, ShouldBeNil)
+		})
+
+		Convey("a payment created by an earlier attempt is not created again", func() {
+			defer httpmock.Reset()
+			created := false
+			createResponder := func(req *http.Request) (*http.Response, error) {
+				created = true
+				return httpmock.NewStringResponse(http.StatusBadRequest, e5ValidationError), nil
+			}
+			okResponder := httpmock.NewBytesResponder(http.StatusOK, nil)
+			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment", createResponder)
+			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/authorise", okResponder)
+			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/confirm", okResponder)
+
+			// confirming the resumed payment releases the lock left by the command that failed
+			mockService.EXPECT().ClearE5Error("10000024", "123").Return(nil)
+
 			c := &e5.Client{}
 			p := validators.PaymentInformation{
 				Amount:    "150",
@@ -159,9 +201,11 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 				},
 			}
 
-			err := MarkTransactionsAsPaid(svc, c, r, p)
+			action, err := MarkTransactionsAsPaid(svc, c, r, p, mocks.LateFilingScheme(), e5.AuthoriseAction)
 
 			So(err, ShouldBeNil)
+			So(action, ShouldBeEmpty)
+			So(created, ShouldBeFalse)
 		})
 
 		Convey("paymentId (PUON) is prefixed with 'X'", func() {
@@ -192,6 +236,8 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/authorise", paymentIDResponder)
 			httpmock.RegisterResponder(http.MethodPost, "/arTransactions/payment/confirm", paymentIDResponder)
 
+			mockService.EXPECT().SaveE5PaymentID("10000024", "123", "X123").Return(errors.New("any error"))
+
 			c := &e5.Client{}
 			p := validators.PaymentInformation{
 				Amount:    "150",
@@ -207,7 +253,7 @@ func TestUnitMarkTransactionsAsPaid(t *testing.T) {
 				},
 			}
 
-			err := MarkTransactionsAsPaid(svc, c, r, p)
+			_, err := MarkTransactionsAsPaid(svc, c, r, p, mocks.LateFilingScheme(), e5.CreateAction)
 			So(err, ShouldBeNil)
 
 		})
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/e5"
+)
+
+// lockingE5Actions are the E5 commands that leave the company account locked when they fail, i.e. those made after the
+// payment has been created in E5
+var lockingE5Actions = []e5.Action{e5.AuthoriseAction, e5.ConfirmAction}
+
+// PenaltySummary is an overview of a company's account in a penalty scheme
+type PenaltySummary struct {
+	CompanyNumber      string         `json:"company_number"`
+	TotalOutstanding   float64        `json:"total_outstanding"`
+	PayableOutstanding float64        `json:"payable_outstanding"`
+	OutstandingCounts  map[string]int `json:"outstanding_counts"`
+	EarliestDueDate    string         `json:"earliest_due_date,omitempty"`
+	HasDCA             bool           `json:"has_dca"`
+	IsLocked           bool           `json:"is_locked"`
+	Kind               string         `json:"kind"`
+}
+
+// PayabilityCheck returns an error if the transaction cannot be paid online under the penalty scheme's
+// payability rules, given the rest of the company's transactions. It is validators.TransactionIsPayable, which cannot
+// be imported by the service.
+type PayabilityCheck func(companyNumber string, tx models.TransactionListItem, transactions []models.TransactionListItem, scheme *config.PenaltyScheme) error
+
+// GetPenaltySummary gets the company's transactions from e5 and summarises them, along with whether the account is
+// locked by a payment through the service that failed in E5 after the account was locked, and has not since been
+// completed in E5 or timed out. The amount payable online is the outstanding amount of the transactions that pass the
+// payability check, so that it matches what can be paid for.
+func (s *PayableResourceService) GetPenaltySummary(companyNumber string, scheme *config.PenaltyScheme, isPayable PayabilityCheck) (*PenaltySummary, ResponseType, error) {
+	transactions, responseType, err := GetPenalties(companyNumber, scheme)
+	if err != nil {
+		return nil, responseType, err
+	}
+
+	summary := SummarisePenalties(companyNumber, transactions)
+	summary.Kind = scheme.ProductType + "#summary"
+
+	for _, transaction := range transactions.Items {
+		if transaction.IsPaid || transaction.Outstanding <= 0 {
+			continue
+		}
+		if isPayable(companyNumber, transaction, transactions.Items, scheme) == nil {
+			summary.PayableOutstanding += transaction.Outstanding
+		}
+	}
+
+	count, err := s.DAO.CountE5CommandErrors(companyNumber, scheme, lockingE5Actions)
+	if err != nil {
+		err = fmt.Errorf("error checking for locked payments in db: [%v]", err)
+		log.Error(err, log.Data{"company_number": companyNumber})
+		return nil, Error, err
+	}
+	summary.IsLocked = count > 0
+
+	return summary, Success, nil
+}
+
+// SummarisePenalties totals the outstanding transactions in the list. The amount that can be paid online depends on the
+// penalty scheme's payability rules, so is left for GetPenaltySummary to total.
+func SummarisePenalties(companyNumber string, transactions *models.TransactionListResponse) *PenaltySummary {
+	summary := &PenaltySummary{
+		CompanyNumber: companyNumber,
+		OutstandingCounts: map[string]int{
+			Penalty.String(): 0,
+			Other.String():   0,
+		},
+	}
+
+	var earliestDueDate time.Time
+
+	for _, transaction := range transactions.Items {
+		if transaction.IsDCA {
+			summary.HasDCA = true
+		}
+
+		if transaction.IsPaid || transaction.Outstanding <= 0 {
+			continue
+		}
+
+		summary.TotalOutstanding += transaction.Outstanding
+		summary.OutstandingCounts[transaction.Type]++
+
+		// transactions with a missing or malformed due date are still totalled but cannot be the earliest
+		dueDate, err := ParseE5Date(transaction.DueDate)
+		if err != nil {
+			continue
+		}
+		if earliestDueDate.IsZero() || dueDate.Before(earliestDueDate) {
+			earliestDueDate = dueDate
+			summary.EarliestDueDate = transaction.DueDate
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitSummarisePenalties(t *testing.T) {
+	Convey("no transactions", t, func() {
+		summary := SummarisePenalties("10000024", &models.TransactionListResponse{})
+		So(summary.CompanyNumber, ShouldEqual, "10000024")
+		So(summary.TotalOutstanding, ShouldEqual, 0)
+		So(summary.PayableOutstanding, ShouldEqual, 0)
+		So(summary.OutstandingCounts["penalty"], ShouldEqual, 0)
+		So(summary.OutstandingCounts["other"], ShouldEqual, 0)
+		So(summary.EarliestDueDate, ShouldBeEmpty)
+		So(summary.HasDCA, ShouldBeFalse)
+	})
+
+	Convey("outstanding transactions are totalled", t, func() {
+		transactions := &models.TransactionListResponse{
+			Items: []models.TransactionListItem{
+				{ID: "00378420", Type: "penalty", DueDate: "2017-12-12", OriginalAmount: 150, Outstanding: 150},
+				{ID: "00378421", Type: "penalty", DueDate: "2017-06-01", OriginalAmount: 150, Outstanding: 50},
+				{ID: "00378422", Type: "penalty", DueDate: "2018-01-01", OriginalAmount: 375, Outstanding: 375, IsDCA: true},
+				{ID: "00378423", Type: "other", DueDate: "2019-01-01", OriginalAmount: 10, Outstanding: 10},
+				{ID: "00378424", Type: "penalty", DueDate: "2016-01-01", OriginalAmount: 150, IsPaid: true},
+			},
+		}
+
+		summary := SummarisePenalties("10000024", transactions)
+		So(summary.TotalOutstanding, ShouldEqual, 585)
+		So(summary.OutstandingCounts["penalty"], ShouldEqual, 3)
+		So(summary.OutstandingCounts["other"], ShouldEqual, 1)
+		So(summary.EarliestDueDate, ShouldEqual, "2017-06-01")
+		So(summary.HasDCA, ShouldBeTrue)
+		So(summary.IsLocked, ShouldBeFalse)
+	})
+}
+
+// payableTransactions is a payability check that passes every transaction
+func payableTransactions(string, models.TransactionListItem, []models.TransactionListItem, *config.PenaltyScheme) error {
+	return nil
+}
+
+func TestUnitGetPenaltySummary(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+	// the penalty types are read relative to the root of the repository
+	scheme := *mocks.LateFilingScheme()
+	scheme.AllowedTypesFile = "../" + scheme.AllowedTypesFile
+
+	Convey("the account is locked while a payment that failed in E5 has not been completed or timed out", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder(http.MethodGet, "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01",
+			httpmock.NewStringResponder(http.StatusOK, `{"page": {"size": 0, "totalElements": 0, "totalPages": 1, "number": 0}, "data": []}`))
+
+		for _, count := range []int64{0, 1} {
+			mockCtrl := gomock.NewController(t)
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().CountE5CommandErrors("10000024", &scheme, lockingE5Actions).Return(count, nil)
+			svc := &PayableResourceService{DAO: mockDaoService, Config: cfg}
+
+			summary, responseType, err := svc.GetPenaltySummary("10000024", &scheme, payableTransactions)
+			So(err, ShouldBeNil)
+			So(responseType, ShouldEqual, Success)
+			So(summary.IsLocked, ShouldEqual, count > 0)
+			mockCtrl.Finish()
+		}
+	})
+	Convey("only outstanding transactions that pass the payability check are payable online", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder(http.MethodGet, "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01",
+			httpmock.NewStringResponder(http.StatusOK, `{"page": {"size": 2, "totalElements": 2, "totalPages": 1, "number": 0}, "data": [
+				{"companyCode": "LP", "transactionReference": "00378420", "amount": 150, "outstandingAmount": 150, "isPaid": false, "transactionType": "1", "transactionSubType": "EU", "dueDate": "2017-12-12"},
+				{"companyCode": "LP", "transactionReference": "00378421", "amount": 150, "outstandingAmount": 50, "isPaid": false, "transactionType": "1", "transactionSubType": "EU", "dueDate": "2017-06-01"}
+			]}`))
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().CountE5CommandErrors("10000024", &scheme, lockingE5Actions).Return(int64(0), nil)
+		svc := &PayableResourceService{DAO: mockDaoService, Config: cfg}
+
+		var checked []string
+		isPayable := func(companyNumber string, tx models.TransactionListItem, transactions []models.TransactionListItem, _ *config.PenaltyScheme) error {
+			checked = append(checked, tx.ID)
+			So(transactions, ShouldHaveLength, 2)
+			if tx.IsPartPaid() {
+				return errors.New("part paid")
+			}
+			return nil
+		}
+
+		summary, _, err := svc.GetPenaltySummary("10000024", &scheme, isPayable)
+		So(err, ShouldBeNil)
+		So(checked, ShouldResemble, []string{"00378420", "00378421"})
+		So(summary.TotalOutstanding, ShouldEqual, 200)
+		So(summary.PayableOutstanding, ShouldEqual, 150)
+	})
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/constants"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// ErrNotPaid is returned when a receipt is requested for a payable resource that has not been paid
+var ErrNotPaid = errors.New("the payable resource has not been paid")
+
+// receiptDateLayout is the format of the payment date shown on HTML and PDF receipts
+const receiptDateLayout = "2 January 2006 at 15:04"
+
+// Receipt is the proof of payment for a paid payable resource
+type Receipt struct {
+	Kind             string           `json:"kind"`
+	Title            string           `json:"-"`
+	CompanyNumber    string           `json:"company_number"`
+	CompanyName      string           `json:"company_name"`
+	Reference        string           `json:"reference"`
+	PaymentReference string           `json:"payment_reference"`
+	PaidAt           *time.Time       `json:"paid_at"`
+	CardType         string           `json:"card_type,omitempty"`
+	TotalAmount      float64          `json:"total_amount"`
+	Penalties        []ReceiptPenalty `json:"penalties"`
+	Links            ReceiptLinks     `json:"links"`
+}
+
+// ReceiptPenalty is a penalty paid for, along with the amount paid off it
+type ReceiptPenalty struct {
+	TransactionID string  `json:"transaction_id"`
+	MadeUpDate    string  `json:"made_up_date"`
+	Amount        float64 `json:"amount"`
+}
+
+// ReceiptLinks links a receipt to itself and to the payable resource it is for
+type ReceiptLinks struct {
+	Self     string `json:"self"`
+	Resource string `json:"resource"`
+}
+
+// GetReceipt creates the receipt for a paid payable resource. ErrNotPaid is returned if it has not been paid.
+func (s *PayableResourceService) GetReceipt(resource *models.PayableResource, companyName string, scheme *config.PenaltyScheme) (*Receipt, error) {
+	if resource.Payment.Status != constants.Paid.String() {
+		return nil, ErrNotPaid
+	}
+
+	// resources paid for before the card was stored will not have one
+	card, err := s.DAO.GetPaymentCard(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting payment card from db: [%v]", err)
+		log.Error(err, log.Data{"lfp_reference": resource.Reference, "company_number": resource.CompanyNumber})
+		return nil, err
+	}
+
+	receipt := &Receipt{
+		Kind:             scheme.ProductType + "#receipt",
+		Title:            scheme.Description + " payment receipt",
+		CompanyNumber:    resource.CompanyNumber,
+		CompanyName:      companyName,
+		Reference:        resource.Reference,
+		PaymentReference: resource.Payment.Reference,
+		PaidAt:           resource.Payment.PaidAt,
+		Links: ReceiptLinks{
+			Self:     resource.Links.Self + "/receipt",
+			Resource: resource.Links.Self,
+		},
+	}
+	if card != nil {
+		receipt.CardType = card.CardType
+	}
+
+	for _, tx := range resource.Transactions {
+		receipt.Penalties = append(receipt.Penalties, ReceiptPenalty{
+			TransactionID: tx.TransactionID,
+			MadeUpDate:    tx.MadeUpDate,
+			Amount:        tx.Amount,
+		})
+		receipt.TotalAmount += tx.Amount
+	}
+
+	return receipt, nil
+}
+
+// receiptTemplate is the HTML rendering of a receipt. It is a complete page so that it can be saved or printed.
+var receiptTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"paidAt":     formatPaidAt,
+	"madeUpDate": formatReceiptDate,
+	"amount":     formatAmount,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.CompanyName}}<br>Company number {{.CompanyNumber}}</p>
+<table>
+<tr><th>Payment reference</th><td>{{.PaymentReference}}</td></tr>
+<tr><th>Paid on</th><td>{{paidAt .}}</td></tr>
+{{- if .CardType}}
+<tr><th>Card type</th><td>{{.CardType}}</td></tr>
+{{- end}}
+</table>
+<table>
+<tr><th>Penalty reference</th><th>Accounts made up to</th><th>Amount paid</th></tr>
+{{- range .Penalties}}
+<tr><td>{{.TransactionID}}</td><td>{{madeUpDate .MadeUpDate}}</td><td>£{{amount .Amount}}</td></tr>
+{{- end}}
+<tr><th colspan="2">Total paid</th><td>£{{amount .TotalAmount}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the receipt as an HTML page
+func (r *Receipt) WriteHTML(w io.Writer) error {
+	return receiptTemplate.Execute(w, r)
+}
+
+// WritePDF writes the receipt as a PDF
+func (r *Receipt) WritePDF(w io.Writer) error {
+	doc := utils.NewPDFDocument()
+	columnWidths := []float64{160, 160, 100}
+
+	doc.AddText(r.Title, 18, true)
+	doc.AddSpace(10)
+	doc.AddText(r.CompanyName, 12, true)
+	doc.AddText("Company number "+r.CompanyNumber, 10, false)
+	doc.AddSpace(10)
+	doc.AddRow([]string{"Payment reference", r.PaymentReference}, columnWidths, 10, false)
+	doc.AddRow([]string{"Paid on", formatPaidAt(r)}, columnWidths, 10, false)
+	if r.CardType != "" {
+		doc.AddRow([]string{"Card type", r.CardType}, columnWidths, 10, false)
+	}
+	doc.AddSpace(10)
+
+	doc.AddRow([]string{"Penalty reference", "Accounts made up to", "Amount paid"}, columnWidths, 10, true)
+	for _, penalty := range r.Penalties {
+		doc.AddRow([]string{penalty.TransactionID, formatReceiptDate(penalty.MadeUpDate), "£" + formatAmount(penalty.Amount)}, columnWidths, 10, false)
+	}
+	doc.AddRow([]string{"Total paid", "", "£" + formatAmount(r.TotalAmount)}, columnWidths, 10, true)
+
+	return doc.Write(w)
+}
+
+// formatPaidAt formats when the receipt was paid in UK time
+func formatPaidAt(r *Receipt) string {
+	if r.PaidAt == nil {
+		return ""
+	}
+	return r.PaidAt.In(ukTime).Format(receiptDateLayout)
+}
+
+// formatReceiptDate formats an E5 date for a receipt, leaving it as it is if it cannot be parsed
+func formatReceiptDate(value string) string {
+	date, err := ParseE5Date(value)
+	if err != nil {
+		return value
+	}
+	return date.Format("2 January 2006")
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func paidPayableResource() *models.PayableResource {
+	paidAt := time.Date(2019, 7, 1, 14, 30, 0, 0, time.UTC)
+	return &models.PayableResource{
+		CompanyNumber: "10000024",
+		Reference:     "LP123456",
+		Transactions: []models.TransactionItem{
+			{TransactionID: "00378420", MadeUpDate: "2019-03-31", Amount: 150},
+			{TransactionID: "00378421", MadeUpDate: "2018-03-31", Amount: 50},
+		},
+		Links:   models.PayableResourceLinks{Self: "/company/10000024/penalties/late-filing/payable/LP123456"},
+		Payment: models.Payment{Amount: "200", Reference: "payment-ref", Status: "paid", PaidAt: &paidAt},
+	}
+}
+
+func TestUnitGetReceipt(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+
+	Convey("receipt for a paid resource", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(&dao.PaymentCardDao{PaymentID: "P1", CardType: "Visa"}, nil)
+		svc := PayableResourceService{DAO: mockDaoService}
+
+		receipt, err := svc.GetReceipt(paidPayableResource(), "TEST LTD", scheme)
+
+		So(err, ShouldBeNil)
+		So(receipt.Kind, ShouldEqual, "late-filing-penalty#receipt")
+		So(receipt.CompanyName, ShouldEqual, "TEST LTD")
+		So(receipt.PaymentReference, ShouldEqual, "payment-ref")
+		So(receipt.CardType, ShouldEqual, "Visa")
+		So(receipt.TotalAmount, ShouldEqual, 200)
+		So(receipt.Penalties, ShouldHaveLength, 2)
+		So(receipt.Penalties[1], ShouldResemble, ReceiptPenalty{TransactionID: "00378421", MadeUpDate: "2018-03-31", Amount: 50})
+		So(receipt.Links.Self, ShouldEqual, "/company/10000024/penalties/late-filing/payable/LP123456/receipt")
+		So(receipt.Links.Resource, ShouldEqual, "/company/10000024/penalties/late-filing/payable/LP123456")
+	})
+
+	Convey("receipt for a resource paid before the card was stored", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPaymentCard(gomock.Any(), gomock.Any()).Return(nil, nil)
+		svc := PayableResourceService{DAO: mockDaoService}
+
+		receipt, err := svc.GetReceipt(paidPayableResource(), "TEST LTD", scheme)
+
+		So(err, ShouldBeNil)
+		So(receipt.CardType, ShouldBeEmpty)
+	})
+
+	Convey("error when the resource has not been paid", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		svc := PayableResourceService{DAO: mocks.NewMockService(mockCtrl)}
+
+		resource := paidPayableResource()
+		resource.Payment.Status = "pending"
+		receipt, err := svc.GetReceipt(resource, "TEST LTD", scheme)
+
+		So(receipt, ShouldBeNil)
+		So(err, ShouldEqual, ErrNotPaid)
+	})
+
+	Convey("error getting the card from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPaymentCard(gomock.Any(), gomock.Any()).Return(nil, errors.New("db error"))
+		svc := PayableResourceService{DAO: mockDaoService}
+
+		receipt, err := svc.GetReceipt(paidPayableResource(), "TEST LTD", scheme)
+
+		So(receipt, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestUnitRenderReceipt(t *testing.T) {
+	paidAt := time.Date(2019, 7, 1, 14, 30, 0, 0, time.UTC)
+	receipt := &Receipt{
+		Title:            "Late Filing Penalty payment receipt",
+		CompanyNumber:    "10000024",
+		CompanyName:      "SMITH & SONS LTD",
+		PaymentReference: "payment-ref",
+		PaidAt:           &paidAt,
+		CardType:         "Visa",
+		TotalAmount:      150,
+		Penalties:        []ReceiptPenalty{{TransactionID: "00378420", MadeUpDate: "2019-03-31", Amount: 150}},
+	}
+
+	Convey("a receipt is rendered as HTML", t, func() {
+		var out bytes.Buffer
+		So(receipt.WriteHTML(&out), ShouldBeNil)
+		So(out.String(), ShouldContainSubstring, "<h1>Late Filing Penalty payment receipt</h1>")
+		So(out.String(), ShouldContainSubstring, "SMITH &amp; SONS LTD")
+		So(out.String(), ShouldContainSubstring, "<td>1 July 2019 at 15:30</td>")
+		So(out.String(), ShouldContainSubstring, "<tr><td>00378420</td><td>31 March 2019</td><td>£150.00</td></tr>")
+		So(out.String(), ShouldContainSubstring, "<td>Visa</td>")
+	})
+
+	Convey("a receipt is rendered as PDF", t, func() {
+		var out bytes.Buffer
+		So(receipt.WritePDF(&out), ShouldBeNil)
+		So(out.String(), ShouldStartWith, "%PDF-1.4")
+		So(out.String(), ShouldContainSubstring, "(SMITH & SONS LTD) Tj")
+		So(out.String(), ShouldContainSubstring, "(payment-ref) Tj")
+	})
+}
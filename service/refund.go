@@ -0,0 +1,496 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/dao"
+)
+
+// PaymentStatusRefunded is the payment status of a payable resource whose payment the payment platform has accepted a
+// refund for
+const PaymentStatusRefunded = "refunded"
+
+const (
+	// RefundStatusRequested is the status of a refund that has been recorded but not yet accepted by the payment
+	// platform
+	RefundStatusRequested = "requested"
+	// RefundStatusSubmitted is the status of a refund that the payment platform has accepted
+	RefundStatusSubmitted = "submitted"
+	// RefundStatusFailed is the status of a refund that the payment platform did not accept. Another refund can be
+	// requested.
+	RefundStatusFailed = "failed"
+	// RefundStatusUnknown is the status of a refund that the payment platform may or may not have made, e.g. because it
+	// could not be reached or its response could not be read. Another refund cannot be requested until it has been
+	// reconciled with the payment platform.
+	RefundStatusUnknown = "unknown"
+
+	// E5ReversalPending is the E5 reversal status of a refund whose payment is still to be reversed in E5. The E5
+	// client of this service can only create, authorise, confirm, time out and reject payments, none of which undo a
+	// confirmed payment, so the reversal is a task for finance, who record it once it is done.
+	E5ReversalPending = "pending"
+	// E5ReversalCompleted is the E5 reversal status of a refund whose payment finance have reversed in E5
+	E5ReversalCompleted = "completed"
+)
+
+// refundReconcileAfter is how long a refund is left as requested before it is assumed that the request to the payment
+// platform was interrupted, and the refund is reconciled. It is longer than the payment platform is given to respond.
+const refundReconcileAfter = 2 * refundTimeout
+
+var (
+	// ErrAlreadyRefunded is returned when refunding a payable resource that already has a refund that has not failed
+	ErrAlreadyRefunded = errors.New("the payable resource has already been refunded")
+	// ErrNotRefundable is returned when refunding a payable resource that was not paid for through the payment
+	// platform, e.g. one settled offline
+	ErrNotRefundable = errors.New("the payable resource has no payment on the payment platform to refund")
+	// ErrRefundRejected is returned when the payment platform does not accept the refund
+	ErrRefundRejected = errors.New("the payment platform did not accept the refund")
+	// ErrRefundUnknown is returned when it is not known whether the payment platform made the refund
+	ErrRefundUnknown = errors.New("the outcome of the refund is not known and it will be reconciled with the payment platform")
+	// ErrRefundNotFound is returned when getting the refund of a payable resource that has not been refunded
+	ErrRefundNotFound = errors.New("the payable resource has not been refunded")
+	// ErrE5ReversalNotPending is returned when recording the E5 reversal of a refund that has not been accepted by the
+	// payment platform, or whose payment has already been reversed
+	ErrE5ReversalNotPending = errors.New("the refund has no E5 reversal waiting to be made")
+)
+
+// RefundRequest is a request from a member of staff to refund the payment for a payable resource
+type RefundRequest struct {
+	Reason string
+	UserID string
+	Email  string
+}
+
+// Refund is the refund of the payment for a payable resource
+type Refund struct {
+	Status           string     `json:"status"`
+	Amount           string     `json:"amount"`
+	Reason           string     `json:"reason"`
+	RefundID         string     `json:"refund_id,omitempty"`
+	FailureReason    string     `json:"failure_reason,omitempty"`
+	E5ReversalStatus string     `json:"e5_reversal_status,omitempty"`
+	E5ReversedBy     string     `json:"e5_reversed_by,omitempty"`
+	E5ReversedAt     *time.Time `json:"e5_reversed_at,omitempty"`
+	RequestedBy      string     `json:"requested_by"`
+	RequestedAt      time.Time  `json:"requested_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// RefundPayableResource refunds the full payment for a paid payable resource. The refund is recorded as requested
+// before the payment platform is asked to make it, so that a second refund cannot be requested at the same time, and
+// is then updated with the outcome. A refund whose outcome was not known is reconciled first, and if the payment
+// platform has no record of it another refund can be made. Once the payment platform has accepted the refund the
+// resource is marked as refunded and the payment is waiting to be reversed in E5. A payment taken for a resource that
+// had been cancelled can also be refunded, which leaves the resource cancelled.
+func (s *PayableResourceService) RefundPayableResource(resource *models.PayableResource, request RefundRequest) (*Refund, error) {
+	logData := log.Data{
+		"lfp_reference":  resource.Reference,
+		"company_number": resource.CompanyNumber,
+		"user_id":        request.UserID,
+	}
+
+	model, err := s.DAO.GetPayableResource(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting payable resource from db: [%v]", err)
+		log.Error(err, logData)
+		return nil, err
+	}
+	if model == nil {
+		return nil, ErrLFPNotFound
+	}
+	if model.Data.Payment.Status == PaymentStatusRefunded {
+		return nil, ErrAlreadyRefunded
+	}
+
+	paymentID, paidAmount, cancelled, err := s.getRefundablePayment(model, logData)
+	if err != nil {
+		return nil, err
+	}
+	logData["payment_id"] = paymentID
+
+	amount, err := amountInPence(paidAmount)
+	if err != nil {
+		log.Error(err, logData)
+		return nil, err
+	}
+
+	now := time.Now()
+	refund := &dao.RefundDao{
+		Status:           RefundStatusRequested,
+		Amount:           paidAmount,
+		Reason:           request.Reason,
+		PaymentID:        paymentID,
+		RequestedByID:    request.UserID,
+		RequestedByEmail: request.Email,
+		RequestedAt:      now,
+		UpdatedAt:        now,
+	}
+
+	err = s.DAO.CreateRefund(resource.CompanyNumber, resource.Reference, refund)
+	if err == dao.ErrRefundExists {
+		// the existing refund only stands in the way if the payment platform may have made it
+		var existing *dao.RefundDao
+		existing, err = s.getReconciledRefund(resource.CompanyNumber, resource.Reference)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil || existing.Status != RefundStatusFailed {
+			return nil, ErrAlreadyRefunded
+		}
+		err = s.DAO.CreateRefund(resource.CompanyNumber, resource.Reference, refund)
+	}
+	if err == dao.ErrRefundExists {
+		return nil, ErrAlreadyRefunded
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	paymentRefund, refundErr := RefundPayment(context.Background(), s.apiKey(), paymentID, amount)
+	refund.UpdatedAt = time.Now()
+	switch {
+	case refundErr == nil:
+		refund.Status = RefundStatusSubmitted
+		refund.RefundID = paymentRefund.RefundID
+		// a payment taken for a cancelled resource was never posted to E5, so there is nothing to reverse
+		if !cancelled {
+			refund.E5ReversalStatus = E5ReversalPending
+		}
+	case IsRefundRejected(refundErr):
+		log.Error(fmt.Errorf("payment platform rejected refund: [%v]", refundErr), logData)
+		refund.Status = RefundStatusFailed
+		refund.FailureReason = refundErr.Error()
+	default:
+		log.Error(fmt.Errorf("outcome of refund is unknown: [%v]", refundErr), logData)
+		refund.Status = RefundStatusUnknown
+		refund.FailureReason = refundErr.Error()
+	}
+
+	err = s.DAO.UpdateRefund(resource.CompanyNumber, resource.Reference, refund)
+	if err != nil {
+		return nil, err
+	}
+
+	switch refund.Status {
+	case RefundStatusFailed:
+		return nil, ErrRefundRejected
+	case RefundStatusUnknown:
+		return nil, ErrRefundUnknown
+	}
+
+	// a cancelled resource keeps its status, as it was never paid for
+	if !cancelled {
+		s.markRefunded(model, logData)
+	}
+
+	logData["refund_id"] = refund.RefundID
+	log.Info("payable resource refunded", logData)
+
+	return newRefund(refund), nil
+}
+
+// getRefundablePayment gets the payment on the payment platform to refund for a payable resource, and its amount. This
+// is the payment that the resource was paid with, or for a cancelled resource the payment that was taken for it after
+// it had been cancelled, if there was one.
+func (s *PayableResourceService) getRefundablePayment(model *models.PayableResourceDao, logData log.Data) (string, string, bool, error) {
+	if model.Data.Payment.Status == PaymentStatusCancelled {
+		payment, err := s.DAO.GetCancelledPayment(model.CompanyNumber, model.Reference)
+		if err != nil {
+			err = fmt.Errorf("error getting cancelled payment from db: [%v]", err)
+			log.Error(err, logData)
+			return "", "", false, err
+		}
+		if payment == nil {
+			return "", "", false, ErrNotPaid
+		}
+		return payment.PaymentID, payment.Amount, true, nil
+	}
+
+	if !model.IsPaid() {
+		return "", "", false, ErrNotPaid
+	}
+
+	card, err := s.DAO.GetPaymentCard(model.CompanyNumber, model.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting payment card from db: [%v]", err)
+		log.Error(err, logData)
+		return "", "", false, err
+	}
+	if card == nil {
+		return "", "", false, ErrNotRefundable
+	}
+
+	return card.PaymentID, model.Data.Payment.Amount, false, nil
+}
+
+// GetRefund gets the refund of a payable resource, reconciling it with the payment platform first if its outcome is
+// not known. ErrRefundNotFound is returned if it has not been refunded.
+func (s *PayableResourceService) GetRefund(resource *models.PayableResource) (*Refund, error) {
+	refund, err := s.getReconciledRefund(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if refund == nil {
+		return nil, ErrRefundNotFound
+	}
+
+	return newRefund(refund), nil
+}
+
+// RecordE5Reversal records that finance have reversed the payment of a refunded payable resource in E5, which is the
+// last step of the refund
+func (s *PayableResourceService) RecordE5Reversal(resource *models.PayableResource, userID, email string) (*Refund, error) {
+	logData := log.Data{
+		"lfp_reference":  resource.Reference,
+		"company_number": resource.CompanyNumber,
+		"user_id":        userID,
+	}
+
+	refund, err := s.DAO.GetRefund(resource.CompanyNumber, resource.Reference)
+	if err != nil {
+		err = fmt.Errorf("error getting refund from db: [%v]", err)
+		log.Error(err, logData)
+		return nil, err
+	}
+	if refund == nil {
+		return nil, ErrRefundNotFound
+	}
+	if refund.Status != RefundStatusSubmitted || refund.E5ReversalStatus != E5ReversalPending {
+		return nil, ErrE5ReversalNotPending
+	}
+
+	now := time.Now()
+	refund.E5ReversalStatus = E5ReversalCompleted
+	refund.E5ReversedByID = userID
+	refund.E5ReversedByEmail = email
+	refund.E5ReversedAt = &now
+	refund.UpdatedAt = now
+
+	err = s.DAO.UpdateRefund(resource.CompanyNumber, resource.Reference, refund)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("e5 reversal of refund recorded", logData)
+
+	return newRefund(refund), nil
+}
+
+// PendingE5Reversal is a refund whose payment finance still have to reverse in E5
+type PendingE5Reversal struct {
+	CompanyNumber string  `json:"company_number"`
+	Reference     string  `json:"reference"`
+	Refund        *Refund `json:"refund"`
+}
+
+// PendingE5ReversalList is the list of refunds of a penalty scheme whose payments finance still have to reverse in E5
+type PendingE5ReversalList struct {
+	Items []PendingE5Reversal `json:"items"`
+}
+
+// ListPendingE5Reversals gets the refunds of the penalty scheme's payable resources whose payments are waiting to be
+// reversed in E5, oldest first, so that finance can work through them
+func (s *PayableResourceService) ListPendingE5Reversals(scheme *config.PenaltyScheme) (*PendingE5ReversalList, error) {
+	reversals, err := s.DAO.ListPendingE5Reversals(scheme)
+	if err != nil {
+		err = fmt.Errorf("error listing pending e5 reversals from db: [%v]", err)
+		log.Error(err, log.Data{"scheme": scheme.Name})
+		return nil, err
+	}
+
+	list := &PendingE5ReversalList{Items: make([]PendingE5Reversal, 0, len(reversals))}
+	for i := range reversals {
+		list.Items = append(list.Items, PendingE5Reversal{
+			CompanyNumber: reversals[i].CompanyNumber,
+			Reference:     reversals[i].Reference,
+			Refund:        newRefund(&reversals[i].Refund),
+		})
+	}
+
+	return list, nil
+}
+
+// CancelledPayment is a payment that the payment platform took for a payable resource after it had been cancelled, along
+// with its refund if one has been requested
+type CancelledPayment struct {
+	CompanyNumber    string    `json:"company_number"`
+	Reference        string    `json:"reference"`
+	PaymentID        string    `json:"payment_id"`
+	PaymentReference string    `json:"payment_reference"`
+	Amount           string    `json:"amount"`
+	PaidAt           time.Time `json:"paid_at"`
+	RecordedAt       time.Time `json:"recorded_at"`
+	Refund           *Refund   `json:"refund,omitempty"`
+}
+
+// CancelledPaymentList is the list of payments taken for a penalty scheme's cancelled payable resources that have not
+// been refunded
+type CancelledPaymentList struct {
+	Items []CancelledPayment `json:"items"`
+}
+
+// ListUnrefundedCancelledPayments gets the payments taken for the penalty scheme's cancelled payable resources that
+// the payment platform has not yet accepted a refund for, oldest first, so that finance can refund them
+func (s *PayableResourceService) ListUnrefundedCancelledPayments(scheme *config.PenaltyScheme) (*CancelledPaymentList, error) {
+	payments, err := s.DAO.ListUnrefundedCancelledPayments(scheme)
+	if err != nil {
+		err = fmt.Errorf("error listing cancelled payments from db: [%v]", err)
+		log.Error(err, log.Data{"scheme": scheme.Name})
+		return nil, err
+	}
+
+	list := &CancelledPaymentList{Items: make([]CancelledPayment, 0, len(payments))}
+	for i := range payments {
+		payment := CancelledPayment{
+			CompanyNumber:    payments[i].CompanyNumber,
+			Reference:        payments[i].Reference,
+			PaymentID:        payments[i].CancelledPayment.PaymentID,
+			PaymentReference: payments[i].CancelledPayment.Reference,
+			Amount:           payments[i].CancelledPayment.Amount,
+			PaidAt:           payments[i].CancelledPayment.PaidAt,
+			RecordedAt:       payments[i].CancelledPayment.RecordedAt,
+		}
+		if payments[i].Refund != nil {
+			payment.Refund = newRefund(payments[i].Refund)
+		}
+		list.Items = append(list.Items, payment)
+	}
+
+	return list, nil
+}
+
+// getReconciledRefund gets the stored refund of a payable resource, or nil if it has not been refunded. A refund whose
+// outcome is unknown, or that has been left as requested for longer than the payment platform is given to respond, is
+// first reconciled with the refunds the payment platform holds for the payment. If the payment platform cannot be
+// reached the refund is returned as it is.
+func (s *PayableResourceService) getReconciledRefund(companyNumber, reference string) (*dao.RefundDao, error) {
+	logData := log.Data{"lfp_reference": reference, "company_number": companyNumber}
+
+	refund, err := s.DAO.GetRefund(companyNumber, reference)
+	if err != nil {
+		err = fmt.Errorf("error getting refund from db: [%v]", err)
+		log.Error(err, logData)
+		return nil, err
+	}
+	if refund == nil || !needsReconciling(refund) {
+		return refund, nil
+	}
+	logData["payment_id"] = refund.PaymentID
+
+	paymentRefunds, err := GetPaymentRefunds(context.Background(), s.apiKey(), refund.PaymentID)
+	if err != nil {
+		log.Error(fmt.Errorf("error reconciling refund with payment platform: [%v]", err), logData)
+		return refund, nil
+	}
+
+	refund.UpdatedAt = time.Now()
+	refund.Status = RefundStatusFailed
+	refund.FailureReason = "the payment platform has no record of the refund"
+	for _, paymentRefund := range paymentRefunds {
+		// only one refund is made through the service for a payment, so any that was not turned down is this one
+		if paymentRefund.Status != paymentRefundFailed {
+			refund.Status = RefundStatusSubmitted
+			refund.RefundID = paymentRefund.RefundID
+			refund.FailureReason = ""
+			if !s.isCancelledPayment(companyNumber, reference, refund.PaymentID, logData) {
+				refund.E5ReversalStatus = E5ReversalPending
+			}
+			break
+		}
+	}
+
+	err = s.DAO.UpdateRefund(companyNumber, reference, refund)
+	if err != nil {
+		return nil, err
+	}
+
+	logData["refund_status"] = refund.Status
+	log.Info("refund reconciled with payment platform", logData)
+
+	if refund.Status == RefundStatusSubmitted {
+		model, err := s.DAO.GetPayableResource(companyNumber, reference)
+		if err != nil {
+			log.Error(fmt.Errorf("error getting payable resource from db: [%v]", err), logData)
+		} else if model != nil && model.IsPaid() {
+			s.markRefunded(model, logData)
+		}
+	}
+
+	return refund, nil
+}
+
+// isCancelledPayment reports whether the payment was taken for the payable resource after it had been cancelled, in
+// which case it was never posted to E5 and there is nothing to reverse. If that cannot be read the payment is assumed
+// to have been posted, so that finance check it.
+func (s *PayableResourceService) isCancelledPayment(companyNumber, reference, paymentID string, logData log.Data) bool {
+	payment, err := s.DAO.GetCancelledPayment(companyNumber, reference)
+	if err != nil {
+		log.Error(fmt.Errorf("error getting cancelled payment from db: [%v]", err), logData)
+		return false
+	}
+	return payment != nil && payment.PaymentID == paymentID
+}
+
+// needsReconciling reports whether the outcome of a refund has to be found from the payment platform
+func needsReconciling(refund *dao.RefundDao) bool {
+	switch refund.Status {
+	case RefundStatusUnknown:
+		return true
+	case RefundStatusRequested:
+		return time.Since(refund.UpdatedAt) > refundReconcileAfter
+	}
+	return false
+}
+
+// markRefunded sets the payment status of a payable resource to refunded once the payment platform has accepted the
+// refund. The refund has already been made so a failure is only logged.
+func (s *PayableResourceService) markRefunded(model *models.PayableResourceDao, logData log.Data) {
+	model.Data.Payment.Status = PaymentStatusRefunded
+
+	err := s.DAO.UpdatePaymentDetails(model)
+	if err != nil {
+		log.Error(fmt.Errorf("error marking payable resource as refunded: [%v]", err), logData)
+	}
+}
+
+// apiKey is the API key the service calls the payment platform with
+func (s *PayableResourceService) apiKey() string {
+	if s.Config == nil {
+		return ""
+	}
+	return s.Config.CHSAPIKey
+}
+
+// newRefund converts the stored refund into the refund returned to clients
+func newRefund(refund *dao.RefundDao) *Refund {
+	return &Refund{
+		Status:           refund.Status,
+		Amount:           refund.Amount,
+		Reason:           refund.Reason,
+		RefundID:         refund.RefundID,
+		FailureReason:    refund.FailureReason,
+		E5ReversalStatus: refund.E5ReversalStatus,
+		E5ReversedBy:     refund.E5ReversedByEmail,
+		E5ReversedAt:     refund.E5ReversedAt,
+		RequestedBy:      refund.RequestedByEmail,
+		RequestedAt:      refund.RequestedAt,
+		UpdatedAt:        refund.UpdatedAt,
+	}
+}
+
+// amountInPence converts an amount paid in pounds, as stored against the payment, into pence as used by the payment
+// platform
+func amountInPence(amount string) (int, error) {
+	pounds, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing payment amount [%s]: [%v]", amount, err)
+	}
+	return int(math.Round(pounds * 100)), nil
+}
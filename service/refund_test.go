@@ -0,0 +1,506 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/dao"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitRefundPayableResource(t *testing.T) {
+	refundURL := "https://api-payments.companieshouse.gov.uk/private/payments/P123/refunds"
+	resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+	request := RefundRequest{Reason: "cancelled on appeal", UserID: "user-id", Email: "finance@companieshouse.gov.uk"}
+
+	paidResource := func() *models.PayableResourceDao {
+		return &models.PayableResourceDao{
+			CompanyNumber: "10000024",
+			Reference:     "LP123456",
+			Data:          models.PayableResourceDataDao{Payment: models.PaymentDao{Status: "paid", Amount: "150.50"}},
+		}
+	}
+	card := &dao.PaymentCardDao{PaymentID: "P123", CardType: "Visa"}
+
+	Convey("error getting the payable resource from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(nil, errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.RefundPayableResource(resource, request)
+		So(refund, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("an unpaid resource cannot be refunded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		pending := paidResource()
+		pending.Data.Payment.Status = "pending"
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(pending, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.RefundPayableResource(resource, request)
+		So(err, ShouldEqual, ErrNotPaid)
+	})
+
+	Convey("a resource without a payment on the payment platform cannot be refunded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil)
+		mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(nil, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.RefundPayableResource(resource, request)
+		So(err, ShouldEqual, ErrNotRefundable)
+	})
+
+	Convey("a resource cannot be refunded twice", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil)
+		mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(card, nil)
+		mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(dao.ErrRefundExists)
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: RefundStatusSubmitted}, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.RefundPayableResource(resource, request)
+		So(err, ShouldEqual, ErrAlreadyRefunded)
+	})
+
+	Convey("a refunded resource cannot be refunded again", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		refunded := paidResource()
+		refunded.Data.Payment.Status = PaymentStatusRefunded
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(refunded, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.RefundPayableResource(resource, request)
+		So(err, ShouldEqual, ErrAlreadyRefunded)
+	})
+
+	Convey("a refund whose outcome is unknown", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		unknown := func() *dao.RefundDao {
+			return &dao.RefundDao{Status: RefundStatusUnknown, PaymentID: "P123", UpdatedAt: time.Now()}
+		}
+
+		Convey("blocks another refund when the payment platform made it", func() {
+			defer httpmock.Reset()
+			responder, _ := httpmock.NewJsonResponder(http.StatusOK, map[string]interface{}{"items": []PaymentRefund{{RefundID: "R123", Status: "submitted"}}})
+			httpmock.RegisterResponder(http.MethodGet, refundURL, responder)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil).Times(2)
+			mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(card, nil)
+			mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(dao.ErrRefundExists)
+			mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(unknown(), nil)
+			mockDaoService.EXPECT().GetCancelledPayment("10000024", "LP123456").Return(nil, nil)
+
+			var reconciled *dao.RefundDao
+			mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).DoAndReturn(func(_, _ string, r *dao.RefundDao) error {
+				reconciled = r
+				return nil
+			})
+			var marked *models.PayableResourceDao
+			mockDaoService.EXPECT().UpdatePaymentDetails(gomock.Any()).DoAndReturn(func(d *models.PayableResourceDao) error {
+				marked = d
+				return nil
+			})
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			_, err := svc.RefundPayableResource(resource, request)
+			So(err, ShouldEqual, ErrAlreadyRefunded)
+			So(reconciled.Status, ShouldEqual, RefundStatusSubmitted)
+			So(reconciled.RefundID, ShouldEqual, "R123")
+			So(reconciled.E5ReversalStatus, ShouldEqual, E5ReversalPending)
+			So(marked.Data.Payment.Status, ShouldEqual, PaymentStatusRefunded)
+		})
+
+		Convey("is replaced when the payment platform has no record of it", func() {
+			defer httpmock.Reset()
+			responder, _ := httpmock.NewJsonResponder(http.StatusOK, map[string]interface{}{"items": []PaymentRefund{}})
+			httpmock.RegisterResponder(http.MethodGet, refundURL, responder)
+			refundResponder, _ := httpmock.NewJsonResponder(http.StatusCreated, PaymentRefund{RefundID: "R456", Status: "submitted"})
+			httpmock.RegisterResponder(http.MethodPost, refundURL, refundResponder)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil)
+			mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(card, nil)
+			gomock.InOrder(
+				mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(dao.ErrRefundExists),
+				mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(unknown(), nil),
+				mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).Return(nil),
+				mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(nil),
+				mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).Return(nil),
+				mockDaoService.EXPECT().UpdatePaymentDetails(gomock.Any()).Return(nil),
+			)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			refund, err := svc.RefundPayableResource(resource, request)
+			So(err, ShouldBeNil)
+			So(refund.RefundID, ShouldEqual, "R456")
+		})
+
+		Convey("blocks another refund when the payment platform cannot be reached", func() {
+			defer httpmock.Reset()
+			httpmock.RegisterResponder(http.MethodGet, refundURL, httpmock.NewStringResponder(http.StatusServiceUnavailable, ""))
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil)
+			mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(card, nil)
+			mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(dao.ErrRefundExists)
+			mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(unknown(), nil)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			_, err := svc.RefundPayableResource(resource, request)
+			So(err, ShouldEqual, ErrAlreadyRefunded)
+		})
+	})
+
+	Convey("refund rejected by the payment platform is recorded as failed", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder(http.MethodPost, refundURL, httpmock.NewStringResponder(http.StatusBadRequest, ""))
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil)
+		mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(card, nil)
+		mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+
+		var updated *dao.RefundDao
+		mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).DoAndReturn(func(_, _ string, r *dao.RefundDao) error {
+			updated = r
+			return nil
+		})
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.RefundPayableResource(resource, request)
+		So(refund, ShouldBeNil)
+		So(err, ShouldEqual, ErrRefundRejected)
+		So(updated.Status, ShouldEqual, RefundStatusFailed)
+		So(updated.FailureReason, ShouldNotBeEmpty)
+		So(updated.E5ReversalStatus, ShouldBeEmpty)
+	})
+
+	Convey("refund whose outcome is unknown is recorded as unknown", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder(http.MethodPost, refundURL, httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil)
+		mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(card, nil)
+		mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+
+		var updated *dao.RefundDao
+		mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).DoAndReturn(func(_, _ string, r *dao.RefundDao) error {
+			updated = r
+			return nil
+		})
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.RefundPayableResource(resource, request)
+		So(refund, ShouldBeNil)
+		So(err, ShouldEqual, ErrRefundUnknown)
+		So(updated.Status, ShouldEqual, RefundStatusUnknown)
+	})
+
+	Convey("refund accepted by the payment platform", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		responder, _ := httpmock.NewJsonResponder(http.StatusCreated, PaymentRefund{RefundID: "R123", Status: "submitted"})
+		httpmock.RegisterResponder(http.MethodPost, refundURL, responder)
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(paidResource(), nil)
+		mockDaoService.EXPECT().GetPaymentCard("10000024", "LP123456").Return(card, nil)
+
+		var created dao.RefundDao
+		mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).DoAndReturn(func(_, _ string, r *dao.RefundDao) error {
+			created = *r
+			return nil
+		})
+		mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+
+		var marked *models.PayableResourceDao
+		mockDaoService.EXPECT().UpdatePaymentDetails(gomock.Any()).DoAndReturn(func(d *models.PayableResourceDao) error {
+			marked = d
+			return nil
+		})
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.RefundPayableResource(resource, request)
+		So(err, ShouldBeNil)
+		So(marked.Data.Payment.Status, ShouldEqual, PaymentStatusRefunded)
+
+		So(created.Status, ShouldEqual, RefundStatusRequested)
+		So(created.PaymentID, ShouldEqual, "P123")
+		So(created.RequestedByID, ShouldEqual, "user-id")
+
+		So(refund.Status, ShouldEqual, RefundStatusSubmitted)
+		So(refund.RefundID, ShouldEqual, "R123")
+		So(refund.Amount, ShouldEqual, "150.50")
+		So(refund.Reason, ShouldEqual, "cancelled on appeal")
+		So(refund.E5ReversalStatus, ShouldEqual, E5ReversalPending)
+		So(refund.RequestedBy, ShouldEqual, "finance@companieshouse.gov.uk")
+	})
+
+	Convey("a payment taken for a cancelled resource", t, func() {
+		cancelledResource := func() *models.PayableResourceDao {
+			cancelled := paidResource()
+			cancelled.Data.Payment.Status = PaymentStatusCancelled
+			cancelled.Data.Payment.Amount = ""
+			return cancelled
+		}
+
+		Convey("cannot be refunded when no payment was taken", func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(cancelledResource(), nil)
+			mockDaoService.EXPECT().GetCancelledPayment("10000024", "LP123456").Return(nil, nil)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			_, err := svc.RefundPayableResource(resource, request)
+			So(err, ShouldEqual, ErrNotPaid)
+		})
+
+		Convey("is refunded without reversing it in E5 or changing the resource", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			responder, _ := httpmock.NewJsonResponder(http.StatusCreated, PaymentRefund{RefundID: "R123", Status: "submitted"})
+			httpmock.RegisterResponder(http.MethodPost, refundURL, responder)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockDaoService := mocks.NewMockService(mockCtrl)
+			mockDaoService.EXPECT().GetPayableResource("10000024", "LP123456").Return(cancelledResource(), nil)
+			mockDaoService.EXPECT().GetCancelledPayment("10000024", "LP123456").Return(&dao.CancelledPaymentDao{PaymentID: "P123", Amount: "150.50"}, nil)
+
+			var created dao.RefundDao
+			mockDaoService.EXPECT().CreateRefund("10000024", "LP123456", gomock.Any()).DoAndReturn(func(_, _ string, r *dao.RefundDao) error {
+				created = *r
+				return nil
+			})
+			mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", gomock.Any()).Return(nil)
+			svc := &PayableResourceService{DAO: mockDaoService}
+
+			refund, err := svc.RefundPayableResource(resource, request)
+			So(err, ShouldBeNil)
+			So(created.PaymentID, ShouldEqual, "P123")
+			So(refund.Status, ShouldEqual, RefundStatusSubmitted)
+			So(refund.Amount, ShouldEqual, "150.50")
+			So(refund.E5ReversalStatus, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestUnitGetRefund(t *testing.T) {
+	resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+
+	Convey("payable resource has not been refunded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(nil, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.GetRefund(resource)
+		So(err, ShouldEqual, ErrRefundNotFound)
+	})
+
+	Convey("refund is returned", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: RefundStatusSubmitted, RefundID: "R123"}, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.GetRefund(resource)
+		So(err, ShouldBeNil)
+		So(refund.Status, ShouldEqual, RefundStatusSubmitted)
+		So(refund.RefundID, ShouldEqual, "R123")
+	})
+
+	Convey("a recently requested refund is not reconciled", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: RefundStatusRequested, UpdatedAt: time.Now()}, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.GetRefund(resource)
+		So(err, ShouldBeNil)
+		So(refund.Status, ShouldEqual, RefundStatusRequested)
+	})
+
+	Convey("a refund left as requested is reconciled as failed when the payment platform has no record of it", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		responder, _ := httpmock.NewJsonResponder(http.StatusOK, map[string]interface{}{"items": []PaymentRefund{{RefundID: "R123", Status: "failed"}}})
+		httpmock.RegisterResponder(http.MethodGet, "https://api-payments.companieshouse.gov.uk/private/payments/P123/refunds", responder)
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		stale := &dao.RefundDao{Status: RefundStatusRequested, PaymentID: "P123", UpdatedAt: time.Now().Add(-time.Hour)}
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(stale, nil)
+		mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", stale).Return(nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.GetRefund(resource)
+		So(err, ShouldBeNil)
+		So(refund.Status, ShouldEqual, RefundStatusFailed)
+		So(refund.FailureReason, ShouldNotBeEmpty)
+	})
+}
+
+func TestUnitRecordE5Reversal(t *testing.T) {
+	resource := &models.PayableResource{CompanyNumber: "10000024", Reference: "LP123456"}
+
+	Convey("payable resource has not been refunded", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(nil, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.RecordE5Reversal(resource, "user-id", "finance@companieshouse.gov.uk")
+		So(err, ShouldEqual, ErrRefundNotFound)
+	})
+
+	Convey("a refund that the payment platform has not accepted has nothing to reverse", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(&dao.RefundDao{Status: RefundStatusUnknown}, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.RecordE5Reversal(resource, "user-id", "finance@companieshouse.gov.uk")
+		So(err, ShouldEqual, ErrE5ReversalNotPending)
+	})
+
+	Convey("e5 reversal is recorded against the refund", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		pending := &dao.RefundDao{Status: RefundStatusSubmitted, E5ReversalStatus: E5ReversalPending}
+		mockDaoService.EXPECT().GetRefund("10000024", "LP123456").Return(pending, nil)
+		mockDaoService.EXPECT().UpdateRefund("10000024", "LP123456", pending).Return(nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		refund, err := svc.RecordE5Reversal(resource, "user-id", "finance@companieshouse.gov.uk")
+		So(err, ShouldBeNil)
+		So(refund.E5ReversalStatus, ShouldEqual, E5ReversalCompleted)
+		So(refund.E5ReversedBy, ShouldEqual, "finance@companieshouse.gov.uk")
+		So(refund.E5ReversedAt, ShouldNotBeNil)
+		So(pending.E5ReversedByID, ShouldEqual, "user-id")
+	})
+}
+
+func TestUnitListPendingE5Reversals(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+
+	Convey("error listing the e5 reversals from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListPendingE5Reversals(scheme).Return(nil, errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.ListPendingE5Reversals(scheme)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("no pending e5 reversals gives an empty list", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListPendingE5Reversals(scheme).Return([]dao.PendingE5ReversalDao{}, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		list, err := svc.ListPendingE5Reversals(scheme)
+		So(err, ShouldBeNil)
+		So(list.Items, ShouldNotBeNil)
+		So(list.Items, ShouldBeEmpty)
+	})
+}
+
+func TestUnitListUnrefundedCancelledPayments(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+
+	Convey("error listing the cancelled payments from the db", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListUnrefundedCancelledPayments(scheme).Return(nil, errors.New("any error"))
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		_, err := svc.ListUnrefundedCancelledPayments(scheme)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("cancelled payments are listed with any failed refund", t, func() {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockDaoService := mocks.NewMockService(mockCtrl)
+		mockDaoService.EXPECT().ListUnrefundedCancelledPayments(scheme).Return([]dao.CancelledPaymentItemDao{
+			{CompanyNumber: "10000024", Reference: "LP123456", CancelledPayment: dao.CancelledPaymentDao{PaymentID: "P123", Amount: "150.50"}},
+			{CompanyNumber: "10000025", Reference: "LP654321", CancelledPayment: dao.CancelledPaymentDao{PaymentID: "P456"}, Refund: &dao.RefundDao{Status: RefundStatusFailed}},
+		}, nil)
+		svc := &PayableResourceService{DAO: mockDaoService}
+
+		list, err := svc.ListUnrefundedCancelledPayments(scheme)
+		So(err, ShouldBeNil)
+		So(list.Items, ShouldHaveLength, 2)
+		So(list.Items[0].PaymentID, ShouldEqual, "P123")
+		So(list.Items[0].Amount, ShouldEqual, "150.50")
+		So(list.Items[0].Refund, ShouldBeNil)
+		So(list.Items[1].Refund.Status, ShouldEqual, RefundStatusFailed)
+	})
+}
+
+func TestUnitAmountInPence(t *testing.T) {
+	Convey("amounts in pounds are converted to pence", t, func() {
+		amount, err := amountInPence("150")
+		So(err, ShouldBeNil)
+		So(amount, ShouldEqual, 15000)
+
+		amount, err = amountInPence("0.29")
+		So(err, ShouldBeNil)
+		So(amount, ShouldEqual, 29)
+	})
+
+	Convey("error when the amount is not a number", t, func() {
+		_, err := amountInPence("£150")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+)
+
+// remainingBalanceTTL is how long a remaining balance looked up in E5 is reused for before E5 is asked again
+const remainingBalanceTTL = time.Minute
+
+// cachedBalance is a remaining balance looked up in E5 and when it should no longer be used
+type cachedBalance struct {
+	balance float64
+	expires time.Time
+}
+
+// remainingBalances are the remaining balances recently looked up for paid resources, keyed by scheme and reference
+var (
+	remainingBalances    = map[string]cachedBalance{}
+	remainingBalancesMtx sync.Mutex
+)
+
+// PayableResourceResponse is a payable resource along with the balance left to pay on its penalties. The remaining
+// balance is only included once the resource has been paid, as it is only then that a part payment has been allocated
+// against the penalties in E5.
+type PayableResourceResponse struct {
+	models.PayableResource
+	RemainingBalance *float64 `json:"remaining_balance,omitempty"`
+}
+
+// RemainingBalance returns the total amount still outstanding in E5 on the transactions paid for by the resource. It
+// is zero when the transactions have been paid off in full.
+func RemainingBalance(resource models.PayableResource, transactions *models.TransactionListResponse) float64 {
+	remaining := 0.0
+	for _, tx := range resource.Transactions {
+		if transaction := FindTransaction(transactions, tx.TransactionID); transaction != nil && !transaction.IsPaid {
+			remaining += transaction.Outstanding
+		}
+	}
+
+	return remaining
+}
+
+// GetRemainingBalance looks up the balance left in E5 on the penalties paid for by the resource. A balance looked up
+// within the last remainingBalanceTTL is reused, so that reading a paid resource does not call E5 every time.
+func GetRemainingBalance(resource models.PayableResource, scheme *config.PenaltyScheme) (float64, error) {
+	key := scheme.Name + "/" + resource.Reference
+	current := now()
+
+	remainingBalancesMtx.Lock()
+	cached, ok := remainingBalances[key]
+	remainingBalancesMtx.Unlock()
+	if ok && current.Before(cached.expires) {
+		return cached.balance, nil
+	}
+
+	transactions, _, err := GetPenalties(resource.CompanyNumber, scheme)
+	if err != nil {
+		return 0, err
+	}
+	balance := RemainingBalance(resource, transactions)
+
+	remainingBalancesMtx.Lock()
+	defer remainingBalancesMtx.Unlock()
+	for k, v := range remainingBalances {
+		if !current.Before(v.expires) {
+			delete(remainingBalances, k)
+		}
+	}
+	remainingBalances[key] = cachedBalance{balance: balance, expires: current.Add(remainingBalanceTTL)}
+
+	return balance, nil
+}
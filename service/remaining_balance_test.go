@@ -0,0 +1,90 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitRemainingBalance(t *testing.T) {
+	resource := models.PayableResource{
+		Transactions: []models.TransactionItem{
+			{TransactionID: "00378420", Amount: 50},
+			{TransactionID: "00378421", Amount: 150},
+		},
+	}
+
+	Convey("the balance left on part paid penalties is summed", t, func() {
+		transactions := &models.TransactionListResponse{Items: []models.TransactionListItem{
+			{ID: "00378420", OriginalAmount: 150, Outstanding: 100},
+			{ID: "00378421", OriginalAmount: 150, Outstanding: 0, IsPaid: true},
+			{ID: "00378422", OriginalAmount: 150, Outstanding: 150},
+		}}
+
+		So(RemainingBalance(resource, transactions), ShouldEqual, 100)
+	})
+
+	Convey("there is no balance when the penalties are paid off in full", t, func() {
+		transactions := &models.TransactionListResponse{Items: []models.TransactionListItem{
+			{ID: "00378420", OriginalAmount: 50, Outstanding: 0, IsPaid: true},
+			{ID: "00378421", OriginalAmount: 150, Outstanding: 0, IsPaid: true},
+		}}
+
+		So(RemainingBalance(resource, transactions), ShouldEqual, 0)
+	})
+}
+
+func TestUnitGetRemainingBalance(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+	// the penalty types are read relative to the root of the repository
+	scheme := *mocks.LateFilingScheme()
+	scheme.AllowedTypesFile = "../" + scheme.AllowedTypesFile
+
+	url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+	resource := models.PayableResource{
+		CompanyNumber: "10000024",
+		Reference:     "remaining-balance",
+		Transactions:  []models.TransactionItem{{TransactionID: "00378420", Amount: 50}},
+	}
+
+	Convey("the balance is looked up in E5 again once the cached balance has expired", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		fixed := time.Now()
+		now = func() time.Time { return fixed }
+		defer func() { now = time.Now }()
+
+		httpmock.RegisterResponder(http.MethodGet, url, httpmock.NewStringResponder(http.StatusOK, e5TransactionResponse(100)))
+		balance, err := GetRemainingBalance(resource, &scheme)
+		So(err, ShouldBeNil)
+		So(balance, ShouldEqual, 100)
+
+		httpmock.RegisterResponder(http.MethodGet, url, httpmock.NewStringResponder(http.StatusOK, e5TransactionResponse(50)))
+		balance, err = GetRemainingBalance(resource, &scheme)
+		So(err, ShouldBeNil)
+		So(balance, ShouldEqual, 100)
+
+		fixed = fixed.Add(remainingBalanceTTL)
+		balance, err = GetRemainingBalance(resource, &scheme)
+		So(err, ShouldBeNil)
+		So(balance, ShouldEqual, 50)
+	})
+}
+
+// e5TransactionResponse is an E5 transaction list with a single penalty with the outstanding amount
+func e5TransactionResponse(outstanding float64) string {
+	return fmt.Sprintf(`{"page": {"size": 1, "totalElements": 1, "totalPages": 1, "number": 0}, "data": [{
+		"companyCode": "LP", "ledgerCode": "EW", "customerCode": "10000024", "transactionReference": "00378420",
+		"transactionDate": "2017-11-28", "madeUpDate": "2017-02-28", "amount": 150, "outstandingAmount": %g,
+		"isPaid": false, "transactionType": "1", "transactionSubType": "EU", "typeDescription": "Penalty",
+		"dueDate": "2017-12-12"}]}`, outstanding)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"github.com/companieshouse/lfp-pay-api/utils"
+)
+
+// The statuses a transaction can have on a statement
+const (
+	StatementStatusPaid        = "Paid"
+	StatementStatusDCA         = "With debt collection agency"
+	StatementStatusOverdue     = "Overdue"
+	StatementStatusPartPaid    = "Part paid"
+	StatementStatusOutstanding = "Outstanding"
+)
+
+// statementDateLayout is the format of the dates shown on a PDF statement
+const statementDateLayout = "2 Jan 2006"
+
+// statementColumns are the headings of the transaction columns in both CSV and PDF statements
+var statementColumns = []string{"Reference", "Type", "Transaction date", "Due date", "Amount", "Outstanding", "Status"}
+
+// pdfStatementColumnWidths are the widths in points of the statementColumns on a PDF statement
+var pdfStatementColumnWidths = []float64{65, 50, 80, 75, 60, 70, 95}
+
+// Statement is a downloadable record of every transaction a company has in a penalty scheme
+type Statement struct {
+	Title            string
+	CompanyNumber    string
+	CompanyName      string
+	GeneratedAt      time.Time
+	Lines            []StatementLine
+	TotalOutstanding float64
+}
+
+// StatementLine is a single transaction on a statement
+type StatementLine struct {
+	models.TransactionListItem
+	Status string
+}
+
+// NewStatement creates a statement of the transactions as of the given time
+func NewStatement(companyNumber, companyName string, transactions *models.TransactionListResponse, scheme *config.PenaltyScheme, at time.Time) *Statement {
+	statement := &Statement{
+		Title:            scheme.Description + " statement",
+		CompanyNumber:    companyNumber,
+		CompanyName:      companyName,
+		GeneratedAt:      at.In(ukTime),
+		TotalOutstanding: SummarisePenalties(companyNumber, transactions).TotalOutstanding,
+	}
+
+	for _, transaction := range transactions.Items {
+		statement.Lines = append(statement.Lines, StatementLine{
+			TransactionListItem: transaction,
+			Status:              statementStatus(transaction, GetDueStatus(transaction, at)),
+		})
+	}
+
+	return statement
+}
+
+// statementStatus describes the state of the transaction, most important first, e.g. an overdue penalty that is with
+// a debt collection agency is shown as being with the agency
+func statementStatus(transaction models.TransactionListItem, dueStatus DueStatus) string {
+	switch {
+	case transaction.IsPaid:
+		return StatementStatusPaid
+	case transaction.IsDCA:
+		return StatementStatusDCA
+	case dueStatus.IsOverdue:
+		return StatementStatusOverdue
+	case transaction.IsPartPaid():
+		return StatementStatusPartPaid
+	default:
+		return StatementStatusOutstanding
+	}
+}
+
+// WriteCSV writes the statement as CSV. The company details and total outstanding come first, followed by a blank
+// row and then a row for each transaction under a heading row.
+func (s *Statement) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	records := [][]string{
+		{"Company name", s.CompanyName},
+		{"Company number", s.CompanyNumber},
+		{"Generated", s.GeneratedAt.Format(time.RFC3339)},
+		{"Total outstanding", formatAmount(s.TotalOutstanding)},
+		{},
+		statementColumns,
+	}
+	for _, line := range s.Lines {
+		records = append(records, []string{
+			line.ID,
+			line.Type,
+			line.TransactionDate,
+			line.DueDate,
+			formatAmount(line.OriginalAmount),
+			formatAmount(line.Outstanding),
+			line.Status,
+		})
+	}
+
+	return writer.WriteAll(records)
+}
+
+// WritePDF writes the statement as a single or multiple page PDF
+func (s *Statement) WritePDF(w io.Writer) error {
+	doc := utils.NewPDFDocument()
+
+	doc.AddText(s.Title, 18, true)
+	doc.AddSpace(10)
+	doc.AddText(s.CompanyName, 12, true)
+	doc.AddText("Company number "+s.CompanyNumber, 10, false)
+	doc.AddText("Generated on "+s.GeneratedAt.Format(statementDateLayout), 10, false)
+	doc.AddSpace(10)
+	doc.AddText("Total outstanding £"+formatAmount(s.TotalOutstanding), 12, true)
+	doc.AddSpace(10)
+
+	doc.AddRow(statementColumns, pdfStatementColumnWidths, 9, true)
+	for _, line := range s.Lines {
+		doc.AddRow([]string{
+			line.ID,
+			line.Type,
+			formatStatementDate(line.TransactionDate),
+			formatStatementDate(line.DueDate),
+			"£" + formatAmount(line.OriginalAmount),
+			"£" + formatAmount(line.Outstanding),
+			line.Status,
+		}, pdfStatementColumnWidths, 9, false)
+	}
+
+	if len(s.Lines) == 0 {
+		doc.AddText("There are no penalties for this company.", 10, false)
+	}
+
+	return doc.Write(w)
+}
+
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// formatStatementDate formats an E5 date for a PDF statement, leaving it as it is if it cannot be parsed
+func formatStatementDate(value string) string {
+	date, err := ParseE5Date(value)
+	if err != nil {
+		return value
+	}
+	return date.Format(statementDateLayout)
+}
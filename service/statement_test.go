@@ -0,0 +1,60 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/mocks"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitNewStatement(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+	at := time.Date(2019, 7, 1, 9, 0, 0, 0, ukTime)
+	transactions := &models.TransactionListResponse{Items: []models.TransactionListItem{
+		{ID: "00378420", Type: "penalty", TransactionDate: "2019-06-27", DueDate: "2019-07-11", OriginalAmount: 750, Outstanding: 750},
+		{ID: "00378421", Type: "penalty", TransactionDate: "2018-04-30", DueDate: "2018-05-14", OriginalAmount: 150, Outstanding: 100},
+		{ID: "00378422", Type: "penalty", TransactionDate: "2017-11-28", DueDate: "2017-12-12", OriginalAmount: 150, Outstanding: 150, IsDCA: true},
+		{ID: "00378423", Type: "other", TransactionDate: "2016-01-05", DueDate: "2016-01-19", OriginalAmount: 150, IsPaid: true},
+	}}
+
+	Convey("each transaction is given a status and the outstanding amount is totalled", t, func() {
+		statement := NewStatement("10000024", "TEST LTD", transactions, scheme, at)
+		So(statement.Title, ShouldEqual, "Late Filing Penalty statement")
+		So(statement.TotalOutstanding, ShouldEqual, 1000)
+		So(statement.Lines, ShouldHaveLength, 4)
+		So(statement.Lines[0].Status, ShouldEqual, StatementStatusOutstanding)
+		So(statement.Lines[1].Status, ShouldEqual, StatementStatusOverdue)
+		So(statement.Lines[2].Status, ShouldEqual, StatementStatusDCA)
+		So(statement.Lines[3].Status, ShouldEqual, StatementStatusPaid)
+	})
+
+	Convey("a statement is written as CSV", t, func() {
+		statement := NewStatement("10000024", "TEST, LTD", transactions, scheme, at)
+
+		var out bytes.Buffer
+		So(statement.WriteCSV(&out), ShouldBeNil)
+		So(out.String(), ShouldStartWith, "Company name,\"TEST, LTD\"\nCompany number,10000024\nGenerated,2019-07-01T09:00:00+01:00\nTotal outstanding,1000.00\n\n")
+		So(out.String(), ShouldContainSubstring, "Reference,Type,Transaction date,Due date,Amount,Outstanding,Status\n")
+		So(out.String(), ShouldContainSubstring, "00378421,penalty,2018-04-30,2018-05-14,150.00,100.00,Overdue\n")
+	})
+
+	Convey("a statement is written as PDF", t, func() {
+		statement := NewStatement("10000024", "TEST LTD", transactions, scheme, at)
+
+		var out bytes.Buffer
+		So(statement.WritePDF(&out), ShouldBeNil)
+		So(out.String(), ShouldStartWith, "%PDF-1.4")
+		So(out.String(), ShouldContainSubstring, "(Total outstanding \\2431000.00) Tj")
+		So(out.String(), ShouldContainSubstring, "(11 Jul 2019) Tj")
+	})
+
+	Convey("a transaction with an invalid due date is still on the statement", t, func() {
+		invalid := &models.TransactionListResponse{Items: []models.TransactionListItem{{ID: "00378420", DueDate: "invalid"}}}
+		statement := NewStatement("10000024", "TEST LTD", invalid, scheme, at)
+		So(statement.Lines, ShouldHaveLength, 1)
+		So(statement.Lines[0].Status, ShouldEqual, StatementStatusOutstanding)
+	})
+}
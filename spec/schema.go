@@ -0,0 +1,161 @@
+package spec
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Violation is a part of a request or response body that does not match the spec. The path is where it is in the
+// body, e.g. transactions[0].amount, and is empty for the body as a whole.
+type Violation struct {
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// validate checks the value decoded from a json body against the schema, returning every part of it that does not
+// match
+func (s *Spec) validate(schema *Schema, value interface{}, at string) []Violation {
+	schema, err := s.resolve(schema)
+	if err != nil {
+		return []Violation{{Path: at, Message: err.Error()}}
+	}
+
+	var violations []Violation
+	for _, sub := range schema.AllOf {
+		violations = append(violations, s.validate(sub, value, at)...)
+	}
+
+	if schema.Type != "" && !hasType(value, schema.Type) {
+		return append(violations, Violation{Path: at, Message: fmt.Sprintf("must be of type %s", schema.Type)})
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(value, schema.Enum) {
+		violations = append(violations, Violation{Path: at, Message: fmt.Sprintf("must be one of %v", schema.Enum)})
+	}
+
+	switch v := value.(type) {
+	case string:
+		if !hasFormat(v, schema.Format) {
+			violations = append(violations, Violation{Path: at, Message: fmt.Sprintf("must be a %s", schema.Format)})
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range v {
+				violations = append(violations, s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		violations = append(violations, s.validateObject(schema, v, at)...)
+	}
+
+	return violations
+}
+
+// validateObject checks the required properties are present and each property matches its schema. The properties are
+// checked in name order, so that the violations are always reported in the same order.
+func (s *Spec) validateObject(schema *Schema, object map[string]interface{}, at string) []Violation {
+	var violations []Violation
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			violations = append(violations, Violation{Path: propertyPath(at, name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := schema.Properties[name]
+		if !ok {
+			property = schema.AdditionalProperties
+		}
+		if property != nil {
+			violations = append(violations, s.validate(property, object[name], propertyPath(at, name))...)
+		}
+	}
+
+	return violations
+}
+
+// resolve follows the schema's reference to a definition in the spec
+func (s *Spec) resolve(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+
+	name := strings.TrimPrefix(schema.Ref, "#/definitions/")
+	definition, ok := s.definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("the spec has no definition for %s", schema.Ref)
+	}
+	return s.resolve(definition)
+}
+
+// hasType returns true if the value decoded from json is of the swagger type
+func hasType(value interface{}, swaggerType string) bool {
+	switch swaggerType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	}
+	return true
+}
+
+// hasFormat returns true if the string is in the format. Only the date formats are checked.
+func hasFormat(value, format string) bool {
+	var err error
+	switch format {
+	case "date":
+		_, err = time.Parse("2006-01-02", value)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	}
+	return err == nil
+}
+
+// inEnum returns true if the value is one of the values of the enum
+func inEnum(value interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(value, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// propertyPath is the path of the property of the object at the given path
+func propertyPath(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
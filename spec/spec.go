@@ -0,0 +1,223 @@
+// Package spec loads the swagger spec describing the API and checks request and response bodies against it.
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File is the swagger spec describing the API
+const File = "spec/schema.json"
+
+// Spec is the swagger spec describing the API. The operations are found by the path of a request, which is relative
+// to the spec's base path for the paths under it.
+type Spec struct {
+	raw         []byte
+	basePath    *regexp.Regexp
+	paths       []*path
+	definitions map[string]*Schema
+}
+
+// document is the structure of the swagger spec file
+type document struct {
+	BasePath    string                                `json:"basePath"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]*Schema                    `json:"definitions"`
+}
+
+// path is a path in the spec and its operations, keyed by upper case method
+type path struct {
+	pattern    *regexp.Regexp
+	operations map[string]*Operation
+}
+
+// Operation is a method on a path in the spec
+type Operation struct {
+	Parameters []Parameter          `json:"parameters"`
+	Responses  map[string]*Response `json:"responses"`
+
+	spec *Spec
+}
+
+// Parameter is a parameter of an operation. Only body parameters have a schema.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Response is a response of an operation, which has a schema if it has a body
+type Response struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the part of a swagger schema object that bodies are checked against
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []interface{}      `json:"enum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	AllOf                []*Schema          `json:"allOf"`
+}
+
+// Load reads the swagger spec from the json file at the given path
+func Load(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading api spec file: [%v]", err)
+	}
+
+	return New(data)
+}
+
+// New parses the swagger spec
+func New(data []byte) (*Spec, error) {
+	var doc document
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling api spec: [%v]", err)
+	}
+
+	s := &Spec{
+		raw:         data,
+		basePath:    regexp.MustCompile("^" + pathPattern(strings.TrimSuffix(doc.BasePath, "/"))),
+		definitions: doc.Definitions,
+	}
+
+	// the paths are matched in a fixed order, so a request always finds the same operation
+	templates := make([]string, 0, len(doc.Paths))
+	for template := range doc.Paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	for _, template := range templates {
+		methods := doc.Paths[template]
+		p := &path{
+			pattern:    regexp.MustCompile("^" + pathPattern(template) + "$"),
+			operations: map[string]*Operation{},
+		}
+		for method, raw := range methods {
+			// the parameters that apply to every operation on the path are listed alongside the methods
+			if method == "parameters" {
+				continue
+			}
+
+			op := &Operation{spec: s}
+			err = json.Unmarshal(raw, op)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshalling api spec operation [%s %s]: [%v]", strings.ToUpper(method), template, err)
+			}
+			p.operations[strings.ToUpper(method)] = op
+		}
+		s.paths = append(s.paths, p)
+	}
+
+	return s, nil
+}
+
+// Raw returns the spec as it was read
+func (s *Spec) Raw() []byte {
+	return s.raw
+}
+
+// FindOperation returns the operation in the spec for the request method and path, or nil if the spec does not
+// describe it
+func (s *Spec) FindOperation(method, requestPath string) *Operation {
+	relative := requestPath
+	if loc := s.basePath.FindStringIndex(requestPath); loc != nil {
+		if rest := requestPath[loc[1]:]; rest == "" || strings.HasPrefix(rest, "/") {
+			relative = rest
+		}
+	}
+	if relative == "" {
+		relative = "/"
+	}
+
+	for _, p := range s.paths {
+		if p.pattern.MatchString(relative) {
+			return p.operations[strings.ToUpper(method)]
+		}
+	}
+	return nil
+}
+
+// bodyParameter returns the operation's body parameter, or nil if it has none
+func (o *Operation) bodyParameter() *Parameter {
+	for i := range o.Parameters {
+		if o.Parameters[i].In == "body" {
+			return &o.Parameters[i]
+		}
+	}
+	return nil
+}
+
+// HasRequestBody returns true if the operation takes a request body
+func (o *Operation) HasRequestBody() bool {
+	return o.bodyParameter() != nil
+}
+
+// RequestViolations checks the request body against the schema of the operation's body parameter
+func (o *Operation) RequestViolations(body []byte) []Violation {
+	param := o.bodyParameter()
+	if param == nil || param.Schema == nil {
+		return nil
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		if param.Required {
+			return []Violation{{Message: "the request body is required"}}
+		}
+		return nil
+	}
+
+	return o.spec.check(param.Schema, body)
+}
+
+// ResponseViolations checks the response body against the schema of the operation's response for the status, or its
+// default response. Responses that the spec has no schema for are not checked.
+func (o *Operation) ResponseViolations(status int, body []byte) []Violation {
+	response, ok := o.Responses[strconv.Itoa(status)]
+	if !ok {
+		response = o.Responses["default"]
+	}
+	if response == nil || response.Schema == nil {
+		return nil
+	}
+
+	return o.spec.check(response.Schema, body)
+}
+
+// check decodes the json body and checks it against the schema
+func (s *Spec) check(schema *Schema, body []byte) []Violation {
+	var value interface{}
+	err := json.Unmarshal(body, &value)
+	if err != nil {
+		return []Violation{{Message: fmt.Sprintf("the body is not valid json: %v", err)}}
+	}
+
+	return s.validate(schema, value, "")
+}
+
+// pathPattern is the regular expression matching the path template, e.g. /payable/{id}
+func pathPattern(template string) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = "[^/]+"
+			continue
+		}
+		segments[i] = regexp.QuoteMeta(segment)
+	}
+	return strings.Join(segments, "/")
+}
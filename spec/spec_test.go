@@ -0,0 +1,144 @@
+package spec
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitLoad(t *testing.T) {
+	Convey("the api spec is loaded", t, func() {
+		apiSpec, err := Load("schema.json")
+
+		So(err, ShouldBeNil)
+		So(apiSpec.Raw(), ShouldNotBeEmpty)
+		So(apiSpec.FindOperation("POST", "/company/10000024/penalties/late-filing/payable"), ShouldNotBeNil)
+	})
+
+	Convey("error if the api spec file does not exist", t, func() {
+		apiSpec, err := Load("missing.json")
+
+		So(apiSpec, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestUnitFindOperation(t *testing.T) {
+	apiSpec, _ := New([]byte(testSpec))
+
+	Convey("paths are found relative to the base path", t, func() {
+		So(apiSpec.FindOperation("GET", "/company/10000024/penalties/late-filing"), ShouldEqual, apiSpec.FindOperation("GET", "/company/SC000123/penalties/late-filing/"))
+		So(apiSpec.FindOperation("GET", "/company/10000024/penalties/late-filing"), ShouldNotBeNil)
+		So(apiSpec.FindOperation("patch", "/company/10000024/penalties/late-filing/payable/LP123456/payment"), ShouldNotBeNil)
+	})
+
+	Convey("paths that are not under the base path are found as they are", t, func() {
+		So(apiSpec.FindOperation("GET", "/healthcheck"), ShouldNotBeNil)
+	})
+
+	Convey("nil if the spec does not describe the operation", t, func() {
+		So(apiSpec.FindOperation("DELETE", "/company/10000024/penalties/late-filing/payable/LP123456/payment"), ShouldBeNil)
+		So(apiSpec.FindOperation("GET", "/company/10000024/penalties/late-filing-other/payable"), ShouldBeNil)
+		So(apiSpec.FindOperation("GET", "/company/10000024/penalties/late-filing/payable/LP123456/receipt"), ShouldBeNil)
+	})
+}
+
+func TestUnitRequestViolations(t *testing.T) {
+	apiSpec, _ := New([]byte(testSpec))
+	op := apiSpec.FindOperation("PATCH", "/company/10000024/penalties/late-filing/payable/LP123456/payment")
+
+	Convey("a body that matches has no violations", t, func() {
+		So(op.HasRequestBody(), ShouldBeTrue)
+		So(op.RequestViolations([]byte(`{"reference": "123", "amounts": [1, 2.5]}`)), ShouldBeEmpty)
+	})
+
+	Convey("every part of the body that does not match is a violation", t, func() {
+		violations := op.RequestViolations([]byte(`{"amounts": [1, "2"], "paid_at": "yesterday", "status": "lost"}`))
+
+		So(violations, ShouldResemble, []Violation{
+			{Path: "reference", Message: "is required"},
+			{Path: "amounts[1]", Message: "must be of type number"},
+			{Path: "paid_at", Message: "must be a date-time"},
+			{Path: "status", Message: "must be one of [paid failed]"},
+		})
+	})
+
+	Convey("a body that is not json is a violation", t, func() {
+		So(op.RequestViolations([]byte(`reference=123`)), ShouldHaveLength, 1)
+	})
+
+	Convey("a required body must be sent", t, func() {
+		So(op.RequestViolations(nil), ShouldResemble, []Violation{{Message: "the request body is required"}})
+	})
+}
+
+func TestUnitResponseViolations(t *testing.T) {
+	apiSpec, _ := New([]byte(testSpec))
+	op := apiSpec.FindOperation("GET", "/company/10000024/penalties/late-filing")
+
+	Convey("the response is checked against the definitions it refers to", t, func() {
+		So(op.ResponseViolations(200, []byte(`{"total_results": 1, "items": [{"id": "A1"}]}`)), ShouldBeEmpty)
+		So(op.ResponseViolations(200, []byte(`{"total_results": 1.5, "items": [{"id": 1}]}`)), ShouldResemble, []Violation{
+			{Path: "total_results", Message: "must be of type integer"},
+			{Path: "items[0].id", Message: "must be of type string"},
+		})
+	})
+
+	Convey("responses that the spec has no schema for are not checked", t, func() {
+		So(op.ResponseViolations(404, []byte(`not json`)), ShouldBeEmpty)
+	})
+}
+
+const testSpec = `
+{
+  "swagger": "2.0",
+  "basePath": "/company/{company_number}/penalties/late-filing",
+  "paths": {
+    "/healthcheck": {
+      "get": {"responses": {"200": {"description": "healthy"}}}
+    },
+    "/": {
+      "get": {
+        "responses": {
+          "404": {"description": "not found"},
+          "200": {
+            "schema": {
+              "allOf": [
+                {"$ref": "#/definitions/List"},
+                {"type": "object", "properties": {"items": {"type": "array", "items": {"$ref": "#/definitions/Item"}}}}
+              ]
+            }
+          }
+        }
+      }
+    },
+    "/payable/{id}/payment": {
+      "parameters": [{"in": "path", "name": "id", "required": true, "type": "string"}],
+      "patch": {
+        "parameters": [
+          {
+            "in": "body",
+            "name": "body",
+            "required": true,
+            "schema": {
+              "type": "object",
+              "required": ["reference"],
+              "properties": {
+                "reference": {"type": "string"},
+                "amounts": {"type": "array", "items": {"type": "number"}},
+                "paid_at": {"type": "string", "format": "date-time"},
+                "status": {"type": "string", "enum": ["paid", "failed"]}
+              }
+            }
+          }
+        ],
+        "responses": {"204": {"description": "paid"}}
+      }
+    }
+  },
+  "definitions": {
+    "List": {"type": "object", "properties": {"total_results": {"type": "integer"}}},
+    "Item": {"type": "object", "properties": {"id": {"type": "string"}}}
+  }
+}
+`
@@ -13,7 +13,7 @@ if [[ -z "${MESOS_SLAVE_PID}" ]]; then
 else
     PORT="$1"
     CONFIG_URL="$2"
-    ENVIRONMENT="$3"
+    export ENVIRONMENT="$3"
     APP_NAME="$4"
 
     source /etc/profile
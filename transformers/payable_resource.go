@@ -2,17 +2,19 @@ package transformers
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api-core/constants"
 	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/utils"
 )
 
 // PayableResourceRequestToDB will take the input request from the REST call and transform it to a dao ready for
-// insertion into the database
-func PayableResourceRequestToDB(req *models.PayableRequest) *models.PayableResourceDao {
+// insertion into the database. The links are built from the penalty scheme the resource is created under.
+func PayableResourceRequestToDB(req *models.PayableRequest, scheme *config.PenaltyScheme) *models.PayableResourceDao {
 	transactionsDAO := map[string]models.TransactionDao{}
 	for _, tx := range req.Transactions {
 		transactionsDAO[tx.TransactionID] = models.TransactionDao{
@@ -23,26 +25,24 @@ func PayableResourceRequestToDB(req *models.PayableRequest) *models.PayableResou
 	}
 
 	reference := utils.GenerateReferenceNumber()
-	etag, err := utils.GenerateEtag()
-	if err != nil {
-		log.Error(fmt.Errorf("error generating etag: [%s]", err))
-	}
-	format := "/company/%s/penalties/late-filing/payable/%s"
+	format := "%s/payable/%s"
 
-	self := fmt.Sprintf(format, req.CompanyNumber, reference)
+	self := fmt.Sprintf(format, scheme.CompanyPath(req.CompanyNumber), reference)
 
 	paymentLinkFormat := "%s/payment"
 	paymentLink := fmt.Sprintf(paymentLinkFormat, self)
 
-	resumeJourneyLinkFormat := "/late-filing-penalty/company/%s/penalty/%s/view-penalties"
-	resumeJourneyLink := fmt.Sprintf(resumeJourneyLinkFormat, req.CompanyNumber, req.Transactions[0].TransactionID) // Assumes there is only one transaction
+	// a single penalty is resumed from that penalty, whereas several are resumed from the company's list of penalties
+	resumeJourneyLink := fmt.Sprintf(scheme.ResumeJourneyLink, req.CompanyNumber, req.Transactions[0].TransactionID)
+	if len(req.Transactions) > 1 {
+		resumeJourneyLink = fmt.Sprintf(scheme.ResumeJourneyMultipleLink, req.CompanyNumber)
+	}
 
 	createdAt := time.Now().Truncate(time.Millisecond)
 	dao := &models.PayableResourceDao{
 		CompanyNumber: req.CompanyNumber,
 		Reference:     reference,
 		Data: models.PayableResourceDataDao{
-			Etag:         etag,
 			Transactions: transactionsDAO,
 			Payment: models.PaymentDao{
 				Status: constants.Pending.String(),
@@ -62,6 +62,12 @@ func PayableResourceRequestToDB(req *models.PayableRequest) *models.PayableResou
 		},
 	}
 
+	etag, err := utils.GeneratePayableResourceEtag(dao)
+	if err != nil {
+		log.Error(fmt.Errorf("error generating etag: [%s]", err))
+	}
+	dao.Data.Etag = etag
+
 	return dao
 }
 
@@ -89,6 +95,11 @@ func PayableResourceDBToRequest(payableDao *models.PayableResourceDao) *models.P
 		transactions = append(transactions, tx)
 	}
 
+	// transactions are stored in a map so are sorted to always be returned in the same order
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].TransactionID < transactions[j].TransactionID
+	})
+
 	payable := models.PayableResource{
 		CompanyNumber: payableDao.CompanyNumber,
 		Reference:     payableDao.Reference,
@@ -104,24 +115,25 @@ func PayableResourceDBToRequest(payableDao *models.PayableResourceDao) *models.P
 }
 
 // PayableResourceToPaymentDetails will create a PaymentDetails resource (for integrating into payment service) from an LFP PayableResource
-func PayableResourceToPaymentDetails(payable *models.PayableResource) *models.PaymentDetails {
+// and the penalty scheme it was created under
+func PayableResourceToPaymentDetails(payable *models.PayableResource, scheme *config.PenaltyScheme) *models.PaymentDetails {
 	costs := []models.Cost{}
 	for _, tx := range payable.Transactions {
 		cost := models.Cost{
 			Amount:                  fmt.Sprintf("%g", tx.Amount),
 			AvailablePaymentMethods: []string{"credit-card"},
 			ClassOfPayment:          []string{"penalty"},
-			Description:             "Late Filing Penalty",
-			DescriptionIdentifier:   "late-filing-penalty",
+			Description:             scheme.Description,
+			DescriptionIdentifier:   scheme.DescriptionIdentifier,
 			Kind:                    "cost#cost",
-			ResourceKind:            "late-filing-penalty#late-filing-penalty",
-			ProductType:             "late-filing-penalty",
+			ResourceKind:            scheme.ResourceKind,
+			ProductType:             scheme.ProductType,
 		}
 		costs = append(costs, cost)
 	}
 
 	payment := models.PaymentDetails{
-		Description: "Late Filing Penalty",
+		Description: scheme.Description,
 		Etag:        payable.Etag, // use the same Etag as PayableResource its built from - if PayableResource changes PaymentDetails may change too
 		Kind:        "payment-details#payment-details",
 		Links: models.PaymentDetailsLinks{
@@ -7,6 +7,7 @@ import (
 
 	"github.com/companieshouse/lfp-pay-api-core/models"
 
+	"github.com/companieshouse/lfp-pay-api/mocks"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -17,7 +18,7 @@ func TestUnitPayableResourceRequestToDB(t *testing.T) {
 				{TransactionID: "123"},
 			},
 		}
-		dao := PayableResourceRequestToDB(req)
+		dao := PayableResourceRequestToDB(req, mocks.LateFilingScheme())
 
 		So(dao.Reference, ShouldHaveLength, 10)
 	})
@@ -29,7 +30,7 @@ func TestUnitPayableResourceRequestToDB(t *testing.T) {
 				{TransactionID: "123"},
 			},
 		}
-		dao := PayableResourceRequestToDB(req)
+		dao := PayableResourceRequestToDB(req, mocks.LateFilingScheme())
 
 		// ensure a reference is generated for the next assertion
 		So(dao.Reference, ShouldHaveLength, 10)
@@ -38,6 +39,20 @@ func TestUnitPayableResourceRequestToDB(t *testing.T) {
 		So(dao.Data.Links.Self, ShouldContainSubstring, expected)
 		So(dao.Data.Links.ResumeJourney, ShouldEqual, "/late-filing-penalty/company/00006400/penalty/123/view-penalties")
 	})
+
+	Convey("resume journey link for multiple penalties is to the list of penalties", t, func() {
+		req := &models.PayableRequest{
+			CompanyNumber: "00006400",
+			Transactions: []models.TransactionItem{
+				{TransactionID: "123"},
+				{TransactionID: "456"},
+			},
+		}
+		dao := PayableResourceRequestToDB(req, mocks.LateFilingScheme())
+
+		So(dao.Data.Transactions, ShouldHaveLength, 2)
+		So(dao.Data.Links.ResumeJourney, ShouldEqual, "/late-filing-penalty/company/00006400/view-penalties")
+	})
 }
 
 func TestUnitPayableResourceDaoToCreatedResponse(t *testing.T) {
@@ -119,9 +134,30 @@ func TestUnitPayableResourceDBToPayableResource(t *testing.T) {
 		So(response.Transactions[0].Type, ShouldEqual, dao.Data.Transactions["123"].Type)
 		So(response.Transactions[0].MadeUpDate, ShouldEqual, dao.Data.Transactions["123"].MadeUpDate)
 	})
+
+	Convey("transactions are sorted by transaction id", t, func() {
+		dao := &models.PayableResourceDao{
+			Data: models.PayableResourceDataDao{
+				Transactions: map[string]models.TransactionDao{
+					"789": models.TransactionDao{Amount: 100},
+					"123": models.TransactionDao{Amount: 100},
+					"456": models.TransactionDao{Amount: 100},
+				},
+			},
+		}
+
+		response := PayableResourceDBToRequest(dao)
+
+		So(response.Transactions, ShouldHaveLength, 3)
+		So(response.Transactions[0].TransactionID, ShouldEqual, "123")
+		So(response.Transactions[1].TransactionID, ShouldEqual, "456")
+		So(response.Transactions[2].TransactionID, ShouldEqual, "789")
+	})
 }
 
 func TestUnitPayableResourceToPaymentDetails(t *testing.T) {
+	scheme := mocks.LateFilingScheme()
+
 	Convey("field mappings are correct from payable resource to payment details", t, func() {
 		t := time.Now().Truncate(time.Millisecond)
 		payable := &models.PayableResource{
@@ -154,7 +190,7 @@ func TestUnitPayableResourceToPaymentDetails(t *testing.T) {
 			},
 		}
 
-		response := PayableResourceToPaymentDetails(payable)
+		response := PayableResourceToPaymentDetails(payable, scheme)
 
 		So(response, ShouldNotBeNil)
 		So(response.Description, ShouldEqual, "Late Filing Penalty")
@@ -2,3 +2,10 @@ package utils
 
 // AdminPenaltyLookupRole defines the path to check whether a user is authorised to look up a penalty.
 const AdminPenaltyLookupRole = "/admin/penalty-lookup"
+
+// AdminPenaltySettleRole defines the path to check whether a user is authorised to mark a penalty as paid outside of
+// the service, e.g. by cheque or bank transfer.
+const AdminPenaltySettleRole = "/admin/penalty-settle"
+
+// AdminPenaltyRefundRole defines the path to check whether a user is authorised to refund the payment for a penalty.
+const AdminPenaltyRefundRole = "/admin/penalty-refund"
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// companyNumberLength is the length of every canonical company number
+const companyNumberLength = 8
+
+// ErrInvalidCompanyNumber is returned when a company number is not in any of the recognised formats
+var ErrInvalidCompanyNumber = errors.New("invalid company number")
+
+// companyNumberPrefixes are the registers whose company numbers start with letters rather than a digit, e.g. SC for
+// Scotland and NI for Northern Ireland. The remainder of the number is always numeric.
+var companyNumberPrefixes = []string{"SC", "NI", "OC", "SO", "NC", "R"}
+
+var digits = regexp.MustCompile(`^[0-9]+$`)
+
+// NormaliseCompanyNumber returns the canonical form of the supplied company number. It is upper-cased and the numeric
+// part is zero padded so that the whole number is 8 characters long, e.g. 6400 becomes 00006400 and sc123 becomes
+// SC000123. An error is returned if the number is not in a recognised format.
+func NormaliseCompanyNumber(companyNumber string) (string, error) {
+	companyNumber = strings.ToUpper(strings.TrimSpace(companyNumber))
+
+	prefix := ""
+	for _, p := range companyNumberPrefixes {
+		if strings.HasPrefix(companyNumber, p) {
+			prefix = p
+			break
+		}
+	}
+
+	number := strings.TrimPrefix(companyNumber, prefix)
+	width := companyNumberLength - len(prefix)
+	if !digits.MatchString(number) || len(number) > width {
+		return "", ErrInvalidCompanyNumber
+	}
+
+	return prefix + strings.Repeat("0", width-len(number)) + number, nil
+}
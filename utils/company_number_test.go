@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitNormaliseCompanyNumber(t *testing.T) {
+	Convey("valid company numbers are returned in canonical form", t, func() {
+		companyNumbers := map[string]string{
+			"10000024": "10000024",
+			"6400":     "00006400",
+			"1":        "00000001",
+			"SC123456": "SC123456",
+			"sc123":    "SC000123",
+			"NI038379": "NI038379",
+			"OC1":      "OC000001",
+			"SO300001": "SO300001",
+			"nc000123": "NC000123",
+			"R0000001": "R0000001",
+			"r12":      "R0000012",
+			" 6400 ":   "00006400",
+		}
+
+		for companyNumber, expected := range companyNumbers {
+			normalised, err := NormaliseCompanyNumber(companyNumber)
+			So(err, ShouldBeNil)
+			So(normalised, ShouldEqual, expected)
+		}
+	})
+
+	Convey("invalid company numbers are rejected", t, func() {
+		for _, companyNumber := range []string{"", "SC", "R", "123456789", "SC1234567", "XX123456", "1234-567", "FC123456"} {
+			normalised, err := NormaliseCompanyNumber(companyNumber)
+			So(normalised, ShouldBeEmpty)
+			So(err, ShouldEqual, ErrInvalidCompanyNumber)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/companieshouse/lfp-pay-api/i18n"
+)
+
+// The error codes for failures that are not specific to a resource. The codes are stable, so clients can decide what
+// to do from the code rather than the message.
+const (
+	ErrorCodeInvalidRequest       = "INVALID_REQUEST"
+	ErrorCodeInvalidRequestBody   = "INVALID_REQUEST_BODY"
+	ErrorCodeInvalidCompanyNumber = "INVALID_COMPANY_NUMBER"
+	ErrorCodeNotAcceptable        = "NOT_ACCEPTABLE"
+	ErrorCodeResourceNotFound     = "RESOURCE_NOT_FOUND"
+	ErrorCodeResourceModified     = "RESOURCE_MODIFIED"
+	ErrorCodeFinanceDataInvalid   = "FINANCE_DATA_INVALID"
+	ErrorCodeFinanceSystemError   = "FINANCE_SYSTEM_ERROR"
+	ErrorCodeInternalError        = "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the body of an error response. The field is the part of the request body that failed validation
+// and the transaction id is the transaction that could not be paid for, where they are known. A message formatted
+// with NewErrorResponsef keeps its format and arguments so that it can be translated.
+type ErrorResponse struct {
+	ErrorCode     string `json:"error_code"`
+	Message       string `json:"message"`
+	Field         string `json:"field,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+
+	format string
+	args   []interface{}
+}
+
+// NewErrorResponse returns an error response with the supplied error code and message.
+func NewErrorResponse(code, message string) *ErrorResponse {
+	return &ErrorResponse{ErrorCode: code, Message: message}
+}
+
+// NewErrorResponsef returns an error response with the supplied error code and the message formatted from the
+// arguments.
+func NewErrorResponsef(code, format string, args ...interface{}) *ErrorResponse {
+	return &ErrorResponse{ErrorCode: code, Message: fmt.Sprintf(format, args...), format: format, args: args}
+}
+
+// Localise translates the message into the language. The arguments of a formatted message are not translated.
+func (e *ErrorResponse) Localise(lang string) {
+	if e.format != "" {
+		e.Message = i18n.Sprintf(lang, e.format, e.args...)
+		return
+	}
+	e.Message = i18n.Translate(lang, e.Message)
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api/i18n"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitErrorResponseLocalise(t *testing.T) {
+	Convey("message is translated", t, func() {
+		m := NewErrorResponse(ErrorCodeInternalError, "there was a problem handling your request")
+		m.Localise(i18n.Welsh)
+		So(m.Message, ShouldEqual, "roedd problem wrth ymdrin â'ch cais")
+	})
+
+	Convey("message without a translation is unchanged", t, func() {
+		m := NewErrorResponse(ErrorCodeInternalError, "not in the catalogue")
+		m.Localise(i18n.Welsh)
+		So(m.Message, ShouldEqual, "not in the catalogue")
+	})
+
+	Convey("format of a formatted message is translated and its arguments are not", t, func() {
+		m := NewErrorResponsef(ErrorCodeInvalidRequestBody, "between 1 and %d company numbers must be supplied", 100)
+		So(m.Message, ShouldEqual, "between 1 and 100 company numbers must be supplied")
+
+		m.Localise(i18n.Welsh)
+		So(m.Message, ShouldEqual, "rhaid rhoi rhwng 1 a 100 o rifau cwmni")
+
+		m.Localise(i18n.English)
+		So(m.Message, ShouldEqual, "between 1 and 100 company numbers must be supplied")
+	})
+}
@@ -4,17 +4,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 )
 
+// Localisable is a response body with messages that can be translated into the language that the client would most
+// like them in
+type Localisable interface {
+	Localise(lang string)
+}
+
 // WriteJSON writes the interface as a json string with status of 200.
 func WriteJSON(w http.ResponseWriter, r *http.Request, data interface{}) {
 	WriteJSONWithStatus(w, r, data, http.StatusOK)
 }
 
-// WriteJSONWithStatus writes the interface as a json string with the supplied status.
+// WriteJSONWithStatus writes the interface as a json string with the supplied status. A Localisable body has its
+// messages translated into the language chosen from the Accept-Language header of the request, falling back to the
+// language stored for the resource the request is for.
 func WriteJSONWithStatus(w http.ResponseWriter, r *http.Request, data interface{}, status int) {
+	if l, ok := data.(Localisable); ok {
+		lang := i18n.FromRequest(r)
+		l.Localise(lang)
+		w.Header().Set("Content-Language", lang)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	err := json.NewEncoder(w).Encode(data)
@@ -33,3 +50,60 @@ func GetCompanyNumberFromVars(vars map[string]string) (string, error) {
 
 	return companyNumber, nil
 }
+
+// SetEtagHeader sets the ETag header on the response to the supplied etag.
+func SetEtagHeader(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", strconv.Quote(etag))
+}
+
+// IsNotModified returns true if the If-None-Match header of the request matches the supplied etag, meaning the client
+// already holds the current version of the resource.
+func IsNotModified(req *http.Request, etag string) bool {
+	return etagMatches(req.Header.Get("If-None-Match"), etag)
+}
+
+// IsPreconditionFailed returns true if the request has an If-Match header that does not match the supplied etag,
+// meaning the client's copy of the resource is out of date.
+func IsPreconditionFailed(req *http.Request, etag string) bool {
+	header := req.Header.Get("If-Match")
+	return header != "" && !etagMatches(header, etag)
+}
+
+// PreferRespondAsync is the preference a client sends in the Prefer header when it would rather not wait for a
+// request to be processed
+const PreferRespondAsync = "respond-async"
+
+// PrefersAsync returns true if the Prefer header of the request asks for an asynchronous response. Any other
+// preferences, and the parameters of each preference, are ignored.
+func PrefersAsync(req *http.Request) bool {
+	for _, header := range req.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			token := strings.TrimSpace(strings.SplitN(preference, ";", 2)[0])
+			if strings.EqualFold(token, PreferRespondAsync) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// etagMatches checks whether the supplied etag is in the comma separated list of etags taken from a conditional
+// request header. Weak etags are compared in the same way as strong etags.
+func etagMatches(header, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if strings.Trim(candidate, `"`) == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/i18n"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -32,6 +33,30 @@ func TestUnitWriteJSON(t *testing.T) {
 		So(w.Code, ShouldEqual, http.StatusOK)
 		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
 		So(w.Body.String(), ShouldEqual, "{\"self\":\"\"}\n")
+		So(w.Header().Get("Content-Language"), ShouldBeEmpty)
+	})
+
+	Convey("error messages are translated into the language of the request", t, func() {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Language", "cy")
+
+		WriteJSONWithStatus(w, r, NewErrorResponse(ErrorCodeInvalidRequestBody, "invalid request body"), http.StatusBadRequest)
+
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Header().Get("Content-Language"), ShouldEqual, "cy")
+		So(w.Body.String(), ShouldEqual, "{\"error_code\":\"INVALID_REQUEST_BODY\",\"message\":\"corff cais annilys\"}\n")
+	})
+
+	Convey("error messages are translated into the language stored for the resource when the request has none", t, func() {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(i18n.WithStoredLanguage(r.Context(), func() string { return i18n.Welsh }))
+
+		WriteJSONWithStatus(w, r, NewErrorResponse(ErrorCodeInvalidRequestBody, "invalid request body"), http.StatusBadRequest)
+
+		So(w.Header().Get("Content-Language"), ShouldEqual, "cy")
+		So(w.Body.String(), ShouldEqual, "{\"error_code\":\"INVALID_REQUEST_BODY\",\"message\":\"corff cais annilys\"}\n")
 	})
 }
 
@@ -52,3 +77,86 @@ func TestUnitGetCompanyNumber(t *testing.T) {
 		So(err.Error(), ShouldEqual, "company number not supplied")
 	})
 }
+
+func TestUnitIsNotModified(t *testing.T) {
+	Convey("No If-None-Match header", t, func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		So(IsNotModified(r, "etag"), ShouldBeFalse)
+	})
+
+	Convey("If-None-Match header matches etag", t, func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-None-Match", `"etag"`)
+		So(IsNotModified(r, "etag"), ShouldBeTrue)
+	})
+
+	Convey("If-None-Match header matches one of a list of etags", t, func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-None-Match", `"other", W/"etag"`)
+		So(IsNotModified(r, "etag"), ShouldBeTrue)
+	})
+
+	Convey("If-None-Match header does not match etag", t, func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-None-Match", `"other"`)
+		So(IsNotModified(r, "etag"), ShouldBeFalse)
+	})
+
+	Convey("If-None-Match wildcard matches any etag", t, func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-None-Match", "*")
+		So(IsNotModified(r, "etag"), ShouldBeTrue)
+	})
+}
+
+func TestUnitIsPreconditionFailed(t *testing.T) {
+	Convey("No If-Match header", t, func() {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		So(IsPreconditionFailed(r, "etag"), ShouldBeFalse)
+	})
+
+	Convey("If-Match header matches etag", t, func() {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		r.Header.Set("If-Match", `"etag"`)
+		So(IsPreconditionFailed(r, "etag"), ShouldBeFalse)
+	})
+
+	Convey("If-Match header does not match etag", t, func() {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		r.Header.Set("If-Match", `"other"`)
+		So(IsPreconditionFailed(r, "etag"), ShouldBeTrue)
+	})
+}
+
+func TestUnitSetEtagHeader(t *testing.T) {
+	Convey("Etag header is quoted", t, func() {
+		w := httptest.NewRecorder()
+		SetEtagHeader(w, "etag")
+		So(w.Header().Get("ETag"), ShouldEqual, `"etag"`)
+	})
+}
+
+func TestUnitPrefersAsync(t *testing.T) {
+	Convey("No Prefer header", t, func() {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		So(PrefersAsync(r), ShouldBeFalse)
+	})
+
+	Convey("Prefer header asks for an asynchronous response", t, func() {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		r.Header.Set("Prefer", "respond-async")
+		So(PrefersAsync(r), ShouldBeTrue)
+	})
+
+	Convey("Prefer header asks for an asynchronous response amongst other preferences", t, func() {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		r.Header.Set("Prefer", "return=minimal, Respond-Async; wait=10")
+		So(PrefersAsync(r), ShouldBeTrue)
+	})
+
+	Convey("Prefer header has other preferences", t, func() {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		r.Header.Set("Prefer", "return=minimal")
+		So(PrefersAsync(r), ShouldBeFalse)
+	})
+}
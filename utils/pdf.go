@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page size and the margin around its content, in points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// PDFDocument is a minimal PDF writer for plain text documents such as statements and receipts. Text is laid out top
+// to bottom in the standard Helvetica fonts, which every PDF reader has, so nothing needs to be embedded and no
+// external renderer is needed. Text with characters that Helvetica cannot show, such as Welsh company names, is
+// written in an embedded font instead. A new page is started when the current one is full.
+type PDFDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+	fonts map[string]*pdfEmbeddedFont
+}
+
+// NewPDFDocument creates a document with a single empty page
+func NewPDFDocument() *PDFDocument {
+	d := &PDFDocument{}
+	d.newPage()
+	return d
+}
+
+// AddText adds a line of text at the left margin
+func (d *PDFDocument) AddText(text string, fontSize float64, bold bool) {
+	d.AddRow([]string{text}, []float64{pdfPageWidth - 2*pdfMargin}, fontSize, bold)
+}
+
+// AddRow adds a line of text split into columns of the given widths. Text that is too long for its column is not
+// wrapped so callers should size the columns for their content.
+func (d *PDFDocument) AddRow(cells []string, columnWidths []float64, fontSize float64, bold bool) {
+	lineHeight := fontSize * 1.4
+	if d.y-lineHeight < pdfMargin {
+		d.newPage()
+	}
+	d.y -= lineHeight
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	x := pdfMargin
+	page := d.pages[len(d.pages)-1]
+	for i, cell := range cells {
+		switch {
+		case cell == "":
+		case isWinAnsiText(cell):
+			fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, fontSize, x, d.y, escapePDFText(cell))
+		default:
+			name, embedded := d.embeddedFont(bold)
+			fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", name, fontSize, x, d.y, embedded.encode(cell))
+		}
+		if i < len(columnWidths) {
+			x += columnWidths[i]
+		}
+	}
+}
+
+// AddSpace leaves a gap of the given height in points
+func (d *PDFDocument) AddSpace(height float64) {
+	d.y -= height
+}
+
+// embeddedFont returns the resource name and embedded font used for text that Helvetica cannot show, adding the font
+// to the document the first time it is needed
+func (d *PDFDocument) embeddedFont(bold bool) (string, *pdfEmbeddedFont) {
+	name, font := "F3", pdfUnicodeFont
+	if bold {
+		name, font = "F4", pdfUnicodeFontBold
+	}
+
+	if d.fonts == nil {
+		d.fonts = map[string]*pdfEmbeddedFont{}
+	}
+	if d.fonts[name] == nil {
+		d.fonts[name] = &pdfEmbeddedFont{font: font, glyphs: map[uint16]rune{}}
+	}
+	return name, d.fonts[name]
+}
+
+func (d *PDFDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// Write writes the document to w as a PDF 1.4 file
+func (d *PDFDocument) Write(w io.Writer) error {
+	out := &bytes.Buffer{}
+	var offsets []int
+
+	// objects are numbered from 1 in the order they are written, so their number is their position in offsets
+	writeObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// the catalog, page tree and fonts come first, followed by a page object and content stream for each page and then
+	// the objects of any embedded fonts
+	pageIDs := make([]string, len(d.pages))
+	for i := range d.pages {
+		pageIDs[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+
+	fontResources := "/F1 3 0 R /F2 4 0 R"
+	var fontObjects []string
+	for _, name := range []string{"F3", "F4"} {
+		if d.fonts[name] == nil {
+			continue
+		}
+		first := 5 + 2*len(d.pages) + len(fontObjects)
+		objects, err := d.fonts[name].objects(first)
+		if err != nil {
+			return err
+		}
+		fontResources += fmt.Sprintf(" /%s %d 0 R", name, first)
+		fontObjects = append(fontObjects, objects...)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageIDs, " "), len(d.pages)))
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, fontResources, 6+2*i))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+	for _, object := range fontObjects {
+		writeObject(object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// isWinAnsiText returns true if every character of the text is printable Latin-1, which Helvetica can show in
+// WinAnsiEncoding
+func isWinAnsiText(text string) bool {
+	for _, r := range text {
+		if (r < 0x20 || r >= 0x7f) && (r < 0xa0 || r > 0xff) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapePDFText encodes text as a PDF string in WinAnsiEncoding. Characters that cannot be encoded, which are
+// outside of Latin-1, are replaced with a question mark, so text is checked with isWinAnsiText first.
+func escapePDFText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,422 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	_ "embed" // the fonts are embedded in the binary so that PDFs can be written without any files on disk
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// DejaVu Sans is used for text that the standard Helvetica fonts cannot show, such as the Welsh ŵ and ŷ. It is
+// distributed under the licence in fonts/LICENSE.
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	dejaVuSans []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	dejaVuSansBold []byte
+
+	pdfUnicodeFont     = mustParseTrueTypeFont("DejaVuSans", dejaVuSans)
+	pdfUnicodeFontBold = mustParseTrueTypeFont("DejaVuSans-Bold", dejaVuSansBold)
+)
+
+// trueTypeTablesToEmbed are the tables a PDF reader needs to draw the glyphs of an embedded TrueType font. The
+// character map and names are not needed as glyphs are referred to by their index.
+var trueTypeTablesToEmbed = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// trueTypeFont is a TrueType font read far enough to look up and measure its glyphs and to embed a subset of them
+type trueTypeFont struct {
+	name       string
+	tables     map[string][]byte
+	unitsPerEm float64
+	numGlyphs  int
+	cmap       []byte
+	cmapFormat uint16
+}
+
+func mustParseTrueTypeFont(name string, data []byte) *trueTypeFont {
+	font, err := parseTrueTypeFont(name, data)
+	if err != nil {
+		panic(fmt.Sprintf("error parsing font [%s]: [%v]", name, err))
+	}
+	return font
+}
+
+// parseTrueTypeFont reads the tables of the font needed to look up and measure glyphs
+func parseTrueTypeFont(name string, data []byte) (*trueTypeFont, error) {
+	tables, err := readTrueTypeTables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	font := &trueTypeFont{name: name, tables: tables}
+	for _, tag := range []string{"cmap", "glyf", "head", "hhea", "hmtx", "loca", "maxp"} {
+		if _, ok := font.tables[tag]; !ok {
+			return nil, fmt.Errorf("table [%s] is missing", tag)
+		}
+	}
+
+	font.unitsPerEm = float64(binary.BigEndian.Uint16(font.tables["head"][18:]))
+	font.numGlyphs = int(binary.BigEndian.Uint16(font.tables["maxp"][4:]))
+
+	// use the Unicode character map, preferring the one that covers characters outside of the basic multilingual plane
+	cmap := font.tables["cmap"]
+	for i := 0; i < int(binary.BigEndian.Uint16(cmap[2:])); i++ {
+		record := 4 + 8*i
+		platform, encoding := binary.BigEndian.Uint16(cmap[record:]), binary.BigEndian.Uint16(cmap[record+2:])
+		subtable := cmap[binary.BigEndian.Uint32(cmap[record+4:]):]
+		format := binary.BigEndian.Uint16(subtable)
+		if platform == 3 && ((encoding == 10 && format == 12) || (encoding == 1 && format == 4 && font.cmap == nil)) {
+			font.cmap, font.cmapFormat = subtable, format
+		}
+	}
+	if font.cmap == nil {
+		return nil, fmt.Errorf("font has no Unicode character map")
+	}
+
+	return font, nil
+}
+
+// readTrueTypeTables reads the table directory of a TrueType font and returns its tables by tag
+func readTrueTypeTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("font is too short")
+	}
+
+	tables := map[string][]byte{}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		record := 12 + 16*i
+		if record+16 > len(data) {
+			return nil, fmt.Errorf("table directory is truncated")
+		}
+		tag := string(data[record : record+4])
+		offset := int(binary.BigEndian.Uint32(data[record+8:]))
+		length := int(binary.BigEndian.Uint32(data[record+12:]))
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("table [%s] is truncated", tag)
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+
+	return tables, nil
+}
+
+// glyphIndex returns the index of the glyph for the character, which is 0 if the font does not have one
+func (f *trueTypeFont) glyphIndex(r rune) uint16 {
+	c := uint32(r)
+	if f.cmapFormat == 12 {
+		for i := 0; i < int(binary.BigEndian.Uint32(f.cmap[12:])); i++ {
+			group := f.cmap[16+12*i:]
+			start, end := binary.BigEndian.Uint32(group), binary.BigEndian.Uint32(group[4:])
+			if c >= start && c <= end {
+				return uint16(binary.BigEndian.Uint32(group[8:]) + c - start)
+			}
+		}
+		return 0
+	}
+
+	if c > 0xffff {
+		return 0
+	}
+	segments := int(binary.BigEndian.Uint16(f.cmap[6:]) / 2)
+	for i := 0; i < segments; i++ {
+		end := uint32(binary.BigEndian.Uint16(f.cmap[14+2*i:]))
+		start := uint32(binary.BigEndian.Uint16(f.cmap[16+2*segments+2*i:]))
+		if c < start || c > end {
+			continue
+		}
+		delta := binary.BigEndian.Uint16(f.cmap[16+4*segments+2*i:])
+		rangeOffsetPosition := 16 + 6*segments + 2*i
+		rangeOffset := int(binary.BigEndian.Uint16(f.cmap[rangeOffsetPosition:]))
+		if rangeOffset == 0 {
+			return uint16(c) + delta
+		}
+		glyph := binary.BigEndian.Uint16(f.cmap[rangeOffsetPosition+rangeOffset+2*int(c-start):])
+		if glyph == 0 {
+			return 0
+		}
+		return glyph + delta
+	}
+	return 0
+}
+
+// advanceWidth returns the width of the glyph in thousandths of the font size, as PDF widths are given
+func (f *trueTypeFont) advanceWidth(glyph uint16) int {
+	metrics := int(binary.BigEndian.Uint16(f.tables["hhea"][34:]))
+	if int(glyph) >= metrics {
+		glyph = uint16(metrics - 1)
+	}
+	return f.scale(int16(binary.BigEndian.Uint16(f.tables["hmtx"][4*int(glyph):])))
+}
+
+// scale converts a measurement in font units to thousandths of the font size
+func (f *trueTypeFont) scale(value int16) int {
+	return int(float64(value) * 1000 / f.unitsPerEm)
+}
+
+// glyphData returns the outline of the glyph from the glyf table, which is empty for a glyph such as a space
+func (f *trueTypeFont) glyphData(glyph uint16) []byte {
+	loca := f.tables["loca"]
+	var start, end int
+	if binary.BigEndian.Uint16(f.tables["head"][50:]) == 0 {
+		start = 2 * int(binary.BigEndian.Uint16(loca[2*int(glyph):]))
+		end = 2 * int(binary.BigEndian.Uint16(loca[2*int(glyph)+2:]))
+	} else {
+		start = int(binary.BigEndian.Uint32(loca[4*int(glyph):]))
+		end = int(binary.BigEndian.Uint32(loca[4*int(glyph)+4:]))
+	}
+	return f.tables["glyf"][start:end]
+}
+
+// componentGlyphs returns the glyphs that a composite glyph, such as an accented letter, is built from
+func componentGlyphs(data []byte) []uint16 {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil
+	}
+
+	const (
+		argsAreWords    = 0x0001
+		haveScale       = 0x0008
+		moreComponents  = 0x0020
+		haveXAndYScale  = 0x0040
+		haveTwoByTwo    = 0x0080
+		componentHeader = 4
+	)
+
+	var components []uint16
+	for offset := 10; offset+componentHeader <= len(data); {
+		flags := binary.BigEndian.Uint16(data[offset:])
+		components = append(components, binary.BigEndian.Uint16(data[offset+2:]))
+		offset += componentHeader
+		if flags&argsAreWords != 0 {
+			offset += 4
+		} else {
+			offset += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			offset += 2
+		case flags&haveXAndYScale != 0:
+			offset += 4
+		case flags&haveTwoByTwo != 0:
+			offset += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return components
+}
+
+// subset returns a copy of the font with only the outlines of the glyphs used, and those they are built from. Glyphs
+// keep their index so that text can refer to glyphs by their index in the full font.
+func (f *trueTypeFont) subset(used map[uint16]rune) []byte {
+	keep := map[uint16]bool{0: true}
+	pending := []uint16{0}
+	for glyph := range used {
+		pending = append(pending, glyph)
+	}
+	for len(pending) > 0 {
+		glyph := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		keep[glyph] = true
+		for _, component := range componentGlyphs(f.glyphData(glyph)) {
+			if !keep[component] {
+				pending = append(pending, component)
+			}
+		}
+	}
+
+	// the outlines are written with long offsets, so the head table has to say so
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*(f.numGlyphs+1))
+	for glyph := 0; glyph < f.numGlyphs; glyph++ {
+		binary.BigEndian.PutUint32(loca[4*glyph:], uint32(glyf.Len()))
+		if keep[uint16(glyph)] {
+			glyf.Write(f.glyphData(uint16(glyph)))
+			for glyf.Len()%4 != 0 {
+				glyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*f.numGlyphs:], uint32(glyf.Len()))
+
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{"glyf": glyf.Bytes(), "head": head, "loca": loca}
+	for _, tag := range trueTypeTablesToEmbed {
+		if _, ok := tables[tag]; !ok && f.tables[tag] != nil {
+			tables[tag] = f.tables[tag]
+		}
+	}
+
+	return writeTrueTypeFont(tables)
+}
+
+// writeTrueTypeFont writes the tables as a TrueType font file, in tag order as the format requires
+func writeTrueTypeFont(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= len(tags) {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	var out bytes.Buffer
+	header := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(tags)))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(16*len(tags)-searchRange))
+
+	offset := len(header)
+	for i, tag := range tags {
+		table := tables[tag]
+		record := header[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], trueTypeChecksum(table))
+		binary.BigEndian.PutUint32(record[8:], uint32(offset))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+		offset += (len(table) + 3) &^ 3
+	}
+	out.Write(header)
+	for _, tag := range tags {
+		out.Write(tables[tag])
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+
+	// the checksum adjustment in the head table makes the checksum of the whole font come to a fixed value
+	font := out.Bytes()
+	headOffset := int(binary.BigEndian.Uint32(header[12+16*sort.SearchStrings(tags, "head")+8:]))
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xb1b0afba-trueTypeChecksum(font))
+
+	return font
+}
+
+// trueTypeChecksum is the sum of the data as big endian 32 bit integers, padded with zeros
+func trueTypeChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// pdfEmbeddedFont is a font embedded in a document along with the glyphs the document has used from it
+type pdfEmbeddedFont struct {
+	font   *trueTypeFont
+	glyphs map[uint16]rune
+}
+
+// encode returns the text as a PDF hex string of glyph indexes, recording the glyphs used. A character the font does
+// not have is replaced with a question mark.
+func (e *pdfEmbeddedFont) encode(text string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range text {
+		glyph := e.font.glyphIndex(r)
+		if glyph == 0 || r < 0x20 {
+			r = '?'
+			glyph = e.font.glyphIndex(r)
+		}
+		e.glyphs[glyph] = r
+		fmt.Fprintf(&b, "%04X", glyph)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// baseFont is the name of the embedded font. A subset is named with a tag made from the glyphs in it, so that
+// different subsets of the same font in one reader are not confused.
+func (e *pdfEmbeddedFont) baseFont(glyphs []uint16) string {
+	hash := fnv.New32a()
+	for _, glyph := range glyphs {
+		fmt.Fprintf(hash, "%d,", glyph)
+	}
+	sum := hash.Sum32()
+
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag) + "+" + e.font.name
+}
+
+// objects returns the bodies of the PDF objects that embed the font, numbered from first: the composite font, its
+// glyph font, the font descriptor, the font file and the map back to Unicode used to copy text out of the document
+func (e *pdfEmbeddedFont) objects(first int) ([]string, error) {
+	glyphs := make([]uint16, 0, len(e.glyphs))
+	for glyph := range e.glyphs {
+		glyphs = append(glyphs, glyph)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+	name := e.baseFont(glyphs)
+
+	var widths, toUnicode strings.Builder
+	for _, glyph := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", glyph, e.font.advanceWidth(glyph))
+		fmt.Fprintf(&toUnicode, "<%04X> <%s>\n", glyph, utf16Hex(e.glyphs[glyph]))
+	}
+
+	var fontFile bytes.Buffer
+	subset := e.font.subset(e.glyphs)
+	writer := zlib.NewWriter(&fontFile)
+	_, err := writer.Write(subset)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error compressing font [%s]: [%v]", e.font.name, err)
+	}
+
+	head, hhea := e.font.tables["head"], e.font.tables["hhea"]
+	value := func(table []byte, offset int) int {
+		return e.font.scale(int16(binary.BigEndian.Uint16(table[offset:])))
+	}
+	ascent, descent := value(hhea, 4), value(hhea, 6)
+
+	cmap := "/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" +
+		fmt.Sprintf("%d beginbfchar\n%sendbfchar\n", len(glyphs), toUnicode.String()) +
+		"endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n"
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			name, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+			name, first+2, strings.TrimSpace(widths.String())),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			name, value(head, 36), value(head, 38), value(head, 40), value(head, 42), ascent, descent, ascent, first+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", fontFile.Len(), len(subset), fontFile.String()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(cmap), cmap),
+	}, nil
+}
+
+// utf16Hex returns the character as hex UTF-16, which is how a ToUnicode map gives the text a glyph stands for
+func utf16Hex(r rune) string {
+	if r < 0x10000 {
+		return fmt.Sprintf("%04X", r)
+	}
+	r -= 0x10000
+	return fmt.Sprintf("%04X%04X", 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+}
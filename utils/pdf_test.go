@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitPDFDocument(t *testing.T) {
+	Convey("a document is written as a valid PDF", t, func() {
+		doc := NewPDFDocument()
+		doc.AddText("Statement (draft)", 16, true)
+		doc.AddRow([]string{"Outstanding", "£150.00"}, []float64{100, 100}, 10, false)
+
+		var out bytes.Buffer
+		err := doc.Write(&out)
+		So(err, ShouldBeNil)
+
+		pdf := out.String()
+		So(pdf, ShouldStartWith, "%PDF-1.4\n")
+		So(pdf, ShouldEndWith, "%%EOF\n")
+		So(pdf, ShouldContainSubstring, "(Statement \\(draft\\)) Tj")
+		So(pdf, ShouldContainSubstring, "(\\243150.00) Tj")
+		So(pdf, ShouldContainSubstring, "/Count 1")
+
+		// every object in the cross reference table must start at the offset it is listed at
+		xref := regexp.MustCompile(`(?m)^(\d{10}) 00000 n $`).FindAllStringSubmatch(pdf, -1)
+		So(xref, ShouldHaveLength, 6)
+		for i, entry := range xref {
+			offset, _ := strconv.Atoi(entry[1])
+			So(strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj", i+1)), ShouldBeTrue)
+		}
+	})
+
+	Convey("a new page is started when the current one is full", t, func() {
+		doc := NewPDFDocument()
+		for i := 0; i < 100; i++ {
+			doc.AddText(fmt.Sprintf("line %d", i), 10, false)
+		}
+
+		var out bytes.Buffer
+		So(doc.Write(&out), ShouldBeNil)
+		So(out.String(), ShouldContainSubstring, "/Count 2")
+	})
+
+	Convey("characters outside of Latin-1 are replaced", t, func() {
+		So(escapePDFText("Caerdydd ŵ \\"), ShouldEqual, "Caerdydd ? \\\\")
+	})
+
+	Convey("text that Helvetica cannot show is written in an embedded font", t, func() {
+		doc := NewPDFDocument()
+		doc.AddText("Cwmni Tŷ Gŵyr Cyf", 12, true)
+		doc.AddText("Cwmni Tŷ Gŵyr Cyf", 10, false)
+		doc.AddText("Total outstanding £150.00", 10, false)
+
+		var out bytes.Buffer
+		So(doc.Write(&out), ShouldBeNil)
+
+		pdf := out.String()
+		So(pdf, ShouldContainSubstring, "(Total outstanding \\243150.00) Tj")
+		So(pdf, ShouldContainSubstring, "/F1 3 0 R /F2 4 0 R /F3 7 0 R /F4 12 0 R")
+		So(pdf, ShouldContainSubstring, "+DejaVuSans /Encoding /Identity-H")
+		So(pdf, ShouldContainSubstring, "+DejaVuSans-Bold /Encoding /Identity-H")
+
+		glyph := pdfUnicodeFont.glyphIndex('ŵ')
+		So(glyph, ShouldNotEqual, 0)
+		So(pdf, ShouldContainSubstring, fmt.Sprintf("%04X", glyph))
+		So(pdf, ShouldContainSubstring, fmt.Sprintf("<%04X> <0175>", glyph))
+
+		xref := regexp.MustCompile(`(?m)^(\d{10}) 00000 n $`).FindAllStringSubmatch(pdf, -1)
+		So(xref, ShouldHaveLength, 16)
+		for i, entry := range xref {
+			offset, _ := strconv.Atoi(entry[1])
+			So(strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj", i+1)), ShouldBeTrue)
+		}
+	})
+
+	Convey("an embedded font only has the outlines of the glyphs used and those they are built from", t, func() {
+		used := map[uint16]rune{pdfUnicodeFont.glyphIndex('ŵ'): 'ŵ'}
+		data := pdfUnicodeFont.subset(used)
+		So(trueTypeChecksum(data), ShouldEqual, 0xb1b0afba)
+
+		tables, err := readTrueTypeTables(data)
+		So(err, ShouldBeNil)
+		_, hasCmap := tables["cmap"]
+		So(hasCmap, ShouldBeFalse)
+		subset := &trueTypeFont{tables: tables}
+
+		glyph := pdfUnicodeFont.glyphIndex('ŵ')
+		So(subset.glyphData(glyph), ShouldResemble, pdfUnicodeFont.glyphData(glyph))
+		for _, component := range componentGlyphs(pdfUnicodeFont.glyphData(glyph)) {
+			So(subset.glyphData(component), ShouldNotBeEmpty)
+		}
+		So(subset.glyphData(pdfUnicodeFont.glyphIndex('x')), ShouldBeEmpty)
+		So(len(subset.tables["glyf"]), ShouldBeLessThan, len(pdfUnicodeFont.tables["glyf"])/100)
+	})
+
+	Convey("characters are looked up in the font", t, func() {
+		So(pdfUnicodeFont.glyphIndex('A'), ShouldNotEqual, 0)
+		So(pdfUnicodeFont.glyphIndex('ŷ'), ShouldNotEqual, pdfUnicodeFont.glyphIndex('ŵ'))
+		So(pdfUnicodeFont.glyphIndex(0x10ffff), ShouldEqual, 0)
+		So(pdfUnicodeFont.advanceWidth(pdfUnicodeFont.glyphIndex('ŵ')), ShouldBeGreaterThan, 0)
+	})
+}
@@ -3,10 +3,13 @@ package utils
 import (
 	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/rand"
-	"strconv"
 	"time"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // GenerateReferenceNumber produces a random reference number in the format of [A-Z]{2}[0-9]{8}
@@ -21,20 +24,30 @@ func GenerateReferenceNumber() string {
 	return string(b) + fmt.Sprintf("%08d", rand.Intn(99999999))
 }
 
-// GenerateEtag generates a random etag which is generated on every write action
-func GenerateEtag() (string, error) {
-	// Get a random number and the time in seconds and milliseconds
-	timeNow := time.Now()
-	rand.Seed(timeNow.UTC().UnixNano())
-	randomNumber := fmt.Sprintf("%07d", rand.Intn(9999999))
-	timeInMillis := strconv.FormatInt(timeNow.UnixNano()/int64(time.Millisecond), 10)
-	timeInSeconds := strconv.FormatInt(timeNow.UnixNano()/int64(time.Second), 10)
+// GenerateEtag generates an etag from the supplied content. The same content will always produce the same etag so the
+// etag only changes when the content does.
+func GenerateEtag(content interface{}) (string, error) {
+	contentBytes, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling etag content: [%s]", err)
+	}
 	// Calculate a SHA-512 truncated digest
 	shaDigest := sha512.New512_224()
-	_, err := shaDigest.Write([]byte(randomNumber + timeInMillis + timeInSeconds))
+	_, err = shaDigest.Write(contentBytes)
 	if err != nil {
 		return "", fmt.Errorf("error writing sha digest: [%s]", err)
 	}
 	sha1Hash := hex.EncodeToString(shaDigest.Sum(nil))
 	return sha1Hash, nil
 }
+
+// GeneratePayableResourceEtag generates the etag for a payable resource from the content of the stored document. The
+// database ID and the existing etag are excluded so that the etag changes exactly when the stored data does, and the
+// E5 command error is excluded as it is not returned to clients and is saved without changing the etag.
+func GeneratePayableResourceEtag(dao *models.PayableResourceDao) (string, error) {
+	content := *dao
+	content.ID = primitive.NilObjectID
+	content.Data.Etag = ""
+	content.E5CommandError = ""
+	return GenerateEtag(content)
+}
@@ -3,6 +3,9 @@ package utils
 import (
 	"testing"
 
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -35,8 +38,58 @@ func TestUnitGenerateReferenceNumber(t *testing.T) {
 
 func TestUnitGenerateEtag(t *testing.T) {
 	Convey("Generate Etag", t, func() {
-		etag, err := GenerateEtag()
+		etag, err := GenerateEtag(map[string]string{"id": "00378420"})
 		So(len(etag), ShouldEqual, 56)
 		So(err, ShouldBeNil)
 	})
+
+	Convey("Same content generates the same etag", t, func() {
+		etag1, _ := GenerateEtag(map[string]string{"id": "00378420"})
+		etag2, _ := GenerateEtag(map[string]string{"id": "00378420"})
+		So(etag1, ShouldEqual, etag2)
+	})
+
+	Convey("Different content generates a different etag", t, func() {
+		etag1, _ := GenerateEtag(map[string]string{"id": "00378420"})
+		etag2, _ := GenerateEtag(map[string]string{"id": "00378421"})
+		So(etag1, ShouldNotEqual, etag2)
+	})
+
+	Convey("Error when content cannot be marshalled", t, func() {
+		etag, err := GenerateEtag(make(chan int))
+		So(etag, ShouldBeEmpty)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestUnitGeneratePayableResourceEtag(t *testing.T) {
+	Convey("Etag ignores the database ID and existing etag", t, func() {
+		dao := &models.PayableResourceDao{CompanyNumber: "10000024", Reference: "AB12345678"}
+		etag1, err := GeneratePayableResourceEtag(dao)
+		So(err, ShouldBeNil)
+
+		dao.ID = primitive.NewObjectID()
+		dao.Data.Etag = etag1
+		etag2, _ := GeneratePayableResourceEtag(dao)
+		So(etag2, ShouldEqual, etag1)
+		So(dao.Data.Etag, ShouldEqual, etag1)
+	})
+
+	Convey("Etag changes when the stored data changes", t, func() {
+		dao := &models.PayableResourceDao{CompanyNumber: "10000024", Reference: "AB12345678"}
+		etag1, _ := GeneratePayableResourceEtag(dao)
+
+		dao.Data.Payment.Status = "paid"
+		etag2, _ := GeneratePayableResourceEtag(dao)
+		So(etag2, ShouldNotEqual, etag1)
+	})
+
+	Convey("Etag ignores the E5 command error", t, func() {
+		dao := &models.PayableResourceDao{CompanyNumber: "10000024", Reference: "AB12345678"}
+		etag1, _ := GeneratePayableResourceEtag(dao)
+
+		dao.E5CommandError = "authorise"
+		etag2, _ := GeneratePayableResourceEtag(dao)
+		So(etag2, ShouldEqual, etag1)
+	})
 }
@@ -0,0 +1,64 @@
+package validators
+
+import "errors"
+
+// The checks made on the transactions in a request that are not payability rules, as reported in a CheckFailure
+const (
+	CheckUniqueTransaction = "unique-transaction"
+	CheckTransactionExists = "transaction-exists"
+)
+
+// CheckFailure is a check that a request failed, reported when the request is validated without being acted on. The
+// check is the payability rule id for a rule that failed.
+type CheckFailure struct {
+	Check         string `json:"check"`
+	ErrorCode     string `json:"error_code"`
+	Message       string `json:"message"`
+	TransactionID string `json:"transaction_id,omitempty"`
+}
+
+// TransactionError is returned when a transaction in a request cannot be paid for because of a check that is not a
+// payability rule, e.g. because it does not exist in E5. It unwraps to the validator error for the check.
+type TransactionError struct {
+	TransactionID string
+	err           error
+}
+
+func (e *TransactionError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the validator error for the check that failed
+func (e *TransactionError) Unwrap() error {
+	return e.err
+}
+
+// NewCheckFailure reports the error from a check that failed. The check and error code are empty if the error is not
+// from one of the checks made on the transactions.
+func NewCheckFailure(err error) CheckFailure {
+	failure := CheckFailure{Message: err.Error()}
+
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
+		failure.Check = ruleErr.RuleID
+		failure.ErrorCode = ruleErr.ErrorCode
+		failure.TransactionID = ruleErr.TransactionID
+		return failure
+	}
+
+	var txErr *TransactionError
+	if errors.As(err, &txErr) {
+		failure.TransactionID = txErr.TransactionID
+	}
+
+	switch {
+	case errors.Is(err, ErrDuplicateTransaction):
+		failure.Check = CheckUniqueTransaction
+		failure.ErrorCode = "DUPLICATE_TRANSACTION"
+	case errors.Is(err, ErrTransactionDoesNotExist):
+		failure.Check = CheckTransactionExists
+		failure.ErrorCode = "TRANSACTION_NOT_FOUND"
+	}
+
+	return failure
+}
@@ -0,0 +1,36 @@
+package validators
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitNewCheckFailure(t *testing.T) {
+	Convey("a failed payability rule is reported with the rule's id, error code and transaction", t, func() {
+		err := &RuleError{RuleID: "not-dca", ErrorCode: "WITH_DCA", Message: "with a DCA", TransactionID: "00378420", err: ErrTransactionDCA}
+
+		So(NewCheckFailure(err), ShouldResemble, CheckFailure{
+			Check:         "not-dca",
+			ErrorCode:     "WITH_DCA",
+			Message:       "with a DCA",
+			TransactionID: "00378420",
+		})
+	})
+
+	Convey("a transaction that does not exist is reported with the transaction", t, func() {
+		err := &TransactionError{TransactionID: "123", err: ErrTransactionDoesNotExist}
+
+		So(NewCheckFailure(err), ShouldResemble, CheckFailure{
+			Check:         CheckTransactionExists,
+			ErrorCode:     "TRANSACTION_NOT_FOUND",
+			Message:       ErrTransactionDoesNotExist.Error(),
+			TransactionID: "123",
+		})
+	})
+
+	Convey("an error that is not from a check has no check or error code", t, func() {
+		So(NewCheckFailure(errors.New("e5 is down")), ShouldResemble, CheckFailure{Message: "e5 is down"})
+	})
+}
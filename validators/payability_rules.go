@@ -0,0 +1,295 @@
+package validators
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/companieshouse/chs.go/log"
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	"gopkg.in/go-playground/validator.v9"
+	"gopkg.in/yaml.v2"
+)
+
+// Rule is a single payability rule from the rules yaml file. The check itself is identified by the rule id, and the
+// rule can be switched off, its parameters set and its error code and message changed without a code change.
+type Rule struct {
+	ID         string         `yaml:"id"         validate:"required"`
+	Enabled    bool           `yaml:"enabled"`
+	ErrorCode  string         `yaml:"error_code" validate:"required"`
+	Message    string         `yaml:"message"    validate:"required"`
+	Parameters RuleParameters `yaml:"parameters"`
+}
+
+// RuleParameters change what a rule allows without switching it off. Only the parameters that a rule's check reads can
+// be set on it.
+type RuleParameters struct {
+	// AllowPartPayments allows less than the outstanding amount of a transaction to be paid off it
+	AllowPartPayments bool `yaml:"allow_part_payments"`
+	// MinimumAmount is the smallest amount that can be paid off a transaction without paying it off in full
+	MinimumAmount float64 `yaml:"minimum_amount" validate:"gte=0"`
+}
+
+// The names of the rule parameters, as they are set in the rules yaml file
+const (
+	paramAllowPartPayments = "allow_part_payments"
+	paramMinimumAmount     = "minimum_amount"
+)
+
+// set returns the names of the parameters that have been set
+func (p RuleParameters) set() []string {
+	var names []string
+	if p.AllowPartPayments {
+		names = append(names, paramAllowPartPayments)
+	}
+	if p.MinimumAmount != 0 {
+		names = append(names, paramMinimumAmount)
+	}
+	return names
+}
+
+// RuleSet is the ordered list of payability rules. Rules are evaluated in the order they are listed and evaluation stops
+// at the first rule that fails.
+type RuleSet struct {
+	Description string `yaml:"description"`
+	Rules       []Rule `yaml:"rules"`
+}
+
+// RuleError is returned when a transaction fails a payability rule. It unwraps to the validator error for the rule,
+// e.g. ErrTransactionDCA, whatever message has been configured.
+type RuleError struct {
+	RuleID        string
+	ErrorCode     string
+	Message       string
+	TransactionID string
+	err           error
+}
+
+func (e *RuleError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the validator error for the rule that failed
+func (e *RuleError) Unwrap() error {
+	return e.err
+}
+
+// ruleInput is what a rule is evaluated against: the transaction, the amount being paid off it and every transaction
+// the company has in E5
+type ruleInput struct {
+	transaction  models.TransactionListItem
+	amount       float64
+	transactions []models.TransactionListItem
+}
+
+// ruleCheck is the implementation of a rule, along with the names of the parameters it reads. Company rules only look
+// at the company's transactions, so are evaluated once before any of the transactions being paid for.
+type ruleCheck struct {
+	passes     func(in ruleInput, params RuleParameters) bool
+	err        error
+	company    bool
+	parameters []string
+}
+
+// ruleChecks are the implementations of the rules that can be listed in the rules yaml file
+var ruleChecks = map[string]ruleCheck{
+	"single-penalty": {
+		passes:  func(in ruleInput, _ RuleParameters) bool { return countPenaltyTransactions(in.transactions) <= 1 },
+		err:     ErrMultiplePenalties,
+		company: true,
+	},
+	"not-part-paid": {
+		passes: func(in ruleInput, _ RuleParameters) bool { return !in.transaction.IsPartPaid() },
+		err:    ErrTransactionIsPartPaid,
+	},
+	"not-paid": {
+		passes: func(in ruleInput, _ RuleParameters) bool { return !in.transaction.IsPaid },
+		err:    ErrTransactionIsPaid,
+	},
+	"is-penalty": {
+		passes: func(in ruleInput, _ RuleParameters) bool { return in.transaction.Type == "penalty" },
+		err:    ErrTransactionNotPayable,
+	},
+	"full-amount": {
+		passes: func(in ruleInput, params RuleParameters) bool {
+			return in.transaction.Outstanding == in.amount || (in.amount < in.transaction.Outstanding && params.AllowPartPayments)
+		},
+		err:        ErrTransactionAmountMismatch,
+		parameters: []string{paramAllowPartPayments},
+	},
+	"part-payment-limits": {
+		passes: func(in ruleInput, params RuleParameters) bool {
+			return in.transaction.Outstanding == in.amount || (in.amount > 0 && in.amount >= params.MinimumAmount)
+		},
+		err:        ErrPartPaymentOutsideLimits,
+		parameters: []string{paramMinimumAmount},
+	},
+	"not-dca": {
+		passes: func(in ruleInput, _ RuleParameters) bool { return !in.transaction.IsDCA },
+		err:    ErrTransactionDCA,
+	},
+}
+
+// LoadRuleSet reads the payability rules from the yaml file at the given path. Every rule that has been implemented
+// must be listed, so that a rule cannot be switched off by leaving it out.
+func LoadRuleSet(path string) (*RuleSet, error) {
+	yamlFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading payability rules yaml file: [%v]", err)
+	}
+
+	// a misspelt parameter is an error rather than a rule that silently allows less than intended
+	ruleSet := RuleSet{}
+	err = yaml.UnmarshalStrict(yamlFile, &ruleSet)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling payability rules yaml file: [%v]", err)
+	}
+
+	v := validator.New()
+	listed := map[string]bool{}
+	for _, rule := range ruleSet.Rules {
+		err = v.Struct(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payability rule [%s]: [%v]", rule.ID, err)
+		}
+		check, ok := ruleChecks[rule.ID]
+		if !ok {
+			return nil, fmt.Errorf("unknown payability rule [%s]", rule.ID)
+		}
+		for _, name := range rule.Parameters.set() {
+			if !contains(check.parameters, name) {
+				return nil, fmt.Errorf("payability rule [%s] does not have the parameter [%s]", rule.ID, name)
+			}
+		}
+		if listed[rule.ID] {
+			return nil, fmt.Errorf("payability rule [%s] is listed more than once", rule.ID)
+		}
+		listed[rule.ID] = true
+	}
+
+	for id := range ruleChecks {
+		if !listed[id] {
+			return nil, fmt.Errorf("payability rule [%s] is missing", id)
+		}
+	}
+
+	return &ruleSet, nil
+}
+
+// evaluateCompanyRules evaluates the enabled company rules against the company's transactions
+func (r *RuleSet) evaluateCompanyRules(in ruleInput, data log.Data) error {
+	return firstFailure(r.evaluate(in, data, true, false))
+}
+
+// evaluateTransactionRules evaluates the enabled transaction rules against a single transaction
+func (r *RuleSet) evaluateTransactionRules(in ruleInput, data log.Data) error {
+	return firstFailure(r.evaluate(in, data, false, false))
+}
+
+// evaluate evaluates the enabled company or transaction rules, returning the rules that fail. Evaluation stops at the
+// first rule that fails unless every failure has been asked for.
+func (r *RuleSet) evaluate(in ruleInput, data log.Data, company bool, all bool) []error {
+	var failures []error
+	for _, rule := range r.Rules {
+		check := ruleChecks[rule.ID]
+		if check.company != company {
+			continue
+		}
+
+		ruleData := log.Data{"rule_id": rule.ID}
+		for k, v := range data {
+			ruleData[k] = v
+		}
+
+		if !rule.Enabled {
+			log.Debug("payability rule is disabled", ruleData)
+			continue
+		}
+
+		if check.passes(in, rule.Parameters) {
+			log.Debug("payability rule passed", ruleData)
+			continue
+		}
+
+		ruleData["error_code"] = rule.ErrorCode
+		if !company {
+			ruleData["attempted_amount"] = fmt.Sprintf("%f", in.amount)
+			ruleData["outstanding_amount"] = fmt.Sprintf("%f", in.transaction.Outstanding)
+		}
+		log.Info("payability rule failed: "+rule.Message, ruleData)
+
+		failures = append(failures, &RuleError{
+			RuleID:        rule.ID,
+			ErrorCode:     rule.ErrorCode,
+			Message:       rule.Message,
+			TransactionID: in.transaction.ID,
+			err:           check.err,
+		})
+		if !all {
+			break
+		}
+	}
+
+	return failures
+}
+
+// contains returns true if the name is one of the names
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// firstFailure returns the first of the failures, or nil if there are none
+func firstFailure(failures []error) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return failures[0]
+}
+
+// schemeRuleSets are the payability rules of each penalty scheme, which are loaded once and not read again
+var (
+	schemeRuleSets    = map[*config.PenaltyScheme]*RuleSet{}
+	schemeRuleSetsMtx sync.RWMutex
+)
+
+// LoadSchemeRuleSets loads and validates the payability rules of every penalty scheme, so that a rules file that
+// cannot be used stops the service from starting rather than failing every payment
+func LoadSchemeRuleSets(schemes []*config.PenaltyScheme) error {
+	for _, scheme := range schemes {
+		_, err := loadSchemeRuleSet(scheme)
+		if err != nil {
+			return fmt.Errorf("error loading payability rules for penalty scheme [%s]: [%v]", scheme.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// loadSchemeRuleSet returns the payability rules for the penalty scheme, reading them the first time they are needed
+// if they were not loaded at startup
+func loadSchemeRuleSet(scheme *config.PenaltyScheme) (*RuleSet, error) {
+	schemeRuleSetsMtx.RLock()
+	ruleSet, ok := schemeRuleSets[scheme]
+	schemeRuleSetsMtx.RUnlock()
+	if ok {
+		return ruleSet, nil
+	}
+
+	ruleSet, err := LoadRuleSet(scheme.PayabilityRulesFile)
+	if err != nil {
+		log.Error(err, log.Data{"penalty_scheme": scheme.Name})
+		return nil, err
+	}
+
+	schemeRuleSetsMtx.Lock()
+	schemeRuleSets[scheme] = ruleSet
+	schemeRuleSetsMtx.Unlock()
+
+	return ruleSet, nil
+}
@@ -0,0 +1,207 @@
+package validators
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testRules = `
+rules:
+  - id: single-penalty
+    enabled: true
+    error_code: MULTIPLE_PENALTIES
+    message: the company has more than one outstanding penalty
+  - id: not-part-paid
+    enabled: false
+    error_code: PART_PAID
+    message: the transaction is already part paid
+  - id: not-paid
+    enabled: true
+    error_code: ALREADY_PAID
+    message: this transaction is already paid
+  - id: is-penalty
+    enabled: true
+    error_code: NOT_A_PENALTY
+    message: you cannot pay for this type of transaction
+  - id: full-amount
+    enabled: true
+    error_code: AMOUNT_MISMATCH
+    message: the amount must be the outstanding amount of the transaction, or less when part payments are allowed
+  - id: part-payment-limits
+    enabled: true
+    error_code: PART_PAYMENT_LIMITS
+    message: the amount is outside the limits for part paying the transaction
+  - id: not-dca
+    enabled: true
+    error_code: WITH_DCA
+    message: this penalty is being collected by a debt collection agency
+`
+
+func writeRulesFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "payability-rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "payability_rules.yml")
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUnitLoadRuleSet(t *testing.T) {
+	Convey("the rules shipped with the service are valid", t, func() {
+		ruleSet, err := LoadRuleSet("../assets/payability_rules.yml")
+		So(err, ShouldBeNil)
+		So(ruleSet.Rules, ShouldHaveLength, len(ruleChecks))
+		for _, rule := range ruleSet.Rules {
+			So(rule.Enabled, ShouldBeTrue)
+		}
+	})
+
+	Convey("error when the file does not exist", t, func() {
+		ruleSet, err := LoadRuleSet("does_not_exist.yml")
+		So(ruleSet, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("error when a rule is not implemented", t, func() {
+		path := writeRulesFile(t, testRules+"  - id: unknown\n    enabled: true\n    error_code: UNKNOWN\n    message: unknown\n")
+		defer os.RemoveAll(filepath.Dir(path))
+
+		ruleSet, err := LoadRuleSet(path)
+		So(ruleSet, ShouldBeNil)
+		So(err.Error(), ShouldEqual, "unknown payability rule [unknown]")
+	})
+
+	Convey("error when a rule is missing", t, func() {
+		path := writeRulesFile(t, strings.Split(testRules, "  - id: not-dca")[0])
+		defer os.RemoveAll(filepath.Dir(path))
+
+		ruleSet, err := LoadRuleSet(path)
+		So(ruleSet, ShouldBeNil)
+		So(err.Error(), ShouldEqual, "payability rule [not-dca] is missing")
+	})
+
+	Convey("the parameters of a rule are read", t, func() {
+		path := writeRulesFile(t, strings.Replace(testRules, "limits for part paying the transaction\n", "limits for part paying the transaction\n    parameters:\n      minimum_amount: 25\n", 1))
+		defer os.RemoveAll(filepath.Dir(path))
+
+		ruleSet, err := LoadRuleSet(path)
+		So(err, ShouldBeNil)
+		So(ruleSet.Rules[5].Parameters.MinimumAmount, ShouldEqual, 25)
+	})
+
+	Convey("error when a rule has a parameter its check does not read", t, func() {
+		path := writeRulesFile(t, strings.Replace(testRules, "more than one outstanding penalty\n", "more than one outstanding penalty\n    parameters:\n      allow_part_payments: true\n", 1))
+		defer os.RemoveAll(filepath.Dir(path))
+
+		ruleSet, err := LoadRuleSet(path)
+		So(ruleSet, ShouldBeNil)
+		So(err.Error(), ShouldEqual, "payability rule [single-penalty] does not have the parameter [allow_part_payments]")
+	})
+
+	Convey("error when a parameter is not known", t, func() {
+		path := writeRulesFile(t, strings.Replace(testRules, "limits for part paying the transaction\n", "limits for part paying the transaction\n    parameters:\n      minimum: 25\n", 1))
+		defer os.RemoveAll(filepath.Dir(path))
+
+		ruleSet, err := LoadRuleSet(path)
+		So(ruleSet, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("error when a rule has no message", t, func() {
+		path := writeRulesFile(t, "rules:\n  - id: not-dca\n    enabled: true\n    error_code: WITH_DCA\n")
+		defer os.RemoveAll(filepath.Dir(path))
+
+		ruleSet, err := LoadRuleSet(path)
+		So(ruleSet, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestUnitRuleSetEvaluate(t *testing.T) {
+	path := writeRulesFile(t, testRules)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	ruleSet, err := LoadRuleSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	penalty := models.TransactionListItem{ID: "00378420", Type: "penalty", OriginalAmount: 150, Outstanding: 150}
+
+	Convey("a disabled rule is not evaluated", t, func() {
+		partPaid := penalty
+		partPaid.Outstanding = 50
+
+		err := ruleSet.evaluateTransactionRules(ruleInput{transaction: partPaid, amount: 50}, nil)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("the failing rule's error code and message are returned", t, func() {
+		dca := penalty
+		dca.IsDCA = true
+
+		err := ruleSet.evaluateTransactionRules(ruleInput{transaction: dca, amount: 150}, nil)
+
+		var ruleErr *RuleError
+		So(errors.As(err, &ruleErr), ShouldBeTrue)
+		So(ruleErr.RuleID, ShouldEqual, "not-dca")
+		So(ruleErr.ErrorCode, ShouldEqual, "WITH_DCA")
+		So(ruleErr.TransactionID, ShouldEqual, "00378420")
+		So(err.Error(), ShouldEqual, "this penalty is being collected by a debt collection agency")
+		So(errors.Is(err, ErrTransactionDCA), ShouldBeTrue)
+	})
+
+	Convey("company rules are only evaluated as company rules", t, func() {
+		other := models.TransactionListItem{ID: "00378421", Type: "penalty", OriginalAmount: 150, Outstanding: 150}
+		in := ruleInput{transaction: penalty, amount: 150, transactions: []models.TransactionListItem{penalty, other}}
+
+		So(ruleSet.evaluateTransactionRules(in, nil), ShouldBeNil)
+		So(errors.Is(ruleSet.evaluateCompanyRules(in, nil), ErrMultiplePenalties), ShouldBeTrue)
+	})
+
+	Convey("a disabled rule applies to the scheme using the rules file", t, func() {
+		scheme := &config.PenaltyScheme{Name: "late-filing", PayabilityRulesFile: path}
+		partPaid := penalty
+		partPaid.Outstanding = 50
+
+		err := TransactionIsPayable("10000024", partPaid, []models.TransactionListItem{partPaid}, scheme)
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestUnitLoadSchemeRuleSets(t *testing.T) {
+	Convey("the rules of every scheme are loaded once", t, func() {
+		path := writeRulesFile(t, testRules)
+		defer os.RemoveAll(filepath.Dir(path))
+		scheme := &config.PenaltyScheme{Name: "late-filing", PayabilityRulesFile: path}
+
+		So(LoadSchemeRuleSets([]*config.PenaltyScheme{scheme}), ShouldBeNil)
+		So(os.Remove(path), ShouldBeNil)
+
+		ruleSet, err := loadSchemeRuleSet(scheme)
+		So(err, ShouldBeNil)
+		So(ruleSet.Rules, ShouldHaveLength, len(ruleChecks))
+	})
+
+	Convey("a scheme with invalid rules stops the load", t, func() {
+		path := writeRulesFile(t, "rules:\n  - id: not-dca\n    enabled: true\n    error_code: WITH_DCA\n")
+		defer os.RemoveAll(filepath.Dir(path))
+		scheme := &config.PenaltyScheme{Name: "late-filing", PayabilityRulesFile: path}
+
+		err := LoadSchemeRuleSets([]*config.PenaltyScheme{scheme})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "late-filing")
+	})
+}
@@ -2,69 +2,121 @@ package validators
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/companieshouse/chs.go/log"
 	"github.com/companieshouse/lfp-pay-api-core/models"
+	"github.com/companieshouse/lfp-pay-api/config"
 	"github.com/companieshouse/lfp-pay-api/service"
 )
 
+// The validator errors for each payability rule. A RuleError unwraps to one of these, so they can still be compared
+// against with errors.Is when a rule's message has been changed in the rules yaml file.
 var (
 	ErrTransactionDoesNotExist   = errors.New("invalid transaction")
 	ErrTransactionNotPayable     = errors.New("you cannot pay for this type of transaction")
 	ErrTransactionDCA            = errors.New("the transaction is with a debt collecting agency")
 	ErrTransactionIsPaid         = errors.New("this transaction is already paid")
 	ErrTransactionIsPartPaid     = errors.New("the transaction is already part paid")
-	ErrTransactionAmountMismatch = errors.New("you can only pay off the full amount of the transaction")
+	ErrTransactionAmountMismatch = errors.New("the amount must be the outstanding amount of the transaction, or less when part payments are allowed")
 	ErrMultiplePenalties         = errors.New("the company has more than one outstanding penalty")
+	ErrDuplicateTransaction      = errors.New("the same transaction cannot be paid for more than once")
+	ErrPartPaymentOutsideLimits  = errors.New("the amount is outside the limits for part paying the transaction")
 )
 
-// TransactionsArePayable validator will verify the transaction in a request do exist for the company. It will also update the
-// type and made up date fields to match what is in E5.
-func TransactionsArePayable(companyNumber string, txs []models.TransactionItem) ([]models.TransactionItem, error) {
-	response, _, err := service.GetPenalties(companyNumber)
+// TransactionsArePayable validator will verify the transaction in a request do exist for the company and pass the
+// scheme's payability rules. It will also update the type and made up date fields to match what is in E5.
+func TransactionsArePayable(companyNumber string, txs []models.TransactionItem, scheme *config.PenaltyScheme) ([]models.TransactionItem, error) {
+	validTxs, failures, err := checkTransactions(companyNumber, txs, scheme, false)
 	if err != nil {
-		log.Error(err)
 		return nil, err
 	}
+	if len(failures) > 0 {
+		return nil, failures[0]
+	}
+
+	return validTxs, nil
+}
+
+// CheckTransactionsArePayable makes the same checks as TransactionsArePayable without stopping at the first that
+// fails, so that every reason the transactions cannot be paid for can be reported. The error is only returned when
+// the checks cannot be made.
+func CheckTransactionsArePayable(companyNumber string, txs []models.TransactionItem, scheme *config.PenaltyScheme) ([]models.TransactionItem, []CheckFailure, error) {
+	validTxs, failures, err := checkTransactions(companyNumber, txs, scheme, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	checkFailures := make([]CheckFailure, 0, len(failures))
+	for _, failure := range failures {
+		checkFailures = append(checkFailures, NewCheckFailure(failure))
+	}
+
+	return validTxs, checkFailures, nil
+}
+
+// checkTransactions checks the transactions against E5 and the payability rules, returning the transactions with the
+// details held in E5 and the checks that failed. Checking stops at the first failure unless every failure has been
+// asked for. The error is only returned when the checks cannot be made.
+func checkTransactions(companyNumber string, txs []models.TransactionItem, scheme *config.PenaltyScheme, all bool) ([]models.TransactionItem, []error, error) {
+	ruleSet, err := loadSchemeRuleSet(scheme)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// rule for first release, a company must only have one outstanding penalty that they can pay for
-	payablePenaltyCount := 0
+	response, _, err := service.GetPenalties(companyNumber, scheme)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, err
+	}
 
 	// create and cache a map of the transaction to make it easier to lookup each one
 	itemMap := map[string]models.TransactionListItem{}
 	for _, tx := range response.Items {
 		itemMap[tx.ID] = tx
-		if isPenaltyTransaction(tx) {
-			payablePenaltyCount++
-		}
 	}
 
 	var validTxs []models.TransactionItem
 
-	// for the first release, the company must only have one outstanding penalty
-	if payablePenaltyCount > 1 {
-		log.Info("company has more than one outstanding penalty", log.Data{
-			"company_number": companyNumber,
-			"penalty_count":  payablePenaltyCount,
-		})
-		return validTxs, ErrMultiplePenalties
+	failures := ruleSet.evaluate(ruleInput{transactions: response.Items}, log.Data{"company_number": companyNumber}, true, all)
+	if len(failures) > 0 && !all {
+		return nil, failures, nil
 	}
 
+	requested := map[string]bool{}
 	for _, t := range txs {
 		val, ok := itemMap[t.TransactionID]
 		data := map[string]interface{}{
 			"transaction_ref": t.TransactionID,
 			"company_number":  companyNumber,
 		}
+
+		// transactions are stored against their id so a transaction requested twice would only be paid for once
+		if requested[t.TransactionID] {
+			log.Info("disallowing paying for the same transaction more than once", data)
+			failures = append(failures, &TransactionError{TransactionID: t.TransactionID, err: ErrDuplicateTransaction})
+			if !all {
+				return nil, failures, nil
+			}
+			continue
+		}
+		requested[t.TransactionID] = true
+
 		if !ok {
 			log.Info("disallowing paying for a transaction that does not exist in E5", data)
-			return nil, ErrTransactionDoesNotExist
+			failures = append(failures, &TransactionError{TransactionID: t.TransactionID, err: ErrTransactionDoesNotExist})
+			if !all {
+				return nil, failures, nil
+			}
+			continue
 		}
 
-		items, err2, done := checkVal(val, data, t)
-		if done {
-			return items, err2
+		ruleFailures := ruleSet.evaluate(ruleInput{transaction: val, amount: t.Amount, transactions: response.Items}, data, false, all)
+		failures = append(failures, ruleFailures...)
+		if len(ruleFailures) > 0 {
+			if !all {
+				return nil, failures, nil
+			}
+			continue
 		}
 
 		validTx := models.TransactionItem{
@@ -78,39 +130,39 @@ func TransactionsArePayable(companyNumber string, txs []models.TransactionItem)
 		validTxs = append(validTxs, validTx)
 	}
 
-	return validTxs, nil
+	return validTxs, failures, nil
 }
 
-func checkVal(val models.TransactionListItem,
-	data map[string]interface{},
-	t models.TransactionItem) ([]models.TransactionItem, error, bool) {
-	if val.IsPartPaid() {
-		log.Info("the penalty that is trying to be paid is already part paid", data)
-		return nil, ErrTransactionIsPartPaid, true
+// TransactionIsPayable verifies that the transaction could be paid off in full online, given the rest of the company's
+// transactions. The error returned is the first of the scheme's payability rules that the transaction fails.
+func TransactionIsPayable(companyNumber string, tx models.TransactionListItem, transactions []models.TransactionListItem, scheme *config.PenaltyScheme) error {
+	ruleSet, err := loadSchemeRuleSet(scheme)
+	if err != nil {
+		return err
 	}
 
-	if val.IsPaid {
-		log.Info("disallowing paying for a transaction that is already paid", data)
-		return nil, ErrTransactionIsPaid, true
+	data := map[string]interface{}{
+		"transaction_ref": tx.ID,
+		"company_number":  companyNumber,
 	}
 
-	if val.Type != "penalty" {
-		log.Info("disallowing paying for a transaction that is not a penalty", data)
-		return nil, ErrTransactionNotPayable, true
+	in := ruleInput{transaction: tx, amount: tx.Outstanding, transactions: transactions}
+	err = ruleSet.evaluateTransactionRules(in, data)
+	if err != nil {
+		return err
 	}
 
-	if val.Outstanding != t.Amount {
-		data["attempted_amount"] = fmt.Sprintf("%f", t.Amount)
-		data["outstanding_amount"] = fmt.Sprintf("%f", val.Outstanding)
-		log.Info("disallowing paying for transaction as attempting to pay off partial balance", data)
-		return nil, ErrTransactionAmountMismatch, true
-	}
+	return ruleSet.evaluateCompanyRules(in, data)
+}
 
-	if val.IsDCA {
-		log.Info("the transaction that is trying to be paid is with a debt collecting agency", data)
-		return nil, ErrTransactionDCA, true
+func countPenaltyTransactions(txs []models.TransactionListItem) int {
+	count := 0
+	for _, tx := range txs {
+		if isPenaltyTransaction(tx) {
+			count++
+		}
 	}
-	return nil, nil, false
+	return count
 }
 
 func isPenaltyTransaction(tx models.TransactionListItem) bool {
@@ -1,8 +1,11 @@
 package validators
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/companieshouse/lfp-pay-api-core/models"
@@ -94,6 +97,8 @@ const multiplePenalties = `
 
 func TestUnitPayableTransactions(t *testing.T) {
 	os.Chdir("..")
+	schemes, _ := config.LoadPenaltySchemes(config.PenaltySchemesFile)
+	scheme := schemes[0]
 	cfg, _ := config.Get()
 	cfg.E5APIURL = "https://e5"
 	cfg.E5Username = "SYSTEM"
@@ -111,7 +116,7 @@ func TestUnitPayableTransactions(t *testing.T) {
 			models.TransactionItem{TransactionID: "123"},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(validTxs, ShouldBeNil)
 		So(err, ShouldBeError, ErrTransactionDoesNotExist)
@@ -128,7 +133,7 @@ func TestUnitPayableTransactions(t *testing.T) {
 			models.TransactionItem{TransactionID: "00378420"},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(validTxs, ShouldBeNil)
 		So(err, ShouldBeError, ErrTransactionNotPayable)
@@ -145,7 +150,7 @@ func TestUnitPayableTransactions(t *testing.T) {
 			models.TransactionItem{TransactionID: "00378420"},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(validTxs, ShouldBeNil)
 		So(err, ShouldBeError, ErrTransactionIsPaid)
@@ -162,7 +167,7 @@ func TestUnitPayableTransactions(t *testing.T) {
 			models.TransactionItem{TransactionID: "00378420", Amount: 150},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(err, ShouldBeNil)
 		So(validTxs[0].MadeUpDate, ShouldEqual, "2017-02-28")
@@ -183,7 +188,7 @@ func TestUnitPayableTransactions(t *testing.T) {
 			models.TransactionItem{TransactionID: "00378420", Amount: 100},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(validTxs, ShouldBeNil)
 		So(err, ShouldBeError, ErrTransactionAmountMismatch)
@@ -199,12 +204,54 @@ func TestUnitPayableTransactions(t *testing.T) {
 			{TransactionID: "00378420", Amount: 100},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(validTxs, ShouldBeNil)
 		So(err, ShouldBeError, ErrMultiplePenalties)
 	})
 
+	Convey("multiple penalties can be paid for when the rule is switched off", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		scheme := schemeWithRules(t, scheme, multiplePenaltiesRules)
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, multiplePenalties))
+
+		txs := []models.TransactionItem{
+			{TransactionID: "00482774", Amount: 150},
+			{TransactionID: "00556352", Amount: 750},
+		}
+
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
+
+		So(err, ShouldBeNil)
+		So(validTxs, ShouldHaveLength, 2)
+		So(validTxs[1].MadeUpDate, ShouldEqual, "2018-06-30")
+	})
+
+	Convey("error when the same transaction is requested twice", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		scheme := schemeWithRules(t, scheme, multiplePenaltiesRules)
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, multiplePenalties))
+
+		txs := []models.TransactionItem{
+			{TransactionID: "00482774", Amount: 150},
+			{TransactionID: "00482774", Amount: 150},
+		}
+
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
+
+		So(validTxs, ShouldBeNil)
+		So(err, ShouldBeError, ErrDuplicateTransaction)
+		So(errors.Is(err, ErrDuplicateTransaction), ShouldBeTrue)
+
+		var txErr *TransactionError
+		So(errors.As(err, &txErr), ShouldBeTrue)
+		So(txErr.TransactionID, ShouldEqual, "00482774")
+	})
+
 	Convey("error is returned if transaction is in DCA status", t, func() {
 		httpmock.Activate()
 		defer httpmock.DeactivateAndReset()
@@ -216,7 +263,7 @@ func TestUnitPayableTransactions(t *testing.T) {
 			{TransactionID: "00378420", Amount: 150},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(validTxs, ShouldBeNil)
 		So(err, ShouldBeError, ErrTransactionDCA)
@@ -233,9 +280,168 @@ func TestUnitPayableTransactions(t *testing.T) {
 			{TransactionID: "00378420", Amount: 50},
 		}
 
-		validTxs, err := TransactionsArePayable("10000024", txs)
+		validTxs, err := TransactionsArePayable("10000024", txs, scheme)
 
 		So(validTxs, ShouldBeNil)
 		So(err, ShouldBeError, ErrTransactionIsPartPaid)
 	})
+
+	Convey("part payments", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		scheme := schemeWithRules(t, scheme, partPaymentRules)
+
+		Convey("part of the outstanding amount can be paid when allowed", func() {
+			e5Response := createE5Response("1", false, false, 150)
+			httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+			validTxs, err := TransactionsArePayable("10000024", []models.TransactionItem{{TransactionID: "00378420", Amount: 50}}, scheme)
+
+			So(err, ShouldBeNil)
+			So(validTxs[0].Amount, ShouldEqual, 50)
+		})
+
+		Convey("a further instalment can be paid off a part paid penalty", func() {
+			e5Response := createE5Response("1", false, false, 100)
+			httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+			validTxs, err := TransactionsArePayable("10000024", []models.TransactionItem{{TransactionID: "00378420", Amount: 100}}, scheme)
+
+			So(err, ShouldBeNil)
+			So(validTxs[0].Amount, ShouldEqual, 100)
+		})
+
+		Convey("error when paying less than the minimum", func() {
+			e5Response := createE5Response("1", false, false, 150)
+			httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+			validTxs, err := TransactionsArePayable("10000024", []models.TransactionItem{{TransactionID: "00378420", Amount: 10}}, scheme)
+
+			So(validTxs, ShouldBeNil)
+			So(err, ShouldBeError, ErrPartPaymentOutsideLimits)
+		})
+
+		Convey("the final instalment can be less than the minimum", func() {
+			e5Response := createE5Response("1", false, false, 10)
+			httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+			validTxs, err := TransactionsArePayable("10000024", []models.TransactionItem{{TransactionID: "00378420", Amount: 10}}, scheme)
+
+			So(err, ShouldBeNil)
+			So(validTxs, ShouldHaveLength, 1)
+		})
+
+		Convey("error when paying more than the outstanding amount", func() {
+			e5Response := createE5Response("1", false, false, 150)
+			httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, e5Response))
+
+			validTxs, err := TransactionsArePayable("10000024", []models.TransactionItem{{TransactionID: "00378420", Amount: 200}}, scheme)
+
+			So(validTxs, ShouldBeNil)
+			So(err, ShouldBeError, ErrTransactionAmountMismatch)
+		})
+	})
+}
+
+// multiplePenaltiesRules are the test rules with the single-penalty rule switched off
+var multiplePenaltiesRules = strings.Replace(testRules, "id: single-penalty\n    enabled: true", "id: single-penalty\n    enabled: false", 1)
+
+// partPaymentRules are the test rules allowing part payments of at least 25
+var partPaymentRules = strings.NewReplacer(
+	"or less when part payments are allowed\n", "or less when part payments are allowed\n    parameters:\n      allow_part_payments: true\n",
+	"limits for part paying the transaction\n", "limits for part paying the transaction\n    parameters:\n      minimum_amount: 25\n",
+).Replace(testRules)
+
+// schemeWithRules returns a copy of the penalty scheme that uses the payability rules
+func schemeWithRules(t *testing.T, scheme *config.PenaltyScheme, rules string) *config.PenaltyScheme {
+	path := writeRulesFile(t, rules)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(path)) })
+
+	withRules := *scheme
+	withRules.PayabilityRulesFile = path
+	return &withRules
+}
+
+func TestUnitCheckTransactionsArePayable(t *testing.T) {
+	schemes, _ := config.LoadPenaltySchemes(config.PenaltySchemesFile)
+	scheme := schemes[0]
+	cfg, _ := config.Get()
+	cfg.E5APIURL = "https://e5"
+	cfg.E5Username = "SYSTEM"
+
+	url := "https://e5/arTransactions/10000024?ADV_userName=SYSTEM&companyCode=LP&fromDate=1990-01-01"
+
+	Convey("every failing check is reported", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, multiplePenalties))
+
+		txs := []models.TransactionItem{
+			{TransactionID: "00482774", Amount: 150},
+			{TransactionID: "00482774", Amount: 150},
+			{TransactionID: "123", Amount: 150},
+			{TransactionID: "00556352", Amount: 100},
+		}
+
+		validTxs, failures, err := CheckTransactionsArePayable("10000024", txs, scheme)
+
+		So(err, ShouldBeNil)
+		So(validTxs, ShouldHaveLength, 1)
+		So(validTxs[0].TransactionID, ShouldEqual, "00482774")
+		So(failures, ShouldResemble, []CheckFailure{
+			{Check: "single-penalty", ErrorCode: "MULTIPLE_PENALTIES", Message: "the company has more than one outstanding penalty"},
+			{Check: CheckUniqueTransaction, ErrorCode: "DUPLICATE_TRANSACTION", Message: ErrDuplicateTransaction.Error(), TransactionID: "00482774"},
+			{Check: CheckTransactionExists, ErrorCode: "TRANSACTION_NOT_FOUND", Message: ErrTransactionDoesNotExist.Error(), TransactionID: "123"},
+			{Check: "full-amount", ErrorCode: "AMOUNT_MISMATCH", Message: "the amount must be the outstanding amount of the transaction, or less when part payments are allowed", TransactionID: "00556352"},
+		})
+	})
+
+	Convey("no failures when the transactions are payable", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, createE5Response("1", false, false, 150)))
+
+		validTxs, failures, err := CheckTransactionsArePayable("10000024", []models.TransactionItem{{TransactionID: "00378420", Amount: 150}}, scheme)
+
+		So(err, ShouldBeNil)
+		So(validTxs, ShouldHaveLength, 1)
+		So(failures, ShouldBeEmpty)
+	})
+
+	Convey("error when the transactions cannot be checked", t, func() {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(500, ""))
+
+		_, _, err := CheckTransactionsArePayable("10000024", []models.TransactionItem{{TransactionID: "00378420", Amount: 150}}, scheme)
+
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestUnitTransactionIsPayable(t *testing.T) {
+	schemes, _ := config.LoadPenaltySchemes(config.PenaltySchemesFile)
+	scheme := schemes[0]
+	penalty := models.TransactionListItem{ID: "00378420", Type: "penalty", OriginalAmount: 150, Outstanding: 150}
+
+	Convey("an outstanding penalty is payable", t, func() {
+		err := TransactionIsPayable("10000024", penalty, []models.TransactionListItem{penalty}, scheme)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("a penalty with a debt collecting agency is not payable", t, func() {
+		dca := penalty
+		dca.IsDCA = true
+		err := TransactionIsPayable("10000024", dca, []models.TransactionListItem{dca}, scheme)
+		So(err, ShouldBeError, ErrTransactionDCA)
+	})
+
+	Convey("a penalty is not payable when the company has another outstanding penalty", t, func() {
+		other := models.TransactionListItem{ID: "00378421", Type: "penalty", OriginalAmount: 150, Outstanding: 150}
+		err := TransactionIsPayable("10000024", penalty, []models.TransactionListItem{penalty, other}, scheme)
+		So(err, ShouldBeError, ErrMultiplePenalties)
+	})
 }